@@ -0,0 +1,41 @@
+package _generated
+
+import (
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+//go:generate msgp -clone
+
+type CloneInner struct {
+	Name  string
+	Data  []byte
+	Attrs map[string]string
+}
+
+type CloneBytes []byte
+
+type CloneOuter struct {
+	Inner      CloneInner
+	InnerPtr   *CloneInner
+	Inners     []CloneInner
+	InnerPtrs  []*CloneInner
+	InnerMap   map[string]*CloneInner
+	Nested     map[string][]string
+	Array      [2][]int
+	Anonymous  struct{ Values []float64 }
+	Intf       interface{}
+	Raw        msgp.Raw
+	Num        msgp.Number
+	Bytes      CloneBytes
+	StrPtr     *string
+	When       time.Time
+	Tags       []string `msg:"tags,omitempty"`
+	unexported []int
+
+	// X is generated without -clone, so
+	// these are copied by assignment
+	Other    X
+	OtherPtr *X
+}
@@ -0,0 +1,80 @@
+package _generated
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func cloneOuterValue() *CloneOuter {
+	inner := func(name string) CloneInner {
+		return CloneInner{Name: name, Data: []byte(name), Attrs: map[string]string{"k": name}}
+	}
+	in1, in2 := inner("ptr"), inner("mapped")
+	str := "string"
+	o := &CloneOuter{
+		Inner:     inner("inner"),
+		InnerPtr:  &in1,
+		Inners:    []CloneInner{inner("a"), inner("b")},
+		InnerPtrs: []*CloneInner{&in2, nil},
+		InnerMap:  map[string]*CloneInner{"m": &in2, "nil": nil},
+		Nested:    map[string][]string{"x": {"y", "z"}},
+		Array:     [2][]int{{1, 2}, {3}},
+		Intf:      map[string]interface{}{"list": []interface{}{[]byte("b")}},
+		Raw:       msgp.Raw(msgp.AppendString(nil, "raw")),
+		Bytes:     CloneBytes("bytes"),
+		StrPtr:    &str,
+		When:      time.Unix(1000, 0),
+		Tags:      []string{"t"},
+		Other:     X{Matrix: [][]int32{{1}}},
+		OtherPtr:  &X{More: Block{1}},
+	}
+	o.Anonymous.Values = []float64{1.5}
+	o.Num.AsInt(4)
+	return o
+}
+
+func TestCloneMsg(t *testing.T) {
+	orig := cloneOuterValue()
+	c := orig.CloneMsg()
+	if !reflect.DeepEqual(orig, c) {
+		t.Fatalf("clone is not equal:\norig:  %#v\nclone: %#v", orig, c)
+	}
+
+	// mutate every reference in the clone
+	c.Inner.Data[0] = 'X'
+	c.Inner.Attrs["k"] = "X"
+	c.InnerPtr.Name = "X"
+	c.InnerPtr.Data[0] = 'X'
+	c.Inners[0].Data[0] = 'X'
+	c.InnerPtrs[0].Attrs["k"] = "X"
+	c.InnerMap["m"].Name = "X"
+	c.InnerMap["new"] = nil
+	c.Nested["x"][0] = "X"
+	c.Array[0][0] = 100
+	c.Anonymous.Values[0] = 100
+	c.Intf.(map[string]interface{})["list"].([]interface{})[0].([]byte)[0] = 'X'
+	c.Raw[1] = 'X'
+	c.Bytes[0] = 'X'
+	*c.StrPtr = "X"
+	c.Tags[0] = "X"
+	c.Other.More[0] = 'X'
+	c.OtherPtr.More[0] = 'X'
+
+	if want := cloneOuterValue(); !reflect.DeepEqual(orig, want) {
+		t.Errorf("mutating the clone changed the original:\ngot:  %#v\nwant: %#v", orig, want)
+	}
+
+	if (*CloneOuter)(nil).CloneMsg() != nil {
+		t.Error("clone of a nil pointer should be nil")
+	}
+	var empty CloneOuter
+	if ec := empty.CloneMsg(); !reflect.DeepEqual(&empty, ec) {
+		t.Errorf("clone of the zero value: %#v", ec)
+	}
+	if b := (&CloneBytes{1, 2}).CloneMsg(); !reflect.DeepEqual(*b, CloneBytes{1, 2}) {
+		t.Errorf("clone of a named type: %v", b)
+	}
+}
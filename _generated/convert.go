@@ -1,6 +1,10 @@
 package _generated
 
-import "errors"
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
 
 //go:generate msgp
 
@@ -81,3 +85,69 @@ type ConvertErrVal string
 type ConvertErr struct {
 	Err ConvertErrVal
 }
+
+//msgp:shim ConvertPointVal as:ConvertPoint using:fromConvertPointVal/toConvertPointVal mode:convert
+//msgp:ignore ConvertPointVal
+
+var errConvertPoint = errors.New("error: malformed point")
+
+// ConvertPointVal is a point in "x,y" form,
+// encoded as a ConvertPoint struct
+type ConvertPointVal string
+
+type ConvertPoint struct {
+	X int
+	Y int
+}
+
+func fromConvertPointVal(v ConvertPointVal) (ConvertPoint, error) {
+	var p ConvertPoint
+	if v == "" {
+		return p, nil
+	}
+	parts := strings.Split(string(v), ",")
+	if len(parts) != 2 {
+		return p, errConvertPoint
+	}
+	var err error
+	if p.X, err = strconv.Atoi(parts[0]); err != nil {
+		return p, errConvertPoint
+	}
+	if p.Y, err = strconv.Atoi(parts[1]); err != nil {
+		return p, errConvertPoint
+	}
+	return p, nil
+}
+
+func toConvertPointVal(p ConvertPoint) (ConvertPointVal, error) {
+	if p.X < 0 || p.Y < 0 {
+		return "", errConvertPoint
+	}
+	return ConvertPointVal(strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y)), nil
+}
+
+//msgp:shim ConvertListVal as:[]string using:fromConvertListVal/toConvertListVal
+//msgp:ignore ConvertListVal
+
+// ConvertListVal is a comma-separated list,
+// encoded as an array of strings
+type ConvertListVal string
+
+func fromConvertListVal(v ConvertListVal) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(string(v), ",")
+}
+
+func toConvertListVal(s []string) ConvertListVal {
+	return ConvertListVal(strings.Join(s, ","))
+}
+
+type ConvertShims struct {
+	Point    ConvertPointVal
+	PointPtr *ConvertPointVal
+	Points   []ConvertPointVal
+	List     ConvertListVal
+	Lists    map[string]ConvertListVal
+}
@@ -2,6 +2,8 @@ package _generated
 
 import (
 	"bytes"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/tinylib/msgp/msgp"
@@ -58,3 +60,96 @@ func TestConvertToMarshalError(t *testing.T) {
 		t.Fatalf("expected conversion error, found %v", err.Error())
 	}
 }
+
+func convertShimsValue() ConvertShims {
+	ptr := ConvertPointVal("5,6")
+	return ConvertShims{
+		Point:    "1,2",
+		PointPtr: &ptr,
+		Points:   []ConvertPointVal{"3,4", "0,0"},
+		List:     "a,b,c",
+		Lists:    map[string]ConvertListVal{"one": "x", "two": "y,z"},
+	}
+}
+
+func checkConvertShims(t *testing.T, in, out *ConvertShims) {
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("shimmed values are not equal:\nin:  %#v\nout: %#v", in, out)
+	}
+}
+
+func TestConvertShimsRoundTrip(t *testing.T) {
+	in := convertShimsValue()
+	var buf bytes.Buffer
+	w := msgp.NewWriter(&buf)
+	if err := in.EncodeMsg(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+
+	// the shimmed fields are encoded in their representation
+	var raw map[string]interface{}
+	raw, _, err := msgp.ReadMapStrIntfBytes(buf.Bytes(), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, ok := raw["Point"].(map[string]interface{}); !ok || pt["X"] != int64(1) || pt["Y"] != int64(2) {
+		t.Errorf("Point encoded as %#v", raw["Point"])
+	}
+	if lst, ok := raw["List"].([]interface{}); !ok || len(lst) != 3 || lst[2] != "c" {
+		t.Errorf("List encoded as %#v", raw["List"])
+	}
+
+	var out ConvertShims
+	if err := out.DecodeMsg(msgp.NewReader(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	checkConvertShims(t, &in, &out)
+
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) > in.Msgsize() {
+		t.Errorf("Msgsize() = %d; encoded %d bytes", in.Msgsize(), len(b))
+	}
+	out = ConvertShims{}
+	if _, err := out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	checkConvertShims(t, &in, &out)
+}
+
+func TestConvertShimsEncodeError(t *testing.T) {
+	in := convertShimsValue()
+	in.Points[1] = "not a point"
+	if _, err := in.MarshalMsg(nil); msgp.Cause(err) != errConvertPoint {
+		t.Fatalf("expected conversion error, found %v", err)
+	}
+	var buf bytes.Buffer
+	if err := in.EncodeMsg(msgp.NewWriter(&buf)); msgp.Cause(err) != errConvertPoint {
+		t.Fatalf("expected conversion error, found %v", err)
+	}
+}
+
+func TestConvertShimsDecodeError(t *testing.T) {
+	// negative coordinates are rejected on the way in
+	b, err := (&ConvertPoint{X: -1, Y: 2}).MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = append(msgp.AppendString(msgp.AppendMapHeader(nil, 1), "Point"), b...)
+
+	var out ConvertShims
+	_, err = out.UnmarshalMsg(b)
+	if msgp.Cause(err) != errConvertPoint {
+		t.Fatalf("expected conversion error, found %v", err)
+	}
+	if !strings.Contains(err.Error(), "Point") {
+		t.Errorf("error %q should mention the field", err)
+	}
+	err = out.DecodeMsg(msgp.NewReader(bytes.NewReader(b)))
+	if msgp.Cause(err) != errConvertPoint {
+		t.Fatalf("expected conversion error, found %v", err)
+	}
+}
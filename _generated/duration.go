@@ -0,0 +1,13 @@
+package _generated
+
+import "time"
+
+//go:generate msgp
+
+type Durations struct {
+	Timeout time.Duration            `msg:"timeout"`
+	Ptr     *time.Duration           `msg:"ptr"`
+	Slice   []time.Duration          `msg:"slice"`
+	Map     map[string]time.Duration `msg:"map"`
+	Omitted time.Duration            `msg:"omitted,omitempty"`
+}
@@ -0,0 +1,63 @@
+package _generated
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestDurations(t *testing.T) {
+	d := 90 * time.Second
+	in := Durations{
+		Timeout: 1500 * time.Millisecond,
+		Ptr:     &d,
+		Slice:   []time.Duration{time.Nanosecond, -time.Hour},
+		Map:     map[string]time.Duration{"a": time.Minute},
+	}
+
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// durations are written as nanoseconds
+	raw, _, err := msgp.ReadMapStrIntfBytes(b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw["timeout"] != int64(1500*time.Millisecond) || raw["ptr"] != int64(d) {
+		t.Errorf("unexpected encoding: %v", raw)
+	}
+	if _, ok := raw["omitted"]; ok {
+		t.Error("zero duration wasn't omitted")
+	}
+
+	var out Durations
+	if _, err = out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, in)
+	}
+
+	var buf bytes.Buffer
+	if err = msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Error("EncodeMsg and MarshalMsg disagree")
+	}
+	out = Durations{}
+	if err = msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("DecodeMsg: got %+v; want %+v", out, in)
+	}
+	if s := in.Msgsize(); s < len(b) {
+		t.Errorf("Msgsize %d is less than the encoded size %d", s, len(b))
+	}
+}
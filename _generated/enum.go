@@ -0,0 +1,34 @@
+package _generated
+
+//go:generate msgp -tests=false
+
+//msgp:enum EnumColor
+//msgp:enum EnumSize EnumSmall EnumLarge
+//msgp:enum EnumName "alpha" "beta"
+
+type EnumColor uint8
+
+const (
+	EnumRed EnumColor = iota + 1
+	EnumGreen
+	EnumBlue
+	EnumDefault = EnumRed // duplicate values are allowed
+)
+
+type EnumSize int
+
+const (
+	EnumSmall  EnumSize = 1
+	EnumMedium EnumSize = 2 // declared, but not allowed
+	EnumLarge           = EnumSize(3)
+)
+
+type EnumName string
+
+type EnumHolder struct {
+	Color  EnumColor
+	Colors []EnumColor
+	Ptr    *EnumColor
+	Size   EnumSize
+	Name   EnumName
+}
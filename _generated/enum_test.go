@@ -0,0 +1,66 @@
+package _generated
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestEnumValid(t *testing.T) {
+	blue := EnumBlue
+	in := EnumHolder{Color: EnumGreen, Colors: []EnumColor{EnumRed, EnumBlue}, Ptr: &blue, Size: EnumLarge, Name: "beta"}
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out EnumHolder
+	if _, err := out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.DecodeMsg(msgp.NewReader(bytes.NewReader(b))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnumInvalid(t *testing.T) {
+	red := EnumRed
+	bad := EnumColor(9)
+	for _, tc := range []struct {
+		in   EnumHolder
+		path string
+	}{
+		{EnumHolder{Color: 0, Ptr: &red, Size: EnumSmall, Name: "alpha"}, "Color"},
+		{EnumHolder{Color: EnumRed, Colors: []EnumColor{EnumRed, 4}, Size: EnumSmall, Name: "alpha"}, "Colors/1"},
+		{EnumHolder{Color: EnumRed, Ptr: &bad, Size: EnumSmall, Name: "alpha"}, "Ptr"},
+		{EnumHolder{Color: EnumRed, Size: EnumMedium, Name: "alpha"}, "Size"},
+		{EnumHolder{Color: EnumRed, Size: EnumSmall, Name: "gamma"}, "Name"},
+	} {
+		b, err := tc.in.MarshalMsg(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out EnumHolder
+		_, uerr := out.UnmarshalMsg(b)
+		derr := out.DecodeMsg(msgp.NewReader(bytes.NewReader(b)))
+		for _, err := range []error{uerr, derr} {
+			e, ok := msgp.Cause(err).(msgp.EnumError)
+			if !ok {
+				t.Errorf("%s: expected an EnumError; got %v", tc.path, err)
+				continue
+			}
+			if !e.Resumable() {
+				t.Errorf("%s: EnumError should be resumable", tc.path)
+			}
+			if want := " at " + tc.path; !bytes.HasSuffix([]byte(err.Error()), []byte(want)) {
+				t.Errorf("%s: error %q should end with %q", tc.path, err, want)
+			}
+		}
+	}
+
+	var c EnumColor
+	b := msgp.AppendUint8(nil, 200)
+	if _, err := c.UnmarshalMsg(b); err == nil {
+		t.Error("expected an error decoding an invalid EnumColor")
+	}
+}
@@ -0,0 +1,31 @@
+package _generated
+
+//go:generate msgp
+
+// IsEmptyID is an array, which omitempty
+// can't compare to its zero value
+type IsEmptyID [4]byte
+
+func (id IsEmptyID) MsgIsEmpty() bool { return id == IsEmptyID{} }
+
+// IsEmptyRange is empty when Lo and Hi are equal,
+// not only when both are zero
+type IsEmptyRange struct {
+	Lo int
+	Hi int
+}
+
+func (r *IsEmptyRange) MsgIsEmpty() bool { return r.Lo == r.Hi }
+
+// IsEmptyLevel uses "unset" as its empty value
+type IsEmptyLevel string
+
+func (l IsEmptyLevel) MsgIsEmpty() bool { return l == "" || l == "unset" }
+
+type IsEmptyHolder struct {
+	ID       IsEmptyID     `msg:"id,omitempty"`
+	Range    IsEmptyRange  `msg:"range,omitempty"`
+	Level    IsEmptyLevel  `msg:"level,omitempty"`
+	Always   IsEmptyLevel  `msg:"always"`
+	RangePtr *IsEmptyRange `msg:"rangeptr,omitempty"`
+}
@@ -0,0 +1,34 @@
+package _generated
+
+import (
+	"testing"
+)
+
+func TestIsEmptyHook(t *testing.T) {
+	for _, tc := range []struct {
+		in   IsEmptyHolder
+		want string
+	}{
+		{IsEmptyHolder{}, `{"always":""}`},
+		{IsEmptyHolder{Range: IsEmptyRange{Lo: 3, Hi: 3}, Level: "unset", Always: "unset"}, `{"always":"unset"}`},
+		{IsEmptyHolder{ID: IsEmptyID{1}}, `{"id":"AQAAAA==","always":""}`},
+		{IsEmptyHolder{Range: IsEmptyRange{Lo: 0, Hi: 1}}, `{"range":{"Lo":0,"Hi":1},"always":""}`},
+		{IsEmptyHolder{Level: "debug", RangePtr: &IsEmptyRange{Lo: 2, Hi: 2}}, `{"level":"debug","always":""}`},
+		{IsEmptyHolder{RangePtr: &IsEmptyRange{Lo: 1, Hi: 2}}, `{"always":"","rangeptr":{"Lo":1,"Hi":2}}`},
+	} {
+		if got := mustEncodeToJSON(&tc.in); got != tc.want {
+			t.Errorf("EncodeMsg: got %s, want %s", got, tc.want)
+		}
+		b, err := tc.in.MarshalMsg(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out IsEmptyHolder
+		if _, err := out.UnmarshalMsg(b); err != nil {
+			t.Fatal(err)
+		}
+		if got := mustEncodeToJSON(&out); got != tc.want {
+			t.Errorf("MarshalMsg: got %s, want %s", got, tc.want)
+		}
+	}
+}
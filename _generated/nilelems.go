@@ -0,0 +1,18 @@
+package _generated
+
+//go:generate msgp
+
+type NilElem struct {
+	Name string
+	Tags []string
+}
+
+type NilElems struct {
+	Default   []*NilElem
+	OmitSlice []*NilElem          `msg:"omitslice,omitnil"`
+	OmitMap   map[string]*NilElem `msg:"omitmap,omitnil"`
+	ZeroSlice []*NilElem          `msg:"zeroslice,zeronil"`
+	ZeroMap   map[string]*NilElem `msg:"zeromap,zeronil"`
+	ZeroInts  []*int              `msg:"zeroints,zeronil"`
+	OmitPtr   *[]*NilElem         `msg:"omitptr,omitnil"`
+}
@@ -0,0 +1,57 @@
+package _generated
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestNilElems(t *testing.T) {
+	a := &NilElem{Name: "a"}
+	one := 1
+	in := NilElems{
+		Default:   []*NilElem{a, nil},
+		OmitSlice: []*NilElem{nil, a, nil},
+		OmitMap:   map[string]*NilElem{"a": a, "nil": nil},
+		ZeroSlice: []*NilElem{nil, a},
+		ZeroMap:   map[string]*NilElem{"nil": nil},
+		ZeroInts:  []*int{&one, nil},
+		OmitPtr:   &[]*NilElem{nil, nil},
+	}
+	want := NilElems{
+		Default:   []*NilElem{a, nil},
+		OmitSlice: []*NilElem{a},
+		OmitMap:   map[string]*NilElem{"a": a},
+		ZeroSlice: []*NilElem{{}, a},
+		ZeroMap:   map[string]*NilElem{"nil": {}},
+		ZeroInts:  []*int{&one, new(int)},
+		OmitPtr:   new([]*NilElem),
+	}
+
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) > in.Msgsize() {
+		t.Errorf("Msgsize() = %d; encoded %d bytes", in.Msgsize(), len(b))
+	}
+	var buf bytes.Buffer
+	w := msgp.NewWriter(&buf)
+	if err := in.EncodeMsg(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if !bytes.Equal(b, buf.Bytes()) {
+		t.Error("EncodeMsg and MarshalMsg disagree")
+	}
+
+	var out NilElems
+	if _, err := out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("got  %#v\nwant %#v", out, want)
+	}
+}
@@ -0,0 +1,17 @@
+package _generated
+
+import "time"
+
+//go:generate msgp
+
+type TimeFormats struct {
+	Ext       time.Time            `msg:"ext"`
+	Unix      time.Time            `msg:"unix,unix"`
+	UnixMilli time.Time            `msg:"unixmilli,unixmilli"`
+	UnixNano  time.Time            `msg:"unixnano,unixnano"`
+	RFC3339   time.Time            `msg:"rfc3339,rfc3339"`
+	Ptr       *time.Time           `msg:"ptr,unixmilli"`
+	Slice     []time.Time          `msg:"slice,unix"`
+	Map       map[string]time.Time `msg:"map,rfc3339"`
+	Omitted   time.Time            `msg:"omitted,unixnano,omitempty"`
+}
@@ -0,0 +1,106 @@
+package _generated
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestTimeFormats(t *testing.T) {
+	now := time.Date(2018, 7, 4, 12, 30, 15, 123456789, time.UTC)
+	in := TimeFormats{
+		Ext:       now,
+		Unix:      now,
+		UnixMilli: now,
+		UnixNano:  now,
+		RFC3339:   now,
+		Ptr:       &now,
+		Slice:     []time.Time{now, now.Add(time.Hour)},
+		Map:       map[string]time.Time{"a": now},
+	}
+
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// check the wire representation of each field
+	raw, _, err := msgp.ReadMapStrIntfBytes(b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"unix":      now.Unix(),
+		"unixmilli": now.UnixNano() / 1e6,
+		"unixnano":  now.UnixNano(),
+		"rfc3339":   "2018-07-04T12:30:15.123456789Z",
+		"ptr":       now.UnixNano() / 1e6,
+	}
+	for k, v := range want {
+		if raw[k] != v {
+			t.Errorf("field %q: got %#v, want %#v", k, raw[k], v)
+		}
+	}
+	if _, ok := raw["ext"].(time.Time); !ok {
+		t.Errorf("field \"ext\": got %#v, want time.Time", raw["ext"])
+	}
+
+	check := func(out *TimeFormats) {
+		if !out.Ext.Equal(now) || !out.UnixNano.Equal(now) || !out.RFC3339.Equal(now) {
+			t.Errorf("lossless fields: got %v, %v, %v", out.Ext, out.UnixNano, out.RFC3339)
+		}
+		if !out.Unix.Equal(now.Truncate(time.Second)) {
+			t.Errorf("unix: got %v", out.Unix)
+		}
+		if !out.UnixMilli.Equal(now.Truncate(time.Millisecond)) || out.Ptr == nil || !out.Ptr.Equal(now.Truncate(time.Millisecond)) {
+			t.Errorf("unixmilli: got %v, %v", out.UnixMilli, out.Ptr)
+		}
+		if len(out.Slice) != 2 || !out.Slice[1].Equal(now.Add(time.Hour).Truncate(time.Second)) {
+			t.Errorf("slice: got %v", out.Slice)
+		}
+		if !out.Map["a"].Equal(now) {
+			t.Errorf("map: got %v", out.Map)
+		}
+		if !out.Omitted.IsZero() {
+			t.Errorf("omitted: got %v", out.Omitted)
+		}
+	}
+
+	var out TimeFormats
+	if _, err := out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	check(&out)
+
+	var buf bytes.Buffer
+	w := msgp.NewWriter(&buf)
+	if err := in.EncodeMsg(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Error("EncodeMsg and MarshalMsg disagree")
+	}
+	out = TimeFormats{}
+	if err := out.DecodeMsg(msgp.NewReader(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	check(&out)
+}
+
+func TestTimeFormatRFC3339Error(t *testing.T) {
+	b := msgp.AppendMapHeader(nil, 1)
+	b = msgp.AppendString(b, "rfc3339")
+	b = msgp.AppendString(b, "yesterday")
+	var out TimeFormats
+	if _, err := out.UnmarshalMsg(b); err == nil {
+		t.Error("expected an error for a malformed RFC 3339 time")
+	}
+
+	in := TimeFormats{RFC3339: time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if _, err := in.MarshalMsg(nil); err == nil {
+		t.Error("expected an error for a year outside of [0,9999]")
+	}
+}
@@ -0,0 +1,191 @@
+package gen
+
+import (
+	"io"
+)
+
+func clone(w io.Writer) *cloneGen {
+	return &cloneGen{
+		p: printer{w: w},
+	}
+}
+
+// cloneGen prints CloneMsg methods.
+//
+// The receiver is first replaced with a shallow
+// copy of itself, and then every element that
+// shares memory with the original (slices, maps,
+// pointers, etc.) is replaced in-place with a copy,
+// so that every element keeps the variable name it
+// has for the other generators. Elements that the
+// generator doesn't know how to copy (extensions,
+// shims, and types that don't get a CloneMsg method
+// of their own) are left as shallow copies, as are
+// the fields that aren't serialized.
+type cloneGen struct {
+	passes
+	p printer
+}
+
+func (c *cloneGen) Method() Method { return Clone }
+
+func (c *cloneGen) Execute(p Elem) error {
+	if !c.p.ok() {
+		return c.p.err
+	}
+	p = c.applyall(p)
+	if p == nil {
+		return nil
+	}
+	if !IsPrintable(p) {
+		return nil
+	}
+
+	c.p.comment("CloneMsg returns a copy of z (see msgp -clone for which fields share memory)")
+
+	rcv := methodReceiver(p)
+	c.p.printf("\nfunc (z %s) CloneMsg() %s {", rcv, rcv)
+	c.p.print("\nif z == nil {\nreturn nil\n}")
+	cp := randIdent()
+	c.p.printf("\n%s := *z\nz = &%s", cp, cp)
+	next(c, p)
+	c.p.print("\nreturn z\n}\n")
+	unsetReceiver(p)
+	return c.p.err
+}
+
+// needsClone returns whether or not
+// the element may share memory with
+// the value it was assigned from, and
+// can be copied
+func (c *cloneGen) needsClone(e Elem) bool {
+	switch e := e.(type) {
+	case *BaseElem:
+		switch e.Value {
+		case Bytes, Intf:
+			// shimmed types are copied by value
+			return e.ShimToBase == ""
+		case IDENT:
+			return c.hasCloneMsg(e)
+		default:
+			return false
+		}
+	case *Struct:
+		for i := range e.Fields {
+			if c.needsClone(e.Fields[i].FieldElem) {
+				return true
+			}
+		}
+		return false
+	case *Array:
+		return c.needsClone(e.Els)
+	default:
+		return true
+	}
+}
+
+// hasCloneMsg returns whether the type of b has
+// a CloneMsg method: the package's builtins do,
+// as do the types being generated, unless they
+// are ignored for this pass
+func (c *cloneGen) hasCloneMsg(b *BaseElem) bool {
+	if b.Value != IDENT || b.ShimToBase != "" {
+		return false
+	}
+	if _, ok := builtins[b.TypeName()]; ok {
+		return true
+	}
+	return b.Local && c.applyall(b) != nil
+}
+
+func (c *cloneGen) gStruct(s *Struct) {
+	if !c.p.ok() {
+		return
+	}
+	for i := range s.Fields {
+		if c.needsClone(s.Fields[i].FieldElem) {
+			next(c, s.Fields[i].FieldElem)
+		}
+	}
+}
+
+func (c *cloneGen) gPtr(p *Ptr) {
+	if !c.p.ok() {
+		return
+	}
+	vname := p.Varname()
+	c.p.printf("\nif %s != nil {", vname)
+	if be, ok := p.Value.(*BaseElem); ok && c.hasCloneMsg(be) {
+		c.p.printf("\n%s = %s.CloneMsg()", vname, vname)
+	} else {
+		cp := randIdent()
+		c.p.printf("\n%s := *%s\n%s = &%s", cp, vname, vname, cp)
+		if c.needsClone(p.Value) {
+			next(c, p.Value)
+		}
+	}
+	c.p.closeblock()
+}
+
+func (c *cloneGen) gSlice(s *Slice) {
+	if !c.p.ok() {
+		return
+	}
+	vname := s.Varname()
+	cp := randIdent()
+	c.p.printf("\nif %s != nil {", vname)
+	c.p.printf("\n%s := make(%s, len(%s))", cp, s.TypeName(), vname)
+	c.p.printf("\ncopy(%s, %s)\n%s = %s", cp, vname, vname, cp)
+	if c.needsClone(s.Els) {
+		c.p.printf("\nfor %s := range %s {", s.Index, vname)
+		next(c, s.Els)
+		c.p.closeblock()
+	}
+	c.p.closeblock()
+}
+
+func (c *cloneGen) gArray(a *Array) {
+	if !c.p.ok() {
+		return
+	}
+	// the array itself was copied with its parent
+	c.p.printf("\nfor %s := range %s {", a.Index, a.Varname())
+	next(c, a.Els)
+	c.p.closeblock()
+}
+
+func (c *cloneGen) gMap(m *Map) {
+	if !c.p.ok() {
+		return
+	}
+	vname := m.Varname()
+	cp := randIdent()
+	c.p.printf("\nif %s != nil {", vname)
+	c.p.printf("\n%s := make(%s, len(%s))", cp, m.TypeName(), vname)
+	c.p.printf("\nfor %s, %s := range %s {", m.Keyidx, m.Validx, vname)
+	if c.needsClone(m.Value) {
+		next(c, m.Value)
+	}
+	c.p.printf("\n%s[%s] = %s", cp, m.Keyidx, m.Validx)
+	c.p.closeblock()
+	c.p.printf("\n%s = %s", vname, cp)
+	c.p.closeblock()
+}
+
+func (c *cloneGen) gBase(b *BaseElem) {
+	if !c.p.ok() {
+		return
+	}
+	if !c.needsClone(b) {
+		return
+	}
+	vname := b.Varname()
+	switch b.Value {
+	case Bytes:
+		c.p.printf("\nif %[1]s != nil {\n%[1]s = append((%[1]s)[:0:0], %[1]s...)\n}", vname)
+	case Intf:
+		c.p.printf("\n%s = msgp.CloneIntf(%s)", vname, vname)
+	case IDENT:
+		c.p.printf("\n%s = *%s.CloneMsg()", vname, vname)
+	}
+}
@@ -133,16 +133,20 @@ func (d *decodeGen) gBase(b *BaseElem) {
 
 	// handle special cases
 	// for object type.
-	switch b.Value {
-	case Bytes:
+	switch {
+	case b.ShimElem != nil:
+		// decode the representation into 'tmp'
+		b.ShimElem.SetVarname(tmp)
+		next(d, b.ShimElem)
+	case b.Value == Bytes:
 		if b.Convert {
 			d.p.printf("\n%s, err = dc.ReadBytes([]byte(%s))", tmp, vname)
 		} else {
 			d.p.printf("\n%s, err = dc.ReadBytes(%s)", vname, vname)
 		}
-	case IDENT:
+	case b.Value == IDENT:
 		d.p.printf("\nerr = %s.DecodeMsg(dc)", vname)
-	case Ext:
+	case b.Value == Ext:
 		d.p.printf("\nerr = dc.ReadExtension(%s)", vname)
 	default:
 		if b.Convert {
@@ -151,7 +155,9 @@ func (d *decodeGen) gBase(b *BaseElem) {
 			d.p.printf("\n%s, err = dc.Read%s()", vname, bname)
 		}
 	}
-	d.p.wrapErrCheck(d.ctx.ArgsStr())
+	if b.ShimElem == nil {
+		d.p.wrapErrCheck(d.ctx.ArgsStr())
+	}
 
 	// close block for 'tmp'
 	if b.Convert {
@@ -162,6 +168,7 @@ func (d *decodeGen) gBase(b *BaseElem) {
 			d.p.wrapErrCheck(d.ctx.ArgsStr())
 		}
 	}
+	d.p.enumCheck(b, d.ctx.ArgsStr())
 }
 
 func (d *decodeGen) gMap(m *Map) {
@@ -88,9 +88,10 @@ const (
 	Int32
 	Int64
 	Bool
-	Intf // interface{}
-	Time // time.Time
-	Ext  // extension
+	Intf     // interface{}
+	Time     // time.Time
+	Duration // time.Duration
+	Ext      // extension
 
 	IDENT // IDENT means an unrecognized identifier
 )
@@ -119,6 +120,7 @@ var primitives = map[string]Primitive{
 	"bool":           Bool,
 	"interface{}":    Intf,
 	"time.Time":      Time,
+	"time.Duration":  Duration,
 	"msgp.Extension": Ext,
 }
 
@@ -335,9 +337,21 @@ func (s *Slice) IfZeroExpr() string { return s.Varname() + " == nil" }
 
 type Ptr struct {
 	common
-	Value Elem
+	Value   Elem
+	NilMode NilMode // handling of nil slice and map elements
 }
 
+// NilMode determines how nil pointers
+// are encoded when they are the elements
+// of a slice or map.
+type NilMode uint8
+
+const (
+	NilAsNil  NilMode = iota // encode nil (the default)
+	NilOmit                  // leave nil elements out
+	NilAsZero                // encode the zero value of the element type
+)
+
 func (s *Ptr) SetVarname(a string) {
 	s.common.SetVarname(a)
 
@@ -351,7 +365,7 @@ func (s *Ptr) SetVarname(a string) {
 
 	case *BaseElem:
 		// identities have pointer receivers
-		if x.Value == IDENT {
+		if x.Value == IDENT && x.ShimElem == nil {
 			x.SetVarname(a)
 		} else {
 			x.SetVarname("*" + a)
@@ -469,6 +483,25 @@ type StructField struct {
 	RawTag        string   // the full struct tag
 	FieldName     string   // the name of the struct field
 	FieldElem     Elem     // the field type
+	HasIsEmpty    bool     // the field type has a MsgIsEmpty() bool method
+}
+
+// IfEmptyExpr returns the expression that decides
+// whether an omitempty field is left out, or the
+// empty string if the field can't be omitted.
+// Types that define MsgIsEmpty() bool decide for
+// themselves, and pointers to them are also empty
+// when nil; otherwise the field is compared to its
+// zero value.
+func (sf *StructField) IfEmptyExpr() string {
+	if sf.HasIsEmpty {
+		v := sf.FieldElem.Varname()
+		if _, ok := sf.FieldElem.(*Ptr); ok {
+			return "(" + v + " == nil || " + v + ".MsgIsEmpty())"
+		}
+		return v + ".MsgIsEmpty()"
+	}
+	return sf.FieldElem.IfZeroExpr()
 }
 
 // HasTagPart returns true if the specified tag part (option) is present.
@@ -501,6 +534,9 @@ type BaseElem struct {
 	ShimFromBase string    // shim from base type, or empty
 	Value        Primitive // Type of element
 	Convert      bool      // should we do an explicit conversion?
+	ShimElem     Elem      // representation of a non-primitive shim, or nil
+	Enum         []string  // allowed values when decoding, or nil
+	Local        bool      // IDENT of a type whose methods are being generated
 	mustinline   bool      // must inline; not printable
 	needsref     bool      // needs reference for shim
 }
@@ -509,7 +545,7 @@ func (s *BaseElem) Printable() bool { return !s.mustinline }
 
 func (s *BaseElem) Alias(typ string) {
 	s.common.Alias(typ)
-	if s.Value != IDENT {
+	if s.Value != IDENT || s.ShimElem != nil {
 		s.Convert = true
 	}
 	if strings.Contains(typ, ".") {
@@ -573,6 +609,9 @@ func (s *BaseElem) BaseName() string {
 func (s *BaseElem) BaseType() string {
 	switch s.Value {
 	case IDENT:
+		if s.ShimElem != nil {
+			return s.ShimElem.TypeName()
+		}
 		return s.TypeName()
 
 	// exceptions to the naming/capitalization
@@ -583,6 +622,8 @@ func (s *BaseElem) BaseType() string {
 		return "[]byte"
 	case Time:
 		return "time.Time"
+	case Duration:
+		return "time.Duration"
 	case Ext:
 		return "msgp.Extension"
 
@@ -599,6 +640,9 @@ func (s *BaseElem) Needsref(b bool) {
 
 func (s *BaseElem) Copy() Elem {
 	g := *s
+	if s.ShimElem != nil {
+		g.ShimElem = s.ShimElem.Copy()
+	}
 	return &g
 }
 
@@ -617,7 +661,7 @@ func (s *BaseElem) Complexity() int {
 // a primitive or a builtin provided
 // by the package.
 func (s *BaseElem) Resolved() bool {
-	if s.Value == IDENT {
+	if s.Value == IDENT && s.ShimElem == nil {
 		_, ok := builtins[s.TypeName()]
 		return ok
 	}
@@ -627,6 +671,13 @@ func (s *BaseElem) Resolved() bool {
 // ZeroExpr returns the zero/empty expression or empty string if not supported.
 func (s *BaseElem) ZeroExpr() string {
 
+	// time.Time encoded in another
+	// representation (see the time
+	// format tag options)
+	if s.Convert && s.TypeName() == "time.Time" {
+		return "(time.Time{})"
+	}
+
 	switch s.Value {
 	case Bytes:
 		return "nil"
@@ -646,7 +697,8 @@ func (s *BaseElem) ZeroExpr() string {
 		Int8,
 		Int16,
 		Int32,
-		Int64:
+		Int64,
+		Duration:
 		return "0"
 	case Bool:
 		return "false"
@@ -710,6 +762,8 @@ func (k Primitive) String() string {
 		return "Intf"
 	case Time:
 		return "time.Time"
+	case Duration:
+		return "Duration"
 	case Ext:
 		return "Extension"
 	case IDENT:
@@ -134,7 +134,7 @@ func (e *encodeGen) structmap(s *Struct) {
 			if !e.p.ok() {
 				return
 			}
-			if ize := sf.FieldElem.IfZeroExpr(); ize != "" && sf.HasTagPart("omitempty") {
+			if ize := sf.IfEmptyExpr(); ize != "" && sf.HasTagPart("omitempty") {
 				e.p.printf("\nif %s {", ize)
 				e.p.printf("\n%s--", fieldNVar)
 				e.p.printf("\n%s", bm.setStmt(i))
@@ -172,7 +172,7 @@ func (e *encodeGen) structmap(s *Struct) {
 		}
 
 		// if field is omitempty, wrap with if statement based on the emptymask
-		oeField := s.Fields[i].HasTagPart("omitempty") && s.Fields[i].FieldElem.IfZeroExpr() != ""
+		oeField := s.Fields[i].HasTagPart("omitempty") && s.Fields[i].IfEmptyExpr() != ""
 		if oeField {
 			e.p.printf("\nif %s == 0 { // if not empty", bm.readExpr(i))
 		}
@@ -199,12 +199,22 @@ func (e *encodeGen) gMap(m *Map) {
 	}
 	e.fuseHook()
 	vname := m.Varname()
-	e.writeAndCheck(mapHeader, lenAsUint32, vname)
-
-	e.p.printf("\nfor %s, %s := range %s {", m.Keyidx, m.Validx, vname)
+	val := m.Value
+	if pt, ok := omitNil(m.Value); ok {
+		val = pt.Value // nil values are skipped
+		sz := randIdent()
+		e.p.declare(sz, u32)
+		e.p.printf("\nfor _, %s := range %s { if %s != nil { %s++ } }", m.Validx, vname, m.Validx, sz)
+		e.writeAndCheck(mapHeader, literalFmt, sz)
+		e.p.printf("\nfor %s, %s := range %s {", m.Keyidx, m.Validx, vname)
+		e.p.printf("\nif %s == nil { continue }", m.Validx)
+	} else {
+		e.writeAndCheck(mapHeader, lenAsUint32, vname)
+		e.p.printf("\nfor %s, %s := range %s {", m.Keyidx, m.Validx, vname)
+	}
 	e.writeAndCheck(stringTyp, literalFmt, m.Keyidx)
 	e.ctx.PushVar(m.Keyidx)
-	next(e, m.Value)
+	next(e, val)
 	e.ctx.Pop()
 	e.p.closeblock()
 }
@@ -214,6 +224,10 @@ func (e *encodeGen) gPtr(s *Ptr) {
 		return
 	}
 	e.fuseHook()
+	if s.NilMode == NilAsZero {
+		next(e, e.p.zeroNil(s))
+		return
+	}
 	e.p.printf("\nif %s == nil { err = en.WriteNil(); if err != nil { return; } } else {", s.Varname())
 	next(e, s.Value)
 	e.p.closeblock()
@@ -224,6 +238,19 @@ func (e *encodeGen) gSlice(s *Slice) {
 		return
 	}
 	e.fuseHook()
+	if pt, ok := omitNil(s.Els); ok {
+		sz := randIdent()
+		e.p.declare(sz, u32)
+		e.p.printf("\nfor %s := range %s { if %s != nil { %s++ } }", s.Index, s.Varname(), pt.Varname(), sz)
+		e.writeAndCheck(arrayHeader, literalFmt, sz)
+		e.ctx.PushVar(s.Index)
+		e.p.printf("\nfor %s := range %s {", s.Index, s.Varname())
+		e.p.printf("\nif %s == nil { continue }", pt.Varname())
+		next(e, pt.Value)
+		e.p.closeblock()
+		e.ctx.Pop()
+		return
+	}
 	e.writeAndCheck(arrayHeader, lenAsUint32, s.Varname())
 	e.p.rangeBlock(e.ctx, s.Index, s.Varname(), e, s.Els)
 }
@@ -251,17 +278,24 @@ func (e *encodeGen) gBase(b *BaseElem) {
 	e.fuseHook()
 	vname := b.Varname()
 	if b.Convert {
-		if b.ShimMode == Cast {
+		if b.ShimMode == Cast && b.ShimElem == nil {
 			vname = tobaseConvert(b)
 		} else {
 			vname = randIdent()
 			e.p.printf("\nvar %s %s", vname, b.BaseType())
-			e.p.printf("\n%s, err = %s", vname, tobaseConvert(b))
-			e.p.wrapErrCheck(e.ctx.ArgsStr())
+			if b.ShimMode == Cast {
+				e.p.printf("\n%s = %s", vname, tobaseConvert(b))
+			} else {
+				e.p.printf("\n%s, err = %s", vname, tobaseConvert(b))
+				e.p.wrapErrCheck(e.ctx.ArgsStr())
+			}
 		}
 	}
 
-	if b.Value == IDENT { // unknown identity
+	if b.ShimElem != nil { // shim to a non-primitive representation
+		b.ShimElem.SetVarname(vname)
+		next(e, b.ShimElem)
+	} else if b.Value == IDENT { // unknown identity
 		e.p.printf("\nerr = %s.EncodeMsg(en)", vname)
 		e.p.wrapErrCheck(e.ctx.ArgsStr())
 	} else { // typical case
@@ -129,7 +129,7 @@ func (m *marshalGen) mapstruct(s *Struct) {
 			if !m.p.ok() {
 				return
 			}
-			if ize := sf.FieldElem.IfZeroExpr(); ize != "" && sf.HasTagPart("omitempty") {
+			if ize := sf.IfEmptyExpr(); ize != "" && sf.HasTagPart("omitempty") {
 				m.p.printf("\nif %s {", ize)
 				m.p.printf("\n%s--", fieldNVar)
 				m.p.printf("\n%s", bm.setStmt(i))
@@ -167,7 +167,7 @@ func (m *marshalGen) mapstruct(s *Struct) {
 		}
 
 		// if field is omitempty, wrap with if statement based on the emptymask
-		oeField := s.Fields[i].HasTagPart("omitempty") && s.Fields[i].FieldElem.IfZeroExpr() != ""
+		oeField := s.Fields[i].HasTagPart("omitempty") && s.Fields[i].IfEmptyExpr() != ""
 		if oeField {
 			m.p.printf("\nif %s == 0 { // if not empty", bm.readExpr(i))
 		}
@@ -204,11 +204,22 @@ func (m *marshalGen) gMap(s *Map) {
 	}
 	m.fuseHook()
 	vname := s.Varname()
-	m.rawAppend(mapHeader, lenAsUint32, vname)
-	m.p.printf("\nfor %s, %s := range %s {", s.Keyidx, s.Validx, vname)
+	val := s.Value
+	if pt, ok := omitNil(s.Value); ok {
+		val = pt.Value // nil values are skipped
+		sz := randIdent()
+		m.p.declare(sz, u32)
+		m.p.printf("\nfor _, %s := range %s { if %s != nil { %s++ } }", s.Validx, vname, s.Validx, sz)
+		m.rawAppend(mapHeader, literalFmt, sz)
+		m.p.printf("\nfor %s, %s := range %s {", s.Keyidx, s.Validx, vname)
+		m.p.printf("\nif %s == nil { continue }", s.Validx)
+	} else {
+		m.rawAppend(mapHeader, lenAsUint32, vname)
+		m.p.printf("\nfor %s, %s := range %s {", s.Keyidx, s.Validx, vname)
+	}
 	m.rawAppend(stringTyp, literalFmt, s.Keyidx)
 	m.ctx.PushVar(s.Keyidx)
-	next(m, s.Value)
+	next(m, val)
 	m.ctx.Pop()
 	m.p.closeblock()
 }
@@ -219,6 +230,19 @@ func (m *marshalGen) gSlice(s *Slice) {
 	}
 	m.fuseHook()
 	vname := s.Varname()
+	if pt, ok := omitNil(s.Els); ok {
+		sz := randIdent()
+		m.p.declare(sz, u32)
+		m.p.printf("\nfor %s := range %s { if %s != nil { %s++ } }", s.Index, vname, pt.Varname(), sz)
+		m.rawAppend(arrayHeader, literalFmt, sz)
+		m.ctx.PushVar(s.Index)
+		m.p.printf("\nfor %s := range %s {", s.Index, vname)
+		m.p.printf("\nif %s == nil { continue }", pt.Varname())
+		next(m, pt.Value)
+		m.p.closeblock()
+		m.ctx.Pop()
+		return
+	}
 	m.rawAppend(arrayHeader, lenAsUint32, vname)
 	m.p.rangeBlock(m.ctx, s.Index, vname, m, s.Els)
 }
@@ -242,6 +266,10 @@ func (m *marshalGen) gPtr(p *Ptr) {
 		return
 	}
 	m.fuseHook()
+	if p.NilMode == NilAsZero {
+		next(m, m.p.zeroNil(p))
+		return
+	}
 	m.p.printf("\nif %s == nil {\no = msgp.AppendNil(o)\n} else {", p.Varname())
 	next(m, p.Value)
 	m.p.closeblock()
@@ -255,16 +283,26 @@ func (m *marshalGen) gBase(b *BaseElem) {
 	vname := b.Varname()
 
 	if b.Convert {
-		if b.ShimMode == Cast {
+		if b.ShimMode == Cast && b.ShimElem == nil {
 			vname = tobaseConvert(b)
 		} else {
 			vname = randIdent()
 			m.p.printf("\nvar %s %s", vname, b.BaseType())
-			m.p.printf("\n%s, err = %s", vname, tobaseConvert(b))
-			m.p.wrapErrCheck(m.ctx.ArgsStr())
+			if b.ShimMode == Cast {
+				m.p.printf("\n%s = %s", vname, tobaseConvert(b))
+			} else {
+				m.p.printf("\n%s, err = %s", vname, tobaseConvert(b))
+				m.p.wrapErrCheck(m.ctx.ArgsStr())
+			}
 		}
 	}
 
+	if b.ShimElem != nil { // shim to a non-primitive representation
+		b.ShimElem.SetVarname(vname)
+		next(m, b.ShimElem)
+		return
+	}
+
 	var echeck bool
 	switch b.Value {
 	case IDENT:
@@ -123,6 +123,11 @@ func (s *sizeGen) gStruct(st *Struct) {
 
 func (s *sizeGen) gPtr(p *Ptr) {
 	s.state = add // inner must use add
+	if p.NilMode == NilAsZero {
+		next(s, s.p.zeroNil(p))
+		s.state = add
+		return
+	}
 	s.p.printf("\nif %s == nil {\ns += msgp.NilSize\n} else {", p.Varname())
 	next(s, p.Value)
 	s.state = add // closing block; reset to add
@@ -190,7 +195,21 @@ func (s *sizeGen) gBase(b *BaseElem) {
 	if !s.p.ok() {
 		return
 	}
-	if b.Convert && b.ShimMode == Convert {
+	if b.ShimElem != nil {
+		// size the representation of the shimmed value;
+		// a conversion error is ignored, since Msgsize is
+		// only an upper-bound estimate
+		s.state = add
+		vname := randIdent()
+		if b.ShimMode == Cast {
+			s.p.printf("\n%s := %s", vname, tobaseConvert(b))
+		} else {
+			s.p.printf("\n%s, _ := %s", vname, tobaseConvert(b))
+		}
+		b.ShimElem.SetVarname(vname)
+		next(s, b.ShimElem)
+
+	} else if b.Convert && b.ShimMode == Convert {
 		s.state = add
 		vname := randIdent()
 		s.p.printf("\nvar %s %s", vname, b.BaseType())
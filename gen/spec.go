@@ -42,9 +42,11 @@ func (m Method) String() string {
 		return "size"
 	case Test:
 		return "test"
+	case Clone:
+		return "clone"
 	default:
 		// return e.g. "decode+encode+test"
-		modes := [...]Method{Decode, Encode, Marshal, Unmarshal, Size, Test}
+		modes := [...]Method{Decode, Encode, Marshal, Unmarshal, Size, Test, Clone}
 		any := false
 		nm := ""
 		for _, mm := range modes {
@@ -76,6 +78,8 @@ func strtoMeth(s string) Method {
 		return Size
 	case "test":
 		return Test
+	case "clone":
+		return Clone
 	default:
 		return 0
 	}
@@ -88,6 +92,7 @@ const (
 	Unmarshal                                            // msgp.Unmarshaler
 	Size                                                 // msgp.Sizer
 	Test                                                 // generate tests
+	Clone                                                // CloneMsg
 	invalidmeth                                          // this isn't a method
 	encodetest  = Encode | Decode | Test                 // tests for Encodable and Decodable
 	marshaltest = Marshal | Unmarshal | Test             // tests for Marshaler and Unmarshaler
@@ -101,7 +106,7 @@ func NewPrinter(m Method, out io.Writer, tests io.Writer) *Printer {
 	if m.isset(Test) && tests == nil {
 		panic("cannot print tests with 'nil' tests argument!")
 	}
-	gens := make([]generator, 0, 7)
+	gens := make([]generator, 0, 8)
 	if m.isset(Decode) {
 		gens = append(gens, decode(out))
 	}
@@ -117,6 +122,9 @@ func NewPrinter(m Method, out io.Writer, tests io.Writer) *Printer {
 	if m.isset(Size) {
 		gens = append(gens, sizes(out))
 	}
+	if m.isset(Clone) {
+		gens = append(gens, clone(out))
+	}
 	if m.isset(marshaltest) {
 		gens = append(gens, mtest(tests))
 	}
@@ -375,6 +383,24 @@ func (p *printer) wrapErrCheck(ctx string) {
 	p.print("\n}")
 }
 
+// rejects decoded values that aren't
+// one of the allowed values of an enum
+func (p *printer) enumCheck(b *BaseElem, ctx string) {
+	if len(b.Enum) == 0 {
+		return
+	}
+	vname := b.Varname()
+	p.print("\nif ")
+	for i, v := range b.Enum {
+		if i > 0 {
+			p.print(" && ")
+		}
+		p.printf("%s != %s", vname, v)
+	}
+	p.printf(" {\nerr = msgp.WrapError(msgp.EnumError{Type: %q, Value: %s}, %s)", b.TypeName(), vname, ctx)
+	p.print("\nreturn\n}")
+}
+
 func (p *printer) resizeSlice(size string, s *Slice) {
 	p.printf("\nif cap(%[1]s) >= int(%[2]s) { %[1]s = (%[1]s)[:%[2]s] } else { %[1]s = make(%[3]s, %[2]s) }", s.Varname(), size, s.TypeName())
 }
@@ -428,6 +454,28 @@ func (p *printer) initPtr(pt *Ptr) {
 	}
 }
 
+// for pointers with NilAsZero, does:
+//
+// tmp := ptr
+// if tmp == nil { tmp = new(type) }
+//
+// and returns the element pointed to by tmp
+func (p *printer) zeroNil(pt *Ptr) Elem {
+	tmp := randIdent()
+	vname := pt.Varname()
+	p.printf("\n%s := %s\nif %s == nil { %s = new(%s) }", tmp, vname, tmp, tmp, pt.Value.TypeName())
+	z := pt.Copy().(*Ptr)
+	z.SetVarname(tmp)
+	return z.Value
+}
+
+// returns the pointer element of a slice or
+// map if nil elements should be left out
+func omitNil(el Elem) (*Ptr, bool) {
+	pt, ok := el.(*Ptr)
+	return pt, ok && pt.NilMode == NilOmit
+}
+
 func (p *printer) ok() bool { return p.err == nil }
 
 func tobaseConvert(b *BaseElem) string {
@@ -129,17 +129,23 @@ func (u *unmarshalGen) gBase(b *BaseElem) {
 		u.p.printf("\n{\nvar %s %s", refname, b.BaseType())
 	}
 
-	switch b.Value {
-	case Bytes:
+	switch {
+	case b.ShimElem != nil:
+		// unmarshal the representation into 'tmp'
+		b.ShimElem.SetVarname(refname)
+		next(u, b.ShimElem)
+	case b.Value == Bytes:
 		u.p.printf("\n%s, bts, err = msgp.ReadBytesBytes(bts, %s)", refname, lowered)
-	case Ext:
+	case b.Value == Ext:
 		u.p.printf("\nbts, err = msgp.ReadExtensionBytes(bts, %s)", lowered)
-	case IDENT:
+	case b.Value == IDENT:
 		u.p.printf("\nbts, err = %s.UnmarshalMsg(bts)", lowered)
 	default:
 		u.p.printf("\n%s, bts, err = msgp.Read%sBytes(bts)", refname, b.BaseName())
 	}
-	u.p.wrapErrCheck(u.ctx.ArgsStr())
+	if b.ShimElem == nil {
+		u.p.wrapErrCheck(u.ctx.ArgsStr())
+	}
 
 	if b.Convert {
 		// close 'tmp' block
@@ -151,6 +157,7 @@ func (u *unmarshalGen) gBase(b *BaseElem) {
 		}
 		u.p.printf("}")
 	}
+	u.p.enumCheck(b, u.ctx.ArgsStr())
 }
 
 func (u *unmarshalGen) gArray(a *Array) {
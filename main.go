@@ -17,6 +17,13 @@
 //  -io = satisfy the `msgp.Decodable` and `msgp.Encodable` interfaces (default is true)
 //  -marshal = satisfy the `msgp.Marshaler` and `msgp.Unmarshaler` interfaces (default is true)
 //  -tests = generate tests and benchmarks (default is true)
+//  -clone = generate `CloneMsg` deep copy methods (default is false)
+//
+// CloneMsg copies everything that is encoded, except extensions, shimmed
+// fields, and fields whose types don't get a CloneMsg method of their own
+// in the same run (such as types from other packages); those are copied
+// by assignment, as are the fields that aren't encoded (unexported or
+// tagged "-"), so any slices, maps or pointers in them are shared.
 //
 // For more information, please read README.md, and the wiki at github.com/tinylib/msgp
 //
@@ -41,6 +48,7 @@ var (
 	encode     = flag.Bool("io", true, "create Encode and Decode methods")
 	marshal    = flag.Bool("marshal", true, "create Marshal and Unmarshal methods")
 	tests      = flag.Bool("tests", true, "create tests and benchmarks")
+	clone      = flag.Bool("clone", false, "create CloneMsg methods")
 	unexported = flag.Bool("unexported", false, "also process unexported types")
 )
 
@@ -66,6 +74,9 @@ func main() {
 	if *tests {
 		mode |= gen.Test
 	}
+	if *clone {
+		mode |= gen.Clone
+	}
 
 	if mode&^gen.Test == 0 {
 		fmt.Println(chalk.Red.Color("No methods to generate; -io=false && -marshal=false"))
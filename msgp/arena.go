@@ -0,0 +1,135 @@
+package msgp
+
+// DefaultArenaBlockSize is the size of the
+// blocks allocated by an Arena whose block
+// size is not set.
+const DefaultArenaBlockSize = 64 * 1024
+
+// Arena hands out memory for decoded strings
+// and byte slices (see Reader.ReadStringArena
+// and Reader.ReadBytesArena) by carving it out
+// of large blocks, so that decoding many small
+// objects doesn't cause many small allocations.
+// Calling Reset makes all of the memory available
+// again at once, without any work for the garbage
+// collector.
+//
+// Everything allocated from an Arena must be
+// dead by the time Reset is called, since its
+// memory is then reused: a string obtained from
+// the Arena before Reset may change afterward.
+// A typical use is to decode a message, handle
+// it, and then Reset the Arena before decoding
+// the next one.
+//
+// The zero value is ready to use, with blocks of
+// DefaultArenaBlockSize. An Arena is not safe for
+// concurrent use.
+type Arena struct {
+	blocks [][]byte
+	cur    int // index of the block in use
+	off    int // bytes used in blocks[cur]
+	size   int
+}
+
+// NewArena returns an *Arena that allocates
+// blocks of blockSize bytes. If blockSize is
+// not positive, DefaultArenaBlockSize is used.
+func NewArena(blockSize int) *Arena {
+	return &Arena{size: blockSize}
+}
+
+func (a *Arena) blockSize() int {
+	if a.size <= 0 {
+		return DefaultArenaBlockSize
+	}
+	return a.size
+}
+
+// Alloc returns a slice of n bytes from the
+// arena, with a capacity of n. Its contents are
+// not zeroed. Requests for more than a quarter
+// of the block size are allocated individually,
+// so that they don't waste the rest of a block.
+func (a *Arena) Alloc(n int) []byte {
+	bs := a.blockSize()
+	if n == 0 {
+		return []byte{}
+	}
+	if n > bs/4 {
+		return make([]byte, n)
+	}
+	if len(a.blocks) == 0 || a.off+n > len(a.blocks[a.cur]) {
+		a.next(bs)
+	}
+	b := a.blocks[a.cur][a.off : a.off+n : a.off+n]
+	a.off += n
+	return b
+}
+
+// next moves on to the next block,
+// allocating it if it doesn't exist
+func (a *Arena) next(bs int) {
+	if len(a.blocks) > 0 {
+		a.cur++
+	}
+	a.off = 0
+	if a.cur == len(a.blocks) {
+		a.blocks = append(a.blocks, make([]byte, bs))
+	}
+}
+
+// Reset makes all of the memory in
+// the arena available for reuse. The
+// blocks that have been allocated are
+// kept for subsequent allocations.
+func (a *Arena) Reset() {
+	a.cur = 0
+	a.off = 0
+}
+
+// Cap returns the total size of the
+// blocks held by the arena.
+func (a *Arena) Cap() int {
+	n := 0
+	for i := range a.blocks {
+		n += len(a.blocks[i])
+	}
+	return n
+}
+
+// ReadStringArena is like ReadString, but
+// the memory for the string comes from 'a'.
+// The string is only valid until a.Reset is
+// called.
+func (m *Reader) ReadStringArena(a *Arena) (string, error) {
+	sz, err := m.ReadStringHeader()
+	if err != nil {
+		return "", err
+	}
+	if sz == 0 {
+		return "", nil
+	}
+	b := a.Alloc(int(sz))
+	if _, err = m.R.ReadFull(b); err != nil {
+		return "", err
+	}
+	if err = m.checkUTF8(b); err != nil {
+		return "", err
+	}
+	return UnsafeString(b), nil
+}
+
+// ReadBytesArena is like ReadBytes, but
+// the memory for the returned slice comes
+// from 'a'. The slice is only valid until
+// a.Reset is called.
+func (m *Reader) ReadBytesArena(a *Arena) ([]byte, error) {
+	sz, err := m.ReadBytesHeader()
+	if err != nil {
+		return nil, err
+	}
+	b := a.Alloc(int(sz))
+	_, err = m.R.ReadFull(b)
+	return b, err
+}
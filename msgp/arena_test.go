@@ -0,0 +1,90 @@
+package msgp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	a := NewArena(64)
+	x := a.Alloc(10)
+	y := a.Alloc(10)
+	if len(x) != 10 || cap(x) != 10 {
+		t.Fatalf("got len %d, cap %d", len(x), cap(x))
+	}
+	copy(x, "0123456789")
+	y = append(y[:0], "abcdefghij"...)
+	if string(x) != "0123456789" {
+		t.Errorf("appending to one allocation overwrote another: %q", x)
+	}
+	a.Alloc(16)
+	a.Alloc(16)
+	a.Alloc(16) // doesn't fit; starts a second block
+	if a.Cap() != 128 {
+		t.Errorf("got Cap %d; want 128", a.Cap())
+	}
+	if big := a.Alloc(100); len(big) != 100 || a.Cap() != 128 {
+		t.Errorf("large allocation should not use a block")
+	}
+	a.Reset()
+	for i := 0; i < 8; i++ {
+		a.Alloc(16)
+	}
+	if a.Cap() != 128 {
+		t.Errorf("Reset should reuse blocks; got Cap %d", a.Cap())
+	}
+	var zero Arena
+	if b := zero.Alloc(0); b == nil || zero.Cap() != 0 {
+		t.Error("empty allocation should be non-nil and not allocate a block")
+	}
+}
+
+func TestReadArena(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	strs := []string{"", "hello", strings.Repeat("x", 300)}
+	for _, s := range strs {
+		w.WriteString(s)
+		w.WriteBytes([]byte(s))
+	}
+	w.WriteInt(1)
+	w.Flush()
+
+	var a Arena
+	r := NewReader(&buf)
+	for _, want := range strs {
+		s, err := r.ReadStringArena(&a)
+		if err != nil || s != want {
+			t.Errorf("got %q, %v; want %q", s, err, want)
+		}
+		b, err := r.ReadBytesArena(&a)
+		if err != nil || string(b) != want {
+			t.Errorf("got %q, %v; want %q", b, err, want)
+		}
+	}
+	if _, err := r.ReadStringArena(&a); err == nil {
+		t.Error("expected an error for an int")
+	}
+
+	if _, err := NewReader(bytes.NewReader(AppendString(nil, "abc")[:2])).ReadStringArena(&a); err == nil {
+		t.Error("expected an error for a truncated string")
+	}
+}
+
+func BenchmarkReadStringArena(b *testing.B) {
+	enc := AppendString(nil, "a short string")
+	rd := bytes.NewReader(enc)
+	r := NewReader(rd)
+	var a Arena
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%1000 == 0 {
+			a.Reset()
+		}
+		rd.Reset(enc)
+		r.Reset(rd)
+		r.ReadStringArena(&a)
+	}
+}
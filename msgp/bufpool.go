@@ -0,0 +1,92 @@
+package msgp
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// The buffer pool keeps encode buffers in
+// size classes by powers of two, so that a
+// request for a small buffer doesn't tie up
+// a large one, and a buffer that grew while
+// it was in use is kept in the class it grew
+// into.
+const (
+	minBufferClass = 9  // 512 bytes
+	maxBufferClass = 20 // 1MB
+
+	// DefaultBufferSize is the capacity
+	// requested by GetBuffer.
+	DefaultBufferSize = 4096
+)
+
+var bufferPools [maxBufferClass - minBufferClass + 1]sync.Pool
+
+// GetBuffer returns an empty slice with a
+// capacity of at least DefaultBufferSize from
+// the package's buffer pool. It is shorthand for
+// GetBufferSize(DefaultBufferSize).
+//
+// A typical use is to marshal a response into
+// the buffer, write it, and then return the
+// buffer with PutBuffer:
+//
+//	buf, err := v.MarshalMsg(msgp.GetBufferSize(v.Msgsize()))
+//	if err == nil {
+//		_, err = conn.Write(buf)
+//	}
+//	msgp.PutBuffer(buf)
+func GetBuffer() []byte { return GetBufferSize(DefaultBufferSize) }
+
+// GetBufferSize returns an empty slice with
+// a capacity of at least n from the package's
+// buffer pool, or a new slice if there is no
+// pooled buffer that is large enough. Requests
+// for more than 1MB are always allocated.
+func GetBufferSize(n int) []byte {
+	c := bufferClass(n, true)
+	if c < 0 {
+		return make([]byte, 0, n)
+	}
+	if b, ok := bufferPools[c].Get().(*[]byte); ok {
+		return (*b)[:0]
+	}
+	return make([]byte, 0, 1<<(c+minBufferClass))
+}
+
+// PutBuffer returns b to the package's buffer
+// pool for reuse by GetBuffer and GetBufferSize.
+// The caller must not use b, or any slice that
+// shares its memory, afterwards. Buffers that
+// are smaller than 512 bytes or larger than
+// 1MB are not kept.
+func PutBuffer(b []byte) {
+	c := bufferClass(cap(b), false)
+	if c < 0 {
+		return
+	}
+	b = b[:0]
+	bufferPools[c].Put(&b)
+}
+
+// bufferClass returns the index of the class
+// for a buffer of size n, or -1 if there isn't
+// one. Rounding up gives the class whose buffers
+// all hold n bytes; rounding down gives the largest
+// class whose size n can serve.
+func bufferClass(n int, up bool) int {
+	if n <= 1<<minBufferClass {
+		if up || n == 1<<minBufferClass {
+			return 0
+		}
+		return -1
+	}
+	if n > 1<<maxBufferClass {
+		return -1
+	}
+	c := bits.Len(uint(n - 1)) // log2 of n, rounded up
+	if !up && n != 1<<c {
+		c--
+	}
+	return c - minBufferClass
+}
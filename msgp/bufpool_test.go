@@ -0,0 +1,47 @@
+package msgp
+
+import "testing"
+
+func TestBufferClass(t *testing.T) {
+	cases := []struct {
+		n        int
+		up, down int
+	}{
+		{0, 0, -1},
+		{100, 0, -1},
+		{512, 0, 0},
+		{513, 1, 0},
+		{1024, 1, 1},
+		{4000, 3, 2},
+		{1 << 20, 11, 11},
+		{1<<20 + 1, -1, -1},
+	}
+	for _, c := range cases {
+		if got := bufferClass(c.n, true); got != c.up {
+			t.Errorf("bufferClass(%d, true) = %d; want %d", c.n, got, c.up)
+		}
+		if got := bufferClass(c.n, false); got != c.down {
+			t.Errorf("bufferClass(%d, false) = %d; want %d", c.n, got, c.down)
+		}
+	}
+}
+
+func TestBufferPool(t *testing.T) {
+	b := GetBuffer()
+	if len(b) != 0 || cap(b) < DefaultBufferSize {
+		t.Fatalf("got len %d, cap %d", len(b), cap(b))
+	}
+	b = AppendString(b, "hello")
+	PutBuffer(b)
+
+	for _, n := range []int{0, 600, 5000, 1 << 20, 3 << 20} {
+		b = GetBufferSize(n)
+		if len(b) != 0 || cap(b) < n {
+			t.Errorf("GetBufferSize(%d): got len %d, cap %d", n, len(b), cap(b))
+		}
+		PutBuffer(b)
+	}
+	// too small to keep
+	PutBuffer(make([]byte, 10))
+	PutBuffer(nil)
+}
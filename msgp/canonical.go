@@ -0,0 +1,149 @@
+package msgp
+
+import (
+	"bytes"
+	"hash"
+	"sort"
+)
+
+// AppendCanonical appends the canonical form of the
+// object in b to dst. Objects that are semantically
+// equal have the same canonical form, however they
+// were encoded:
+//
+//   - every header and integer uses its smallest encoding,
+//     and non-negative integers are encoded as unsigned;
+//   - float32s are widened to float64s;
+//   - map entries are sorted by the canonical form of
+//     their keys, compared bytewise.
+//
+// Values of different types remain different, so
+// the integer 1, the float 1.0, and the strings and
+// 'bin' objects with the same contents don't share
+// a canonical form. The object must be followed by
+// no more data; otherwise, ErrTrailingBytes is returned.
+func AppendCanonical(dst, b []byte) ([]byte, error) {
+	dst, o, err := appendCanonical(dst, b)
+	if err == nil && len(o) > 0 {
+		err = ErrTrailingBytes
+	}
+	return dst, err
+}
+
+// Hash writes the canonical form (see
+// AppendCanonical) of the object in b to h,
+// so that objects that are semantically equal
+// have the same digest, for deduplication or
+// signing.
+func Hash(b []byte, h hash.Hash) error {
+	c, err := AppendCanonical(GetBufferSize(len(b)), b)
+	if err == nil {
+		_, err = h.Write(c)
+	}
+	PutBuffer(c)
+	return err
+}
+
+func appendCanonical(dst, b []byte) ([]byte, []byte, error) {
+	if len(b) == 0 {
+		return dst, b, ErrShortBytes
+	}
+	if sizes[b[0]].typ == ExtensionType {
+		typ, err := peekExtension(b)
+		if err != nil {
+			return dst, b, err
+		}
+		e := RawExtension{Type: typ}
+		o, err := ReadExtensionBytes(b, &e)
+		if err != nil {
+			return dst, b, err
+		}
+		dst, err = AppendExtension(dst, &e)
+		return dst, o, err
+	}
+	switch NextType(b) {
+	case MapType:
+		return appendCanonicalMap(dst, b)
+	case ArrayType:
+		sz, o, err := ReadArrayHeaderBytes(b)
+		if err != nil {
+			return dst, b, err
+		}
+		dst = AppendArrayHeader(dst, sz)
+		for i := uint32(0); i < sz; i++ {
+			dst, o, err = appendCanonical(dst, o)
+			if err != nil {
+				return dst, o, WrapError(err, i)
+			}
+		}
+		return dst, o, nil
+	case IntType:
+		i, o, err := ReadInt64Bytes(b)
+		if err != nil {
+			return dst, b, err
+		}
+		if i >= 0 {
+			return AppendUint64(dst, uint64(i)), o, nil
+		}
+		return AppendInt64(dst, i), o, nil
+	case UintType:
+		u, o, err := ReadUint64Bytes(b)
+		return AppendUint64(dst, u), o, err
+	case Float32Type, Float64Type:
+		f, o, err := ReadFloat64Bytes(b)
+		return AppendFloat64(dst, f), o, err
+	case StrType:
+		s, o, err := ReadStringZC(b)
+		return AppendStringFromBytes(dst, s), o, err
+	case BinType:
+		p, o, err := ReadBytesZC(b)
+		return AppendBytes(dst, p), o, err
+	case BoolType, NilType:
+		return append(dst, b[0]), b[1:], nil
+	default:
+		return dst, b, InvalidPrefixError(b[0])
+	}
+}
+
+// canonicalEntry locates the key and value
+// of a map entry within the canonical output
+type canonicalEntry struct {
+	key, val, end int
+}
+
+func appendCanonicalMap(dst, b []byte) ([]byte, []byte, error) {
+	sz, o, err := ReadMapHeaderBytes(b)
+	if err != nil {
+		return dst, b, err
+	}
+	if uint64(sz) > uint64(len(o)/2) {
+		// each entry is at least two bytes
+		return dst, b, ErrShortBytes
+	}
+	dst = AppendMapHeader(dst, sz)
+	start := len(dst)
+	entries := make([]canonicalEntry, sz)
+	for i := range entries {
+		e := &entries[i]
+		e.key = len(dst)
+		dst, o, err = appendCanonical(dst, o)
+		if err != nil {
+			return dst, o, err
+		}
+		e.val = len(dst)
+		dst, o, err = appendCanonical(dst, o)
+		if err != nil {
+			return dst, o, err
+		}
+		e.end = len(dst)
+	}
+	body := append([]byte(nil), dst[start:]...)
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(body[entries[i].key-start:entries[i].val-start], body[entries[j].key-start:entries[j].val-start]) < 0
+	})
+	dst = dst[:start]
+	for _, e := range entries {
+		dst = append(dst, body[e.key-start:e.end-start]...)
+	}
+	return dst, o, nil
+}
@@ -0,0 +1,75 @@
+package msgp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	// the same map, encoded two ways
+	a := AppendMapHeader(nil, 3)
+	a = AppendString(a, "b")
+	a = AppendInt64(a, 1)
+	a = AppendString(a, "a")
+	a = AppendArrayHeader(a, 2)
+	a = AppendFloat32(a, 1.5)
+	a = AppendInt(a, -3)
+	a = AppendString(a, "c")
+	a, _ = AppendExtension(a, &RawExtension{Type: 9, Data: []byte{1, 2, 3, 4}})
+
+	b := []byte{mmap16, 0, 3}
+	b = append(b, mstr8, 1, 'c')
+	b = append(b, mext8, 4, 9, 1, 2, 3, 4)
+	b = append(b, mstr16, 0, 1, 'a')
+	b = append(b, marray32, 0, 0, 0, 2)
+	b = AppendFloat64(b, 1.5)
+	b = append(b, mint64, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfd)
+	b = append(b, 0xa1, 'b')
+	b = append(b, muint32, 0, 0, 0, 1)
+
+	ca, err := AppendCanonical(nil, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb, err := AppendCanonical(nil, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ca, cb) {
+		t.Errorf("canonical forms differ:\n%x\n%x", ca, cb)
+	}
+	if k, _, _ := ReadMapKeyZC(ca[1:]); string(k) != "a" {
+		t.Errorf("first key is %q", k)
+	}
+
+	ha, hb := sha256.New(), sha256.New()
+	if err := Hash(a, ha); err != nil {
+		t.Fatal(err)
+	}
+	if err := Hash(b, hb); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ha.Sum(nil), hb.Sum(nil)) {
+		t.Error("hashes differ")
+	}
+
+	// types are kept apart
+	for _, pair := range [][2][]byte{
+		{AppendInt(nil, 1), AppendFloat64(nil, 1)},
+		{AppendString(nil, "x"), AppendBytes(nil, []byte("x"))},
+	} {
+		c0, _ := AppendCanonical(nil, pair[0])
+		c1, _ := AppendCanonical(nil, pair[1])
+		if bytes.Equal(c0, c1) {
+			t.Errorf("%x and %x have the same canonical form", pair[0], pair[1])
+		}
+	}
+
+	if _, err := AppendCanonical(nil, append(AppendNil(nil), 1)); err != ErrTrailingBytes {
+		t.Errorf("expected ErrTrailingBytes; got %v", err)
+	}
+	if _, err := AppendCanonical(nil, []byte{mmap32, 0xff, 0xff, 0xff, 0xff}); err == nil {
+		t.Error("expected an error for a truncated map")
+	}
+}
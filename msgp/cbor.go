@@ -0,0 +1,582 @@
+package msgp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// CBOR major types
+const (
+	cborUint   = 0
+	cborNeg    = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+const (
+	cborIndefinite = 31
+	cborBreak      = 0xff
+
+	// CBOR tags for date/time strings
+	// and epoch-based date/time
+	cborTagTime  = 0
+	cborTagEpoch = 1
+
+	// the maximum nesting depth of the
+	// CBOR accepted by CopyFromCBOR and
+	// the MessagePack read by CopyToCBOR
+	cborMaxDepth = 10000
+)
+
+var (
+	errCBORBreak    = errors.New("msgp: unexpected CBOR break")
+	errCBORReserved = errors.New("msgp: reserved CBOR additional information")
+	errCBORChunk    = errors.New("msgp: invalid chunk in indefinite-length CBOR string")
+	errCBORTime     = errors.New("msgp: invalid CBOR date/time")
+	errCBORSimple   = errors.New("msgp: CBOR simple value has no MessagePack equivalent")
+	errCBORNegative = errors.New("msgp: CBOR negative integer overflows an int64")
+	errCBORSize     = errors.New("msgp: CBOR length overflows a MessagePack header")
+)
+
+// CopyToCBOR reads MessagePack from 'src' and writes it
+// to 'dst' as CBOR (RFC 8949) until 'src' returns io.EOF.
+// Each object is translated as it is read, without being
+// decoded. It returns the number of bytes written.
+//
+// Integers, floats, strings, binary, arrays, maps, booleans
+// and nil translate to their CBOR counterparts. Times (both
+// the msgp time extension and the -1 timestamp extension)
+// are written as epoch-based date/time (tag 1): an integer
+// number of seconds if the time has no fractional part,
+// and a float otherwise, which may lose precision. Other
+// extensions, including complex numbers, have no CBOR
+// equivalent and cause an error.
+func CopyToCBOR(dst io.Writer, src io.Reader) (n int64, err error) {
+	r := NewReader(src)
+	r.SetMaxDepth(cborMaxDepth)
+	bf := bufio.NewWriter(dst)
+	w := &countWriter{jsWriter: bf}
+	for {
+		if _, err = r.R.Peek(1); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			break
+		}
+		if err = cborNext(w, r); err != nil {
+			break
+		}
+	}
+	if ferr := bf.Flush(); err == nil {
+		err = ferr
+	}
+	freeR(r)
+	return w.n, err
+}
+
+// appendCBORHead appends the initial
+// byte and argument of a CBOR item
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(b, major|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return append(b, major|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	default:
+		b = append(b, major|27)
+		for s := 56; s >= 0; s -= 8 {
+			b = append(b, byte(n>>uint(s)))
+		}
+		return b
+	}
+}
+
+func writeCBORHead(w jsWriter, major byte, n uint64) error {
+	var buf [9]byte
+	_, err := w.Write(appendCBORHead(buf[:0], major, n))
+	return err
+}
+
+// cborNext translates the next
+// object in 'r' to CBOR
+func cborNext(w jsWriter, r *Reader) error {
+	t, err := r.NextType()
+	if err != nil {
+		return err
+	}
+	switch t {
+	case StrType, BinType:
+		var sz uint32
+		var major byte = cborText
+		if t == StrType {
+			sz, err = r.ReadStringHeader()
+		} else {
+			sz, err = r.ReadBytesHeader()
+			major = cborBytes
+		}
+		if err != nil {
+			return err
+		}
+		if err = writeCBORHead(w, major, uint64(sz)); err != nil {
+			return err
+		}
+		_, err = io.CopyN(w, r.R, int64(sz))
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	case MapType, ArrayType:
+		var sz uint32
+		var major byte = cborArray
+		if t == MapType {
+			sz, err = r.ReadMapHeader()
+			major = cborMap
+		} else {
+			sz, err = r.ReadArrayHeader()
+		}
+		if err != nil {
+			return err
+		}
+		if err = r.enter(); err != nil {
+			return err
+		}
+		defer r.leave()
+		if err = writeCBORHead(w, major, uint64(sz)); err != nil {
+			return err
+		}
+		n := uint64(sz)
+		if t == MapType {
+			n *= 2
+		}
+		for i := uint64(0); i < n; i++ {
+			if err = cborNext(w, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case IntType:
+		i, err := r.ReadInt64()
+		if err != nil {
+			return err
+		}
+		return writeCBORInt(w, i)
+	case UintType:
+		u, err := r.ReadUint64()
+		if err != nil {
+			return err
+		}
+		return writeCBORHead(w, cborUint, u)
+	case Float32Type:
+		f, err := r.ReadFloat32()
+		if err != nil {
+			return err
+		}
+		var buf [5]byte
+		buf[0] = cborSimple<<5 | 26
+		binary.BigEndian.PutUint32(buf[1:], math.Float32bits(f))
+		_, err = w.Write(buf[:])
+		return err
+	case Float64Type:
+		f, err := r.ReadFloat64()
+		if err != nil {
+			return err
+		}
+		return writeCBORFloat64(w, f)
+	case BoolType:
+		b, err := r.ReadBool()
+		if err != nil {
+			return err
+		}
+		if b {
+			return w.WriteByte(cborSimple<<5 | 21)
+		}
+		return w.WriteByte(cborSimple<<5 | 20)
+	case NilType:
+		if err = r.ReadNil(); err != nil {
+			return err
+		}
+		return w.WriteByte(cborSimple<<5 | 22)
+	case TimeType, ExtensionType:
+		var tm time.Time
+		if t == TimeType {
+			tm, err = r.ReadTime()
+		} else {
+			var typ int8
+			if typ, err = r.peekExtensionType(); err != nil {
+				return err
+			}
+			if typ != TimestampExtension {
+				return &ErrUnsupportedCBOR{Type: t, ExtensionType: typ}
+			}
+			tm, err = r.ReadTimestamp()
+		}
+		if err != nil {
+			return err
+		}
+		if err = writeCBORHead(w, cborTag, cborTagEpoch); err != nil {
+			return err
+		}
+		if tm.Nanosecond() == 0 {
+			return writeCBORInt(w, tm.Unix())
+		}
+		return writeCBORFloat64(w, float64(tm.Unix())+float64(tm.Nanosecond())/1e9)
+	default:
+		return &ErrUnsupportedCBOR{Type: t}
+	}
+}
+
+func writeCBORInt(w jsWriter, i int64) error {
+	if i < 0 {
+		return writeCBORHead(w, cborNeg, uint64(-1-i))
+	}
+	return writeCBORHead(w, cborUint, uint64(i))
+}
+
+func writeCBORFloat64(w jsWriter, f float64) error {
+	var buf [9]byte
+	buf[0] = cborSimple<<5 | 27
+	binary.BigEndian.PutUint64(buf[1:], math.Float64bits(f))
+	_, err := w.Write(buf[:])
+	return err
+}
+
+// ErrUnsupportedCBOR is returned by CopyToCBOR
+// when it reads an object that has no CBOR
+// equivalent.
+type ErrUnsupportedCBOR struct {
+	Type          Type
+	ExtensionType int8 // set if Type is ExtensionType
+}
+
+// Error implements error
+func (e *ErrUnsupportedCBOR) Error() string {
+	if e.Type == ExtensionType {
+		return "msgp: extension type " + strconv.Itoa(int(e.ExtensionType)) + " has no CBOR equivalent"
+	}
+	return "msgp: " + e.Type.String() + " has no CBOR equivalent"
+}
+
+// Resumable returns 'true' for ErrUnsupportedCBOR
+func (e *ErrUnsupportedCBOR) Resumable() bool { return true }
+
+// CopyFromCBOR reads CBOR (RFC 8949) from 'src' and
+// writes it to 'dst' as MessagePack until 'src' returns
+// io.EOF between two items. Each top-level item is written
+// once it has been read entirely, so that indefinite-length
+// arrays, maps and strings can be counted. It returns the
+// number of bytes written.
+//
+// Integers, floats, byte and text strings, arrays, maps,
+// booleans and null translate to their MessagePack
+// counterparts; half-precision floats are widened to
+// 'float32', and 'undefined' is translated to nil. Standard
+// and epoch-based date/time (tags 0 and 1) are translated
+// to times, and other tags are dropped, leaving the item
+// they enclose. Negative integers below math.MinInt64 and
+// simple values other than those above cause an error.
+func CopyFromCBOR(dst io.Writer, src io.Reader) (n int64, err error) {
+	br, ok := src.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(src)
+	}
+	var buf []byte
+	for {
+		if _, err = br.Peek(1); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		buf, err = appendFromCBOR(buf[:0], br, 0)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		var nn int
+		nn, err = dst.Write(buf)
+		n += int64(nn)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readCBORHead reads the initial byte of a CBOR item
+// and its argument. For indefinite-length items, info
+// is cborIndefinite and the argument is zero.
+func readCBORHead(r *bufio.Reader) (major, info byte, arg uint64, err error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+	major, info = c>>5, c&0x1f
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		var buf [8]byte
+		sz := 1 << (info - 24)
+		if _, err = io.ReadFull(r, buf[8-sz:]); err != nil {
+			return
+		}
+		arg = binary.BigEndian.Uint64(buf[:])
+	case info == cborIndefinite:
+		if major < cborBytes || major == cborTag {
+			err = errCBORReserved
+		}
+	default:
+		err = errCBORReserved
+	}
+	return
+}
+
+// appendFromCBOR appends the MessagePack
+// translation of the next CBOR item in 'r'
+func appendFromCBOR(b []byte, r *bufio.Reader, depth int) ([]byte, error) {
+	major, info, arg, err := readCBORHead(r)
+	// other tags are dropped in a loop, so that
+	// a long run of them can't exhaust the stack
+	for err == nil && major == cborTag && arg != cborTagTime && arg != cborTagEpoch {
+		major, info, arg, err = readCBORHead(r)
+	}
+	if err != nil {
+		return b, err
+	}
+	switch major {
+	case cborUint:
+		return AppendUint64(b, arg), nil
+	case cborNeg:
+		if arg > math.MaxInt64 {
+			return b, errCBORNegative
+		}
+		return AppendInt64(b, -1-int64(arg)), nil
+	case cborBytes, cborText:
+		var data []byte
+		if info == cborIndefinite {
+			data, err = readCBORChunks(r, major)
+		} else {
+			data, err = appendCBORData(nil, r, arg)
+		}
+		if err != nil {
+			return b, err
+		}
+		if uint64(len(data)) > math.MaxUint32 {
+			return b, errCBORSize
+		}
+		if major == cborText {
+			return AppendStringFromBytes(b, data), nil
+		}
+		return AppendBytes(b, data), nil
+	case cborArray, cborMap:
+		if depth >= cborMaxDepth {
+			return b, LimitError{Limit: "depth", Size: uint64(depth + 1), Max: cborMaxDepth}
+		}
+		if info == cborIndefinite {
+			return appendCBORIndefinite(b, r, major, depth)
+		}
+		if arg > math.MaxUint32 {
+			return b, errCBORSize
+		}
+		n := arg
+		if major == cborMap {
+			b = AppendMapHeader(b, uint32(arg))
+			n *= 2
+		} else {
+			b = AppendArrayHeader(b, uint32(arg))
+		}
+		for i := uint64(0); i < n; i++ {
+			if b, err = appendFromCBOR(b, r, depth+1); err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	case cborTag:
+		t, err := readCBORTime(r, arg)
+		if err != nil {
+			return b, err
+		}
+		return AppendTime(b, t), nil
+	default: // cborSimple
+		switch info {
+		case 20:
+			return AppendBool(b, false), nil
+		case 21:
+			return AppendBool(b, true), nil
+		case 22, 23:
+			return AppendNil(b), nil
+		case 25:
+			return AppendFloat32(b, halfToFloat32(uint16(arg))), nil
+		case 26:
+			return AppendFloat32(b, math.Float32frombits(uint32(arg))), nil
+		case 27:
+			return AppendFloat64(b, math.Float64frombits(arg)), nil
+		case cborIndefinite:
+			return b, errCBORBreak
+		default:
+			return b, errCBORSimple
+		}
+	}
+}
+
+// appendCBORData appends n bytes read from r,
+// growing b as the bytes arrive so that a bogus
+// length can't cause a huge allocation
+func appendCBORData(b []byte, r io.Reader, n uint64) ([]byte, error) {
+	for n > 0 {
+		c := n
+		if c > 64*1024 {
+			c = 64 * 1024
+		}
+		var o int
+		b, o = ensure(b, int(c))
+		if _, err := io.ReadFull(r, b[o:]); err != nil {
+			return b[:o], err
+		}
+		n -= c
+	}
+	return b, nil
+}
+
+// readCBORChunks reads the chunks of an
+// indefinite-length byte or text string
+func readCBORChunks(r *bufio.Reader, major byte) ([]byte, error) {
+	var data []byte
+	for {
+		if p, err := r.Peek(1); err == nil && p[0] == cborBreak {
+			r.ReadByte()
+			return data, nil
+		}
+		m, info, arg, err := readCBORHead(r)
+		if err != nil {
+			return data, err
+		}
+		if m != major || info == cborIndefinite {
+			return data, errCBORChunk
+		}
+		if data, err = appendCBORData(data, r, arg); err != nil {
+			return data, err
+		}
+	}
+}
+
+// appendCBORIndefinite translates the items of an
+// indefinite-length array or map, which are counted
+// before the header is written
+func appendCBORIndefinite(b []byte, r *bufio.Reader, major byte, depth int) ([]byte, error) {
+	var body []byte
+	var n uint64
+	for {
+		if p, err := r.Peek(1); err == nil && p[0] == cborBreak {
+			r.ReadByte()
+			break
+		}
+		var err error
+		if body, err = appendFromCBOR(body, r, depth+1); err != nil {
+			return b, err
+		}
+		n++
+	}
+	if major == cborMap {
+		if n%2 != 0 {
+			return b, errCBORBreak
+		}
+		n /= 2
+	}
+	if n > math.MaxUint32 {
+		return b, errCBORSize
+	}
+	if major == cborMap {
+		b = AppendMapHeader(b, uint32(n))
+	} else {
+		b = AppendArrayHeader(b, uint32(n))
+	}
+	return append(b, body...), nil
+}
+
+// readCBORTime reads the item enclosed by
+// a date/time tag: a string for tag 0, and
+// a number of seconds for tag 1
+func readCBORTime(r *bufio.Reader, tag uint64) (time.Time, error) {
+	major, info, arg, err := readCBORHead(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch {
+	case tag == cborTagTime && major == cborText && info != cborIndefinite:
+		if arg > 64 {
+			return time.Time{}, errCBORTime
+		}
+		s, err := appendCBORData(nil, r, arg)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t, err := time.Parse(time.RFC3339Nano, string(s))
+		if err != nil {
+			return time.Time{}, errCBORTime
+		}
+		return t, nil
+	case tag == cborTagEpoch && major == cborUint:
+		if arg > math.MaxInt64 {
+			return time.Time{}, errCBORTime
+		}
+		return time.Unix(int64(arg), 0), nil
+	case tag == cborTagEpoch && major == cborNeg:
+		if arg > math.MaxInt64 {
+			return time.Time{}, errCBORTime
+		}
+		return time.Unix(-1-int64(arg), 0), nil
+	case tag == cborTagEpoch && major == cborSimple && info >= 25 && info <= 27:
+		var f float64
+		switch info {
+		case 25:
+			f = float64(halfToFloat32(uint16(arg)))
+		case 26:
+			f = float64(math.Float32frombits(uint32(arg)))
+		default:
+			f = math.Float64frombits(arg)
+		}
+		if math.IsNaN(f) || math.Abs(f) >= 1<<63 {
+			return time.Time{}, errCBORTime
+		}
+		sec := math.Floor(f)
+		return time.Unix(int64(sec), int64((f-sec)*1e9)), nil
+	}
+	return time.Time{}, errCBORTime
+}
+
+// halfToFloat32 converts the bits of an
+// IEEE 754 half-precision float to a float32
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h & 0x3ff)
+	switch exp {
+	case 0:
+		// zero or subnormal
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		// infinity or NaN
+		return math.Float32frombits(sign | 0x7f800000 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+}
@@ -0,0 +1,192 @@
+package msgp
+
+import (
+	"bytes"
+	enchex "encoding/hex"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCopyToCBOR(t *testing.T) {
+	var m []byte
+	m = AppendInt64(m, -1)
+	m = AppendUint64(m, 1000)
+	m = AppendString(m, "a")
+	m = AppendBytes(m, []byte{1, 2})
+	m = AppendArrayHeader(m, 2)
+	m = AppendBool(m, true)
+	m = AppendNil(m)
+	m = AppendMapHeader(m, 1)
+	m = AppendString(m, "b")
+	m = AppendFloat64(m, 1.5)
+	m = AppendTime(m, time.Unix(1363896240, 0))
+	m = AppendTimestamp(m, time.Unix(-2, 0))
+
+	var out bytes.Buffer
+	n, err := CopyToCBOR(&out, bytes.NewReader(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "20" + "1903e8" + "6161" + "420102" + "82f5f6" +
+		"a16162fb3ff8000000000000" + "c11a514b67b0" + "c121"
+	if got := enchex.EncodeToString(out.Bytes()); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if n != int64(out.Len()) {
+		t.Errorf("returned %d bytes written; wrote %d", n, out.Len())
+	}
+
+	ext, _ := AppendExtension(nil, &RawExtension{Type: 42, Data: []byte{1}})
+	for _, b := range [][]byte{AppendComplex64(nil, 1), ext} {
+		_, err := CopyToCBOR(&out, bytes.NewReader(b))
+		if _, ok := err.(*ErrUnsupportedCBOR); !ok {
+			t.Errorf("%x: got error %v", b, err)
+		}
+	}
+}
+
+func TestCopyFromCBOR(t *testing.T) {
+	tests := []struct {
+		cbor string
+		want []byte
+	}{
+		// examples from RFC 8949 appendix A
+		{"00", AppendUint64(nil, 0)},
+		{"1903e8", AppendUint64(nil, 1000)},
+		{"1bffffffffffffffff", AppendUint64(nil, math.MaxUint64)},
+		{"3863", AppendInt64(nil, -100)},
+		{"3b7fffffffffffffff", AppendInt64(nil, math.MinInt64)},
+		{"f93c00", AppendFloat32(nil, 1)},
+		{"f9c400", AppendFloat32(nil, -4)},
+		{"f90001", AppendFloat32(nil, 5.960464477539063e-8)},
+		{"f97c00", AppendFloat32(nil, float32(math.Inf(1)))},
+		{"fa47c35000", AppendFloat32(nil, 100000)},
+		{"fb3ff199999999999a", AppendFloat64(nil, 1.1)},
+		{"f4", AppendBool(nil, false)},
+		{"f5", AppendBool(nil, true)},
+		{"f6", AppendNil(nil)},
+		{"f7", AppendNil(nil)},
+		{"4401020304", AppendBytes(nil, []byte{1, 2, 3, 4})},
+		{"6449455446", AppendString(nil, "IETF")},
+		{"5f42010243030405ff", AppendBytes(nil, []byte{1, 2, 3, 4, 5})},
+		{"7f657374726561646d696e67ff", AppendString(nil, "streaming")},
+		{"c074323031332d30332d32315432303a30343a30305a", AppendTime(nil, time.Unix(1363896240, 0))},
+		{"c11a514b67b0", AppendTime(nil, time.Unix(1363896240, 0))},
+		{"c1fb41d452d9ec200000", AppendTime(nil, time.Unix(1363896240, 5e8))},
+		{"d74401020304", AppendBytes(nil, []byte{1, 2, 3, 4})},
+	}
+	for _, tt := range tests {
+		in, _ := enchex.DecodeString(tt.cbor)
+		var out bytes.Buffer
+		n, err := CopyFromCBOR(&out, bytes.NewReader(in))
+		if err != nil {
+			t.Errorf("%s: %v", tt.cbor, err)
+			continue
+		}
+		if !bytes.Equal(out.Bytes(), tt.want) {
+			t.Errorf("%s: got %x, want %x", tt.cbor, out.Bytes(), tt.want)
+		}
+		if n != int64(out.Len()) {
+			t.Errorf("%s: returned %d bytes written; wrote %d", tt.cbor, n, out.Len())
+		}
+	}
+
+	// nested and indefinite-length containers
+	var want []byte
+	want = AppendArrayHeader(want, 3)
+	want = AppendUint64(want, 1)
+	want = AppendArrayHeader(want, 2)
+	want = AppendUint64(want, 2)
+	want = AppendUint64(want, 3)
+	want = AppendArrayHeader(want, 2)
+	want = AppendUint64(want, 4)
+	want = AppendUint64(want, 5)
+	want = AppendMapHeader(want, 2)
+	want = AppendString(want, "a")
+	want = AppendUint64(want, 1)
+	want = AppendString(want, "b")
+	want = AppendArrayHeader(want, 2)
+	want = AppendUint64(want, 2)
+	want = AppendUint64(want, 3)
+	for _, s := range []string{
+		"83018202039f0405ff" + "a26161016162820203",
+		"9f018202039f0405ffff" + "bf61610161629f0203ffff",
+	} {
+		in, _ := enchex.DecodeString(s)
+		var out bytes.Buffer
+		if _, err := CopyFromCBOR(&out, bytes.NewReader(in)); err != nil {
+			t.Errorf("%s: %v", s, err)
+		} else if !bytes.Equal(out.Bytes(), want) {
+			t.Errorf("%s: got %x, want %x", s, out.Bytes(), want)
+		}
+	}
+
+	for _, s := range []string{
+		"3bffffffffffffffff", // below math.MinInt64
+		"f0",                 // unassigned simple value
+		"ff",                 // break outside of a container
+		"1c",                 // reserved additional information
+		"5f6161ff",           // text chunk in a byte string
+		"bf6161ff",           // map with a key and no value
+		"c06161",             // tag 0 on a non-date string
+		"8201",               // short array
+		"64494554",           // short string
+	} {
+		in, _ := enchex.DecodeString(s)
+		if _, err := CopyFromCBOR(&bytes.Buffer{}, bytes.NewReader(in)); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
+
+func TestCBORDepth(t *testing.T) {
+	// a long run of tags is not a stack overflow
+	in := append(bytes.Repeat([]byte{0xc6}, 1<<23), 0x01)
+	var out bytes.Buffer
+	if _, err := CopyFromCBOR(&out, bytes.NewReader(in)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), AppendUint64(nil, 1)) {
+		t.Errorf("got %x", out.Bytes())
+	}
+
+	in = append(bytes.Repeat([]byte{0x81}, cborMaxDepth+1), 0x01)
+	_, err := CopyFromCBOR(&bytes.Buffer{}, bytes.NewReader(in))
+	if _, ok := err.(LimitError); !ok {
+		t.Errorf("CopyFromCBOR: expected a LimitError; got %v", err)
+	}
+	_, err = CopyToCBOR(&bytes.Buffer{}, bytes.NewReader(nested(cborMaxDepth+1)))
+	if _, ok := err.(LimitError); !ok {
+		t.Errorf("CopyToCBOR: expected a LimitError; got %v", err)
+	}
+	if _, err = CopyToCBOR(&bytes.Buffer{}, bytes.NewReader(nested(cborMaxDepth))); err != nil {
+		t.Errorf("CopyToCBOR: %v", err)
+	}
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+	var m []byte
+	m = AppendMapHeader(m, 3)
+	m = AppendString(m, "name")
+	m = AppendString(m, "sensor-1")
+	m = AppendString(m, "readings")
+	m = AppendArrayHeader(m, 3)
+	m = AppendFloat32(m, 21.5)
+	m = AppendFloat64(m, -0.25)
+	m = AppendInt64(m, math.MinInt64)
+	m = AppendString(m, "raw")
+	m = AppendBytes(m, bytes.Repeat([]byte{0xab}, 300))
+	m = AppendUint64(m, math.MaxUint64)
+
+	var cb, out bytes.Buffer
+	if _, err := CopyToCBOR(&cb, bytes.NewReader(m)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CopyFromCBOR(&out, &cb); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), m) {
+		t.Errorf("got %x, want %x", out.Bytes(), m)
+	}
+}
@@ -0,0 +1,41 @@
+package msgp
+
+// CloneIntf returns a deep copy of i for the
+// types that ReadIntf and ReadIntfBytes produce:
+// []byte, []interface{}, map[string]interface{},
+// and *RawExtension are copied recursively, and
+// every other value is returned as-is.
+func CloneIntf(i interface{}) interface{} {
+	switch v := i.(type) {
+	case []byte:
+		if v == nil {
+			return v
+		}
+		return append([]byte{}, v...)
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		out := make([]interface{}, len(v))
+		for j := range v {
+			out[j] = CloneIntf(v[j])
+		}
+		return out
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		out := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			out[key] = CloneIntf(val)
+		}
+		return out
+	case *RawExtension:
+		if v == nil {
+			return v
+		}
+		return &RawExtension{Type: v.Type, Data: append([]byte(nil), v.Data...)}
+	default:
+		return i
+	}
+}
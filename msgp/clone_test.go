@@ -0,0 +1,67 @@
+package msgp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCloneIntf(t *testing.T) {
+	in := map[string]interface{}{
+		"bytes": []byte("hello"),
+		"array": []interface{}{"one", int64(2), []byte{3}},
+		"map":   map[string]interface{}{"inner": []byte{4}},
+		"ext":   &RawExtension{Type: 55, Data: []byte{5}},
+		"int":   int64(6),
+		"nil":   nil,
+	}
+	out, ok := CloneIntf(in).(map[string]interface{})
+	if !ok {
+		t.Fatalf("CloneIntf returned %T", CloneIntf(in))
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("clone is not equal:\nin:  %#v\nout: %#v", in, out)
+	}
+
+	// mutate everything in the clone
+	out["bytes"].([]byte)[0] = 'j'
+	out["array"].([]interface{})[2].([]byte)[0] = 0
+	out["map"].(map[string]interface{})["inner"] = nil
+	out["ext"].(*RawExtension).Data[0] = 0
+	out["new"] = true
+
+	if string(in["bytes"].([]byte)) != "hello" {
+		t.Error("bytes were shared")
+	}
+	if in["array"].([]interface{})[2].([]byte)[0] != 3 {
+		t.Error("array element was shared")
+	}
+	if in["map"].(map[string]interface{})["inner"] == nil {
+		t.Error("inner map was shared")
+	}
+	if in["ext"].(*RawExtension).Data[0] != 5 {
+		t.Error("extension data was shared")
+	}
+	if _, ok := in["new"]; ok {
+		t.Error("map was shared")
+	}
+}
+
+func TestCloneRawAndNumber(t *testing.T) {
+	r := Raw(AppendString(nil, "raw"))
+	rc := r.CloneMsg()
+	(*rc)[1] = 'x'
+	if s, _, err := ReadStringBytes(r); err != nil || s != "raw" {
+		t.Errorf("raw was shared: %q %v", s, err)
+	}
+	if (*Raw)(nil).CloneMsg() != nil {
+		t.Error("clone of nil *Raw should be nil")
+	}
+
+	var n Number
+	n.AsInt(7)
+	nc := n.CloneMsg()
+	nc.AsFloat64(1.5)
+	if i, ok := n.Int(); !ok || i != 7 {
+		t.Errorf("number was shared: %v", n)
+	}
+}
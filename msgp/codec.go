@@ -0,0 +1,42 @@
+package msgp
+
+// Codec adapts Marshal and Unmarshal to
+// the codec interfaces used by messaging and
+// RPC frameworks, so that it can be plugged
+// into them directly. It satisfies, for example,
+// the Encoder interface of the NATS client
+// (Encode and Decode, which take a subject),
+// go-micro's codec.Marshaler (Marshal, Unmarshal,
+// and String), and gRPC's encoding.Codec (Marshal,
+// Unmarshal, and Name).
+//
+// Values that implement Marshaler and Unmarshaler
+// use their generated methods; anything else is
+// handled by reflection, as with Marshal and Unmarshal.
+//
+// The zero value is ready to use.
+type Codec struct{}
+
+// CodecName is the name returned by
+// Codec.Name and Codec.String.
+const CodecName = "msgpack"
+
+// Marshal returns the encoding of v.
+func (Codec) Marshal(v interface{}) ([]byte, error) { return Marshal(v) }
+
+// Unmarshal decodes data into v, which
+// must be a pointer or implement Unmarshaler.
+func (Codec) Unmarshal(data []byte, v interface{}) error { return Unmarshal(data, v) }
+
+// Encode returns the encoding of v. The
+// subject is ignored.
+func (Codec) Encode(subject string, v interface{}) ([]byte, error) { return Marshal(v) }
+
+// Decode decodes data into v. The subject is ignored.
+func (Codec) Decode(subject string, data []byte, v interface{}) error { return Unmarshal(data, v) }
+
+// Name returns CodecName.
+func (Codec) Name() string { return CodecName }
+
+// String returns CodecName.
+func (Codec) String() string { return CodecName }
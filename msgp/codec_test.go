@@ -0,0 +1,53 @@
+package msgp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	// the interfaces that Codec is meant to satisfy
+	var (
+		_ interface {
+			Encode(string, interface{}) ([]byte, error)
+			Decode(string, []byte, interface{}) error
+		} = Codec{}
+		_ interface {
+			Marshal(interface{}) ([]byte, error)
+			Unmarshal([]byte, interface{}) error
+			Name() string
+			String() string
+		} = Codec{}
+	)
+
+	var c Codec
+	in := reflInner{Name: "x", Tags: []string{"a", "b"}}
+	b, err := c.Encode("subject", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out reflInner
+	if err = c.Decode("subject", b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != in.Name || len(out.Tags) != 2 {
+		t.Errorf("got %+v; want %+v", out, in)
+	}
+
+	// Marshaler and Unmarshaler are used directly
+	raw := Raw(AppendInt(nil, 5))
+	b, err = c.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r Raw
+	if err = c.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, raw) {
+		t.Errorf("got %x; want %x", []byte(r), []byte(raw))
+	}
+	if c.Name() != CodecName || c.String() != CodecName {
+		t.Errorf("unexpected name %q", c.Name())
+	}
+}
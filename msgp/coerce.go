@@ -0,0 +1,187 @@
+package msgp
+
+import (
+	"fmt"
+	"math"
+)
+
+// Coercions is a policy that sets which
+// conversions between types a Reader makes
+// when the object it is asked to read (e.g. by
+// ReadInt64, and so by generated DecodeMsg methods)
+// has a different type. Sharing one Coercions
+// between the Readers of a service (see
+// ReaderOptions) makes them all equally tolerant.
+//
+// The zero value is the default policy, which
+// makes no conversions other than reading 'int'
+// and 'uint' objects (and float32s as float64s)
+// interchangeably when the value fits.
+type Coercions struct {
+	// StrictSign rejects 'uint' objects in
+	// the methods that read signed integers,
+	// and 'int' objects in those that read
+	// unsigned integers, except for positive
+	// fixints, which belong to both.
+	StrictSign bool
+
+	// IntToFloat lets ReadFloat64 and ReadFloat32
+	// read integers, which are rounded to the
+	// nearest float if necessary.
+	IntToFloat bool
+
+	// FloatToInt lets the methods that read
+	// integers read floats holding whole numbers
+	// that fit; other floats cause a CoercionError.
+	FloatToInt bool
+
+	// BoolToInt lets the methods that read
+	// integers read bools as 0 and 1.
+	BoolToInt bool
+
+	// IntToBool lets ReadBool read integers,
+	// which are true unless they are zero.
+	IntToBool bool
+
+	// NilToZero lets the methods that read
+	// integers, floats and bools read nil as
+	// zero or false. (See NilAsEmpty for
+	// strings, maps and arrays.)
+	NilToZero bool
+}
+
+// SetCoercions sets the conversions between types
+// that the Reader makes; see Coercions.
+func (m *Reader) SetCoercions(c Coercions) { m.coerce = c }
+
+// CoercionError is returned when a value can't
+// be converted to the type being read under a
+// Reader's Coercions, e.g. a float holding 1.5
+// when reading an integer.
+type CoercionError struct {
+	Value interface{} // the decoded value
+	Type  Type        // the type being read
+	ctx   string
+}
+
+// Error implements the error interface
+func (c CoercionError) Error() string {
+	str := fmt.Sprintf("msgp: cannot convert %v to %s", c.Value, c.Type)
+	if c.ctx != "" {
+		str += " at " + c.ctx
+	}
+	return str
+}
+
+// Resumable is always 'true' for CoercionErrors
+func (c CoercionError) Resumable() bool { return true }
+
+func (c CoercionError) withContext(ctx string) error { c.ctx = addCtx(c.ctx, ctx); return c }
+
+// coerceZero skips the next object and returns
+// true if it is nil and the Coercions allow
+// reading it as zero
+func (m *Reader) coerceZero(lead byte) bool {
+	if lead != mnil || !m.coerce.NilToZero {
+		return false
+	}
+	m.R.Skip(1)
+	return true
+}
+
+// coerceInt reads the next object, which has the
+// prefix lead and isn't an integer, as an int64
+// if the Coercions allow it
+func (m *Reader) coerceInt(lead byte) (int64, error) {
+	c := &m.coerce
+	switch {
+	case m.coerceZero(lead):
+		return 0, nil
+	case c.BoolToInt && (lead == mtrue || lead == mfalse):
+		b, err := m.ReadBool()
+		if b {
+			return 1, err
+		}
+		return 0, err
+	case c.FloatToInt && (lead == mfloat32 || lead == mfloat64):
+		f, err := m.ReadFloat64()
+		if err != nil {
+			return 0, err
+		}
+		// float64(math.MaxInt64) rounds up to 2^63
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, CoercionError{Value: f, Type: IntType}
+		}
+		return int64(f), nil
+	}
+	return 0, badPrefix(IntType, lead)
+}
+
+// coerceUint is coerceInt for uint64s
+func (m *Reader) coerceUint(lead byte) (uint64, error) {
+	c := &m.coerce
+	switch {
+	case m.coerceZero(lead):
+		return 0, nil
+	case c.BoolToInt && (lead == mtrue || lead == mfalse):
+		b, err := m.ReadBool()
+		if b {
+			return 1, err
+		}
+		return 0, err
+	case c.FloatToInt && (lead == mfloat32 || lead == mfloat64):
+		f, err := m.ReadFloat64()
+		if err != nil {
+			return 0, err
+		}
+		// float64(math.MaxUint64) rounds up to 2^64
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+			return 0, CoercionError{Value: f, Type: UintType}
+		}
+		return uint64(f), nil
+	}
+	if isnfixint(lead) {
+		return 0, UintBelowZero{Value: int64(rnfixint(lead))}
+	}
+	return 0, badPrefix(UintType, lead)
+}
+
+// coerceFloat reads the next object, which has
+// the prefix lead and isn't a float, as a float64
+// if the Coercions allow it
+func (m *Reader) coerceFloat(lead byte, want Type) (float64, error) {
+	switch {
+	case m.coerceZero(lead):
+		return 0, nil
+	case m.coerce.IntToFloat:
+		switch getType(lead) {
+		case IntType:
+			i, err := m.ReadInt64()
+			return float64(i), err
+		case UintType:
+			u, err := m.ReadUint64()
+			return float64(u), err
+		}
+	}
+	return 0, badPrefix(want, lead)
+}
+
+// coerceBool reads the next object, which has
+// the prefix lead and isn't a bool, as a bool
+// if the Coercions allow it
+func (m *Reader) coerceBool(lead byte) (bool, error) {
+	switch {
+	case m.coerceZero(lead):
+		return false, nil
+	case m.coerce.IntToBool:
+		switch getType(lead) {
+		case IntType:
+			i, err := m.ReadInt64()
+			return i != 0, err
+		case UintType:
+			u, err := m.ReadUint64()
+			return u != 0, err
+		}
+	}
+	return false, badPrefix(BoolType, lead)
+}
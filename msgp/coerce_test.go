@@ -0,0 +1,90 @@
+package msgp
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestCoercions(t *testing.T) {
+	var b []byte
+	b = AppendNil(b)
+	b = AppendBool(b, true)
+	b = AppendFloat64(b, 42)
+	b = AppendFloat32(b, -3)
+	b = AppendInt64(b, 7)
+	b = AppendUint64(b, math.MaxUint64)
+	b = AppendInt64(b, 300)
+
+	all := Coercions{IntToFloat: true, FloatToInt: true, BoolToInt: true, IntToBool: true, NilToZero: true}
+	m := NewReaderWithOptions(bytes.NewReader(b), ReaderOptions{Coercions: all})
+	if i, err := m.ReadInt64(); err != nil || i != 0 {
+		t.Errorf("nil: got %d, %v", i, err)
+	}
+	if i, err := m.ReadUint8(); err != nil || i != 1 {
+		t.Errorf("bool: got %d, %v", i, err)
+	}
+	if i, err := m.ReadUint64(); err != nil || i != 42 {
+		t.Errorf("float64: got %d, %v", i, err)
+	}
+	if i, err := m.ReadInt32(); err != nil || i != -3 {
+		t.Errorf("float32: got %d, %v", i, err)
+	}
+	if f, err := m.ReadFloat32(); err != nil || f != 7 {
+		t.Errorf("int: got %v, %v", f, err)
+	}
+	if f, err := m.ReadFloat64(); err != nil || f != math.MaxUint64 {
+		t.Errorf("uint: got %v, %v", f, err)
+	}
+	if v, err := m.ReadBool(); err != nil || !v {
+		t.Errorf("int: got %v, %v", v, err)
+	}
+
+	// the default policy makes none of these conversions
+	m = NewReader(bytes.NewReader(b))
+	for _, read := range []func() error{
+		func() error { _, err := m.ReadInt64(); return err },
+		func() error { _, err := m.ReadUint64(); return err },
+		func() error { _, err := m.ReadInt64(); return err },
+		func() error { _, err := m.ReadUint64(); return err },
+		func() error { _, err := m.ReadFloat32(); return err },
+		func() error { _, err := m.ReadFloat64(); return err },
+		func() error { _, err := m.ReadBool(); return err },
+	} {
+		if err := read(); err == nil {
+			t.Error("expected an error")
+		} else if _, ok := err.(TypeError); !ok {
+			t.Errorf("expected a TypeError; got %v", err)
+		}
+		m.Skip()
+	}
+
+	// the int at the end of the stream
+	m = NewReaderWithOptions(bytes.NewReader(AppendInt(nil, 1)), ReaderOptions{Coercions: all})
+	if f, err := m.ReadFloat64(); err != nil || f != 1 {
+		t.Errorf("int: got %v, %v", f, err)
+	}
+
+	for _, f := range []float64{1.5, math.Inf(1), math.NaN(), -1, 1 << 64} {
+		m = NewReaderWithOptions(bytes.NewReader(AppendFloat64(nil, f)), ReaderOptions{Coercions: all})
+		if _, err := m.ReadUint64(); err == nil {
+			t.Errorf("%v: expected an error", f)
+		} else if _, ok := err.(CoercionError); !ok {
+			t.Errorf("%v: expected a CoercionError; got %v", f, err)
+		}
+	}
+
+	m = NewReaderWithOptions(bytes.NewReader(b[2:]), ReaderOptions{Coercions: Coercions{StrictSign: true}})
+	m.Skip()
+	m.Skip()
+	if i, err := m.ReadUint64(); err != nil || i != 7 {
+		t.Errorf("fixint: got %d, %v", i, err)
+	}
+	if _, err := m.ReadInt64(); err == nil {
+		t.Error("StrictSign: expected an error for a uint")
+	}
+	m.Skip()
+	if _, err := m.ReadUint64(); err == nil {
+		t.Error("StrictSign: expected an error for an int")
+	}
+}
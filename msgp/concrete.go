@@ -0,0 +1,197 @@
+package msgp
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// Values of interface types can be written along
+// with the name of their concrete type, so that they
+// can be decoded back into that type. The concrete
+// types have to be registered with RegisterConcrete
+// by both the writer and the reader. The value and
+// its name are written as a 2-element array,
+//
+//	[name, value]
+//
+// and a nil value is written as nil.
+
+var concreteTypes = struct {
+	sync.RWMutex
+	byName map[string]func() Decodable
+	byType map[reflect.Type]string
+}{
+	byName: make(map[string]func() Decodable),
+	byType: make(map[reflect.Type]string),
+}
+
+// RegisterConcrete registers the concrete type of
+// the values returned by factory under name, which
+// identifies it in the encoded data. factory should
+// return a new zero value (usually a pointer) each
+// time it is called. RegisterConcrete panics if name
+// or the type has already been registered.
+func RegisterConcrete(name string, factory func() Decodable) {
+	t := reflect.TypeOf(factory())
+	concreteTypes.Lock()
+	defer concreteTypes.Unlock()
+	if _, ok := concreteTypes.byName[name]; ok {
+		panic("msgp: RegisterConcrete() called with name " + strconv.Quote(name) + " more than once")
+	}
+	if _, ok := concreteTypes.byType[t]; ok {
+		panic("msgp: RegisterConcrete() called with type " + t.String() + " more than once")
+	}
+	concreteTypes.byName[name] = factory
+	concreteTypes.byType[t] = name
+}
+
+// concreteName returns the name that
+// the type of v was registered under
+func concreteName(v interface{}) (string, error) {
+	t := reflect.TypeOf(v)
+	concreteTypes.RLock()
+	name, ok := concreteTypes.byType[t]
+	if !ok && t.Kind() != reflect.Ptr {
+		name, ok = concreteTypes.byType[reflect.PtrTo(t)]
+	}
+	concreteTypes.RUnlock()
+	if !ok {
+		return "", &ErrUnsupportedType{T: t}
+	}
+	return name, nil
+}
+
+// newConcrete returns a new value of
+// the type registered under name
+func newConcrete(name string) (Decodable, error) {
+	concreteTypes.RLock()
+	f, ok := concreteTypes.byName[name]
+	concreteTypes.RUnlock()
+	if !ok {
+		return nil, UnknownConcreteError{Name: name}
+	}
+	return f(), nil
+}
+
+// UnknownConcreteError is returned when the
+// name of a value's type hasn't been registered
+// with RegisterConcrete. The value is skipped.
+type UnknownConcreteError struct {
+	Name string
+}
+
+// Error implements the error interface
+func (u UnknownConcreteError) Error() string {
+	return "msgp: unknown concrete type " + strconv.Quote(u.Name)
+}
+
+// Resumable is always 'true' for UnknownConcreteErrors
+func (u UnknownConcreteError) Resumable() bool { return true }
+
+// WriteConcrete writes v along with the name
+// of its type, which must have been registered
+// with RegisterConcrete. A nil v is written as nil.
+func (mw *Writer) WriteConcrete(v Encodable) error {
+	if v == nil {
+		return mw.WriteNil()
+	}
+	name, err := concreteName(v)
+	if err != nil {
+		return err
+	}
+	if err = mw.WriteArrayHeader(2); err != nil {
+		return err
+	}
+	if err = mw.WriteString(name); err != nil {
+		return err
+	}
+	return v.EncodeMsg(mw)
+}
+
+// ReadConcrete reads a value written by
+// WriteConcrete into a new value of the type
+// registered under its name. It returns nil
+// for nil.
+func (m *Reader) ReadConcrete() (Decodable, error) {
+	if m.IsNil() {
+		return nil, m.ReadNil()
+	}
+	sz, err := m.ReadArrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	if sz != 2 {
+		return nil, ArrayError{Wanted: 2, Got: sz}
+	}
+	name, err := m.ReadString()
+	if err != nil {
+		return nil, err
+	}
+	v, err := newConcrete(name)
+	if err != nil {
+		if serr := m.Skip(); serr != nil {
+			return nil, serr
+		}
+		return nil, err
+	}
+	if err = v.DecodeMsg(m); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// AppendConcrete appends v along with the
+// name of its type, which must have been
+// registered with RegisterConcrete. A nil
+// v is appended as nil.
+func AppendConcrete(b []byte, v Marshaler) ([]byte, error) {
+	if v == nil {
+		return AppendNil(b), nil
+	}
+	name, err := concreteName(v)
+	if err != nil {
+		return b, err
+	}
+	return v.MarshalMsg(AppendString(AppendArrayHeader(b, 2), name))
+}
+
+// ReadConcreteBytes reads a value written by
+// AppendConcrete (or WriteConcrete) from b into
+// a new value of the type registered under its
+// name, using its UnmarshalMsg method if it has
+// one, and returns the remaining bytes. It returns
+// nil for nil.
+func ReadConcreteBytes(b []byte) (Decodable, []byte, error) {
+	if IsNil(b) {
+		o, err := ReadNilBytes(b)
+		return nil, o, err
+	}
+	sz, o, err := ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, b, err
+	}
+	if sz != 2 {
+		return nil, b, ArrayError{Wanted: 2, Got: sz}
+	}
+	name, o, err := ReadStringZC(o)
+	if err != nil {
+		return nil, b, err
+	}
+	v, err := newConcrete(string(name))
+	if err != nil {
+		if o, serr := Skip(o); serr == nil {
+			return nil, o, err
+		}
+		return nil, b, err
+	}
+	if u, ok := v.(Unmarshaler); ok {
+		o, err = u.UnmarshalMsg(o)
+		return v, o, err
+	}
+	rd := NewReaderFromBytes(o)
+	if err = v.DecodeMsg(rd); err != nil {
+		return nil, b, err
+	}
+	return v, o[rd.Offset():], nil
+}
@@ -0,0 +1,109 @@
+package msgp
+
+import (
+	"bytes"
+	"testing"
+)
+
+// concreteStr is registered with both
+// the streaming and the []byte methods
+type concreteStr struct{ s string }
+
+func (c *concreteStr) EncodeMsg(w *Writer) error { return w.WriteString(c.s) }
+func (c *concreteStr) DecodeMsg(r *Reader) (err error) {
+	c.s, err = r.ReadString()
+	return
+}
+func (c *concreteStr) MarshalMsg(b []byte) ([]byte, error) { return AppendString(b, c.s), nil }
+func (c *concreteStr) UnmarshalMsg(b []byte) (o []byte, err error) {
+	c.s, o, err = ReadStringBytes(b)
+	return
+}
+
+func init() {
+	RegisterConcrete("test.str", func() Decodable { return new(concreteStr) })
+	RegisterConcrete("test.int", func() Decodable { return new(streamInt) })
+}
+
+func TestConcrete(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	vals := []Encodable{&concreteStr{s: "hello"}, &streamInt{v: -7}, nil}
+	for _, v := range vals {
+		if err := w.WriteConcrete(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteConcrete(Raw(AppendNil(nil))); err == nil {
+		t.Error("expected an error for an unregistered type")
+	}
+	w.Flush()
+
+	check := func(i int, v Decodable) {
+		switch i {
+		case 0:
+			if c, ok := v.(*concreteStr); !ok || c.s != "hello" {
+				t.Errorf("value %d: got %#v", i, v)
+			}
+		case 1:
+			if c, ok := v.(*streamInt); !ok || c.v != -7 {
+				t.Errorf("value %d: got %#v", i, v)
+			}
+		case 2:
+			if v != nil {
+				t.Errorf("value %d: got %#v", i, v)
+			}
+		}
+	}
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	for i := range vals {
+		v, err := r.ReadConcrete()
+		if err != nil {
+			t.Fatalf("value %d: %v", i, err)
+		}
+		check(i, v)
+	}
+	o := buf.Bytes()
+	for i := range vals {
+		var v Decodable
+		var err error
+		v, o, err = ReadConcreteBytes(o)
+		if err != nil {
+			t.Fatalf("value %d: %v", i, err)
+		}
+		check(i, v)
+	}
+	if len(o) != 0 {
+		t.Errorf("%d bytes left over", len(o))
+	}
+
+	b, err := AppendConcrete(nil, &concreteStr{s: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := AppendString(AppendString(AppendArrayHeader(nil, 2), "test.str"), "x"); !bytes.Equal(b, want) {
+		t.Errorf("got %x, want %x", b, want)
+	}
+	if v, _, err := ReadConcreteBytes(b); err != nil || v.(*concreteStr).s != "x" {
+		t.Errorf("AppendConcrete: got %v, %v", v, err)
+	}
+
+	// unknown names are skipped
+	b = AppendString(AppendArrayHeader(nil, 2), "test.unknown")
+	b = AppendMapHeader(b, 0)
+	b = AppendInt(b, 3)
+	_, o, err = ReadConcreteBytes(b)
+	if _, ok := err.(UnknownConcreteError); !ok {
+		t.Errorf("expected an UnknownConcreteError; got %v", err)
+	}
+	if i, _, err := ReadIntBytes(o); err != nil || i != 3 {
+		t.Errorf("after an UnknownConcreteError: got %d, %v", i, err)
+	}
+	r = NewReader(bytes.NewReader(b))
+	if _, err = r.ReadConcrete(); err == nil || !Resumable(err) {
+		t.Errorf("expected a resumable error; got %v", err)
+	}
+	if i, err := r.ReadInt(); err != nil || i != 3 {
+		t.Errorf("after an UnknownConcreteError: got %d, %v", i, err)
+	}
+}
@@ -0,0 +1,66 @@
+package msgp
+
+import (
+	"context"
+	"io"
+)
+
+// EncodeCtx is like Encode, but it checks ctx
+// every time it writes to w and stops with the
+// context's error once ctx is done. Large objects
+// are written in many pieces, so this bounds how
+// long a slow or stalled w can hold up a caller
+// whose context has been canceled. (A single write
+// that blocks is not interrupted.)
+func EncodeCtx(ctx context.Context, w io.Writer, e Encodable) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	wr := NewWriter(&ctxWriter{ctx: ctx, w: w})
+	err := e.EncodeMsg(wr)
+	if err == nil {
+		err = wr.Flush()
+	}
+	freeW(wr)
+	return err
+}
+
+// DecodeCtx is like Decode, but it checks ctx
+// every time it needs to read more data from r,
+// and stops with the context's error once ctx
+// is done. (A single read that blocks is not
+// interrupted.) The context's error is wrapped
+// like any other, so check for it with errors.Is.
+func DecodeCtx(ctx context.Context, r io.Reader, d Decodable) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rd := NewReader(&ctxReader{ctx: ctx, r: r})
+	err := withOffset(d.DecodeMsg(rd), rd.Offset())
+	freeR(rd)
+	return err
+}
+
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c *ctxWriter) Write(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(p)
+}
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,92 @@
+package msgp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// cancelAfter cancels a context after
+// n calls to Read or Write
+type cancelAfter struct {
+	n      int
+	cancel context.CancelFunc
+	r      io.Reader
+	w      io.Writer
+}
+
+func (c *cancelAfter) tick() {
+	if c.n--; c.n == 0 {
+		c.cancel()
+	}
+}
+
+func (c *cancelAfter) Read(p []byte) (int, error) {
+	c.tick()
+	if len(p) > 16 {
+		p = p[:16]
+	}
+	return c.r.Read(p)
+}
+
+func (c *cancelAfter) Write(p []byte) (int, error) {
+	c.tick()
+	return c.w.Write(p)
+}
+
+// manyStrings writes itself as that many
+// 500-byte strings
+type manyStrings int
+
+func (m manyStrings) EncodeMsg(w *Writer) error {
+	s := string(make([]byte, 500))
+	for i := 0; i < int(m); i++ {
+		if err := w.WriteString(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestEncodeDecodeCtx(t *testing.T) {
+	in := Raw(AppendBytes(nil, RandBytes(10000)))
+
+	var buf bytes.Buffer
+	if err := EncodeCtx(context.Background(), &buf, in); err != nil {
+		t.Fatal(err)
+	}
+	var out Raw
+	if err := DecodeCtx(context.Background(), bytes.NewReader(buf.Bytes()), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(in, out) {
+		t.Fatal("round trip failed")
+	}
+
+	// a context canceled part way through
+	ctx, cancel := context.WithCancel(context.Background())
+	src := &cancelAfter{n: 2, cancel: cancel, r: bytes.NewReader(buf.Bytes())}
+	err := DecodeCtx(ctx, src, &out)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("DecodeCtx: got %v; want context.Canceled", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	dst := &cancelAfter{n: 1, cancel: cancel, w: ioutil.Discard}
+	err = EncodeCtx(ctx, dst, manyStrings(100))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("EncodeCtx: got %v; want context.Canceled", err)
+	}
+
+	// an already-canceled context does nothing
+	dst = &cancelAfter{w: ioutil.Discard}
+	if err = EncodeCtx(ctx, dst, in); err != context.Canceled {
+		t.Errorf("got %v", err)
+	}
+	if err = DecodeCtx(ctx, bytes.NewReader(buf.Bytes()), &out); err != context.Canceled {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package msgp
+
+// openContainer is a map or array
+// whose header hasn't been written
+type openContainer struct {
+	pos int // offset in the buffer of the space reserved for the header
+	typ Type
+}
+
+// the space reserved for a header
+// is enough for the largest one
+const deferredHeaderSize = 5
+
+var errOddMap error = errContainer("msgp: EndMap called after a key without a value")
+
+// BeginMap begins a map whose size isn't
+// known yet. The entries are written as
+// usual (a key followed by its value) and
+// the map is ended with EndMap, which fills
+// in the header. Containers can be nested,
+// and can contain containers of known size.
+//
+// Until the outermost open container is
+// ended, everything written is kept in the
+// Writer's buffer, which grows as necessary,
+// so that the header can be filled in; Flush
+// returns ErrContainerOpen in the meantime.
+func (mw *Writer) BeginMap() error { return mw.begin(MapType) }
+
+// BeginArray begins an array whose size
+// isn't known yet, which is ended with
+// EndArray. See BeginMap.
+func (mw *Writer) BeginArray() error { return mw.begin(ArrayType) }
+
+func (mw *Writer) begin(t Type) error {
+	o, err := mw.require(deferredHeaderSize)
+	if err != nil {
+		return err
+	}
+	mw.open = append(mw.open, openContainer{pos: o, typ: t})
+	return nil
+}
+
+// EndMap ends the innermost container, which
+// must have been begun with BeginMap, and writes
+// its header. The number of entries is found by
+// counting the objects written since BeginMap,
+// which must be complete key/value pairs.
+func (mw *Writer) EndMap() error { return mw.end(MapType) }
+
+// EndArray ends the innermost container,
+// which must have been begun with BeginArray,
+// and writes its header. The number of
+// elements is found by counting the objects
+// written since BeginArray.
+func (mw *Writer) EndArray() error { return mw.end(ArrayType) }
+
+func (mw *Writer) end(t Type) error {
+	if mw.err != nil {
+		return mw.err
+	}
+	n := len(mw.open)
+	if n == 0 || mw.open[n-1].typ != t {
+		return ErrNoContainer
+	}
+	c := mw.open[n-1]
+	body := mw.buf[c.pos+deferredHeaderSize : mw.wloc]
+	var count uint64
+	for o := body; len(o) > 0; count++ {
+		var err error
+		if o, err = Skip(o); err != nil {
+			return err
+		}
+	}
+	if t == MapType {
+		if count%2 != 0 {
+			return errOddMap
+		}
+		count /= 2
+	}
+	var hdr [deferredHeaderSize]byte
+	var h []byte
+	if t == MapType {
+		h = AppendMapHeader(hdr[:0], uint32(count))
+	} else {
+		h = AppendArrayHeader(hdr[:0], uint32(count))
+	}
+	// move the body up against
+	// the header that was written
+	copy(mw.buf[c.pos:], h)
+	copy(mw.buf[c.pos+len(h):], body)
+	mw.wloc -= deferredHeaderSize - len(h)
+	mw.open = mw.open[:n-1]
+	return nil
+}
+
+// WriteArrayFromChan writes the values received
+// from ch, until it is closed, as an array whose
+// header is deferred as for BeginArray, so the
+// values don't have to be collected first. They
+// are still kept in the Writer's buffer until
+// ch is closed, since the header comes first.
+//
+// If a value can't be encoded, the partial array
+// is discarded and the error returned, leaving
+// the Writer as it was before the call; the rest
+// of ch is not received.
+func (mw *Writer) WriteArrayFromChan(ch <-chan Encodable) error {
+	depth := len(mw.open)
+	if err := mw.BeginArray(); err != nil {
+		return err
+	}
+	for e := range ch {
+		if err := e.EncodeMsg(mw); err != nil {
+			mw.discardOpen(depth)
+			return err
+		}
+	}
+	return mw.EndArray()
+}
+
+// discardOpen discards the open containers
+// past the first depth, and everything written
+// in them
+func (mw *Writer) discardOpen(depth int) {
+	if mw.err != nil || len(mw.open) <= depth {
+		return
+	}
+	mw.wloc = mw.open[depth].pos
+	mw.open = mw.open[:depth]
+}
+
+// grow grows the buffer so
+// that it has room for n more bytes
+func (mw *Writer) grow(n int) {
+	sz := 2 * len(mw.buf)
+	if sz < mw.wloc+n {
+		sz = mw.wloc + n
+	}
+	buf := make([]byte, sz)
+	copy(buf, mw.buf[:mw.wloc])
+	mw.buf = buf
+}
@@ -0,0 +1,24 @@
+//go:build go1.23
+
+package msgp
+
+import "iter"
+
+// WriteArrayFrom writes the values yielded by
+// seq as an array, like WriteArrayFromChan. If
+// a value can't be encoded, the iteration is
+// stopped, the partial array is discarded, and
+// the error is returned.
+func (mw *Writer) WriteArrayFrom(seq iter.Seq[Encodable]) error {
+	depth := len(mw.open)
+	if err := mw.BeginArray(); err != nil {
+		return err
+	}
+	for e := range seq {
+		if err := e.EncodeMsg(mw); err != nil {
+			mw.discardOpen(depth)
+			return err
+		}
+	}
+	return mw.EndArray()
+}
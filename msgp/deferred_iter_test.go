@@ -0,0 +1,54 @@
+//go:build go1.23
+
+package msgp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteArrayFrom(t *testing.T) {
+	seq := func(n int, bad bool) func(func(Encodable) bool) {
+		return func(yield func(Encodable) bool) {
+			for i := 0; i < n; i++ {
+				var e Encodable = &streamInt{v: int64(i)}
+				if bad && i == n/2 {
+					e = badEncodable{}
+				}
+				if !yield(e) {
+					return
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	w := NewWriterSize(&buf, 18)
+	w.BeginMap()
+	w.WriteString("a")
+	if err := w.WriteArrayFrom(seq(30, false)); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("b")
+	if err := w.WriteArrayFrom(seq(30, true)); err != errBadEncodable {
+		t.Errorf("expected errBadEncodable; got %v", err)
+	}
+	if err := w.WriteArrayFrom(seq(0, false)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.EndMap(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := AppendString(AppendMapHeader(nil, 2), "a")
+	want = AppendArrayHeader(want, 30)
+	for i := 0; i < 30; i++ {
+		want = AppendInt64(want, int64(i))
+	}
+	want = AppendArrayHeader(AppendString(want, "b"), 0)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got  %x\nwant %x", buf.Bytes(), want)
+	}
+}
@@ -0,0 +1,168 @@
+package msgp
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeferredContainers(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriterSize(&buf, 18)
+	w.WriteString("before")
+	if err := w.BeginMap(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if i%3 == 0 {
+			continue
+		}
+		w.WriteString(strings.Repeat("k", i+1))
+		w.WriteInt(i)
+	}
+	w.WriteString("list")
+	w.BeginArray()
+	w.WriteBytes(make([]byte, 100))
+	w.WriteArrayHeader(1)
+	w.WriteNil()
+	w.BeginArray()
+	if err := w.EndArray(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.EndMap(); err != ErrNoContainer {
+		t.Errorf("EndMap on an array: expected ErrNoContainer; got %v", err)
+	}
+	if err := w.Flush(); err != ErrContainerOpen {
+		t.Errorf("expected ErrContainerOpen; got %v", err)
+	}
+	if err := w.EndArray(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.EndMap(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.EndMap(); err != ErrNoContainer {
+		t.Errorf("expected ErrNoContainer; got %v", err)
+	}
+	w.WriteString("after")
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := AppendString(nil, "before")
+	want = AppendMapHeader(want, 14)
+	for i := 0; i < 20; i++ {
+		if i%3 == 0 {
+			continue
+		}
+		want = AppendString(want, strings.Repeat("k", i+1))
+		want = AppendInt(want, i)
+	}
+	want = AppendString(want, "list")
+	want = AppendArrayHeader(want, 3)
+	want = AppendBytes(want, make([]byte, 100))
+	want = AppendArrayHeader(want, 1)
+	want = AppendNil(want)
+	want = AppendArrayHeader(want, 0)
+	want = AppendString(want, "after")
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got  %x\nwant %x", buf.Bytes(), want)
+	}
+
+	// an odd number of objects in a map
+	w.Reset(&buf)
+	w.BeginMap()
+	w.WriteString("key")
+	if err := w.EndMap(); err == nil {
+		t.Error("expected an error for a key without a value")
+	}
+	w.Reset(&buf)
+	if err := w.Flush(); err != nil {
+		t.Errorf("Reset should discard open containers; got %v", err)
+	}
+}
+
+func TestDeferredLarge(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.BeginArray()
+	big := strings.Repeat("x", 10000)
+	for i := 0; i < 70000; i++ {
+		w.WriteInt(i)
+	}
+	w.WriteString(big)
+	w.Write(AppendBytes(nil, []byte(big)))
+	w.EndArray()
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	sz, o, err := ReadArrayHeaderBytes(buf.Bytes())
+	if err != nil || sz != 70002 {
+		t.Fatalf("got %d elements, %v", sz, err)
+	}
+	for i := 0; i < 70000; i++ {
+		var v int
+		if v, o, err = ReadIntBytes(o); err != nil || v != i {
+			t.Fatalf("element %d: got %d, %v", i, v, err)
+		}
+	}
+	s, o, err := ReadStringBytes(o)
+	if err != nil || s != big {
+		t.Fatalf("string: %v", err)
+	}
+	b, o, err := ReadBytesBytes(o, nil)
+	if err != nil || !reflect.DeepEqual(b, []byte(big)) || len(o) != 0 {
+		t.Fatalf("bytes: %v (%d left)", err, len(o))
+	}
+}
+
+// badEncodable writes part of
+// a container and then fails
+type badEncodable struct{}
+
+var errBadEncodable = errors.New("bad encodable")
+
+func (badEncodable) EncodeMsg(w *Writer) error {
+	w.BeginMap()
+	w.WriteString("partial")
+	return errBadEncodable
+}
+
+func TestWriteArrayFromChan(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriterSize(&buf, 18)
+	ch := make(chan Encodable)
+	go func() {
+		for i := 0; i < 40; i++ {
+			ch <- &streamInt{v: int64(i)}
+		}
+		close(ch)
+	}()
+	if err := w.WriteArrayFromChan(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	ch = make(chan Encodable, 3)
+	ch <- &streamInt{v: 1}
+	ch <- badEncodable{}
+	ch <- &streamInt{v: 2}
+	close(ch)
+	if err := w.WriteArrayFromChan(ch); err != errBadEncodable {
+		t.Errorf("expected errBadEncodable; got %v", err)
+	}
+	w.WriteNil()
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := AppendArrayHeader(nil, 40)
+	for i := 0; i < 40; i++ {
+		want = AppendInt64(want, int64(i))
+	}
+	want = AppendNil(want)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got  %x\nwant %x", buf.Bytes(), want)
+	}
+}
@@ -49,6 +49,14 @@ func isfixstr(b byte) bool {
 	return b&first3 == mfixstr
 }
 
+func isstr(b byte) bool {
+	return isfixstr(b) || b == mstr8 || b == mstr16 || b == mstr32
+}
+
+func isbin(b byte) bool {
+	return b == mbin8 || b == mbin16 || b == mbin32
+}
+
 func wfixint(u uint8) byte {
 	return u & last7
 }
@@ -0,0 +1,285 @@
+package msgp
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"time"
+)
+
+// Difference is a place where two
+// MessagePack documents compared by Diff
+// have different values.
+type Difference struct {
+	// Path is the list of map keys (strings)
+	// and array indexes (ints) leading to the
+	// value, in the form accepted by Get.
+	Path []interface{}
+
+	// Expected is the value in the first
+	// document, or nil if it is missing there.
+	Expected Raw
+
+	// Actual is the value in the second
+	// document, or nil if it is missing there.
+	Actual Raw
+}
+
+// String returns a description of the
+// difference, with the values as JSON.
+func (d Difference) String() string {
+	path := ctxString(d.Path)
+	if path == "" {
+		path = "(root)"
+	}
+	return path + ": expected " + diffJSON(d.Expected) + ", got " + diffJSON(d.Actual)
+}
+
+func diffJSON(r Raw) string {
+	if r == nil {
+		return "(missing)"
+	}
+	js, err := r.MarshalJSON()
+	if err != nil {
+		return "(" + err.Error() + ")"
+	}
+	return string(js)
+}
+
+// Diff compares the MessagePack objects a and b
+// value by value and returns the places where they
+// differ. Maps are compared by key, regardless of
+// the order of their entries, and numbers are compared
+// by value, regardless of their encoded width: int
+// and uint values are equal if they represent the
+// same integer, and float32 and float64 values are
+// equal if they represent the same number. Every
+// other value is equal only to the same type and
+// contents. When the values at a path differ in
+// type, the whole values are reported rather than
+// their contents.
+//
+// Both a and b must contain exactly one object. The
+// differences are returned in the order in which
+// they appear in a, followed by values that only
+// appear in b.
+func Diff(a, b []byte) ([]Difference, error) {
+	if _, err := Validate(a); err != nil {
+		return nil, err
+	}
+	if _, err := Validate(b); err != nil {
+		return nil, err
+	}
+	return diffNext(nil, nil, a, b)
+}
+
+// diffNext appends the differences between
+// the objects at the beginning of a and b
+func diffNext(out []Difference, path []interface{}, a, b []byte) ([]Difference, error) {
+	ta, tb := NextType(a), NextType(b)
+	switch {
+	case ta == MapType && tb == MapType:
+		return diffMaps(out, path, a, b)
+	case ta == ArrayType && tb == ArrayType:
+		return diffArrays(out, path, a, b)
+	}
+	d := Difference{Path: append([]interface{}(nil), path...)}
+	var err error
+	if d.Expected, _, err = diffSkip(a); err != nil {
+		return out, err
+	}
+	if d.Actual, _, err = diffSkip(b); err != nil {
+		return out, err
+	}
+	if ta == MapType || ta == ArrayType || tb == MapType || tb == ArrayType {
+		return append(out, d), nil
+	}
+	va, _, err := ReadIntfBytes(a)
+	if err != nil {
+		return out, err
+	}
+	vb, _, err := ReadIntfBytes(b)
+	if err != nil {
+		return out, err
+	}
+	if !diffEqual(va, vb) {
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func diffArrays(out []Difference, path []interface{}, a, b []byte) ([]Difference, error) {
+	na, a, err := ReadArrayHeaderBytes(a)
+	if err != nil {
+		return out, err
+	}
+	nb, b, err := ReadArrayHeaderBytes(b)
+	if err != nil {
+		return out, err
+	}
+	for i := 0; i < int(na) || i < int(nb); i++ {
+		d := Difference{Path: append(append([]interface{}(nil), path...), i)}
+		switch {
+		case i >= int(na):
+			d.Actual, b, err = diffSkip(b)
+		case i >= int(nb):
+			d.Expected, a, err = diffSkip(a)
+		default:
+			out, err = diffNext(out, d.Path, a, b)
+			if err != nil {
+				return out, err
+			}
+			_, a, err = diffSkip(a)
+			if err != nil {
+				return out, err
+			}
+			_, b, err = diffSkip(b)
+			continue
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// diffEntry is a map entry being compared
+type diffEntry struct {
+	key   string
+	value []byte // the encoded value and everything after it
+	seen  bool   // whether it has been matched
+}
+
+// diffEntries returns the entries of
+// the map at the beginning of b
+func diffEntries(b []byte) ([]diffEntry, error) {
+	sz, b, err := ReadMapHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	ents := make([]diffEntry, sz)
+	for i := range ents {
+		var key []byte
+		start := b
+		key, b, err = ReadMapKeyZC(b)
+		if err != nil {
+			// not a str or bin key; compare encodings
+			b, err = Skip(start)
+			if err != nil {
+				return nil, err
+			}
+			key = start[:len(start)-len(b)]
+		}
+		ents[i] = diffEntry{key: string(key), value: b}
+		b, err = Skip(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ents, nil
+}
+
+func diffMaps(out []Difference, path []interface{}, a, b []byte) ([]Difference, error) {
+	ea, err := diffEntries(a)
+	if err != nil {
+		return out, err
+	}
+	eb, err := diffEntries(b)
+	if err != nil {
+		return out, err
+	}
+	byKey := make(map[string]*diffEntry, len(eb))
+	for i := range eb {
+		if _, ok := byKey[eb[i].key]; !ok {
+			byKey[eb[i].key] = &eb[i]
+		}
+	}
+	for i := range ea {
+		sub := append(append([]interface{}(nil), path...), ea[i].key)
+		e, ok := byKey[ea[i].key]
+		if !ok || e.seen {
+			d := Difference{Path: sub}
+			d.Expected, _, err = diffSkip(ea[i].value)
+			if err != nil {
+				return out, err
+			}
+			out = append(out, d)
+			continue
+		}
+		e.seen = true
+		out, err = diffNext(out, sub, ea[i].value, e.value)
+		if err != nil {
+			return out, err
+		}
+	}
+	for i := range eb {
+		if eb[i].seen {
+			continue
+		}
+		d := Difference{Path: append(append([]interface{}(nil), path...), eb[i].key)}
+		d.Actual, _, err = diffSkip(eb[i].value)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// diffSkip returns the object at the
+// beginning of b and the bytes after it
+func diffSkip(b []byte) (Raw, []byte, error) {
+	o, err := Skip(b)
+	if err != nil {
+		return nil, b, err
+	}
+	return Raw(b[:len(b)-len(o)]), o, nil
+}
+
+// diffEqual compares two values returned by ReadIntfBytes
+func diffEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return a == b
+		case uint64:
+			return a >= 0 && uint64(a) == b
+		}
+		return false
+	case uint64:
+		switch b := b.(type) {
+		case uint64:
+			return a == b
+		case int64:
+			return b >= 0 && uint64(b) == a
+		}
+		return false
+	case float32:
+		return diffFloat(float64(a), b)
+	case float64:
+		return diffFloat(a, b)
+	case []byte:
+		b, ok := b.([]byte)
+		return ok && bytes.Equal(a, b)
+	case time.Time:
+		b, ok := b.(time.Time)
+		return ok && a.Equal(b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func diffFloat(a float64, b interface{}) bool {
+	var f float64
+	switch b := b.(type) {
+	case float32:
+		f = float64(b)
+	case float64:
+		f = b
+	default:
+		return false
+	}
+	// a NaN is the same as another NaN
+	return a == f || (math.IsNaN(a) && math.IsNaN(f))
+}
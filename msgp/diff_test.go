@@ -0,0 +1,89 @@
+package msgp
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	var a []byte
+	a = AppendMapHeader(a, 5)
+	a = AppendString(a, "same")
+	a = AppendArrayHeader(a, 2)
+	a = AppendInt(a, 1)
+	a = AppendFloat64(a, math.NaN())
+	a = AppendString(a, "num")
+	a = AppendInt(a, 5)
+	a = AppendString(a, "arr")
+	a = AppendArrayHeader(a, 3)
+	a = AppendString(a, "x")
+	a = AppendString(a, "y")
+	a = AppendString(a, "z")
+	a = AppendString(a, "gone")
+	a = AppendNil(a)
+	a = AppendString(a, "kind")
+	a = AppendMapHeader(a, 0)
+
+	var b []byte
+	b = AppendMapHeader(b, 5)
+	b = AppendString(b, "new")
+	b = AppendBool(b, true)
+	b = AppendString(b, "kind")
+	b = AppendArrayHeader(b, 0)
+	b = AppendString(b, "arr")
+	b = AppendArrayHeader(b, 2)
+	b = AppendString(b, "x")
+	b = AppendString(b, "Y")
+	b = AppendString(b, "num")
+	b = AppendFloat64(b, 5)
+	b = AppendString(b, "same")
+	b = AppendArrayHeader(b, 2)
+	b = AppendUint64(b, 1)
+	b = AppendFloat32(b, float32(math.NaN()))
+
+	diffs, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diffs {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`num: expected 5, got 5`,
+		`arr/1: expected "y", got "Y"`,
+		`arr/2: expected "z", got (missing)`,
+		`gone: expected null, got (missing)`,
+		`kind: expected {}, got []`,
+		`new: expected (missing), got true`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q", got)
+	}
+	if !reflect.DeepEqual(diffs[1].Path, []interface{}{"arr", 1}) {
+		t.Errorf("got path %v", diffs[1].Path)
+	}
+
+	diffs, err = Diff(a, a)
+	if err != nil || len(diffs) != 0 {
+		t.Errorf("comparing a to itself: %v %v", diffs, err)
+	}
+
+	// times are compared as times
+	t1 := time.Unix(10, 5)
+	diffs, err = Diff(AppendTime(nil, t1), AppendTimestamp(nil, t1))
+	if err != nil || len(diffs) != 0 {
+		t.Errorf("comparing times: %v %v", diffs, err)
+	}
+
+	diffs, err = Diff(AppendInt(nil, 1), AppendInt(nil, 2))
+	if err != nil || len(diffs) != 1 || diffs[0].String() != "(root): expected 1, got 2" {
+		t.Errorf("got %v %v", diffs, err)
+	}
+
+	if _, err := Diff(a[:len(a)-1], b); err != ErrShortBytes {
+		t.Errorf("got error %v", err)
+	}
+}
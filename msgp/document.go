@@ -0,0 +1,354 @@
+package msgp
+
+import (
+	"sync"
+	"time"
+)
+
+// Document is a read-only view of an encoded
+// object that is navigated lazily: each step
+// (Key or Index) only walks as much of the buffer
+// as it needs to, and the offsets of the elements
+// of every map and array that is stepped into are
+// cached, so that later lookups in the same container
+// don't walk it again. This makes it cheap to read a
+// few fields from a large message without decoding it:
+//
+//	doc := msgp.NewDocument(b)
+//	id, err := doc.Map().Key("user").Index(3).Int()
+//
+// Steps can be chained without checking for errors;
+// the first error is carried through the rest of the
+// chain and returned by the method that produces a
+// value (or by Err). A missing key or index results
+// in an error whose Cause is ErrNotFound, and stepping
+// into an object of the wrong type results in a TypeError.
+//
+// A Document and all of the Documents derived from
+// it share one cache, and are safe for concurrent use.
+// The underlying buffer must not be modified while
+// they are in use, and values returned by Bytes and
+// Raw point into it.
+type Document struct {
+	d   *docRoot
+	off int // offset of the object in d.b
+	err error
+}
+
+// docRoot is the buffer and the
+// cache shared by a tree of Documents
+type docRoot struct {
+	b []byte
+
+	mu    sync.Mutex
+	index map[int]*docIndex // by offset of the container
+}
+
+// docIndex holds the offsets of
+// the elements of a map or array
+type docIndex struct {
+	keys []string // map keys, pointing into b
+	vals []int    // offsets of the values
+	end  int      // offset just past the container
+}
+
+// NewDocument returns a Document for
+// the first object in b.
+func NewDocument(b []byte) Document {
+	return Document{d: &docRoot{b: b}}
+}
+
+// Err returns the error that
+// occurred while navigating to d,
+// if any.
+func (d Document) Err() error { return d.err }
+
+// Exists returns whether d refers
+// to an object. It is false if a key
+// or index along the way was not found,
+// or if any other error occurred.
+func (d Document) Exists() bool { return d.err == nil && len(d.bytes()) > 0 }
+
+// Type returns the type of the object,
+// or InvalidType if there is no object.
+func (d Document) Type() Type {
+	if !d.Exists() {
+		return InvalidType
+	}
+	return NextType(d.d.b[d.off:])
+}
+
+func (d Document) bytes() []byte {
+	if d.d == nil {
+		return nil
+	}
+	return d.d.b[d.off:]
+}
+
+// fail returns a Document carrying err
+func (d Document) fail(err error) Document {
+	d.err = err
+	return d
+}
+
+// expect returns d, or an error if
+// d is not an object of type t
+func (d Document) expect(t Type) Document {
+	if d.err != nil {
+		return d
+	}
+	if len(d.bytes()) == 0 {
+		return d.fail(ErrShortBytes)
+	}
+	if got := d.Type(); got != t {
+		return d.fail(TypeError{Method: t, Encoded: got})
+	}
+	return d
+}
+
+// Map returns d, or a Document carrying
+// a TypeError if d is not a map.
+func (d Document) Map() Document { return d.expect(MapType) }
+
+// Array returns d, or a Document carrying
+// a TypeError if d is not an array.
+func (d Document) Array() Document { return d.expect(ArrayType) }
+
+// index returns the cached index for the
+// container at d, building it if necessary
+func (d Document) index(t Type) (*docIndex, error) {
+	if d = d.expect(t); d.err != nil {
+		return nil, d.err
+	}
+	d.d.mu.Lock()
+	defer d.d.mu.Unlock()
+	if idx := d.d.index[d.off]; idx != nil {
+		return idx, nil
+	}
+	idx, err := buildDocIndex(d.d.b, d.off, t)
+	if err != nil {
+		return nil, err
+	}
+	if d.d.index == nil {
+		d.d.index = make(map[int]*docIndex)
+	}
+	d.d.index[d.off] = idx
+	return idx, nil
+}
+
+func buildDocIndex(b []byte, off int, t Type) (*docIndex, error) {
+	var (
+		sz  uint32
+		o   []byte
+		err error
+	)
+	if t == MapType {
+		sz, o, err = ReadMapHeaderBytes(b[off:])
+	} else {
+		sz, o, err = ReadArrayHeaderBytes(b[off:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	// every element takes at least
+	// one byte, so don't trust sz
+	// beyond what is left in b
+	if int(sz) > len(o) {
+		return nil, ErrShortBytes
+	}
+	idx := &docIndex{vals: make([]int, sz)}
+	if t == MapType {
+		idx.keys = make([]string, sz)
+	}
+	for i := range idx.vals {
+		if t == MapType {
+			var key []byte
+			key, o, err = ReadMapKeyZC(o)
+			if err != nil {
+				return nil, err
+			}
+			idx.keys[i] = UnsafeString(key)
+		}
+		idx.vals[i] = len(b) - len(o)
+		o, err = Skip(o)
+		if err != nil {
+			return nil, err
+		}
+	}
+	idx.end = len(b) - len(o)
+	return idx, nil
+}
+
+// Key returns the value for key in
+// the map d. If there is more than one
+// value for key, the first is used.
+func (d Document) Key(key string) Document {
+	if d.err != nil {
+		return d
+	}
+	idx, err := d.index(MapType)
+	if err != nil {
+		return d.fail(WrapError(err, key))
+	}
+	for i := range idx.keys {
+		if idx.keys[i] == key {
+			d.off = idx.vals[i]
+			return d
+		}
+	}
+	return d.fail(WrapError(ErrNotFound, key))
+}
+
+// Index returns element i of the array d.
+func (d Document) Index(i int) Document {
+	if d.err != nil {
+		return d
+	}
+	idx, err := d.index(ArrayType)
+	if err != nil {
+		return d.fail(WrapError(err, i))
+	}
+	if i < 0 || i >= len(idx.vals) {
+		return d.fail(WrapError(ErrNotFound, i))
+	}
+	d.off = idx.vals[i]
+	return d
+}
+
+// Len returns the number of
+// elements in the map or array d.
+func (d Document) Len() (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	switch t := d.Type(); t {
+	case MapType:
+		sz, _, err := ReadMapHeaderBytes(d.bytes())
+		return int(sz), err
+	case ArrayType:
+		sz, _, err := ReadArrayHeaderBytes(d.bytes())
+		return int(sz), err
+	default:
+		return 0, TypeError{Method: MapType, Encoded: t}
+	}
+}
+
+// Keys returns the keys of the map d.
+// The strings point into the underlying
+// buffer, and must not be retained once
+// it is modified.
+func (d Document) Keys() ([]string, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	idx, err := d.index(MapType)
+	if err != nil {
+		return nil, err
+	}
+	return idx.keys, nil
+}
+
+// Raw returns the encoded object.
+// It points into the underlying buffer.
+func (d Document) Raw() (Raw, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.bytes()) == 0 {
+		return nil, ErrShortBytes
+	}
+	var end int
+	d.d.mu.Lock()
+	if idx := d.d.index[d.off]; idx != nil {
+		end = idx.end
+	}
+	d.d.mu.Unlock()
+	if end == 0 {
+		o, err := Skip(d.bytes())
+		if err != nil {
+			return nil, err
+		}
+		end = len(d.d.b) - len(o)
+	}
+	return Raw(d.d.b[d.off:end]), nil
+}
+
+// Int returns the integer d.
+func (d Document) Int() (int64, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	i, _, err := ReadInt64Bytes(d.bytes())
+	return i, err
+}
+
+// Uint returns the unsigned integer d.
+func (d Document) Uint() (uint64, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	u, _, err := ReadUint64Bytes(d.bytes())
+	return u, err
+}
+
+// Float returns the float d, which
+// may be encoded as a float32 or float64.
+func (d Document) Float() (float64, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	f, _, err := ReadFloat64Bytes(d.bytes())
+	return f, err
+}
+
+// Bool returns the bool d.
+func (d Document) Bool() (bool, error) {
+	if d.err != nil {
+		return false, d.err
+	}
+	v, _, err := ReadBoolBytes(d.bytes())
+	return v, err
+}
+
+// Str returns the string d.
+func (d Document) Str() (string, error) {
+	if d.err != nil {
+		return "", d.err
+	}
+	s, _, err := ReadStringBytes(d.bytes())
+	return s, err
+}
+
+// Bytes returns the contents of the
+// bin object d, pointing into the
+// underlying buffer.
+func (d Document) Bytes() ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	v, _, err := ReadBytesZC(d.bytes())
+	return v, err
+}
+
+// Time returns the time d.
+func (d Document) Time() (time.Time, error) {
+	if d.err != nil {
+		return time.Time{}, d.err
+	}
+	t, _, err := ReadTimeBytes(d.bytes())
+	return t, err
+}
+
+// IsNil returns whether d is nil.
+func (d Document) IsNil() bool {
+	return d.Exists() && IsNil(d.bytes())
+}
+
+// Intf returns d decoded with ReadIntfBytes.
+func (d Document) Intf() (interface{}, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	v, _, err := ReadIntfBytes(d.bytes())
+	return v, err
+}
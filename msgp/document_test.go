@@ -0,0 +1,105 @@
+package msgp
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestDocument(t *testing.T) {
+	b := AppendMapHeader(nil, 3)
+	b = AppendString(b, "id")
+	b = AppendInt64(b, -7)
+	b = AppendString(b, "users")
+	b = AppendArrayHeader(b, 2)
+	b = AppendMapHeader(b, 2)
+	b = AppendString(b, "name")
+	b = AppendString(b, "ann")
+	b = AppendString(b, "score")
+	b = AppendFloat32(b, 1.5)
+	b = AppendMapHeader(b, 2)
+	b = AppendString(b, "name")
+	b = AppendString(b, "bob")
+	b = AppendString(b, "data")
+	b = AppendBytes(b, []byte{1, 2})
+	b = AppendString(b, "ok")
+	b = AppendBool(b, true)
+
+	doc := NewDocument(b)
+	if id, err := doc.Map().Key("id").Int(); err != nil || id != -7 {
+		t.Errorf("id: got %d, %v", id, err)
+	}
+	users := doc.Key("users").Array()
+	if n, err := users.Len(); err != nil || n != 2 {
+		t.Errorf("len: got %d, %v", n, err)
+	}
+	if s, err := users.Index(1).Key("name").Str(); err != nil || s != "bob" {
+		t.Errorf("name: got %q, %v", s, err)
+	}
+	if f, err := users.Index(0).Key("score").Float(); err != nil || f != 1.5 {
+		t.Errorf("score: got %g, %v", f, err)
+	}
+	if d, err := users.Index(1).Key("data").Bytes(); err != nil || len(d) != 2 {
+		t.Errorf("data: got %x, %v", d, err)
+	}
+	if ok, err := doc.Key("ok").Bool(); err != nil || !ok {
+		t.Errorf("ok: got %t, %v", ok, err)
+	}
+	keys, err := doc.Keys()
+	if err != nil || len(keys) != 3 || keys[1] != "users" {
+		t.Errorf("keys: got %q, %v", keys, err)
+	}
+
+	// Raw matches Get
+	r, err := users.Index(0).Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _, _ := Get(b, "users", 0)
+	if string(r) != string(want) {
+		t.Errorf("raw: got %x; want %x", []byte(r), []byte(want))
+	}
+	if r, err = doc.Raw(); err != nil || len(r) != len(b) {
+		t.Errorf("root raw: got %d bytes, %v", len(r), err)
+	}
+
+	// errors carry through the chain
+	missing := doc.Key("nope").Index(0).Key("x")
+	if missing.Exists() || Cause(missing.Err()) != ErrNotFound {
+		t.Errorf("expected ErrNotFound; got %v", missing.Err())
+	}
+	if _, err = users.Index(2).Int(); Cause(err) != ErrNotFound {
+		t.Errorf("expected ErrNotFound; got %v", err)
+	}
+	var terr TypeError
+	if _, err = doc.Key("id").Key("x").Int(); !errors.As(err, &terr) {
+		t.Errorf("expected TypeError; got %v", err)
+	}
+	if doc.Key("id").Array().Err() == nil {
+		t.Error("expected an error for Array on an int")
+	}
+	if (Document{}).Exists() || (Document{}).Key("x").Err() == nil {
+		t.Error("zero Document should be empty")
+	}
+}
+
+func TestDocumentConcurrent(t *testing.T) {
+	b := AppendArrayHeader(nil, 100)
+	for i := 0; i < 100; i++ {
+		b = AppendInt(b, i)
+	}
+	doc := NewDocument(b)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if v, err := doc.Index(i).Int(); err != nil || v != int64(i) {
+					t.Errorf("index %d: got %d, %v", i, v, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,248 @@
+package msgp
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	dumpMaxStr = 64 // longest string printed in full
+	dumpMaxBin = 32 // longest bin or ext data printed in full
+)
+
+// Fdump writes a description of every MessagePack
+// object in b to w, one line per object. Each line
+// shows the offset of the object in b, its type prefix,
+// the size of its header, and its value or size.
+// The elements of maps and arrays are indented below
+// their container. For example:
+//
+//	0000  fixmap hdr=1 len=1
+//	0001    key: fixstr hdr=1 len=1 "a"
+//	0003    val: fixarray hdr=1 len=2
+//	0004      [0]: fixint hdr=1 1
+//	0005      [1]: float64 hdr=1 1.5
+//
+// Long strings and binary data are abbreviated. If
+// b is malformed, Fdump describes the problem on the
+// last line and returns the error.
+func Fdump(w io.Writer, b []byte) error {
+	d := dumper{w: w, b: b, width: 4}
+	if len(b) > 0xffff {
+		d.width = 8
+	}
+	var err error
+	for off := 0; off < len(b) && err == nil; {
+		off, err = d.dump(off, 0, "")
+	}
+	if d.err != nil {
+		return d.err
+	}
+	return err
+}
+
+type dumper struct {
+	w     io.Writer
+	b     []byte
+	width int   // hex digits in offsets
+	err   error // the first error writing to w
+}
+
+func (d *dumper) line(off, depth int, label string, format string, args ...interface{}) {
+	if d.err != nil {
+		return
+	}
+	if label != "" {
+		label += ": "
+	}
+	_, d.err = fmt.Fprintf(d.w, "%0*x  %*s%s"+format+"\n",
+		append([]interface{}{d.width, off, 2 * depth, "", label}, args...)...)
+}
+
+// dump describes the object at d.b[off:]
+// and returns the offset of the next one
+func (d *dumper) dump(off, depth int, label string) (int, error) {
+	b := d.b[off:]
+	sz, asz, err := getSize(b)
+	if err == nil && sz > uintptr(len(b)) {
+		err = ErrShortBytes
+	}
+	if err != nil {
+		d.line(off, depth, label, "error: %s", err)
+		return off, err
+	}
+	lead := b[0]
+	name := prefixName(lead)
+	spec := &sizes[lead]
+	hdr := 1
+	switch spec.typ {
+	case MapType, ArrayType:
+		hdr = int(sz)
+	case StrType, BinType:
+		if spec.extra != constsize {
+			hdr = int(spec.size)
+		}
+	case ExtensionType:
+		hdr = 2
+		if spec.extra != constsize {
+			hdr = int(spec.size)
+		}
+	}
+	next := off + int(sz)
+
+	switch spec.typ {
+	case MapType:
+		d.line(off, depth, label, "%s hdr=%d len=%d", name, hdr, asz/2)
+		for i := uintptr(0); i < asz && err == nil; i++ {
+			sub := "key"
+			if i%2 == 1 {
+				sub = "val"
+			}
+			next, err = d.dump(next, depth+1, sub)
+		}
+		return next, err
+	case ArrayType:
+		d.line(off, depth, label, "%s hdr=%d len=%d", name, hdr, asz)
+		for i := uintptr(0); i < asz && err == nil; i++ {
+			next, err = d.dump(next, depth+1, "["+strconv.FormatUint(uint64(i), 10)+"]")
+		}
+		return next, err
+	case StrType:
+		s := b[hdr:sz]
+		if len(s) > dumpMaxStr {
+			d.line(off, depth, label, "%s hdr=%d len=%d %q...", name, hdr, len(s), s[:dumpMaxStr])
+		} else {
+			d.line(off, depth, label, "%s hdr=%d len=%d %q", name, hdr, len(s), s)
+		}
+	case BinType:
+		d.line(off, depth, label, "%s hdr=%d len=%d %s", name, hdr, sz-uintptr(hdr), dumpHex(b[hdr:sz]))
+	case ExtensionType:
+		typ := int8(b[hdr-1])
+		data := b[hdr:sz]
+		var t time.Time
+		var terr error
+		switch typ {
+		case TimeExtension:
+			t, _, terr = ReadTimeBytes(b)
+		case TimestampExtension:
+			t, _, terr = ReadTimestampBytes(b)
+		default:
+			terr = fatal
+		}
+		if terr == nil {
+			d.line(off, depth, label, "%s hdr=%d type=%d len=%d %s", name, hdr, typ, len(data), t.UTC().Format(time.RFC3339Nano))
+		} else {
+			d.line(off, depth, label, "%s hdr=%d type=%d len=%d %s", name, hdr, typ, len(data), dumpHex(data))
+		}
+	case IntType:
+		i, _, _ := ReadInt64Bytes(b)
+		d.line(off, depth, label, "%s hdr=%d %d", name, hdr, i)
+	case UintType:
+		u, _, _ := ReadUint64Bytes(b)
+		d.line(off, depth, label, "%s hdr=%d %d", name, hdr, u)
+	case Float32Type:
+		f, _, _ := ReadFloat32Bytes(b)
+		d.line(off, depth, label, "%s hdr=%d %s", name, hdr, strconv.FormatFloat(float64(f), 'g', -1, 32))
+	case Float64Type:
+		f, _, _ := ReadFloat64Bytes(b)
+		d.line(off, depth, label, "%s hdr=%d %s", name, hdr, strconv.FormatFloat(f, 'g', -1, 64))
+	default: // nil and bool
+		d.line(off, depth, label, "%s hdr=%d", name, hdr)
+	}
+	return next, nil
+}
+
+// dumpHex formats data in hex,
+// abbreviating it if it is long
+func dumpHex(data []byte) string {
+	if len(data) > dumpMaxBin {
+		return fmt.Sprintf("%x...", data[:dumpMaxBin])
+	}
+	return fmt.Sprintf("%x", data)
+}
+
+// prefixName returns the name that the
+// MessagePack specification gives to
+// the format that begins with lead
+func prefixName(lead byte) string {
+	switch {
+	case isfixint(lead):
+		return "fixint"
+	case isnfixint(lead):
+		return "negative fixint"
+	case isfixmap(lead):
+		return "fixmap"
+	case isfixarray(lead):
+		return "fixarray"
+	case isfixstr(lead):
+		return "fixstr"
+	}
+	switch lead {
+	case mnil:
+		return "nil"
+	case mfalse:
+		return "false"
+	case mtrue:
+		return "true"
+	case mbin8:
+		return "bin8"
+	case mbin16:
+		return "bin16"
+	case mbin32:
+		return "bin32"
+	case mext8:
+		return "ext8"
+	case mext16:
+		return "ext16"
+	case mext32:
+		return "ext32"
+	case mfloat32:
+		return "float32"
+	case mfloat64:
+		return "float64"
+	case muint8:
+		return "uint8"
+	case muint16:
+		return "uint16"
+	case muint32:
+		return "uint32"
+	case muint64:
+		return "uint64"
+	case mint8:
+		return "int8"
+	case mint16:
+		return "int16"
+	case mint32:
+		return "int32"
+	case mint64:
+		return "int64"
+	case mfixext1:
+		return "fixext1"
+	case mfixext2:
+		return "fixext2"
+	case mfixext4:
+		return "fixext4"
+	case mfixext8:
+		return "fixext8"
+	case mfixext16:
+		return "fixext16"
+	case mstr8:
+		return "str8"
+	case mstr16:
+		return "str16"
+	case mstr32:
+		return "str32"
+	case marray16:
+		return "array16"
+	case marray32:
+		return "array32"
+	case mmap16:
+		return "map16"
+	case mmap32:
+		return "map32"
+	default:
+		return "(never used)"
+	}
+}
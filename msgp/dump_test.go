@@ -0,0 +1,53 @@
+package msgp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFdump(t *testing.T) {
+	var b []byte
+	b = AppendMapHeader(b, 2)
+	b = AppendString(b, "a")
+	b = AppendArrayHeader(b, 4)
+	b = AppendInt(b, -3)
+	b = AppendUint16(b, 300)
+	b = AppendFloat64(b, 1.5)
+	b = AppendNil(b)
+	b = AppendString(b, "bin")
+	b = AppendBytes(b, []byte{0xde, 0xad})
+	b = AppendTimestamp(b, time.Unix(1, 0))
+	b = AppendString(b, strings.Repeat("x", 300))
+
+	var buf bytes.Buffer
+	if err := Fdump(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	want := `0000  fixmap hdr=1 len=2
+0001    key: fixstr hdr=1 len=1 "a"
+0003    val: fixarray hdr=1 len=4
+0004      [0]: negative fixint hdr=1 -3
+0005      [1]: uint16 hdr=1 300
+0008      [2]: float64 hdr=1 1.5
+0011      [3]: nil hdr=1
+0012    key: fixstr hdr=1 len=3 "bin"
+0016    val: bin8 hdr=2 len=2 dead
+001a  fixext4 hdr=2 type=-1 len=4 1970-01-01T00:00:01Z
+0020  str16 hdr=3 len=300 "` + strings.Repeat("x", 64) + `"...
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// truncated input
+	buf.Reset()
+	err := Fdump(&buf, b[:10])
+	if err != ErrShortBytes {
+		t.Errorf("got error %v", err)
+	}
+	if !strings.HasSuffix(buf.String(), "0008      [2]: error: "+ErrShortBytes.Error()+"\n") {
+		t.Errorf("got\n%s", buf.String())
+	}
+}
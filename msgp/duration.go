@@ -0,0 +1,40 @@
+package msgp
+
+import (
+	"time"
+)
+
+// Durations are encoded as an integer number
+// of nanoseconds, which is how the code generator
+// encodes time.Duration fields. Any integer may be
+// read as a duration, as long as it fits in an int64.
+
+// DurationSize is the maximum encoded size of a time.Duration.
+const DurationSize = Int64Size
+
+// AppendDuration appends d to b as an integer number of nanoseconds.
+func AppendDuration(b []byte, d time.Duration) []byte {
+	return AppendInt64(b, int64(d))
+}
+
+// ReadDurationBytes reads a time.Duration
+// from 'b' and returns the remaining bytes.
+// Possible errors:
+// - ErrShortBytes (too few bytes)
+// - TypeError{} (not an integer)
+// - UintOverflow{} (an unsigned integer too large for an int64)
+func ReadDurationBytes(b []byte) (time.Duration, []byte, error) {
+	i, o, err := ReadInt64Bytes(b)
+	return time.Duration(i), o, err
+}
+
+// WriteDuration writes d as an integer number of nanoseconds.
+func (mw *Writer) WriteDuration(d time.Duration) error {
+	return mw.WriteInt64(int64(d))
+}
+
+// ReadDuration reads a time.Duration from the reader.
+func (m *Reader) ReadDuration() (time.Duration, error) {
+	i, err := m.ReadInt64()
+	return time.Duration(i), err
+}
@@ -0,0 +1,49 @@
+package msgp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	durs := []time.Duration{0, time.Nanosecond, -time.Hour, 1<<63 - 1, -1 << 63}
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	var b []byte
+	for _, d := range durs {
+		b = AppendDuration(b, d)
+		wr.WriteDuration(d)
+	}
+	wr.Flush()
+	if !bytes.Equal(b, buf.Bytes()) {
+		t.Fatal("AppendDuration and WriteDuration disagree")
+	}
+
+	rd := NewReader(&buf)
+	for _, d := range durs {
+		got, err := rd.ReadDuration()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != d {
+			t.Errorf("ReadDuration: got %v; want %v", got, d)
+		}
+		got, b, err = ReadDurationBytes(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != d {
+			t.Errorf("ReadDurationBytes: got %v; want %v", got, d)
+		}
+	}
+
+	// any integer that fits is accepted
+	got, _, err := ReadDurationBytes(AppendUint8(nil, 200))
+	if err != nil || got != 200 {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if _, _, err = ReadDurationBytes(AppendString(nil, "1s")); err == nil {
+		t.Error("expected an error reading a string as a duration")
+	}
+}
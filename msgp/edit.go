@@ -2,6 +2,7 @@ package msgp
 
 import (
 	"math"
+	"reflect"
 )
 
 // Locate returns a []byte pointing to the field
@@ -70,6 +71,309 @@ func HasKey(key string, raw []byte) bool {
 	return false
 }
 
+// Get walks 'path' through the object in 'b' and
+// returns the object it leads to along with its type.
+// Each element of 'path' is either a string, which
+// selects the value with that key in a map, or an
+// integer, which selects the element at that index in
+// an array. Get does not decode the siblings of the
+// objects along the path, and the returned Raw points
+// into 'b'. With an empty path, Get returns the first
+// object in 'b'.
+//
+// If a key or index doesn't exist, Get returns an error
+// whose Cause is ErrNotFound. If an object along the path
+// is not of the type the path expects, the error is a
+// TypeError.
+func Get(b []byte, path ...interface{}) (Raw, Type, error) {
+	o, rest, err := walkPath(b, path)
+	if err != nil {
+		return nil, InvalidType, err
+	}
+	r := Raw(o[:len(o)-len(rest)])
+	return r, NextType(r), nil
+}
+
+// GetMany is like calling Get with each of 'paths',
+// except that it finds all of the objects in a single
+// pass over 'b', stopping once the last one has been
+// found. The returned slice holds the object for each
+// path, in the same order as 'paths'.
+//
+// Unlike Get, a key or index that doesn't exist is
+// not an error: the Raw for that path is left nil (an
+// encoded nil is not an empty Raw, so the two can be
+// told apart). An object along a path that is not of
+// the type the path expects is still an error, as is
+// malformed input that is reached before the last
+// object has been found.
+func GetMany(b []byte, paths [][]interface{}) ([]Raw, error) {
+	g := getMany{paths: paths, out: make([]Raw, len(paths)), left: len(paths)}
+	if g.left == 0 {
+		return g.out, nil
+	}
+	idx := make([]int, len(paths))
+	for i := range idx {
+		idx[i] = i
+	}
+	if _, err := g.scan(b, 0, idx); err != nil {
+		return nil, err
+	}
+	return g.out, nil
+}
+
+type getMany struct {
+	paths [][]interface{}
+	out   []Raw
+	left  int // paths that haven't been found or ruled out
+}
+
+// scan walks the object at the beginning of 'o',
+// which all of the paths in 'idx' lead to after
+// 'depth' elements, and returns the bytes that
+// follow it. Once every path is done, scan returns
+// right away, and the bytes it returns are unspecified.
+func (g *getMany) scan(o []byte, depth int, idx []int) ([]byte, error) {
+	var leaves, inner []int
+	for _, i := range idx {
+		if len(g.paths[i]) == depth {
+			leaves = append(leaves, i)
+		} else {
+			inner = append(inner, i)
+		}
+	}
+	var rest []byte
+	var err error
+	if len(inner) == 0 {
+		rest, err = Skip(o)
+	} else {
+		rest, err = g.walk(o, depth, inner)
+	}
+	if err != nil || g.left == 0 {
+		return rest, err
+	}
+	for _, i := range leaves {
+		g.out[i] = Raw(o[:len(o)-len(rest)])
+		g.left--
+	}
+	return rest, nil
+}
+
+// walk steps through the map or array at the
+// beginning of 'o', scanning the values that
+// the paths in 'idx' select
+func (g *getMany) walk(o []byte, depth int, idx []int) ([]byte, error) {
+	t := NextType(o)
+	isMap := t == MapType
+	for _, i := range idx {
+		p := g.paths[i][depth]
+		var err error
+		if _, ok := p.(string); ok {
+			if t != MapType {
+				_, _, err = ReadMapHeaderBytes(o)
+			}
+		} else if _, ok := pathIndex(p); ok {
+			if t != ArrayType {
+				_, _, err = ReadArrayHeaderBytes(o)
+			}
+		} else {
+			err = &ErrUnsupportedType{T: reflect.TypeOf(p)}
+		}
+		if err != nil {
+			return nil, WrapError(err, g.paths[i][:depth+1]...)
+		}
+	}
+	where := g.paths[idx[0]][:depth]
+	var sz uint32
+	var err error
+	if isMap {
+		sz, o, err = ReadMapHeaderBytes(o)
+	} else {
+		sz, o, err = ReadArrayHeaderBytes(o)
+	}
+	if err != nil {
+		return nil, g.wrap(err, where)
+	}
+	found := make([]bool, len(idx))
+	var sub []int
+	var key []byte
+	for n := uint32(0); n < sz; n++ {
+		sub = sub[:0]
+		if isMap {
+			if key, o, err = ReadMapKeyZC(o); err != nil {
+				return nil, g.wrap(err, where)
+			}
+		}
+		for j, i := range idx {
+			if found[j] {
+				continue
+			}
+			p := g.paths[i][depth]
+			if isMap {
+				found[j] = p.(string) == UnsafeString(key)
+			} else {
+				k, _ := pathIndex(p)
+				found[j] = k == int64(n)
+			}
+			if found[j] {
+				sub = append(sub, i)
+			}
+		}
+		if len(sub) > 0 {
+			o, err = g.scan(o, depth+1, sub)
+			if err != nil || g.left == 0 {
+				return o, err
+			}
+		} else if o, err = Skip(o); err != nil {
+			return nil, g.wrap(err, where)
+		}
+	}
+	// what is left doesn't exist
+	for j := range idx {
+		if !found[j] {
+			g.left--
+		}
+	}
+	return o, nil
+}
+
+func (g *getMany) wrap(err error, path []interface{}) error {
+	if len(path) == 0 {
+		return err
+	}
+	return WrapError(err, path...)
+}
+
+// Set replaces the object that 'path' leads to
+// in 'b' with 'value', which must be a single encoded
+// object (an empty Raw is written as nil), and returns
+// the new buffer. Paths are as described for Get; with
+// an empty path, the first object in 'b' is replaced.
+// Nothing else in 'b' changes, since the enclosing
+// containers only record the number of their elements.
+//
+// Like Replace, Set may modify 'b' and use its full
+// capacity, so the returned []byte may point to the
+// same memory as 'b'. If an error is returned, 'b'
+// is unchanged.
+func Set(b []byte, value Raw, path ...interface{}) ([]byte, error) {
+	if len(value) == 0 {
+		value = Raw{mnil}
+	} else if rest, err := Skip(value); err != nil {
+		return b, err
+	} else if len(rest) != 0 {
+		return b, ErrTrailingBytes
+	}
+	o, rest, err := walkPath(b, path)
+	if err != nil {
+		return b, err
+	}
+	start := len(b) - len(o)
+	end := len(b) - len(rest)
+	return replace(b, start, end, value, true), nil
+}
+
+// walkPath returns the bytes beginning with
+// the object that 'path' leads to in 'b', and
+// the bytes following that object
+func walkPath(b []byte, path []interface{}) (o, rest []byte, err error) {
+	o = b
+	for i, p := range path {
+		if key, ok := p.(string); ok {
+			o, err = getKey(o, key)
+		} else if idx, ok := pathIndex(p); ok {
+			o, err = getIndex(o, idx)
+		} else {
+			err = &ErrUnsupportedType{T: reflect.TypeOf(p)}
+		}
+		if err != nil {
+			return nil, nil, WrapError(err, path[:i+1]...)
+		}
+	}
+	rest, err = Skip(o)
+	if err != nil {
+		return nil, nil, WrapError(err, path...)
+	}
+	return o, rest, nil
+}
+
+// pathIndex returns p as an array
+// index, if it is an integer
+func pathIndex(p interface{}) (int64, bool) {
+	switch p := p.(type) {
+	case int:
+		return int64(p), true
+	case int8:
+		return int64(p), true
+	case int16:
+		return int64(p), true
+	case int32:
+		return int64(p), true
+	case int64:
+		return p, true
+	case uint:
+		return int64(p), true
+	case uint8:
+		return int64(p), true
+	case uint16:
+		return int64(p), true
+	case uint32:
+		return int64(p), true
+	case uint64:
+		if p > math.MaxInt64 {
+			return -1, true
+		}
+		return int64(p), true
+	default:
+		return 0, false
+	}
+}
+
+// getKey returns the bytes beginning
+// with the value for 'key' in the map
+// at the beginning of 'b'
+func getKey(b []byte, key string) ([]byte, error) {
+	sz, o, err := ReadMapHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	var field []byte
+	for i := uint32(0); i < sz; i++ {
+		field, o, err = ReadMapKeyZC(o)
+		if err != nil {
+			return nil, err
+		}
+		if UnsafeString(field) == key {
+			return o, nil
+		}
+		o, err = Skip(o)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// getIndex returns the bytes beginning
+// with the element at index 'idx' in the
+// array at the beginning of 'b'
+func getIndex(b []byte, idx int64) ([]byte, error) {
+	sz, o, err := ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if idx < 0 || idx >= int64(sz) {
+		return nil, ErrNotFound
+	}
+	for i := int64(0); i < idx; i++ {
+		o, err = Skip(o)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
 func replace(raw []byte, start int, end int, val []byte, inplace bool) []byte {
 	ll := end - start // length of segment to replace
 	lv := len(val)
@@ -240,3 +544,113 @@ func resizeMap(raw []byte, delta int64) []byte {
 		return append(n, raw[1:]...)
 	}
 }
+
+// MergeMaps merges the entries of the map in
+// 'src' into the map in 'dst' and returns the
+// result as a new map. The value for a key that
+// is in both maps is taken from 'src', and stays
+// in the position it had in 'dst'; keys that are
+// only in 'src' follow, in their order in 'src'.
+// The merge is shallow: a map value in 'src'
+// replaces the whole map for that key in 'dst'.
+// A nil 'dst' or 'src' is treated as an empty map.
+//
+// Keys are compared by value, so the same string
+// encoded with different string prefixes (or as
+// a bin object) is the same key. Neither input is
+// modified, and the values are copied without
+// being decoded.
+func MergeMaps(dst, src []byte) ([]byte, error) {
+	dents, err := mapEntries(dst)
+	if err != nil {
+		return nil, WrapError(err, "dst")
+	}
+	sents, err := mapEntries(src)
+	if err != nil {
+		return nil, WrapError(err, "src")
+	}
+	byKey := make(map[mergeKey]int, len(sents))
+	for i := range sents {
+		// the last of a duplicated key wins,
+		// as it would when decoding
+		byKey[sents[i].id] = i
+	}
+	used := make([]bool, len(sents))
+	merged := make([]mapEntry, 0, len(dents)+len(sents))
+	for _, e := range dents {
+		if i, ok := byKey[e.id]; ok {
+			if used[i] {
+				// a duplicate in dst of a key
+				// that has already been merged
+				continue
+			}
+			used[i] = true
+			e.val = sents[i].val
+		}
+		merged = append(merged, e)
+	}
+	for i, e := range sents {
+		if !used[i] && byKey[e.id] == i {
+			merged = append(merged, e)
+		}
+	}
+	out := AppendMapHeader(make([]byte, 0, len(dst)+len(src)), uint32(len(merged)))
+	for _, e := range merged {
+		out = append(out, e.key...)
+		out = append(out, e.val...)
+	}
+	return out, nil
+}
+
+// mergeKey identifies a map key by value
+type mergeKey struct {
+	str bool   // key is a str or bin
+	val string // contents of a str or bin, or else the encoded key
+}
+
+type mapEntry struct {
+	id       mergeKey
+	key, val []byte
+}
+
+// mapEntries returns the entries of
+// the map at the beginning of 'b'
+func mapEntries(b []byte) ([]mapEntry, error) {
+	if len(b) == 0 || IsNil(b) {
+		return nil, nil
+	}
+	sz, o, err := ReadMapHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	// each entry is at least two bytes
+	if uint64(sz)*2 > uint64(len(o)) {
+		return nil, ErrShortBytes
+	}
+	ents := make([]mapEntry, sz)
+	for i := range ents {
+		e := &ents[i]
+		rest, err := Skip(o)
+		if err != nil {
+			return nil, err
+		}
+		e.key = o[:len(o)-len(rest)]
+		switch NextType(e.key) {
+		case StrType, BinType:
+			k, _, err := ReadMapKeyZC(e.key)
+			if err != nil {
+				return nil, err
+			}
+			e.id = mergeKey{str: true, val: UnsafeString(k)}
+		default:
+			e.id = mergeKey{val: UnsafeString(e.key)}
+		}
+		o = rest
+		if rest, err = Skip(o); err != nil {
+			return nil, err
+		}
+		e.val = o[:len(o)-len(rest)]
+		o = rest
+	}
+	return ents, nil
+}
@@ -3,6 +3,7 @@ package msgp
 import (
 	"bytes"
 	"reflect"
+	"strconv"
 	"testing"
 )
 
@@ -198,3 +199,294 @@ func BenchmarkLocate(b *testing.B) {
 		Locate("thing_three", raw)
 	}
 }
+
+func TestGet(t *testing.T) {
+	// {"a": [1, {"b": "c"}, [true]], "d": nil}
+	bts := AppendMapHeader(nil, 2)
+	bts = AppendString(bts, "a")
+	bts = AppendArrayHeader(bts, 3)
+	bts = AppendInt(bts, 1)
+	bts = AppendMapHeader(bts, 1)
+	bts = AppendString(bts, "b")
+	bts = AppendString(bts, "c")
+	bts = AppendArrayHeader(bts, 1)
+	bts = AppendBool(bts, true)
+	bts = AppendString(bts, "d")
+	bts = AppendNil(bts)
+
+	cases := []struct {
+		path []interface{}
+		typ  Type
+		want interface{}
+	}{
+		{[]interface{}{"a", 0}, IntType, int64(1)},
+		{[]interface{}{"a", uint8(1), "b"}, StrType, "c"},
+		{[]interface{}{"a", int64(2), 0}, BoolType, true},
+		{[]interface{}{"a", 2}, ArrayType, []interface{}{true}},
+		{[]interface{}{"d"}, NilType, nil},
+	}
+	for _, c := range cases {
+		r, typ, err := Get(bts, c.path...)
+		if err != nil {
+			t.Errorf("%v: %v", c.path, err)
+			continue
+		}
+		if typ != c.typ {
+			t.Errorf("%v: got type %s, want %s", c.path, typ, c.typ)
+		}
+		v, rest, err := ReadIntfBytes(r)
+		if err != nil || len(rest) != 0 {
+			t.Errorf("%v: %v (%d bytes left)", c.path, err, len(rest))
+		}
+		if !reflect.DeepEqual(v, c.want) {
+			t.Errorf("%v: got %#v, want %#v", c.path, v, c.want)
+		}
+	}
+
+	r, typ, err := Get(bts)
+	if err != nil || typ != MapType || !bytes.Equal(r, bts) {
+		t.Errorf("empty path: got %x, %s, %v", []byte(r), typ, err)
+	}
+
+	for _, path := range [][]interface{}{
+		{"x"},
+		{"a", 3},
+		{"a", -1},
+		{"a", 1, "x"},
+	} {
+		_, _, err := Get(bts, path...)
+		if Cause(err) != ErrNotFound {
+			t.Errorf("%v: expected ErrNotFound; got %v", path, err)
+		}
+	}
+	if _, _, err := Get(bts, "a", "b"); err == nil {
+		t.Error("expected an error for a key into an array")
+	} else if _, ok := Cause(err).(TypeError); !ok {
+		t.Errorf("expected a TypeError; got %v", err)
+	} else if want := "at a/b"; !bytes.HasSuffix([]byte(err.Error()), []byte(want)) {
+		t.Errorf("expected %q to end with %q", err, want)
+	}
+	if _, _, err := Get(bts, 1.5); err == nil {
+		t.Error("expected an error for a float path element")
+	}
+	if _, _, err := Get(bts[:len(bts)-2], "d"); err == nil {
+		t.Error("expected an error for truncated input")
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	// {"a": [1, {"b": "c"}, [true]], "d": nil, "e": 5}
+	bts := AppendMapHeader(nil, 3)
+	bts = AppendString(bts, "a")
+	bts = AppendArrayHeader(bts, 3)
+	bts = AppendInt(bts, 1)
+	bts = AppendMapHeader(bts, 1)
+	bts = AppendString(bts, "b")
+	bts = AppendString(bts, "c")
+	bts = AppendArrayHeader(bts, 1)
+	bts = AppendBool(bts, true)
+	bts = AppendString(bts, "d")
+	bts = AppendNil(bts)
+	bts = AppendString(bts, "e")
+	bts = AppendInt(bts, 5)
+
+	paths := [][]interface{}{
+		{"e"},
+		{"a", 1, "b"},
+		{"a", 1},
+		{"a", 2, 0},
+		{"x"},
+		{"a", 7},
+		{"a", 1, "x"},
+		{"d"},
+		{"a", 1, "b"},
+		{},
+	}
+	got, err := GetMany(bts, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, path := range paths {
+		want, _, err := Get(bts, path...)
+		if err != nil {
+			want = nil
+		}
+		if !bytes.Equal(got[i], want) || (got[i] == nil) != (want == nil) {
+			t.Errorf("%v: got %x, want %x", path, []byte(got[i]), []byte(want))
+		}
+	}
+
+	// finding everything early means the
+	// malformed tail is never reached
+	got, err = GetMany(append(bts[:len(bts):len(bts)], 0xc1), [][]interface{}{{"a", 0}})
+	if err != nil || len(got) != 1 || !bytes.Equal(got[0], AppendInt(nil, 1)) {
+		t.Errorf("got %x, %v", got, err)
+	}
+	if _, err = GetMany(bts[:len(bts)-2], [][]interface{}{{"e"}}); err == nil {
+		t.Error("expected an error for truncated input")
+	}
+
+	if _, err := GetMany(bts, [][]interface{}{{"d"}, {"a", "b"}}); err == nil {
+		t.Error("expected an error for a key into an array")
+	} else if _, ok := Cause(err).(TypeError); !ok {
+		t.Errorf("expected a TypeError; got %v", err)
+	} else if want := "at a/b"; !bytes.HasSuffix([]byte(err.Error()), []byte(want)) {
+		t.Errorf("expected %q to end with %q", err, want)
+	}
+	if _, err := GetMany(bts, [][]interface{}{{1.5}}); err == nil {
+		t.Error("expected an error for a float path element")
+	}
+}
+
+func BenchmarkGetMany(b *testing.B) {
+	bts := AppendMapHeader(nil, 20)
+	for i := 0; i < 20; i++ {
+		bts = AppendString(bts, "field"+strconv.Itoa(i))
+		bts = AppendString(bts, "a value that has to be skipped")
+	}
+	paths := [][]interface{}{{"field3"}, {"field11"}, {"field17"}}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(bts)))
+	for i := 0; i < b.N; i++ {
+		if _, err := GetMany(bts, paths); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	// {"a": [1, {"b": "c"}], "d": nil}
+	bts := AppendMapHeader(nil, 2)
+	bts = AppendString(bts, "a")
+	bts = AppendArrayHeader(bts, 2)
+	bts = AppendInt(bts, 1)
+	bts = AppendMapHeader(bts, 1)
+	bts = AppendString(bts, "b")
+	bts = AppendString(bts, "c")
+	bts = AppendString(bts, "d")
+	bts = AppendNil(bts)
+
+	cases := []struct {
+		path  []interface{}
+		value Raw
+		want  interface{}
+	}{
+		{[]interface{}{"a", 0}, Raw(AppendInt(nil, 2)), int64(2)},                                  // same size
+		{[]interface{}{"a", 1, "b"}, Raw(AppendString(nil, "a longer string")), "a longer string"}, // grow
+		{[]interface{}{"a", 1}, Raw(AppendBool(nil, false)), false},                                // shrink
+		{[]interface{}{"d"}, Raw(AppendArrayHeader(nil, 0)), []interface{}{}},
+		{[]interface{}{"a", 0}, nil, nil},
+	}
+	for _, c := range cases {
+		out, err := Set(append([]byte(nil), bts...), c.value, c.path...)
+		if err != nil {
+			t.Errorf("%v: %v", c.path, err)
+			continue
+		}
+		r, _, err := Get(out, c.path...)
+		if err != nil {
+			t.Errorf("%v: %v", c.path, err)
+			continue
+		}
+		v, _, err := ReadIntfBytes(r)
+		if err != nil || !reflect.DeepEqual(v, c.want) {
+			t.Errorf("%v: got %#v, %v; want %#v", c.path, v, err, c.want)
+		}
+		// the rest of the message is intact
+		if _, err = Validate(out); err != nil {
+			t.Errorf("%v: %v", c.path, err)
+		}
+	}
+
+	out, err := Set(append([]byte(nil), bts...), Raw(AppendInt(nil, 9)))
+	if err != nil || !bytes.Equal(out, AppendInt(nil, 9)) {
+		t.Errorf("empty path: got %x, %v", out, err)
+	}
+	if _, err := Set(bts, Raw(AppendInt(nil, 9)), "x"); Cause(err) != ErrNotFound {
+		t.Errorf("expected ErrNotFound; got %v", err)
+	}
+	if _, err := Set(bts, Raw(AppendInt(AppendInt(nil, 1), 2)), "d"); err != ErrTrailingBytes {
+		t.Errorf("expected ErrTrailingBytes for two objects; got %v", err)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	bts := AppendMapHeader(nil, 10)
+	for i := 0; i < 10; i++ {
+		bts = AppendString(bts, string(rune('a'+i)))
+		bts = AppendArrayHeader(bts, 10)
+		for j := 0; j < 10; j++ {
+			bts = AppendInt(bts, j)
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Get(bts, "j", 9)
+	}
+}
+
+func TestMergeMaps(t *testing.T) {
+	// {"a": 1, "b": {"x": 1}, "c": "dst"}
+	dst := AppendMapHeader(nil, 3)
+	dst = AppendString(dst, "a")
+	dst = AppendInt(dst, 1)
+	dst = AppendString(dst, "b")
+	dst = AppendMapHeader(dst, 1)
+	dst = AppendString(dst, "x")
+	dst = AppendInt(dst, 1)
+	dst = AppendString(dst, "c")
+	dst = AppendString(dst, "dst")
+
+	// {"c": "src", "d": true, "b": nil}, with "b" as bin
+	src := AppendMapHeader(nil, 3)
+	src = AppendString(src, "c")
+	src = AppendString(src, "src")
+	src = AppendString(src, "d")
+	src = AppendBool(src, true)
+	src = AppendBytes(src, []byte("b"))
+	src = AppendNil(src)
+
+	dcopy := append([]byte(nil), dst...)
+	out, err := MergeMaps(dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst, dcopy) {
+		t.Error("dst was modified")
+	}
+	var keys []string
+	err = Raw(out).MapRange(func(k, v Raw) bool {
+		s, _, _ := ReadStringBytes(k)
+		keys = append(keys, s)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got keys %q; want %q", keys, want)
+	}
+	m, _, err := ReadMapStrIntfBytes(out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"a": int64(1), "b": nil, "c": "src", "d": true}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got %v; want %v", m, want)
+	}
+
+	// nil on either side
+	if out, err = MergeMaps(nil, src); err != nil || !bytes.Equal(out, src) {
+		t.Errorf("nil dst: got %x, %v", out, err)
+	}
+	if out, err = MergeMaps(AppendNil(nil), dst); err != nil || !bytes.Equal(out, dst) {
+		t.Errorf("nil dst: got %x, %v", out, err)
+	}
+	if _, err = MergeMaps(dst, AppendInt(nil, 1)); err == nil {
+		t.Error("expected an error for a src that isn't a map")
+	}
+	if _, err = MergeMaps(dst[:len(dst)-1], src); err == nil {
+		t.Error("expected an error for a truncated dst")
+	}
+}
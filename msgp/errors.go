@@ -1,8 +1,12 @@
 package msgp
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 const resumableDefault = false
@@ -13,6 +17,28 @@ var (
 	// contain the contents of the message
 	ErrShortBytes error = errShort{}
 
+	// ErrTrailingBytes is returned when
+	// a slice that should contain exactly
+	// one object has bytes left over after it
+	ErrTrailingBytes error = errTrailing{}
+
+	// ErrNotFound is returned when
+	// a map key or array index that
+	// was searched for doesn't exist
+	ErrNotFound error = errNotFound{}
+
+	// ErrContainerOpen is returned by
+	// Writer.Flush while a container begun
+	// with BeginMap or BeginArray hasn't
+	// been ended
+	ErrContainerOpen error = errContainer("msgp: Flush called with a container still open")
+
+	// ErrNoContainer is returned by
+	// Writer.EndMap and Writer.EndArray when
+	// the innermost open container is not a
+	// map or an array, respectively
+	ErrNoContainer error = errContainer("msgp: no matching container to end")
+
 	// this error is only returned
 	// if we reach code that should
 	// be unreachable
@@ -45,6 +71,9 @@ type contextError interface {
 // Cause returns the underlying cause of an error that has been wrapped
 // with additional context.
 func Cause(e error) error {
+	if o, ok := e.(OffsetError); ok {
+		e = o.Err
+	}
 	out := e
 	if e, ok := e.(errWrapped); ok && e.cause != nil {
 		out = e.cause
@@ -93,6 +122,116 @@ func ctxString(ctx []interface{}) string {
 	return out
 }
 
+// ErrorPath returns the location of the
+// value that caused err, as added by WrapError,
+// in the form of a Go selector expression; for
+// example, an error wrapped as "user/addresses/3/zip"
+// has the path "user.addresses[3].zip". Components
+// that are all digits are taken to be array indexes.
+// It returns "" if err has no location.
+func ErrorPath(err error) string {
+	var ctx string
+	switch e := err.(type) {
+	case OffsetError:
+		return ErrorPath(e.Err)
+	case errWrapped:
+		ctx = e.ctx
+	case errFatal:
+		ctx = e.ctx
+	case ArrayError:
+		ctx = e.ctx
+	case IntOverflow:
+		ctx = e.ctx
+	case UintOverflow:
+		ctx = e.ctx
+	case UintBelowZero:
+		ctx = e.ctx
+	case EnumError:
+		ctx = e.ctx
+	case LimitError:
+		ctx = e.ctx
+	case UTF8Error:
+		ctx = e.ctx
+	case TypeError:
+		ctx = e.ctx
+	case *ErrUnsupportedType:
+		ctx = e.ctx
+	case NilPointerError:
+		ctx = e.ctx
+	}
+	if ctx == "" {
+		return ""
+	}
+	var out []byte
+	for _, part := range strings.Split(ctx, "/") {
+		if _, err := strconv.ParseUint(part, 10, 64); err == nil {
+			out = append(out, '[')
+			out = append(out, part...)
+			out = append(out, ']')
+			continue
+		}
+		if len(out) > 0 {
+			out = append(out, '.')
+		}
+		out = append(out, part...)
+	}
+	return string(out)
+}
+
+// OffsetError is an error annotated with the
+// position in the input at which it happened.
+// Decode returns errors of this type.
+type OffsetError struct {
+	Offset int64 // the number of bytes consumed before the error
+	Err    error // the underlying error
+}
+
+// Error implements the error interface
+func (o OffsetError) Error() string {
+	return fmt.Sprintf("%s (offset %d)", o.Err, o.Offset)
+}
+
+// Unwrap returns the underlying error.
+func (o OffsetError) Unwrap() error { return o.Err }
+
+// Resumable returns whether the underlying error is resumable.
+func (o OffsetError) Resumable() bool { return Resumable(o.Err) }
+
+func (o OffsetError) withContext(ctx string) error {
+	if e, ok := o.Err.(contextError); ok {
+		o.Err = e.withContext(ctx)
+	} else {
+		o.Err = errWrapped{cause: o.Err, ctx: ctx}
+	}
+	return o
+}
+
+// ErrorOffset returns the offset at which
+// err (or an error that it wraps) happened,
+// if it is known.
+func ErrorOffset(err error) (int64, bool) {
+	var o OffsetError
+	if errors.As(err, &o) {
+		return o.Offset, true
+	}
+	return 0, false
+}
+
+// withOffset annotates err with the offset
+// 'off', unless it already has one. io.EOF
+// and ErrShortBytes are left alone so that
+// they can still be compared directly.
+func withOffset(err error, off int64) error {
+	switch err.(type) {
+	case nil, OffsetError, errShort:
+		return err
+	}
+	if err == io.EOF {
+		return err
+	}
+	return OffsetError{Offset: off, Err: err}
+}
+
 func addCtx(ctx, add string) string {
 	if ctx != "" {
 		return add + "/" + ctx
@@ -126,11 +265,28 @@ func (e errWrapped) Resumable() bool {
 // Unwrap returns the cause.
 func (e errWrapped) Unwrap() error { return e.cause }
 
+func (e errWrapped) withContext(ctx string) error { e.ctx = addCtx(e.ctx, ctx); return e }
+
 type errShort struct{}
 
 func (e errShort) Error() string   { return "msgp: too few bytes left to read object" }
 func (e errShort) Resumable() bool { return false }
 
+type errTrailing struct{}
+
+func (e errTrailing) Error() string   { return "msgp: trailing bytes after object" }
+func (e errTrailing) Resumable() bool { return true }
+
+type errContainer string
+
+func (e errContainer) Error() string   { return string(e) }
+func (e errContainer) Resumable() bool { return false }
+
+type errNotFound struct{}
+
+func (e errNotFound) Error() string   { return "msgp: not found" }
+func (e errNotFound) Resumable() bool { return true }
+
 type errFatal struct {
 	ctx string
 }
@@ -193,18 +349,32 @@ func (i IntOverflow) Resumable() bool { return true }
 
 func (i IntOverflow) withContext(ctx string) error { i.ctx = addCtx(i.ctx, ctx); return i }
 
+// Is reports whether target is an IntOverflow
+// whose FailedBitsize is zero or the same as i's,
+// whatever the value, so IntOverflow{} matches
+// every IntOverflow.
+func (i IntOverflow) Is(target error) bool {
+	o, ok := target.(IntOverflow)
+	return ok && (o.FailedBitsize == 0 || o.FailedBitsize == i.FailedBitsize)
+}
+
 // UintOverflow is returned when a call
 // would downcast an unsigned integer to a type
 // with too few bits to hold its value
 type UintOverflow struct {
 	Value         uint64 // value of the uint
 	FailedBitsize int    // the bit size that couldn't fit the value
+	Signed        bool   // whether the type that couldn't fit the value is signed
 	ctx           string
 }
 
 // Error implements the error interface
 func (u UintOverflow) Error() string {
-	str := fmt.Sprintf("msgp: %d overflows uint%d", u.Value, u.FailedBitsize)
+	typ := "uint"
+	if u.Signed {
+		typ = "int"
+	}
+	str := fmt.Sprintf("msgp: %d overflows %s%d", u.Value, typ, u.FailedBitsize)
 	if u.ctx != "" {
 		str += " at " + u.ctx
 	}
@@ -216,6 +386,15 @@ func (u UintOverflow) Resumable() bool { return true }
 
 func (u UintOverflow) withContext(ctx string) error { u.ctx = addCtx(u.ctx, ctx); return u }
 
+// Is reports whether target is a UintOverflow
+// whose FailedBitsize is zero or the same as u's,
+// whatever the value and signedness, so
+// UintOverflow{} matches every UintOverflow.
+func (u UintOverflow) Is(target error) bool {
+	o, ok := target.(UintOverflow)
+	return ok && (o.FailedBitsize == 0 || o.FailedBitsize == u.FailedBitsize)
+}
+
 // UintBelowZero is returned when a call
 // would cast a signed integer below zero
 // to an unsigned integer.
@@ -236,11 +415,108 @@ func (u UintBelowZero) Error() string {
 // Resumable is always 'true' for overflows
 func (u UintBelowZero) Resumable() bool { return true }
 
-func (u UintBelowZero) withContext(ctx string) error {
-	u.ctx = ctx
-	return u
+func (u UintBelowZero) withContext(ctx string) error { u.ctx = addCtx(u.ctx, ctx); return u }
+
+// Is reports whether target is a UintBelowZero,
+// whatever the value.
+func (u UintBelowZero) Is(target error) bool {
+	_, ok := target.(UintBelowZero)
+	return ok
+}
+
+// EnumError is returned when a decoded value
+// is not one of the values allowed for its type
+// by a //msgp:enum directive.
+type EnumError struct {
+	Type  string      // name of the enum type
+	Value interface{} // the decoded value
+	ctx   string
 }
 
+// Error implements the error interface
+func (e EnumError) Error() string {
+	str := fmt.Sprintf("msgp: %v is not a valid %s", e.Value, e.Type)
+	if e.ctx != "" {
+		str += " at " + e.ctx
+	}
+	return str
+}
+
+// Resumable is always 'true' for EnumErrors
+func (e EnumError) Resumable() bool { return true }
+
+func (e EnumError) withContext(ctx string) error { e.ctx = addCtx(e.ctx, ctx); return e }
+
+// LimitError is returned when an object
+// exceeds one of the limits configured
+// on a Reader.
+type LimitError struct {
+	Limit string // the name of the limit, e.g. "depth"
+	Size  uint64 // the size of the object
+	Max   uint64 // the configured limit
+	ctx   string
+}
+
+// Error implements the error interface
+func (l LimitError) Error() string {
+	str := fmt.Sprintf("msgp: %s %d exceeds the limit of %d", l.Limit, l.Size, l.Max)
+	if l.ctx != "" {
+		str += " at " + l.ctx
+	}
+	return str
+}
+
+// Resumable is always 'false' for LimitErrors,
+// since the rest of the object wasn't read
+func (l LimitError) Resumable() bool { return false }
+
+func (l LimitError) withContext(ctx string) error { l.ctx = addCtx(l.ctx, ctx); return l }
+
+// UTF8Error is returned by a Reader that
+// requires strict UTF-8 when it reads a
+// string that isn't valid UTF-8.
+type UTF8Error struct {
+	ctx string
+}
+
+// Error implements the error interface
+func (u UTF8Error) Error() string {
+	str := "msgp: string is not valid UTF-8"
+	if u.ctx != "" {
+		str += " at " + u.ctx
+	}
+	return str
+}
+
+// Resumable is always 'true' for UTF8Errors
+func (u UTF8Error) Resumable() bool { return true }
+
+func (u UTF8Error) withContext(ctx string) error { u.ctx = addCtx(u.ctx, ctx); return u }
+
+// BufferSizeError is returned by ReadBytesInto
+// and ReadStringInto when the buffer they are
+// given is too small for the object, which is
+// left unread.
+type BufferSizeError struct {
+	Need int // the size of the object
+	Have int // the size of the buffer
+	ctx  string
+}
+
+// Error implements the error interface
+func (b BufferSizeError) Error() string {
+	str := fmt.Sprintf("msgp: object of %d bytes doesn't fit in a buffer of %d", b.Need, b.Have)
+	if b.ctx != "" {
+		str += " at " + b.ctx
+	}
+	return str
+}
+
+// Resumable is always 'true' for BufferSizeErrors
+func (b BufferSizeError) Resumable() bool { return true }
+
+func (b BufferSizeError) withContext(ctx string) error { b.ctx = addCtx(b.ctx, ctx); return b }
+
 // A TypeError is returned when a particular
 // decoding method is unsuitable for decoding
 // a particular MessagePack value.
@@ -265,6 +541,21 @@ func (t TypeError) Resumable() bool { return true }
 
 func (t TypeError) withContext(ctx string) error { t.ctx = addCtx(t.ctx, ctx); return t }
 
+// Is reports whether target is a TypeError
+// whose fields are either InvalidType or the
+// same as t's, so that callers can check for
+// kinds of mismatch with errors.Is, e.g.
+//
+//	errors.Is(err, msgp.TypeError{Encoded: msgp.NilType})
+//
+// matches any attempt to read nil as something
+// else, and TypeError{} matches every TypeError.
+func (t TypeError) Is(target error) bool {
+	o, ok := target.(TypeError)
+	return ok && (o.Method == InvalidType || o.Method == t.Method) &&
+		(o.Encoded == InvalidType || o.Encoded == t.Encoded)
+}
+
 // returns either InvalidPrefixError or
 // TypeError depending on whether or not
 // the prefix is recognized
@@ -289,6 +580,29 @@ func (i InvalidPrefixError) Error() string {
 // Resumable returns 'false' for InvalidPrefixErrors
 func (i InvalidPrefixError) Resumable() bool { return false }
 
+// NilPointerError is returned by a Writer
+// with the NilPointerError option set when
+// WriteIntf is passed a nil pointer.
+type NilPointerError struct {
+	T reflect.Type // the type of the pointer
+
+	ctx string
+}
+
+// Error implements the error interface
+func (n NilPointerError) Error() string {
+	out := fmt.Sprintf("msgp: nil pointer of type %s", n.T)
+	if n.ctx != "" {
+		out += " at " + n.ctx
+	}
+	return out
+}
+
+// Resumable returns 'true' for NilPointerErrors
+func (n NilPointerError) Resumable() bool { return true }
+
+func (n NilPointerError) withContext(ctx string) error { n.ctx = addCtx(n.ctx, ctx); return n }
+
 // ErrUnsupportedType is returned
 // when a bad argument is supplied
 // to a function that takes `interface{}`.
@@ -1,9 +1,11 @@
 package msgp
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"strings"
 	"testing"
 )
@@ -129,3 +131,134 @@ func TestUnwrap(t *testing.T) {
 	}
 
 }
+
+func TestWrapMultipleCause(t *testing.T) {
+	err := errors.New("test")
+	w := WrapError(WrapError(err, "b"), "a")
+	if w.Error() != "test at a/b" {
+		t.Fatalf("got %q", w.Error())
+	}
+	if Cause(w) != err {
+		t.Fatal("lost the cause")
+	}
+}
+
+func TestErrorPath(t *testing.T) {
+	inner := WrapError(TypeError{Method: IntType, Encoded: StrType}, "zip")
+	err := WrapError(WrapError(inner, "addresses", 3), "user")
+	if got := err.Error(); !strings.HasSuffix(got, " at user/addresses/3/zip") {
+		t.Errorf("got %q", got)
+	}
+	if got := ErrorPath(err); got != "user.addresses[3].zip" {
+		t.Errorf("got path %q", got)
+	}
+	if got := ErrorPath(WrapError(UintBelowZero{Value: -1}, 0, 1)); got != "[0][1]" {
+		t.Errorf("got path %q", got)
+	}
+	if got := ErrorPath(TypeError{}); got != "" {
+		t.Errorf("got path %q for an error without context", got)
+	}
+	if got := ErrorPath(WrapError(errors.New("x"), "a", "b")); got != "a.b" {
+		t.Errorf("got path %q", got)
+	}
+}
+
+// twoStrings decodes an array of two strings
+type twoStrings [2]string
+
+func (s *twoStrings) DecodeMsg(r *Reader) error {
+	if _, err := r.ReadArrayHeader(); err != nil {
+		return err
+	}
+	for i := range s {
+		var err error
+		s[i], err = r.ReadString()
+		if err != nil {
+			return WrapError(err, i)
+		}
+	}
+	return nil
+}
+
+func TestDecodeOffset(t *testing.T) {
+	var s twoStrings
+	b := AppendArrayHeader(nil, 2)
+	b = AppendString(b, "abc")
+	b = AppendInt(b, 4)
+	err := Decode(bytes.NewReader(b), &s)
+	off, ok := ErrorOffset(err)
+	if !ok || off != 5 {
+		t.Fatalf("got offset %d, %t from %v; want 5", off, ok, err)
+	}
+	if !strings.HasSuffix(err.Error(), "at 1 (offset 5)") {
+		t.Errorf("got %q", err)
+	}
+	if ErrorPath(err) != "[1]" {
+		t.Errorf("got path %q", ErrorPath(err))
+	}
+	var te TypeError
+	if !errors.As(err, &te) || te.Encoded != IntType {
+		t.Errorf("couldn't unwrap %v to a TypeError", err)
+	}
+	if _, ok := Cause(err).(TypeError); !ok {
+		t.Errorf("Cause returned %T", Cause(err))
+	}
+	if !Resumable(err) {
+		t.Error("expected a resumable error")
+	}
+
+	// io.EOF is returned as it is
+	if err := Decode(bytes.NewReader(nil), &s); err != io.EOF {
+		t.Errorf("got %v; want io.EOF", err)
+	}
+
+	// offsets survive further wrapping
+	if _, ok := ErrorOffset(WrapError(OffsetError{Offset: 2, Err: TypeError{}}, "x")); !ok {
+		t.Error("offset lost by WrapError")
+	}
+	if got := ErrorPath(WrapError(OffsetError{Offset: 2, Err: TypeError{}}, "x")); got != "x" {
+		t.Errorf("got path %q", got)
+	}
+}
+
+func TestErrorsIs(t *testing.T) {
+	_, _, err := ReadInt64Bytes(AppendNil(nil))
+	err = WrapError(err, "a", 1)
+	for _, target := range []error{
+		TypeError{},
+		TypeError{Method: IntType},
+		TypeError{Encoded: NilType},
+		TypeError{Method: IntType, Encoded: NilType},
+	} {
+		if !errors.Is(err, target) {
+			t.Errorf("%v doesn't match %#v", err, target)
+		}
+	}
+	for _, target := range []error{TypeError{Method: StrType}, IntOverflow{}, ErrShortBytes} {
+		if errors.Is(err, target) {
+			t.Errorf("%v matches %#v", err, target)
+		}
+	}
+
+	_, _, err = ReadInt8Bytes(AppendInt(nil, 1000))
+	if !errors.Is(err, IntOverflow{}) || !errors.Is(err, IntOverflow{FailedBitsize: 8}) || errors.Is(err, IntOverflow{FailedBitsize: 16}) {
+		t.Errorf("%v: wrong matches", err)
+	}
+	_, _, err = ReadUint16Bytes(AppendUint(nil, 1<<20))
+	if !errors.Is(err, UintOverflow{FailedBitsize: 16}) || errors.Is(err, IntOverflow{}) {
+		t.Errorf("%v: wrong matches", err)
+	}
+	_, _, err = ReadUint64Bytes(AppendInt(nil, -1))
+	if !errors.Is(WrapError(err, "x"), UintBelowZero{}) {
+		t.Errorf("%v: doesn't match UintBelowZero", err)
+	}
+	ext, _ := AppendExtension(nil, &RawExtension{Type: 42, Data: make([]byte, 8)})
+	_, _, err = ReadComplex64Bytes(ext)
+	if !errors.Is(err, ExtensionTypeError{Want: Complex64Extension}) || errors.Is(err, ExtensionTypeError{Want: TimeExtension}) {
+		t.Errorf("%v: wrong matches", err)
+	}
+
+	if err := NewWriter(ioutil.Discard).WriteIntf(make(chan int)); !errors.As(err, new(*ErrUnsupportedType)) {
+		t.Errorf("got %v; want an ErrUnsupportedType", err)
+	}
+}
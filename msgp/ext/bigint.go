@@ -0,0 +1,110 @@
+package ext
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// BigInt is a big.Int that is encoded as an
+// extension of type BigIntExtension. Convert
+// between the two with NewBigInt and Int.
+type BigInt big.Int
+
+var errBadBigInt = errors.New("msgp/ext: invalid big integer encoding")
+
+// NewBigInt returns x as a *BigInt.
+// The two share their memory.
+func NewBigInt(x *big.Int) *BigInt { return (*BigInt)(x) }
+
+// Int returns b as a *big.Int.
+// The two share their memory.
+func (b *BigInt) Int() *big.Int { return (*big.Int)(b) }
+
+// String returns b in base 10.
+func (b *BigInt) String() string { return b.Int().String() }
+
+// MarshalJSON implements json.Marshaler,
+// writing b as a JSON number.
+func (b *BigInt) MarshalJSON() ([]byte, error) { return b.Int().MarshalJSON() }
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *BigInt) UnmarshalJSON(p []byte) error { return b.Int().UnmarshalJSON(p) }
+
+// ExtensionType implements msgp.Extension.
+func (b *BigInt) ExtensionType() int8 { return BigIntExtension }
+
+// Len implements msgp.Extension.
+func (b *BigInt) Len() int { return bigIntLen(b.Int()) }
+
+// MarshalBinaryTo implements msgp.Extension.
+func (b *BigInt) MarshalBinaryTo(p []byte) error {
+	putBigInt(p, b.Int())
+	return nil
+}
+
+// UnmarshalBinary implements msgp.Extension.
+func (b *BigInt) UnmarshalBinary(p []byte) error {
+	return getBigInt(p, b.Int())
+}
+
+// AppendBigInt appends x to b as a BigInt.
+func AppendBigInt(b []byte, x *big.Int) []byte {
+	// BigInt's methods don't fail
+	b, _ = msgp.AppendExtension(b, NewBigInt(x))
+	return b
+}
+
+// ReadBigIntBytes reads a BigInt from b
+// and returns it and the remaining bytes.
+func ReadBigIntBytes(b []byte) (*big.Int, []byte, error) {
+	x := new(big.Int)
+	o, err := msgp.ReadExtensionBytes(b, NewBigInt(x))
+	return x, o, err
+}
+
+// WriteBigInt writes x to w as a BigInt.
+func WriteBigInt(w *msgp.Writer, x *big.Int) error {
+	return w.WriteExtension(NewBigInt(x))
+}
+
+// ReadBigInt reads a BigInt from r.
+func ReadBigInt(r *msgp.Reader) (*big.Int, error) {
+	x := new(big.Int)
+	err := r.ReadExtension(NewBigInt(x))
+	return x, err
+}
+
+// bigIntLen returns the size of the
+// encoding of x: a sign byte and
+// the magnitude
+func bigIntLen(x *big.Int) int {
+	return 1 + (x.BitLen()+7)/8
+}
+
+// putBigInt encodes x into p,
+// which must be bigIntLen(x) bytes
+func putBigInt(p []byte, x *big.Int) {
+	p[0] = 0
+	if x.Sign() < 0 {
+		p[0] = 1
+	}
+	x.FillBytes(p[1:])
+}
+
+// getBigInt decodes p into x
+func getBigInt(p []byte, x *big.Int) error {
+	if len(p) == 0 || p[0] > 1 || (len(p) > 1 && p[1] == 0) {
+		return errBadBigInt
+	}
+	x.SetBytes(p[1:])
+	if p[0] == 1 {
+		if len(p) == 1 {
+			// there is no negative zero
+			return errBadBigInt
+		}
+		x.Neg(x)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package ext
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an arbitrary-precision decimal
+// number, with the value Unscaled × 10^-Scale.
+// It is encoded as an extension of type
+// DecimalExtension.
+//
+// The same number can be represented with
+// different scales (1.5 and 1.50, say), and
+// the scale is preserved when encoding and
+// decoding. Decimal does no arithmetic; it is
+// meant to be converted to and from the decimal
+// type used by the application.
+type Decimal struct {
+	Unscaled big.Int
+	Scale    int32
+}
+
+var errBadDecimal = errors.New("msgp/ext: invalid decimal")
+
+// ParseDecimal parses a decimal number with
+// an optional sign and fractional part, like
+// "-12.340". The scale is the number of digits
+// after the decimal point.
+func ParseDecimal(s string) (*Decimal, error) { return parseDecimal(s, false) }
+
+// parseDecimal implements ParseDecimal and, with
+// exp set, UnmarshalText, which also accepts an
+// exponent, like "-1.234E+5"
+func parseDecimal(s string, exp bool) (*Decimal, error) {
+	d := new(Decimal)
+	digits := s
+	var scale int64
+	if i := strings.IndexAny(s, "eE"); i >= 0 && exp {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return nil, errBadDecimal
+		}
+		digits = s[:i]
+		scale = -e
+	}
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		frac := digits[i+1:]
+		if frac == "" || strings.ContainsAny(frac, "+-") {
+			return nil, errBadDecimal
+		}
+		digits = digits[:i] + frac
+		scale += int64(len(frac))
+	}
+	if scale <= math.MinInt32 || scale > math.MaxInt32 {
+		return nil, errBadDecimal
+	}
+	d.Scale = int32(scale)
+	if _, ok := d.Unscaled.SetString(digits, 10); !ok {
+		return nil, errBadDecimal
+	}
+	return d, nil
+}
+
+// NewDecimal returns a Decimal with the value
+// coef × 10^exp, which is how decimal libraries
+// such as github.com/shopspring/decimal represent
+// their numbers, so that
+//
+//	ext.NewDecimal(d.Coefficient(), d.Exponent())
+//
+// converts one of theirs to a Decimal, and
+//
+//	decimal.NewFromBigInt(x.Coefficient(), x.Exponent())
+//
+// converts it back.
+//
+// An exp of math.MinInt32 has no matching scale,
+// so coef is divided by 10, truncating, and the
+// scale is set to math.MaxInt32.
+func NewDecimal(coef *big.Int, exp int32) *Decimal {
+	d := new(Decimal)
+	if exp == math.MinInt32 {
+		d.Unscaled.Quo(coef, big.NewInt(10))
+		d.Scale = math.MaxInt32
+		return d
+	}
+	d.Unscaled.Set(coef)
+	d.Scale = -exp
+	return d
+}
+
+// Coefficient returns a copy of d.Unscaled.
+func (d *Decimal) Coefficient() *big.Int { return new(big.Int).Set(&d.Unscaled) }
+
+// Exponent returns -d.Scale, or math.MaxInt32
+// for a Scale of math.MinInt32, which can't be
+// negated (and which UnmarshalBinary rejects).
+func (d *Decimal) Exponent() int32 {
+	if d.Scale == math.MinInt32 {
+		return math.MaxInt32
+	}
+	return -d.Scale
+}
+
+// maxDecimalZeros is the most zeros String
+// pads a number with before switching to
+// exponent notation
+const maxDecimalZeros = 1000
+
+// String returns d in decimal notation, with
+// Scale digits after the decimal point when the
+// scale is positive. Numbers that would need
+// more than a thousand zeros of padding are
+// written in exponent notation instead, as in
+// "-5E+2000".
+func (d *Decimal) String() string {
+	s := d.Unscaled.String()
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	if d.Scale < -maxDecimalZeros || int64(d.Scale) > int64(len(s))+maxDecimalZeros {
+		exp := -int64(d.Scale)
+		sign := "+"
+		if exp < 0 {
+			sign = ""
+		}
+		s += "E" + sign + strconv.FormatInt(exp, 10)
+	} else if d.Scale <= 0 {
+		if d.Unscaled.Sign() != 0 {
+			s += strings.Repeat("0", int(-d.Scale))
+		}
+	} else {
+		if n := int(d.Scale) + 1 - len(s); n > 0 {
+			s = strings.Repeat("0", n) + s
+		}
+		s = s[:len(s)-int(d.Scale)] + "." + s[len(s)-int(d.Scale):]
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// MarshalJSON implements json.Marshaler,
+// writing d as a JSON number.
+func (d *Decimal) MarshalJSON() ([]byte, error) { return []byte(d.String()), nil }
+
+// MarshalText implements encoding.TextMarshaler,
+// writing d as String does.
+func (d *Decimal) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Unlike ParseDecimal, it accepts an exponent, as in
+// "1.5E+3" (which has a scale of -2), so that decimals
+// can be converted to and from those of libraries that
+// implement encoding.TextMarshaler, such as
+// github.com/cockroachdb/apd, which writes numbers
+// that way:
+//
+//	b, _ := a.MarshalText() // a is an *apd.Decimal
+//	err := d.UnmarshalText(b)
+//
+// NaNs and infinities can't be represented.
+func (d *Decimal) UnmarshalText(b []byte) error {
+	v, err := parseDecimal(string(b), true)
+	if err != nil {
+		return err
+	}
+	d.Scale = v.Scale
+	d.Unscaled.Set(&v.Unscaled)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler,
+// accepting a JSON number or string, with
+// an exponent if MarshalJSON would write one.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	v, err := parseDecimal(s, true)
+	if err != nil {
+		return err
+	}
+	d.Scale = v.Scale
+	d.Unscaled.Set(&v.Unscaled)
+	return nil
+}
+
+// ExtensionType implements msgp.Extension.
+func (d *Decimal) ExtensionType() int8 { return DecimalExtension }
+
+// Len implements msgp.Extension.
+func (d *Decimal) Len() int { return 4 + bigIntLen(&d.Unscaled) }
+
+// MarshalBinaryTo implements msgp.Extension.
+func (d *Decimal) MarshalBinaryTo(b []byte) error {
+	binary.BigEndian.PutUint32(b, uint32(d.Scale))
+	putBigInt(b[4:], &d.Unscaled)
+	return nil
+}
+
+// UnmarshalBinary implements msgp.Extension.
+func (d *Decimal) UnmarshalBinary(b []byte) error {
+	if len(b) < 5 {
+		return LengthError{Type: DecimalExtension, Len: len(b)}
+	}
+	scale := int32(binary.BigEndian.Uint32(b))
+	if scale == math.MinInt32 {
+		return errBadDecimal
+	}
+	if err := getBigInt(b[4:], &d.Unscaled); err != nil {
+		return err
+	}
+	d.Scale = scale
+	return nil
+}
@@ -0,0 +1,51 @@
+package ext
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Duration is a time.Duration that is encoded
+// as an extension of type DurationExtension
+// holding the number of nanoseconds.
+type Duration time.Duration
+
+// String returns the duration
+// formatted like time.Duration.
+func (d Duration) String() string { return time.Duration(d).String() }
+
+// MarshalText implements encoding.TextMarshaler,
+// so durations appear as strings like "1.5s" in JSON.
+func (d Duration) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+// UnmarshalText implements encoding.TextUnmarshaler,
+// accepting anything time.ParseDuration does.
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// ExtensionType implements msgp.Extension.
+func (d *Duration) ExtensionType() int8 { return DurationExtension }
+
+// Len implements msgp.Extension.
+func (d *Duration) Len() int { return 8 }
+
+// MarshalBinaryTo implements msgp.Extension.
+func (d *Duration) MarshalBinaryTo(b []byte) error {
+	binary.BigEndian.PutUint64(b, uint64(*d))
+	return nil
+}
+
+// UnmarshalBinary implements msgp.Extension.
+func (d *Duration) UnmarshalBinary(b []byte) error {
+	if len(b) != 8 {
+		return LengthError{Type: DurationExtension, Len: len(b)}
+	}
+	*d = Duration(binary.BigEndian.Uint64(b))
+	return nil
+}
@@ -0,0 +1,61 @@
+// Package ext provides msgp.Extension implementations
+// for common types that MessagePack has no native
+// representation for, with fixed extension type
+// numbers, so that programs exchanging these types
+// agree on their encoding.
+//
+// The types and their encodings are:
+//
+//	UUID      16  the 16 bytes of the UUID
+//	Decimal   17  the scale as a 4-byte big-endian int32,
+//	              followed by the unscaled value as for BigInt
+//	BigInt    18  a sign byte (0 for zero or positive, 1 for
+//	              negative) followed by the magnitude as
+//	              big-endian bytes, with no leading zeros
+//	Duration  19  nanoseconds as an 8-byte big-endian int64
+//	Rat       20  a sign byte as for BigInt, the length of the
+//	              numerator's magnitude as a 4-byte big-endian
+//	              uint32, the magnitude, and then the denominator,
+//	              with no leading zeros
+//
+// To have the methods that decode interface{} values
+// return these types, register them with Register.
+package ext
+
+import (
+	"strconv"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// The extension type numbers used by this package.
+const (
+	UUIDExtension     = 16
+	DecimalExtension  = 17
+	BigIntExtension   = 18
+	DurationExtension = 19
+	RatExtension      = 20
+)
+
+// Register registers all of the extensions
+// in this package with r (typically
+// msgp.DefaultRegistry). Like Registry.Register,
+// it panics if any of them is already registered.
+func Register(r *msgp.Registry) {
+	r.Register(UUIDExtension, func() msgp.Extension { return new(UUID) })
+	r.Register(DecimalExtension, func() msgp.Extension { return new(Decimal) })
+	r.Register(BigIntExtension, func() msgp.Extension { return new(BigInt) })
+	r.Register(DurationExtension, func() msgp.Extension { return new(Duration) })
+	r.Register(RatExtension, func() msgp.Extension { return new(Rat) })
+}
+
+// LengthError is returned when decoding an
+// extension whose data has the wrong length.
+type LengthError struct {
+	Type int8 // the extension type
+	Len  int  // the length of the data
+}
+
+func (e LengthError) Error() string {
+	return "msgp/ext: invalid length " + strconv.Itoa(e.Len) + " for extension type " + strconv.Itoa(int(e.Type))
+}
@@ -0,0 +1,248 @@
+package ext
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestRoundTrip(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	uid, err := ParseUUID("f47ac10b-58cc-4372-a567-0e02b2c3d479")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := ParseDecimal("-12.340")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dur := Duration(90 * time.Second)
+	tests := []struct {
+		in, out msgp.Extension
+	}{
+		{&uid, new(UUID)},
+		{NewBigInt(huge), new(BigInt)},
+		{NewBigInt(new(big.Int)), new(BigInt)},
+		{dec, new(Decimal)},
+		{&Decimal{Scale: -3}, new(Decimal)},
+		{&dur, new(Duration)},
+		{NewRat(big.NewRat(-22, 7)), new(Rat)},
+		{NewRat(new(big.Rat)), new(Rat)},
+	}
+	for _, tt := range tests {
+		b, err := msgp.AppendExtension(nil, tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rest, err := msgp.ReadExtensionBytes(b, tt.out)
+		if err != nil || len(rest) != 0 {
+			t.Fatalf("%T: %v", tt.in, err)
+		}
+		in, _ := json.Marshal(tt.in)
+		out, _ := json.Marshal(tt.out)
+		if string(in) != string(out) {
+			t.Errorf("%T: got %s; want %s", tt.in, out, in)
+		}
+	}
+	if uid.String() != "f47ac10b-58cc-4372-a567-0e02b2c3d479" {
+		t.Errorf("uuid: got %s", uid)
+	}
+	if dec.String() != "-12.340" || dec.Scale != 3 {
+		t.Errorf("decimal: got %s with scale %d", dec, dec.Scale)
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	for _, s := range []string{"0", "0.05", "-0.5", "1.000", "123456789.123456789"} {
+		d, err := ParseDecimal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.String() != s {
+			t.Errorf("got %s; want %s", d, s)
+		}
+	}
+	d := &Decimal{Scale: -2}
+	d.Unscaled.SetInt64(7)
+	if d.String() != "700" {
+		t.Errorf("got %s; want 700", d)
+	}
+	for _, s := range []string{"", "1.", "1.-5", "1e5", "x"} {
+		if _, err := ParseDecimal(s); err == nil {
+			t.Errorf("expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestDecimalExtremeScale(t *testing.T) {
+	// a scale of math.MinInt32 can't be negated
+	if err := new(Decimal).UnmarshalBinary([]byte{0x80, 0, 0, 0, 0, 5}); err == nil {
+		t.Error("expected an error decoding a scale of math.MinInt32")
+	}
+	if err := new(Decimal).UnmarshalText([]byte("5E+2147483648")); err == nil {
+		t.Error("expected an error parsing a scale of math.MinInt32")
+	}
+	for _, tt := range []struct {
+		scale int32
+		out   string
+	}{
+		{-2000, "-5E+2000"},
+		{2000, "-5E-2000"},
+		{math.MaxInt32, "-5E-2147483647"},
+		{-math.MaxInt32, "-5E+2147483647"},
+	} {
+		d := &Decimal{Scale: tt.scale}
+		d.Unscaled.SetInt64(-5)
+		if d.String() != tt.out {
+			t.Errorf("scale %d: got %s; want %s", tt.scale, d, tt.out)
+		}
+		b, _ := json.Marshal(d)
+		var back Decimal
+		if err := json.Unmarshal(b, &back); err != nil || back.Scale != tt.scale || back.Unscaled.Cmp(&d.Unscaled) != 0 {
+			t.Errorf("scale %d: json round trip gave %s, %v", tt.scale, &back, err)
+		}
+	}
+}
+
+// libDecimal has the methods of
+// shopspring's decimal.Decimal
+type libDecimal struct {
+	coef *big.Int
+	exp  int32
+}
+
+func (l libDecimal) Coefficient() *big.Int { return l.coef }
+func (l libDecimal) Exponent() int32       { return l.exp }
+
+func TestDecimalInterop(t *testing.T) {
+	l := libDecimal{coef: big.NewInt(-12340), exp: -3}
+	d := NewDecimal(l.Coefficient(), l.Exponent())
+	if d.String() != "-12.340" {
+		t.Errorf("NewDecimal: got %s", d)
+	}
+	back := libDecimal{coef: d.Coefficient(), exp: d.Exponent()}
+	if back.coef.Cmp(l.coef) != 0 || back.exp != l.exp {
+		t.Errorf("got %v; want %v", back, l)
+	}
+
+	d = NewDecimal(big.NewInt(-123), math.MinInt32)
+	if d.Scale != math.MaxInt32 || d.Unscaled.Int64() != -12 {
+		t.Errorf("NewDecimal(-123, MinInt32): got %s with scale %d", d, d.Scale)
+	}
+	if e := (&Decimal{Scale: math.MinInt32}).Exponent(); e <= 0 {
+		t.Errorf("Exponent for a scale of MinInt32: got %d", e)
+	}
+
+	// the text forms written by apd
+	for _, tt := range []struct {
+		in, out string
+		scale   int32
+	}{
+		{"1.5E+3", "1500", -2},
+		{"-1E-7", "-0.0000001", 7},
+		{"0E-2", "0.00", 2},
+		{"12.5", "12.5", 1},
+	} {
+		var d Decimal
+		if err := d.UnmarshalText([]byte(tt.in)); err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if d.String() != tt.out || d.Scale != tt.scale {
+			t.Errorf("%s: got %s with scale %d", tt.in, &d, d.Scale)
+		}
+		if b, _ := d.MarshalText(); string(b) != tt.out {
+			t.Errorf("%s: MarshalText: got %s", tt.in, b)
+		}
+	}
+	for _, s := range []string{"NaN", "Infinity", "1E", "1E+99999999999", "1.E5"} {
+		if err := new(Decimal).UnmarshalText([]byte(s)); err == nil {
+			t.Errorf("expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	reg := msgp.NewRegistry()
+	Register(reg)
+	u := UUID{1, 2, 3}
+	b, _ := msgp.AppendExtension(nil, &u)
+	b, _ = msgp.AppendExtension(b, NewBigInt(big.NewInt(-300)))
+	for _, want := range []string{u.String(), "-300"} {
+		var v interface{}
+		var err error
+		v, b, err = reg.ReadIntfBytes(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := v.(interface{ String() string }).String(); s != want {
+			t.Errorf("got %s; want %s", s, want)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	if err := new(UUID).UnmarshalBinary(make([]byte, 15)); err == nil {
+		t.Error("expected an error for a short UUID")
+	}
+	for _, p := range [][]byte{{}, {2, 1}, {0, 0, 1}, {1}} {
+		if err := new(BigInt).UnmarshalBinary(p); err == nil {
+			t.Errorf("expected an error for %x", p)
+		}
+	}
+	// a short numerator, a zero denominator, a leading
+	// zero, a negative zero and a length past the end
+	for _, p := range [][]byte{{0, 0, 0, 0}, {0, 0, 0, 0, 0, 0}, {0, 0, 0, 0, 1, 0, 1}, {1, 0, 0, 0, 0, 1}, {0, 0, 0, 0, 2, 1, 1}} {
+		if err := new(Rat).UnmarshalBinary(p); err == nil {
+			t.Errorf("expected an error for %x", p)
+		}
+	}
+	if _, err := ParseUUID("f47ac10b58cc-4372-a567-0e02b2c3d4790"); err == nil {
+		t.Error("expected an error for a misplaced hyphen")
+	}
+}
+
+func TestBigHelpers(t *testing.T) {
+	x, _ := new(big.Int).SetString("-340282366920938463463374607431768211457", 10)
+	q := big.NewRat(1, 3)
+	var buf bytes.Buffer
+	w := msgp.NewWriter(&buf)
+	if err := WriteBigInt(w, x); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteRat(w, q); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	b := AppendRat(AppendBigInt(nil, x), q)
+	if !bytes.Equal(b, buf.Bytes()) {
+		t.Errorf("got %x, want %x", b, buf.Bytes())
+	}
+
+	gx, o, err := ReadBigIntBytes(b)
+	if err != nil || gx.Cmp(x) != 0 {
+		t.Errorf("ReadBigIntBytes: got %v, %v", gx, err)
+	}
+	gq, o, err := ReadRatBytes(o)
+	if err != nil || gq.Cmp(q) != 0 || len(o) != 0 {
+		t.Errorf("ReadRatBytes: got %v, %v", gq, err)
+	}
+	r := msgp.NewReader(&buf)
+	if gx, err := ReadBigInt(r); err != nil || gx.Cmp(x) != 0 {
+		t.Errorf("ReadBigInt: got %v, %v", gx, err)
+	}
+	if gq, err := ReadRat(r); err != nil || gq.Cmp(q) != 0 {
+		t.Errorf("ReadRat: got %v, %v", gq, err)
+	}
+
+	// the layout is fixed
+	b, _ = msgp.AppendExtension(nil, NewRat(big.NewRat(-300, 7)))
+	if want := []byte{0xd7, RatExtension, 1, 0, 0, 0, 2, 1, 0x2c, 7}; !bytes.Equal(b, want) {
+		t.Errorf("got %x, want %x", b, want)
+	}
+}
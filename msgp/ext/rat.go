@@ -0,0 +1,106 @@
+package ext
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// Rat is a big.Rat that is encoded as an
+// extension of type RatExtension. Convert
+// between the two with NewRat and Rat.
+type Rat big.Rat
+
+var errBadRat = errors.New("msgp/ext: invalid rational encoding")
+
+// NewRat returns x as a *Rat.
+// The two share their memory.
+func NewRat(x *big.Rat) *Rat { return (*Rat)(x) }
+
+// Rat returns r as a *big.Rat.
+// The two share their memory.
+func (r *Rat) Rat() *big.Rat { return (*big.Rat)(r) }
+
+// String returns r as "a/b".
+func (r *Rat) String() string { return r.Rat().String() }
+
+// MarshalText implements encoding.TextMarshaler,
+// so rationals appear as strings like "1/3" in JSON.
+func (r *Rat) MarshalText() ([]byte, error) { return r.Rat().MarshalText() }
+
+// UnmarshalText implements encoding.TextUnmarshaler,
+// accepting anything big.Rat.SetString does.
+func (r *Rat) UnmarshalText(p []byte) error { return r.Rat().UnmarshalText(p) }
+
+// ExtensionType implements msgp.Extension.
+func (r *Rat) ExtensionType() int8 { return RatExtension }
+
+// Len implements msgp.Extension.
+func (r *Rat) Len() int {
+	return 1 + 4 + (r.Rat().Num().BitLen()+7)/8 + (r.Rat().Denom().BitLen()+7)/8
+}
+
+// MarshalBinaryTo implements msgp.Extension.
+func (r *Rat) MarshalBinaryTo(p []byte) error {
+	num, den := r.Rat().Num(), r.Rat().Denom()
+	n := (num.BitLen() + 7) / 8
+	p[0] = 0
+	if num.Sign() < 0 {
+		p[0] = 1
+	}
+	binary.BigEndian.PutUint32(p[1:], uint32(n))
+	num.FillBytes(p[5 : 5+n])
+	den.FillBytes(p[5+n:])
+	return nil
+}
+
+// UnmarshalBinary implements msgp.Extension.
+func (r *Rat) UnmarshalBinary(p []byte) error {
+	if len(p) < 6 || p[0] > 1 {
+		return errBadRat
+	}
+	n := binary.BigEndian.Uint32(p[1:])
+	if uint64(n) > uint64(len(p)-6) {
+		return errBadRat
+	}
+	num, den := p[5:5+n], p[5+n:]
+	if (n > 0 && num[0] == 0) || den[0] == 0 || (n == 0 && p[0] == 1) {
+		return errBadRat
+	}
+	var a, b big.Int
+	a.SetBytes(num)
+	if p[0] == 1 {
+		a.Neg(&a)
+	}
+	r.Rat().SetFrac(&a, b.SetBytes(den))
+	return nil
+}
+
+// AppendRat appends x to b as a Rat.
+func AppendRat(b []byte, x *big.Rat) []byte {
+	// Rat's methods don't fail
+	b, _ = msgp.AppendExtension(b, NewRat(x))
+	return b
+}
+
+// ReadRatBytes reads a Rat from b and
+// returns it and the remaining bytes.
+func ReadRatBytes(b []byte) (*big.Rat, []byte, error) {
+	x := new(big.Rat)
+	o, err := msgp.ReadExtensionBytes(b, NewRat(x))
+	return x, o, err
+}
+
+// WriteRat writes x to w as a Rat.
+func WriteRat(w *msgp.Writer, x *big.Rat) error {
+	return w.WriteExtension(NewRat(x))
+}
+
+// ReadRat reads a Rat from r.
+func ReadRat(r *msgp.Reader) (*big.Rat, error) {
+	x := new(big.Rat)
+	err := r.ReadExtension(NewRat(x))
+	return x, err
+}
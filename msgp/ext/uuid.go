@@ -0,0 +1,84 @@
+package ext
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+// UUID is an RFC 4122 UUID. It is
+// encoded as an extension of type
+// UUIDExtension holding its 16 bytes.
+type UUID [16]byte
+
+var errBadUUID = errors.New("msgp/ext: invalid UUID")
+
+// ParseUUID parses a UUID in the canonical
+// form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx,
+// or as 32 hex digits without hyphens.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	switch len(s) {
+	case 32:
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return u, errBadUUID
+		}
+		s = s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	default:
+		return u, errBadUUID
+	}
+	if _, err := hex.Decode(u[:], []byte(s)); err != nil {
+		return u, errBadUUID
+	}
+	return u, nil
+}
+
+// String returns u in the canonical form.
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
+
+// MarshalText implements encoding.TextMarshaler,
+// so UUIDs appear as strings in JSON.
+func (u UUID) MarshalText() ([]byte, error) { return []byte(u.String()), nil }
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *UUID) UnmarshalText(b []byte) error {
+	v, err := ParseUUID(string(b))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+// ExtensionType implements msgp.Extension.
+func (u *UUID) ExtensionType() int8 { return UUIDExtension }
+
+// Len implements msgp.Extension.
+func (u *UUID) Len() int { return len(u) }
+
+// MarshalBinaryTo implements msgp.Extension.
+func (u *UUID) MarshalBinaryTo(b []byte) error {
+	copy(b, u[:])
+	return nil
+}
+
+// UnmarshalBinary implements msgp.Extension.
+func (u *UUID) UnmarshalBinary(b []byte) error {
+	if len(b) != len(u) {
+		return LengthError{Type: UUIDExtension, Len: len(b)}
+	}
+	copy(u[:], b)
+	return nil
+}
@@ -2,6 +2,7 @@ package msgp
 
 import (
 	"fmt"
+	"io"
 	"math"
 )
 
@@ -14,14 +15,15 @@ const (
 
 	// TimeExtension is the extension number used for time.Time
 	TimeExtension = 5
-)
 
-// our extensions live here
-var extensionReg = make(map[int8]func() Extension)
+	// TimestampExtension is the extension number that
+	// the MessagePack specification reserves for timestamps
+	TimestampExtension = -1
+)
 
-// RegisterExtension registers extensions so that they
-// can be initialized and returned by methods that
-// decode `interface{}` values. This should only
+// RegisterExtension registers extensions in DefaultRegistry
+// so that they can be initialized and returned by methods
+// that decode `interface{}` values. This should only
 // be called during initialization. f() should return
 // a newly-initialized zero value of the extension. Keep in
 // mind that extensions 3, 4, and 5 are reserved for
@@ -36,14 +38,7 @@ var extensionReg = make(map[int8]func() Extension)
 // with the same 'typ' argument, or if you use a reserved
 // type (3, 4, or 5).
 func RegisterExtension(typ int8, f func() Extension) {
-	switch typ {
-	case Complex64Extension, Complex128Extension, TimeExtension:
-		panic(fmt.Sprint("msgp: forbidden extension type:", typ))
-	}
-	if _, ok := extensionReg[typ]; ok {
-		panic(fmt.Sprint("msgp: RegisterExtension() called with typ", typ, "more than once"))
-	}
-	extensionReg[typ] = f
+	DefaultRegistry.Register(typ, f)
 }
 
 // ExtensionTypeError is an error type returned
@@ -62,6 +57,49 @@ func (e ExtensionTypeError) Error() string {
 // Resumable returns 'true' for ExtensionTypeErrors
 func (e ExtensionTypeError) Resumable() bool { return true }
 
+// Is reports whether target is an ExtensionTypeError
+// whose Want is zero or the same as e's, whatever
+// was found, so ExtensionTypeError{} matches every
+// ExtensionTypeError.
+func (e ExtensionTypeError) Is(target error) bool {
+	o, ok := target.(ExtensionTypeError)
+	return ok && (o.Want == 0 || o.Want == e.Want)
+}
+
+// UnknownExtensionError is returned by ReadIntf
+// for an extension whose type is not registered,
+// if the Reader's IntfPolicy asks for it with
+// ExtError. The extension has been skipped.
+type UnknownExtensionError struct {
+	Type int8
+}
+
+// Error implements the error interface
+func (e UnknownExtensionError) Error() string {
+	return fmt.Sprintf("msgp: unknown extension type %d", e.Type)
+}
+
+// Resumable returns 'true' for UnknownExtensionErrors
+func (e UnknownExtensionError) Resumable() bool { return true }
+
+// ExtensionLengthError is returned when an
+// ExtensionStreamer writes a different amount
+// of data than its Len method declared. The
+// encoded stream is no longer valid.
+type ExtensionLengthError struct {
+	Type    int8
+	Len     int // the length returned by Len
+	Written int // the number of bytes written
+}
+
+// Error implements the error interface
+func (e ExtensionLengthError) Error() string {
+	return fmt.Sprintf("msgp: extension type %d wrote %d bytes of data; Len() returned %d", e.Type, e.Written, e.Len)
+}
+
+// Resumable returns 'false' for ExtensionLengthErrors
+func (e ExtensionLengthError) Resumable() bool { return false }
+
 func errExt(got int8, wanted int8) error {
 	return ExtensionTypeError{Got: got, Want: wanted}
 }
@@ -90,6 +128,15 @@ type Extension interface {
 }
 
 // RawExtension implements the Extension interface
+// for extensions of any type. Reading into a
+// *RawExtension with ReadExtension or
+// ReadExtensionBytes accepts whatever type is on
+// the wire and stores it in Type, so a RawExtension
+// can hold extensions that are not registered, e.g.
+// in a struct field tagged `msg:",extension"`, and
+// write them back out unchanged. ReadIntf and
+// ReadIntfBytes return unregistered extensions
+// as *RawExtension.
 type RawExtension struct {
 	Data []byte
 	Type int8
@@ -120,11 +167,34 @@ func (r *RawExtension) UnmarshalBinary(b []byte) error {
 	return nil
 }
 
-// WriteExtension writes an extension type to the writer
-func (mw *Writer) WriteExtension(e Extension) error {
-	l := e.Len()
-	var err error
-	switch l {
+// ExtensionStreamer is an optional interface
+// for extensions whose data is too large to be
+// handled in memory all at once. When a Writer
+// writes an ExtensionStreamer, it calls
+// WriteExtensionTo rather than MarshalBinaryTo,
+// and when a Reader reads one, it calls
+// ReadExtensionFrom rather than UnmarshalBinary.
+// (The functions that read and write []byte still
+// use MarshalBinaryTo and UnmarshalBinary.)
+type ExtensionStreamer interface {
+	Extension
+
+	// WriteExtensionTo should write
+	// exactly Len() bytes of data to w.
+	WriteExtensionTo(w io.Writer) error
+
+	// ReadExtensionFrom should read the n
+	// bytes of data in the extension from r.
+	// Any data that it doesn't read is discarded.
+	ReadExtensionFrom(r io.Reader, n int) error
+}
+
+// WriteExtensionHeader writes the header of an
+// extension of type typ with sz bytes of data.
+// The data must be written by the caller (with
+// Write) immediately afterwards.
+func (mw *Writer) WriteExtensionHeader(typ int8, sz int) error {
+	switch sz {
 	case 0:
 		o, err := mw.require(3)
 		if err != nil {
@@ -132,70 +202,86 @@ func (mw *Writer) WriteExtension(e Extension) error {
 		}
 		mw.buf[o] = mext8
 		mw.buf[o+1] = 0
-		mw.buf[o+2] = byte(e.ExtensionType())
+		mw.buf[o+2] = byte(typ)
 	case 1:
 		o, err := mw.require(2)
 		if err != nil {
 			return err
 		}
 		mw.buf[o] = mfixext1
-		mw.buf[o+1] = byte(e.ExtensionType())
+		mw.buf[o+1] = byte(typ)
 	case 2:
 		o, err := mw.require(2)
 		if err != nil {
 			return err
 		}
 		mw.buf[o] = mfixext2
-		mw.buf[o+1] = byte(e.ExtensionType())
+		mw.buf[o+1] = byte(typ)
 	case 4:
 		o, err := mw.require(2)
 		if err != nil {
 			return err
 		}
 		mw.buf[o] = mfixext4
-		mw.buf[o+1] = byte(e.ExtensionType())
+		mw.buf[o+1] = byte(typ)
 	case 8:
 		o, err := mw.require(2)
 		if err != nil {
 			return err
 		}
 		mw.buf[o] = mfixext8
-		mw.buf[o+1] = byte(e.ExtensionType())
+		mw.buf[o+1] = byte(typ)
 	case 16:
 		o, err := mw.require(2)
 		if err != nil {
 			return err
 		}
 		mw.buf[o] = mfixext16
-		mw.buf[o+1] = byte(e.ExtensionType())
+		mw.buf[o+1] = byte(typ)
 	default:
 		switch {
-		case l < math.MaxUint8:
+		case sz < math.MaxUint8:
 			o, err := mw.require(3)
 			if err != nil {
 				return err
 			}
 			mw.buf[o] = mext8
-			mw.buf[o+1] = byte(uint8(l))
-			mw.buf[o+2] = byte(e.ExtensionType())
-		case l < math.MaxUint16:
+			mw.buf[o+1] = byte(uint8(sz))
+			mw.buf[o+2] = byte(typ)
+		case sz < math.MaxUint16:
 			o, err := mw.require(4)
 			if err != nil {
 				return err
 			}
 			mw.buf[o] = mext16
-			big.PutUint16(mw.buf[o+1:], uint16(l))
-			mw.buf[o+3] = byte(e.ExtensionType())
+			big.PutUint16(mw.buf[o+1:], uint16(sz))
+			mw.buf[o+3] = byte(typ)
 		default:
 			o, err := mw.require(6)
 			if err != nil {
 				return err
 			}
 			mw.buf[o] = mext32
-			big.PutUint32(mw.buf[o+1:], uint32(l))
-			mw.buf[o+5] = byte(e.ExtensionType())
+			big.PutUint32(mw.buf[o+1:], uint32(sz))
+			mw.buf[o+5] = byte(typ)
 		}
 	}
+	return nil
+}
+
+// WriteExtension writes an extension type to the writer
+func (mw *Writer) WriteExtension(e Extension) error {
+	l := e.Len()
+	err := mw.WriteExtensionHeader(e.ExtensionType(), l)
+	if err != nil {
+		return err
+	}
+	if es, ok := e.(ExtensionStreamer); ok {
+		return mw.streamExtension(es, l)
+	}
+	if len(mw.open) > 0 && l > mw.avail() {
+		mw.grow(l)
+	}
 	// we can only write directly to the
 	// buffer if we're sure that it
 	// fits the object
@@ -204,7 +290,7 @@ func (mw *Writer) WriteExtension(e Extension) error {
 		if err != nil {
 			return err
 		}
-		return e.MarshalBinaryTo(mw.buf[o:])
+		return e.MarshalBinaryTo(mw.buf[o : o+l])
 	}
 	// here we create a new buffer
 	// just large enough for the body
@@ -223,10 +309,110 @@ func (mw *Writer) WriteExtension(e Extension) error {
 	return nil
 }
 
+// streamExtension writes the data of es,
+// checking that it is l bytes long
+func (mw *Writer) streamExtension(es ExtensionStreamer, l int) error {
+	cw := extCounter{w: mw}
+	err := es.WriteExtensionTo(&cw)
+	if err != nil {
+		return err
+	}
+	if cw.n != l {
+		return ExtensionLengthError{Type: es.ExtensionType(), Len: l, Written: cw.n}
+	}
+	return nil
+}
+
+// extCounter counts the bytes written to a Writer
+type extCounter struct {
+	w *Writer
+	n int
+}
+
+func (c *extCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+// peekExtensionHeader returns the type, data
+// length, and header size of the next extension
+func (m *Reader) peekExtensionHeader() (typ int8, sz int, hdr int, err error) {
+	var p []byte
+	p, err = m.R.Peek(1)
+	if err != nil {
+		return
+	}
+	spec := &sizes[p[0]]
+	if spec.typ != ExtensionType {
+		err = badPrefix(ExtensionType, p[0])
+		return
+	}
+	hdr = 2
+	if spec.extra != constsize {
+		hdr = int(spec.size)
+	}
+	p, err = m.peek(hdr)
+	if err != nil {
+		return
+	}
+	switch spec.extra {
+	case constsize:
+		sz = int(spec.size) - 2
+	case extra8:
+		sz = int(p[1])
+	case extra16:
+		sz = int(big.Uint16(p[1:]))
+	default:
+		sz = int(big.Uint32(p[1:]))
+	}
+	typ = int8(p[hdr-1])
+	err = m.checkShort(int64(hdr + sz))
+	return
+}
+
+// ReadExtensionHeader reads the header of the next
+// extension and returns its type and the length
+// of its data. The caller must consume exactly
+// sz bytes of data immediately afterwards, e.g.
+// with ReadFull, or with R.Next to avoid a copy.
+func (m *Reader) ReadExtensionHeader() (typ int8, sz int, err error) {
+	var hdr int
+	typ, sz, hdr, err = m.peekExtensionHeader()
+	if err != nil {
+		return
+	}
+	_, err = m.R.Skip(hdr)
+	return
+}
+
+// readStreamedExtension reads an extension into es
+func (m *Reader) readStreamedExtension(es ExtensionStreamer) error {
+	typ, sz, hdr, err := m.peekExtensionHeader()
+	if err != nil {
+		return err
+	}
+	if typ != es.ExtensionType() {
+		return errExt(typ, es.ExtensionType())
+	}
+	if _, err = m.R.Skip(hdr); err != nil {
+		return err
+	}
+	lr := io.LimitedReader{R: m.R, N: int64(sz)}
+	err = es.ReadExtensionFrom(&lr, sz)
+	if err != nil {
+		return err
+	}
+	if lr.N > 0 {
+		_, err = m.R.Skip(int(lr.N))
+	}
+	return err
+}
+
 // peek at the extension type, assuming the next
 // kind to be read is Extension
 func (m *Reader) peekExtensionType() (int8, error) {
-	p, err := m.R.Peek(2)
+	p, err := m.peek(2)
 	if err != nil {
 		return 0, err
 	}
@@ -238,7 +424,7 @@ func (m *Reader) peekExtensionType() (int8, error) {
 		return int8(p[1]), nil
 	}
 	size := spec.size
-	p, err = m.R.Peek(int(size))
+	p, err = m.peek(int(size))
 	if err != nil {
 		return 0, err
 	}
@@ -270,10 +456,17 @@ func peekExtension(b []byte) (int8, error) {
 // ReadExtension reads the next object from the reader
 // as an extension. ReadExtension will fail if the next
 // object in the stream is not an extension, or if
-// e.Type() is not the same as the wire type.
+// e.Type() is not the same as the wire type (unless
+// e is a *RawExtension; see RawExtension).
 func (m *Reader) ReadExtension(e Extension) (err error) {
+	if es, ok := e.(ExtensionStreamer); ok {
+		return m.readStreamedExtension(es)
+	}
+	if r, ok := e.(*RawExtension); ok {
+		return m.readRawExtension(r)
+	}
 	var p []byte
-	p, err = m.R.Peek(2)
+	p, err = m.peek(2)
 	if err != nil {
 		return
 	}
@@ -286,7 +479,7 @@ func (m *Reader) ReadExtension(e Extension) (err error) {
 			err = errExt(int8(p[1]), e.ExtensionType())
 			return
 		}
-		p, err = m.R.Peek(3)
+		p, err = m.peek(3)
 		if err != nil {
 			return
 		}
@@ -301,7 +494,7 @@ func (m *Reader) ReadExtension(e Extension) (err error) {
 			err = errExt(int8(p[1]), e.ExtensionType())
 			return
 		}
-		p, err = m.R.Peek(4)
+		p, err = m.peek(4)
 		if err != nil {
 			return
 		}
@@ -316,7 +509,7 @@ func (m *Reader) ReadExtension(e Extension) (err error) {
 			err = errExt(int8(p[1]), e.ExtensionType())
 			return
 		}
-		p, err = m.R.Peek(6)
+		p, err = m.peek(6)
 		if err != nil {
 			return
 		}
@@ -331,7 +524,7 @@ func (m *Reader) ReadExtension(e Extension) (err error) {
 			err = errExt(int8(p[1]), e.ExtensionType())
 			return
 		}
-		p, err = m.R.Peek(10)
+		p, err = m.peek(10)
 		if err != nil {
 			return
 		}
@@ -346,7 +539,7 @@ func (m *Reader) ReadExtension(e Extension) (err error) {
 			err = errExt(int8(p[1]), e.ExtensionType())
 			return
 		}
-		p, err = m.R.Peek(18)
+		p, err = m.peek(18)
 		if err != nil {
 			return
 		}
@@ -357,7 +550,7 @@ func (m *Reader) ReadExtension(e Extension) (err error) {
 		return
 
 	case mext8:
-		p, err = m.R.Peek(3)
+		p, err = m.peek(3)
 		if err != nil {
 			return
 		}
@@ -369,7 +562,7 @@ func (m *Reader) ReadExtension(e Extension) (err error) {
 		off = 3
 
 	case mext16:
-		p, err = m.R.Peek(4)
+		p, err = m.peek(4)
 		if err != nil {
 			return
 		}
@@ -381,7 +574,7 @@ func (m *Reader) ReadExtension(e Extension) (err error) {
 		off = 4
 
 	case mext32:
-		p, err = m.R.Peek(6)
+		p, err = m.peek(6)
 		if err != nil {
 			return
 		}
@@ -397,7 +590,10 @@ func (m *Reader) ReadExtension(e Extension) (err error) {
 		return
 	}
 
-	p, err = m.R.Peek(read + off)
+	if err = m.checkShort(int64(read + off)); err != nil {
+		return
+	}
+	p, err = m.peek(read + off)
 	if err != nil {
 		return
 	}
@@ -408,73 +604,129 @@ func (m *Reader) ReadExtension(e Extension) (err error) {
 	return
 }
 
-// AppendExtension appends a MessagePack extension to the provided slice
-func AppendExtension(b []byte, e Extension) ([]byte, error) {
-	l := e.Len()
+// readRawExtension reads an extension
+// of any type into r
+func (m *Reader) readRawExtension(r *RawExtension) error {
+	typ, sz, hdr, err := m.peekExtensionHeader()
+	if err != nil {
+		return err
+	}
+	p, err := m.peek(hdr + sz)
+	if err != nil {
+		return err
+	}
+	r.Type = typ
+	r.UnmarshalBinary(p[hdr:])
+	_, err = m.R.Skip(hdr + sz)
+	return err
+}
+
+// AppendExtensionHeader appends the header of an
+// extension of type typ with sz bytes of data to b.
+// The caller must append the data itself.
+func AppendExtensionHeader(b []byte, typ int8, sz int) []byte {
 	var o []byte
 	var n int
-	switch l {
+	switch sz {
 	case 0:
 		o, n = ensure(b, 3)
 		o[n] = mext8
 		o[n+1] = 0
-		o[n+2] = byte(e.ExtensionType())
-		return o[:n+3], nil
+		o[n+2] = byte(typ)
 	case 1:
-		o, n = ensure(b, 3)
+		o, n = ensure(b, 2)
 		o[n] = mfixext1
-		o[n+1] = byte(e.ExtensionType())
-		n += 2
+		o[n+1] = byte(typ)
 	case 2:
-		o, n = ensure(b, 4)
+		o, n = ensure(b, 2)
 		o[n] = mfixext2
-		o[n+1] = byte(e.ExtensionType())
-		n += 2
+		o[n+1] = byte(typ)
 	case 4:
-		o, n = ensure(b, 6)
+		o, n = ensure(b, 2)
 		o[n] = mfixext4
-		o[n+1] = byte(e.ExtensionType())
-		n += 2
+		o[n+1] = byte(typ)
 	case 8:
-		o, n = ensure(b, 10)
+		o, n = ensure(b, 2)
 		o[n] = mfixext8
-		o[n+1] = byte(e.ExtensionType())
-		n += 2
+		o[n+1] = byte(typ)
 	case 16:
-		o, n = ensure(b, 18)
+		o, n = ensure(b, 2)
 		o[n] = mfixext16
-		o[n+1] = byte(e.ExtensionType())
-		n += 2
+		o[n+1] = byte(typ)
 	default:
 		switch {
-		case l < math.MaxUint8:
-			o, n = ensure(b, l+3)
+		case sz < math.MaxUint8:
+			o, n = ensure(b, 3)
 			o[n] = mext8
-			o[n+1] = byte(uint8(l))
-			o[n+2] = byte(e.ExtensionType())
-			n += 3
-		case l < math.MaxUint16:
-			o, n = ensure(b, l+4)
+			o[n+1] = byte(uint8(sz))
+			o[n+2] = byte(typ)
+		case sz < math.MaxUint16:
+			o, n = ensure(b, 4)
 			o[n] = mext16
-			big.PutUint16(o[n+1:], uint16(l))
-			o[n+3] = byte(e.ExtensionType())
-			n += 4
+			big.PutUint16(o[n+1:], uint16(sz))
+			o[n+3] = byte(typ)
 		default:
-			o, n = ensure(b, l+6)
+			o, n = ensure(b, 6)
 			o[n] = mext32
-			big.PutUint32(o[n+1:], uint32(l))
-			o[n+5] = byte(e.ExtensionType())
-			n += 6
+			big.PutUint32(o[n+1:], uint32(sz))
+			o[n+5] = byte(typ)
 		}
 	}
+	return o
+}
+
+// AppendExtension appends a MessagePack extension to the provided slice
+func AppendExtension(b []byte, e Extension) ([]byte, error) {
+	l := e.Len()
+	o := AppendExtensionHeader(b, e.ExtensionType(), l)
+	if l == 0 {
+		return o, nil
+	}
+	o, n := ensure(o, l)
 	return o, e.MarshalBinaryTo(o[n:])
 }
 
+// ReadExtensionHeaderBytes reads the header of an
+// extension from 'b' and returns the extension's
+// type, the length of its data, and the bytes that
+// follow the header, which begin with the data.
+// It does not check that the data is present.
+// Possible errors:
+// - ErrShortBytes ('b' not long enough)
+// - TypeError{} (next object not an extension)
+func ReadExtensionHeaderBytes(b []byte) (typ int8, sz int, o []byte, err error) {
+	if len(b) < 1 {
+		return 0, 0, b, ErrShortBytes
+	}
+	spec := &sizes[b[0]]
+	if spec.typ != ExtensionType {
+		return 0, 0, b, badPrefix(ExtensionType, b[0])
+	}
+	hdr := 2
+	if spec.extra != constsize {
+		hdr = int(spec.size)
+	}
+	if len(b) < hdr {
+		return 0, 0, b, ErrShortBytes
+	}
+	switch spec.extra {
+	case constsize:
+		sz = int(spec.size) - 2
+	case extra8:
+		sz = int(b[1])
+	case extra16:
+		sz = int(big.Uint16(b[1:]))
+	default:
+		sz = int(big.Uint32(b[1:]))
+	}
+	return int8(b[hdr-1]), sz, b[hdr:], nil
+}
+
 // ReadExtensionBytes reads an extension from 'b' into 'e'
 // and returns any remaining bytes.
 // Possible errors:
 // - ErrShortBytes ('b' not long enough)
-// - ExtensionTypeError{} (wire type not the same as e.Type())
+// - ExtensionTypeError{} (wire type not the same as e.Type(), unless e is a *RawExtension)
 // - TypeError{} (next object not an extension)
 // - InvalidPrefixError
 // - An umarshal error returned from e.UnmarshalBinary
@@ -514,9 +766,6 @@ func ReadExtensionBytes(b []byte, e Extension) ([]byte, error) {
 		sz = int(uint8(b[1]))
 		typ = int8(b[2])
 		off = 3
-		if sz == 0 {
-			return b[3:], e.UnmarshalBinary(b[3:3])
-		}
 	case mext16:
 		if l < 4 {
 			return b, ErrShortBytes
@@ -535,7 +784,9 @@ func ReadExtensionBytes(b []byte, e Extension) ([]byte, error) {
 		return b, badPrefix(ExtensionType, lead)
 	}
 
-	if typ != e.ExtensionType() {
+	if r, ok := e.(*RawExtension); ok {
+		r.Type = typ
+	} else if typ != e.ExtensionType() {
 		return b, errExt(typ, e.ExtensionType())
 	}
 
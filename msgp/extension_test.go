@@ -2,6 +2,10 @@ package msgp
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"math/rand"
 	"testing"
 	"time"
@@ -72,3 +76,205 @@ func TestAppendAndWriteCompatibility(t *testing.T) {
 		}
 	}
 }
+
+// streamExt is an ExtensionStreamer that
+// generates and checks its data on the fly
+type streamExt struct {
+	n    int
+	sum  int
+	read int
+}
+
+func (s *streamExt) ExtensionType() int8 { return 40 }
+func (s *streamExt) Len() int            { return s.n }
+
+func (s *streamExt) MarshalBinaryTo(b []byte) error {
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return nil
+}
+
+func (s *streamExt) UnmarshalBinary(b []byte) error {
+	return s.ReadExtensionFrom(bytes.NewReader(b), len(b))
+}
+
+func (s *streamExt) WriteExtensionTo(w io.Writer) error {
+	chunk := make([]byte, 100)
+	for off := 0; off < s.n; off += len(chunk) {
+		if s.n-off < len(chunk) {
+			chunk = chunk[:s.n-off]
+		}
+		for i := range chunk {
+			chunk[i] = byte(off + i)
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *streamExt) ReadExtensionFrom(r io.Reader, n int) error {
+	s.n = n
+	s.sum = 0
+	s.read = 0
+	chunk := make([]byte, 100)
+	for {
+		c, err := r.Read(chunk)
+		for i := 0; i < c; i++ {
+			if chunk[i] != byte(s.read+i) {
+				return fmt.Errorf("byte %d is %d", s.read+i, chunk[i])
+			}
+		}
+		s.read += c
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestExtensionStreamer(t *testing.T) {
+	for _, sz := range []int{0, 4, 200, 70000} {
+		var buf bytes.Buffer
+		w := NewWriterSize(&buf, 512)
+		if err := w.WriteExtension(&streamExt{n: sz}); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteString("after")
+		w.Flush()
+
+		// the bytes must match the non-streamed encoding
+		want, _ := AppendExtension(nil, &streamExt{n: sz})
+		want = AppendString(want, "after")
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Fatalf("size %d: streamed encoding differs", sz)
+		}
+
+		r := NewReaderSize(&buf, 512)
+		var out streamExt
+		if err := r.ReadExtension(&out); err != nil {
+			t.Fatalf("size %d: %s", sz, err)
+		}
+		if out.n != sz || out.read != sz {
+			t.Errorf("size %d: read %d of %d bytes", sz, out.read, out.n)
+		}
+		if s, err := r.ReadString(); err != nil || s != "after" {
+			t.Errorf("size %d: got %q, %v after the extension", sz, s, err)
+		}
+	}
+}
+
+// shortExt writes less data than it claims
+type shortExt struct{ streamExt }
+
+func (s *shortExt) WriteExtensionTo(w io.Writer) error {
+	_, err := w.Write([]byte{0})
+	return err
+}
+
+func TestExtensionStreamerShort(t *testing.T) {
+	w := NewWriter(ioutil.Discard)
+	err := w.WriteExtension(&shortExt{streamExt{n: 10}})
+	var le ExtensionLengthError
+	if !errors.As(err, &le) || le.Type != 40 || le.Len != 10 || le.Written != 1 {
+		t.Errorf("got %v; want an ExtensionLengthError", err)
+	}
+}
+
+func TestWriteExtensionHeader(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	data := []byte("some data")
+	w.WriteExtensionHeader(12, len(data))
+	w.Write(data)
+	w.Flush()
+	var e RawExtension
+	e.Type = 12
+	if _, err := ReadExtensionBytes(buf.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(e.Data, data) {
+		t.Errorf("got %q", e.Data)
+	}
+}
+
+func TestExtensionHeader(t *testing.T) {
+	for _, sz := range extSizes {
+		data := RandBytes(sz)
+		e := RawExtension{Type: 42, Data: data}
+		want, _ := AppendExtension(nil, &e)
+
+		b := AppendExtensionHeader(nil, 42, sz)
+		b = append(b, data...)
+		if !bytes.Equal(b, want) {
+			t.Errorf("size %d: got %x, want %x", sz, b[:8], want[:8])
+		}
+
+		typ, n, o, err := ReadExtensionHeaderBytes(b)
+		if err != nil || typ != 42 || n != sz || !bytes.Equal(o, data) {
+			t.Errorf("size %d: ReadExtensionHeaderBytes returned %d, %d, %v", sz, typ, n, err)
+		}
+
+		rd := NewReader(bytes.NewReader(b))
+		typ, n, err = rd.ReadExtensionHeader()
+		if err != nil || typ != 42 || n != sz {
+			t.Errorf("size %d: ReadExtensionHeader returned %d, %d, %v", sz, typ, n, err)
+		}
+		got := make([]byte, n)
+		if _, err = rd.ReadFull(got); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("size %d: read data %v", sz, err)
+		}
+	}
+
+	if _, _, _, err := ReadExtensionHeaderBytes(AppendInt(nil, 1)); err == nil {
+		t.Error("expected an error for an int")
+	}
+	if _, _, _, err := ReadExtensionHeaderBytes([]byte{mext16, 0}); err != ErrShortBytes {
+		t.Errorf("got error %v for a short header", err)
+	}
+	if _, _, err := NewReader(bytes.NewReader(AppendNil(nil))).ReadExtensionHeader(); err == nil {
+		t.Error("expected an error for nil")
+	}
+}
+
+func TestRawExtensionAnyType(t *testing.T) {
+	for _, sz := range extSizes {
+		in := RawExtension{Type: 99, Data: RandBytes(sz)}
+		b, _ := AppendExtension(nil, &in)
+
+		out := RawExtension{Type: 3}
+		if o, err := ReadExtensionBytes(b, &out); err != nil || len(o) != 0 {
+			t.Fatalf("size %d: %v", sz, err)
+		}
+		if out.Type != 99 || !bytes.Equal(out.Data, in.Data) {
+			t.Errorf("size %d: got type %d and %d bytes", sz, out.Type, len(out.Data))
+		}
+
+		out = RawExtension{}
+		if err := NewReader(bytes.NewReader(b)).ReadExtension(&out); err != nil {
+			t.Fatalf("size %d: %v", sz, err)
+		}
+		if out.Type != 99 || !bytes.Equal(out.Data, in.Data) {
+			t.Errorf("size %d: got type %d and %d bytes", sz, out.Type, len(out.Data))
+		}
+
+		// and it is written back out unchanged
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.WriteExtension(&out)
+		w.Flush()
+		if !bytes.Equal(buf.Bytes(), b) {
+			t.Errorf("size %d: wrote %x, want %x", sz, buf.Bytes(), b)
+		}
+	}
+
+	// other extensions still check the type
+	b, _ := AppendExtension(nil, &RawExtension{Type: 99})
+	if _, err := ReadExtensionBytes(b, &regExt{}); err == nil {
+		t.Error("expected an error for a zero-length extension of the wrong type")
+	}
+}
@@ -1,5 +1,5 @@
 // +build linux darwin dragonfly freebsd netbsd openbsd
-// +build !appengine
+// +build !appengine,!purego
 
 package msgp
 
@@ -39,6 +39,72 @@ func ReadFile(dst Unmarshaler, file *os.File) error {
 	return err
 }
 
+// MappedFile is a file mapped into memory
+// by MapFile.
+type MappedFile struct {
+	data []byte
+}
+
+// MapFile maps file into memory, so that the
+// MessagePack objects in it can be read in place
+// through Bytes, Reader or Document, without
+// first being read into a buffer; the OS loads
+// the pages of the file as they are touched, so
+// files much larger than memory can be scanned.
+// The file can be closed once MapFile returns,
+// but it must not be truncated while it is mapped,
+// or reading past its new end will fault (SIGBUS).
+//
+// The mapping is private and copy-on-write, so
+// anything that writes to the memory (as code
+// using the R field of a Reader over it directly
+// may) never changes the file.
+func MapFile(file *os.File) (*MappedFile, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	sz := stat.Size()
+	if sz == 0 {
+		return &MappedFile{}, nil
+	}
+	if int64(int(sz)) != sz {
+		return nil, syscall.EFBIG
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(sz), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	adviseRead(data)
+	return &MappedFile{data: data}, nil
+}
+
+// Bytes returns the contents of the file.
+func (f *MappedFile) Bytes() []byte { return f.data }
+
+// Reader returns a Reader that reads the objects
+// in the file from the beginning, using the
+// mapped memory as its buffer (see NewReaderFromBytes).
+func (f *MappedFile) Reader() *Reader { return NewReaderFromBytes(f.data) }
+
+// Document returns a Document for the
+// first object in the file.
+func (f *MappedFile) Document() Document { return NewDocument(f.data) }
+
+// Close unmaps the file. The slice returned by
+// Bytes, Readers and Documents over the file, and
+// anything read from them without copying (e.g.
+// by ReadStringZC or Document.Raw), must not be
+// used afterwards.
+func (f *MappedFile) Close() error {
+	if f.data == nil {
+		return nil
+	}
+	err := syscall.Munmap(f.data)
+	f.data = nil
+	return err
+}
+
 // MarshalSizer is the combination
 // of the Marshaler and Sizer
 // interfaces.
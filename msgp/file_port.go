@@ -1,8 +1,9 @@
-// +build windows appengine
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd appengine purego
 
 package msgp
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
 )
@@ -15,6 +16,35 @@ type MarshalSizer interface {
 	Sizer
 }
 
+type MappedFile struct {
+	data []byte
+}
+
+// MapFile reads the file into memory,
+// since it can't be mapped in this build.
+func MapFile(file *os.File) (*MappedFile, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(io.NewSectionReader(file, 0, stat.Size()))
+	if err != nil {
+		return nil, err
+	}
+	return &MappedFile{data: data}, nil
+}
+
+func (f *MappedFile) Bytes() []byte { return f.data }
+
+func (f *MappedFile) Reader() *Reader { return NewReaderFromBytes(f.data) }
+
+func (f *MappedFile) Document() Document { return NewDocument(f.data) }
+
+func (f *MappedFile) Close() error {
+	f.data = nil
+	return nil
+}
+
 func ReadFile(dst Unmarshaler, file *os.File) error {
 	if u, ok := dst.(Decodable); ok {
 		return u.DecodeMsg(NewReader(file))
@@ -38,10 +68,10 @@ func WriteFile(src MarshalSizer, file *os.File) error {
 		return err
 	}
 
-	raw, err := src.MarshalMsg(nil)
-	if err != nil {
-		return err
+	raw, err := src.MarshalMsg(GetBufferSize(src.Msgsize()))
+	if err == nil {
+		_, err = file.Write(raw)
 	}
-	_, err = file.Write(raw)
+	PutBuffer(raw)
 	return err
 }
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"crypto/rand"
 	"github.com/tinylib/msgp/msgp"
+	"io/ioutil"
 	prand "math/rand"
 	"os"
 	"testing"
@@ -59,6 +60,64 @@ func TestReadWriteFile(t *testing.T) {
 	}
 }
 
+func TestMapFile(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Create("tmpfile-map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		f.Close()
+		os.Remove("tmpfile-map")
+	}()
+
+	var data []byte
+	for i := 0; i < 1000; i++ {
+		data = msgp.AppendMapHeader(data, 1)
+		data = msgp.AppendString(data, "i")
+		data = msgp.AppendInt(data, i)
+	}
+	// the last object is truncated
+	data = msgp.AppendString(data, "trunc")[:len(data)+3]
+	if _, err = f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	mf, err := msgp.MapFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(mf.Bytes(), data) {
+		t.Fatal("Bytes() doesn't match the file")
+	}
+	if i, err := mf.Document().Key("i").Int(); err != nil || i != 0 {
+		t.Errorf("Document: got %d, %v", i, err)
+	}
+	rd := mf.Reader()
+	for i := 0; i < 1000; i++ {
+		m := make(map[string]interface{})
+		if err = rd.ReadMapStrIntf(m); err != nil {
+			t.Fatal(err)
+		}
+		if m["i"] != int64(i) {
+			t.Fatalf("object %d: got %v", i, m)
+		}
+	}
+	if _, err = rd.ReadString(); err == nil {
+		t.Error("expected an error for the truncated object")
+	}
+	if err = mf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is unchanged
+	got, err := ioutil.ReadFile("tmpfile-map")
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("the file changed: %v", err)
+	}
+}
+
 var blobstrings = []string{"", "a string", "a longer string here!"}
 var blobfloats = []float64{0.0, -1.0, 1.0, 3.1415926535}
 var blobints = []int64{0, 1, -1, 80000, 1 << 30}
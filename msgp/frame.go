@@ -0,0 +1,491 @@
+package msgp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+)
+
+// A frame is a message preceded by its length
+// as a 4-byte big-endian unsigned integer, which
+// lets the reader of a stream (a TCP connection,
+// for example) find the boundaries between messages
+// without decoding them, and skip or reject
+// messages that it doesn't want. Since a frame
+// is always consumed entirely, a message that is
+// too large or can't be decoded only costs that
+// frame, and reading resumes with the next one.
+//
+// A FrameWriter with a Compressor (see SetCompression)
+// first writes a stream header naming the Compressor,
+// which consists of the length 0xffffffff, a version
+// byte (1), the Compressor's ID (or 0), and a byte of
+// flags. After the header, the top bit of a frame's
+// length is set if the message is compressed, and the
+// length is that of the compressed message. If the
+// lowest bit of the flags is set (see SetChecksum),
+// each frame is followed by the CRC-32C of its
+// contents as a 4-byte big-endian integer, which
+// isn't counted in its length. A FrameReader handles
+// the header wherever it appears between frames, as
+// long as it has been given the Compressor (see
+// SetCompressors).
+
+// frameHeaderSize is the size of a frame's length prefix
+const frameHeaderSize = 4
+
+const (
+	// frameStreamMarker is the length
+	// that begins a stream header
+	frameStreamMarker = math.MaxUint32
+
+	// frameStreamHeaderSize is the size of a
+	// stream header, including the marker
+	frameStreamHeaderSize = frameHeaderSize + 3
+
+	frameVersion = 1
+
+	// frameFlagChecksum is the stream header
+	// flag for frames followed by a checksum
+	frameFlagChecksum = 1
+
+	frameChecksumSize = 4
+
+	// frameCompressed marks a compressed
+	// frame in a stream with a header
+	frameCompressed = 1 << 31
+)
+
+// Compressor compresses and decompresses the
+// messages in frames, so that a FrameWriter
+// and FrameReader can use any compression
+// library (such as snappy or zstd) without
+// this package depending on it.
+type Compressor interface {
+	// ID identifies the compression in the
+	// stream header. It must not be zero.
+	ID() uint8
+
+	// Compress appends the compressed
+	// form of src to dst and returns it.
+	Compress(dst, src []byte) ([]byte, error)
+
+	// Decompress appends the decompressed
+	// form of src to dst and returns it. If
+	// max is positive, it is the most that a
+	// FrameReader accepts (see SetMaxFrameSize),
+	// and Decompress should stop once it has
+	// appended more than max bytes, rather than
+	// decompressing the rest of src, so that a
+	// small frame can't expand into a huge message.
+	Decompress(dst, src []byte, max int) ([]byte, error)
+}
+
+// UnknownCompressionError is returned by a
+// FrameReader for a stream header naming a
+// Compressor that it hasn't been given. The
+// rest of the stream can't be read.
+type UnknownCompressionError struct {
+	ID uint8
+}
+
+// Error implements the error interface
+func (u UnknownCompressionError) Error() string {
+	return "msgp: unknown frame compression " + strconv.Itoa(int(u.ID))
+}
+
+// Resumable is always 'false' for UnknownCompressionErrors
+func (u UnknownCompressionError) Resumable() bool { return false }
+
+// ErrFrameHeader is returned by a FrameReader
+// for a stream header that it can't understand.
+var ErrFrameHeader error = errFrameHeader{}
+
+type errFrameHeader struct{}
+
+func (e errFrameHeader) Error() string   { return "msgp: invalid frame stream header" }
+func (e errFrameHeader) Resumable() bool { return false }
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ChecksumError is returned by a FrameReader
+// for a frame that doesn't match its checksum
+// (see FrameWriter.SetChecksum). The frame has
+// been consumed, so the next one can be read,
+// although it may be unreadable if the length
+// of the frame was what had been corrupted.
+type ChecksumError struct {
+	Want, Got uint32
+}
+
+// Error implements the error interface
+func (c ChecksumError) Error() string {
+	return fmt.Sprintf("msgp: frame checksum mismatch: have %08x, computed %08x", c.Want, c.Got)
+}
+
+// Resumable is always 'true' for ChecksumErrors
+func (c ChecksumError) Resumable() bool { return true }
+
+// FrameWriter writes length-prefixed
+// messages to an io.Writer. Each frame is
+// written to the underlying writer with
+// a single call to Write.
+type FrameWriter struct {
+	w   io.Writer
+	buf []byte
+
+	// compression; see SetCompression
+	comp    Compressor
+	minComp int
+	cbuf    []byte
+	hdr     bool // whether a stream header is due
+	ext     bool // whether a stream header has been written
+	crc     bool // see SetChecksum
+}
+
+// NewFrameWriter returns a *FrameWriter that writes to w.
+func NewFrameWriter(w io.Writer) *FrameWriter {
+	return &FrameWriter{w: w}
+}
+
+// SetCompression sets the Compressor used for
+// the frames written after it, which is announced
+// to the reader with a stream header (see above).
+// Messages shorter than min bytes, and messages
+// that don't get any smaller, are written without
+// compression. A nil Compressor turns compression
+// off. Frames in a stream with a header are limited
+// to 2GiB.
+func (f *FrameWriter) SetCompression(c Compressor, min int) {
+	f.comp = c
+	f.minComp = min
+	f.hdr = c != nil || f.crc || f.ext
+}
+
+// SetChecksum sets whether the frames written
+// after it are followed by a CRC-32C of their
+// contents, so that a FrameReader can detect
+// corrupted frames. This is announced to the
+// reader with a stream header (see above).
+func (f *FrameWriter) SetChecksum(on bool) {
+	f.crc = on
+	f.hdr = on || f.comp != nil || f.ext
+}
+
+// WriteFrame writes msg, which should
+// be a complete message, as one frame.
+func (f *FrameWriter) WriteFrame(msg []byte) error {
+	f.buf = append(append(f.buf[:0], 0, 0, 0, 0), msg...)
+	return f.flush()
+}
+
+// Encode writes e as one frame.
+func (f *FrameWriter) Encode(e Marshaler) error {
+	var err error
+	f.buf, err = e.MarshalMsg(append(f.buf[:0], 0, 0, 0, 0))
+	if err != nil {
+		return err
+	}
+	return f.flush()
+}
+
+// flush writes the message in f.buf,
+// after room for its length, as a frame
+func (f *FrameWriter) flush() error {
+	if f.hdr {
+		if err := f.streamHeader(); err != nil {
+			return err
+		}
+	}
+	frame := f.buf
+	var flag uint32
+	if msg := f.buf[frameHeaderSize:]; f.comp != nil && len(msg) >= f.minComp {
+		var err error
+		f.cbuf, err = f.comp.Compress(append(f.cbuf[:0], 0, 0, 0, 0), msg)
+		if err != nil {
+			return err
+		}
+		if len(f.cbuf) < len(f.buf) {
+			frame, flag = f.cbuf, frameCompressed
+		}
+	}
+	sz := uint64(len(frame) - frameHeaderSize)
+	max := uint64(frameStreamMarker - 1)
+	if f.ext {
+		max = frameCompressed - 1
+	}
+	if sz > max {
+		return LimitError{Limit: "frame size", Size: sz, Max: max}
+	}
+	binary.BigEndian.PutUint32(frame, uint32(sz)|flag)
+	if f.crc {
+		var sum [frameChecksumSize]byte
+		binary.BigEndian.PutUint32(sum[:], crc32.Checksum(frame[frameHeaderSize:], castagnoli))
+		frame = append(frame, sum[:]...)
+	}
+	_, err := f.w.Write(frame)
+	return err
+}
+
+// streamHeader writes a stream header
+// for the current settings
+func (f *FrameWriter) streamHeader() error {
+	var id uint8
+	if f.comp != nil {
+		id = f.comp.ID()
+	}
+	var flags uint8
+	if f.crc {
+		flags |= frameFlagChecksum
+	}
+	hdr := [frameStreamHeaderSize]byte{0xff, 0xff, 0xff, 0xff, frameVersion, id, flags}
+	if _, err := f.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	f.hdr = false
+	f.ext = true
+	return nil
+}
+
+// FrameReader reads length-prefixed
+// messages, as written by a FrameWriter,
+// from an io.Reader.
+type FrameReader struct {
+	r   io.Reader
+	buf []byte
+	max uint32
+
+	// used by DecodeMsg to read
+	// the message in a frame
+	lr io.LimitedReader
+	br bytes.Reader
+	mr *Reader
+
+	// compression, as set by the last
+	// stream header; see SetCompressors
+	comps []Compressor
+	comp  Compressor
+	dbuf  []byte
+	ext   bool
+	crc   bool
+}
+
+// NewFrameReader returns a *FrameReader that reads from r.
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{r: r}
+}
+
+// SetMaxFrameSize sets the maximum size of the
+// message in a frame. Reading a larger frame causes
+// a LimitError, and the frame is discarded without
+// being kept in memory, so that reading can go on
+// with the next frame. Zero means no limit. (Without
+// a limit, memory is still only allocated as the
+// message is read, so a corrupt length can't cause
+// a huge allocation on its own.) The limit also
+// applies to decompressed messages, and is passed
+// to Compressor.Decompress.
+func (f *FrameReader) SetMaxFrameSize(n uint32) { f.max = n }
+
+// SetCompressors sets the Compressors that
+// the stream may use (see FrameWriter.SetCompression).
+// A stream header naming any other Compressor
+// causes an UnknownCompressionError.
+func (f *FrameReader) SetCompressors(cs ...Compressor) { f.comps = cs }
+
+// NextFrame reads the next frame and returns
+// the message in it. The returned slice is only
+// valid until the next call to NextFrame or Decode.
+// At the end of the stream, NextFrame returns io.EOF,
+// or io.ErrUnexpectedEOF if the stream ends in the
+// middle of a frame.
+func (f *FrameReader) NextFrame() ([]byte, error) {
+	sz, compressed, err := f.header()
+	if err != nil {
+		return nil, err
+	}
+	return f.frame(sz, compressed)
+}
+
+// frame reads the sz bytes of the current frame
+// and returns the message in it
+func (f *FrameReader) frame(sz uint32, compressed bool) ([]byte, error) {
+	f.buf = f.buf[:0]
+	for rem := int(sz); rem > 0; {
+		chunk := rem
+		if chunk > rawChunk && chunk > cap(f.buf)-len(f.buf) {
+			chunk = rawChunk
+		}
+		var i int
+		f.buf, i = ensure(f.buf, chunk)
+		if _, err := io.ReadFull(f.r, f.buf[i:]); err != nil {
+			return nil, noEOF(err)
+		}
+		rem -= chunk
+	}
+	if f.crc {
+		var sum [frameChecksumSize]byte
+		if _, err := io.ReadFull(f.r, sum[:]); err != nil {
+			return nil, noEOF(err)
+		}
+		want := binary.BigEndian.Uint32(sum[:])
+		if got := crc32.Checksum(f.buf, castagnoli); got != want {
+			return nil, ChecksumError{Want: want, Got: got}
+		}
+	}
+	if !compressed {
+		return f.buf, nil
+	}
+	var err error
+	f.dbuf, err = f.comp.Decompress(f.dbuf[:0], f.buf, int(f.max))
+	if err != nil {
+		return nil, err
+	}
+	if f.max > 0 && uint64(len(f.dbuf)) > uint64(f.max) {
+		return nil, LimitError{Limit: "frame size", Size: uint64(len(f.dbuf)), Max: uint64(f.max)}
+	}
+	return f.dbuf, nil
+}
+
+// Decode reads the next frame into u. The
+// frame must contain exactly one message;
+// if the message is followed by more data,
+// Decode returns ErrTrailingBytes.
+func (f *FrameReader) Decode(u Unmarshaler) error {
+	msg, err := f.NextFrame()
+	if err != nil {
+		return err
+	}
+	left, err := u.UnmarshalMsg(msg)
+	if err != nil {
+		return err
+	}
+	if len(left) > 0 {
+		return ErrTrailingBytes
+	}
+	return nil
+}
+
+// DecodeMsg reads the message in the next frame
+// into d. Unlike Decode, the message is decoded
+// as it is read, rather than being read into
+// memory first (except for compressed and
+// checksummed frames). Whether or not decoding succeeds,
+// the whole frame is consumed, so a message that
+// can't be decoded doesn't prevent the next one
+// from being read. As with Decode, the frame must
+// contain exactly one message.
+func (f *FrameReader) DecodeMsg(d Decodable) error {
+	sz, compressed, err := f.header()
+	if err != nil {
+		return err
+	}
+	// compressed messages have to be decompressed,
+	// and checksums verified, in memory first
+	inmem := compressed || f.crc
+	var src io.Reader = &f.lr
+	f.lr = io.LimitedReader{R: f.r, N: int64(sz)}
+	if inmem {
+		msg, err := f.frame(sz, compressed)
+		if err != nil {
+			return err
+		}
+		f.lr.N = 0
+		f.br.Reset(msg)
+		src = &f.br
+	}
+	if f.mr == nil {
+		f.mr = NewReader(src)
+	} else {
+		f.mr.Reset(src)
+	}
+	err = d.DecodeMsg(f.mr)
+	if Cause(err) == io.EOF {
+		// the frame ended before the message
+		err = io.ErrUnexpectedEOF
+	} else if err == nil && (f.mr.Buffered() > 0 || f.br.Len() > 0 || f.lr.N > 0) {
+		err = ErrTrailingBytes
+	}
+	f.br.Reset(nil)
+	if inmem {
+		return err
+	}
+	// skip whatever is left of the frame
+	if _, derr := io.Copy(ioutil.Discard, &f.lr); derr != nil {
+		return derr
+	}
+	if f.lr.N > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// header reads the length of the next frame,
+// and whether it is compressed, handling any
+// stream headers that come before it. If the
+// frame is too large, it is discarded.
+func (f *FrameReader) header() (uint32, bool, error) {
+	var hdr [frameHeaderSize]byte
+	if _, err := io.ReadFull(f.r, hdr[:]); err != nil {
+		return 0, false, err
+	}
+	sz := binary.BigEndian.Uint32(hdr[:])
+	for sz == frameStreamMarker {
+		if err := f.streamHeader(); err != nil {
+			return 0, false, err
+		}
+		if _, err := io.ReadFull(f.r, hdr[:]); err != nil {
+			return 0, false, noEOF(err)
+		}
+		sz = binary.BigEndian.Uint32(hdr[:])
+	}
+	compressed := false
+	if f.ext {
+		compressed = sz&frameCompressed != 0
+		sz &^= frameCompressed
+		if compressed && f.comp == nil {
+			return 0, false, ErrFrameHeader
+		}
+	}
+	if f.max > 0 && sz > f.max {
+		skip := int64(sz)
+		if f.crc {
+			skip += frameChecksumSize
+		}
+		if _, err := io.CopyN(ioutil.Discard, f.r, skip); err != nil {
+			return 0, false, noEOF(err)
+		}
+		return 0, false, LimitError{Limit: "frame size", Size: uint64(sz), Max: uint64(f.max)}
+	}
+	return sz, compressed, nil
+}
+
+// streamHeader reads the rest of a stream header
+func (f *FrameReader) streamHeader() error {
+	var hdr [frameStreamHeaderSize - frameHeaderSize]byte
+	if _, err := io.ReadFull(f.r, hdr[:]); err != nil {
+		return noEOF(err)
+	}
+	if hdr[0] != frameVersion || hdr[2]&^frameFlagChecksum != 0 {
+		return ErrFrameHeader
+	}
+	f.ext = true
+	f.crc = hdr[2]&frameFlagChecksum != 0
+	f.comp = nil
+	if id := hdr[1]; id != 0 {
+		for _, c := range f.comps {
+			if c.ID() == id {
+				f.comp = c
+			}
+		}
+		if f.comp == nil {
+			return UnknownCompressionError{ID: id}
+		}
+	}
+	return nil
+}
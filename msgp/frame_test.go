@@ -0,0 +1,382 @@
+package msgp
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFrames(t *testing.T) {
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf)
+	big := make([]byte, 3*rawChunk)
+	msgs := []Raw{
+		Raw(AppendString(nil, "hello")),
+		Raw(AppendNil(nil)),
+		Raw(AppendBytes(nil, big)),
+	}
+	for i := range msgs {
+		if err := fw.Encode(msgs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.WriteFrame(AppendInt(nil, 7)); err != nil {
+		t.Fatal(err)
+	}
+	enc := buf.Bytes()
+
+	// partial reads must not split frames
+	fr := NewFrameReader(iotest.OneByteReader(bytes.NewReader(enc)))
+	for i := range msgs {
+		f, err := fr.NextFrame()
+		if err != nil {
+			t.Fatalf("frame %d: %s", i, err)
+		}
+		if !bytes.Equal(f, msgs[i]) {
+			t.Errorf("frame %d: got %d bytes; want %d", i, len(f), len(msgs[i]))
+		}
+	}
+	var r Raw
+	if err := fr.Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, AppendInt(nil, 7)) {
+		t.Errorf("got %x", []byte(r))
+	}
+	if _, err := fr.NextFrame(); err != io.EOF {
+		t.Errorf("expected io.EOF; got %v", err)
+	}
+
+	// truncated in the length and in the message
+	for _, n := range []int{2, 6} {
+		fr = NewFrameReader(bytes.NewReader(enc[:n]))
+		if _, err := fr.NextFrame(); err != io.ErrUnexpectedEOF {
+			t.Errorf("%d bytes: expected io.ErrUnexpectedEOF; got %v", n, err)
+		}
+	}
+
+	fr = NewFrameReader(bytes.NewReader(enc))
+	fr.SetMaxFrameSize(16)
+	if _, err := fr.NextFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fr.NextFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fr.NextFrame(); err == nil {
+		t.Error("expected a LimitError")
+	} else if _, ok := err.(LimitError); !ok {
+		t.Errorf("expected a LimitError; got %T", err)
+	}
+	// the large frame is skipped
+	if f, err := fr.NextFrame(); err != nil || !bytes.Equal(f, AppendInt(nil, 7)) {
+		t.Errorf("after a LimitError: got %x, %v", f, err)
+	}
+
+	buf.Reset()
+	if err := fw.WriteFrame(append(AppendNil(nil), 0xc0)); err != nil {
+		t.Fatal(err)
+	}
+	fr = NewFrameReader(&buf)
+	if err := fr.Decode(&r); err != ErrTrailingBytes {
+		t.Errorf("expected ErrTrailingBytes; got %v", err)
+	}
+}
+
+// intDecoder decodes an int
+type intDecoder struct{ v int64 }
+
+func (d *intDecoder) DecodeMsg(r *Reader) (err error) {
+	d.v, err = r.ReadInt64()
+	return
+}
+
+func TestFrameReaderDecodeMsg(t *testing.T) {
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf)
+	frames := [][]byte{
+		AppendInt(nil, 1),
+		{0xc1},                             // invalid
+		AppendString(nil, "not an int"),    // wrong type
+		AppendInt(AppendInt(nil, 2), 3),    // trailing bytes
+		AppendBytes(nil, make([]byte, 64)), // too large
+		{},                                 // empty
+		AppendInt(nil, 4),
+	}
+	for _, f := range frames {
+		if err := fw.WriteFrame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enc := buf.Bytes()
+
+	fr := NewFrameReader(iotest.OneByteReader(bytes.NewReader(enc)))
+	fr.SetMaxFrameSize(32)
+	var d intDecoder
+	for i, f := range frames {
+		err := fr.DecodeMsg(&d)
+		switch i {
+		case 0, 6:
+			if err != nil {
+				t.Errorf("frame %d: %v", i, err)
+			} else if want, _, _ := ReadInt64Bytes(f); d.v != want {
+				t.Errorf("frame %d: got %d, want %d", i, d.v, want)
+			}
+		case 3:
+			if err != ErrTrailingBytes {
+				t.Errorf("frame %d: expected ErrTrailingBytes; got %v", i, err)
+			}
+		case 4:
+			if _, ok := err.(LimitError); !ok {
+				t.Errorf("frame %d: expected a LimitError; got %v", i, err)
+			}
+		case 5:
+			if err != io.ErrUnexpectedEOF {
+				t.Errorf("frame %d: expected io.ErrUnexpectedEOF; got %v", i, err)
+			}
+		default:
+			if err == nil {
+				t.Errorf("frame %d: expected an error", i)
+			}
+		}
+	}
+	if err := fr.DecodeMsg(&d); err != io.EOF {
+		t.Errorf("expected io.EOF; got %v", err)
+	}
+
+	fr = NewFrameReader(bytes.NewReader(enc[:len(enc)-1]))
+	for range frames[:len(frames)-1] {
+		fr.DecodeMsg(&d)
+	}
+	if err := fr.DecodeMsg(&d); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated frame: expected io.ErrUnexpectedEOF; got %v", err)
+	}
+
+	// including when the decoder wraps io.EOF
+	buf.Reset()
+	fw.WriteFrame(AppendString(AppendArrayHeader(nil, 2), "abc"))
+	var s twoStrings
+	if err := NewFrameReader(&buf).DecodeMsg(&s); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated message: expected io.ErrUnexpectedEOF; got %v", err)
+	}
+}
+
+// flateCompressor is a Compressor for the tests
+type flateCompressor struct{}
+
+func (flateCompressor) ID() uint8 { return 7 }
+
+func (flateCompressor) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, _ := flate.NewWriter(buf, flate.BestSpeed)
+	w.Write(src)
+	err := w.Close()
+	return buf.Bytes(), err
+}
+
+func (flateCompressor) Decompress(dst, src []byte, max int) ([]byte, error) {
+	var r io.Reader = flate.NewReader(bytes.NewReader(src))
+	if max > 0 {
+		r = io.LimitReader(r, int64(max)+1)
+	}
+	b, err := ioutil.ReadAll(r)
+	return append(dst, b...), err
+}
+
+// countingCompressor records how much
+// Decompress produced
+type countingCompressor struct {
+	flateCompressor
+	out int
+}
+
+func (c *countingCompressor) Decompress(dst, src []byte, max int) ([]byte, error) {
+	dst, err := c.flateCompressor.Decompress(dst, src, max)
+	c.out = len(dst)
+	return dst, err
+}
+
+func TestFrameDecompressionLimit(t *testing.T) {
+	// 16MB of zeros compress to a few kilobytes
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf)
+	fw.SetCompression(flateCompressor{}, 0)
+	if err := fw.WriteFrame(make([]byte, 16<<20)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.WriteFrame([]byte("next")); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 64<<10 {
+		t.Fatalf("compressed to %d bytes", buf.Len())
+	}
+
+	c := new(countingCompressor)
+	fr := NewFrameReader(bytes.NewReader(buf.Bytes()))
+	fr.SetCompressors(c)
+	fr.SetMaxFrameSize(1 << 10)
+	if _, err := fr.NextFrame(); err == nil {
+		t.Error("expected a LimitError")
+	} else if _, ok := err.(LimitError); !ok {
+		t.Errorf("expected a LimitError; got %T", err)
+	}
+	if c.out > 1<<10+1 {
+		t.Errorf("decompressed %d bytes with a limit of %d", c.out, 1<<10)
+	}
+	if f, err := fr.NextFrame(); err != nil || string(f) != "next" {
+		t.Errorf("after a LimitError: got %q, %v", f, err)
+	}
+}
+
+func TestFrameCompression(t *testing.T) {
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf)
+	small := Raw(AppendString(nil, "small"))
+	large := Raw(AppendBytes(nil, make([]byte, 3*rawChunk)))
+	if err := fw.Encode(small); err != nil {
+		t.Fatal(err)
+	}
+	fw.SetCompression(flateCompressor{}, 16)
+	for _, m := range []Raw{small, large, large} {
+		if err := fw.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fw.SetCompression(nil, 0)
+	if err := fw.WriteFrame(large); err != nil {
+		t.Fatal(err)
+	}
+	enc := buf.Bytes()
+	if len(enc) > 2*len(large) {
+		t.Errorf("%d bytes were written; the large frames weren't compressed", len(enc))
+	}
+	want := []Raw{small, small, large, large, large}
+
+	fr := NewFrameReader(iotest.OneByteReader(bytes.NewReader(enc)))
+	fr.SetCompressors(flateCompressor{})
+	for i := range want {
+		f, err := fr.NextFrame()
+		if err != nil {
+			t.Fatalf("frame %d: %s", i, err)
+		}
+		if !bytes.Equal(f, want[i]) {
+			t.Errorf("frame %d: got %d bytes; want %d", i, len(f), len(want[i]))
+		}
+	}
+	if _, err := fr.NextFrame(); err != io.EOF {
+		t.Errorf("expected io.EOF; got %v", err)
+	}
+
+	fr = NewFrameReader(bytes.NewReader(enc))
+	fr.SetCompressors(flateCompressor{})
+	for i := range want {
+		var r Raw
+		if err := fr.Decode(&r); err != nil {
+			t.Fatalf("frame %d: %s", i, err)
+		}
+		if !bytes.Equal(r, want[i]) {
+			t.Errorf("frame %d: got %d bytes; want %d", i, len(r), len(want[i]))
+		}
+	}
+
+	// the limit applies to the decompressed message
+	fr = NewFrameReader(bytes.NewReader(enc))
+	fr.SetCompressors(flateCompressor{})
+	fr.SetMaxFrameSize(2 * rawChunk)
+	fr.NextFrame()
+	fr.NextFrame()
+	if _, err := fr.NextFrame(); err == nil {
+		t.Error("expected a LimitError")
+	} else if _, ok := err.(LimitError); !ok {
+		t.Errorf("expected a LimitError; got %T", err)
+	}
+
+	fr = NewFrameReader(bytes.NewReader(enc))
+	fr.NextFrame()
+	if _, err := fr.NextFrame(); err != (UnknownCompressionError{ID: 7}) {
+		t.Errorf("expected an UnknownCompressionError; got %v", err)
+	}
+
+	bad := append([]byte{}, enc...)
+	bad[len(small)+frameHeaderSize+4] = 2 // version
+	fr = NewFrameReader(bytes.NewReader(bad))
+	fr.SetCompressors(flateCompressor{})
+	fr.NextFrame()
+	if _, err := fr.NextFrame(); err != ErrFrameHeader {
+		t.Errorf("expected ErrFrameHeader; got %v", err)
+	}
+}
+
+func TestFrameChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf)
+	fw.SetChecksum(true)
+	msgs := []Raw{
+		Raw(AppendString(nil, "hello")),
+		Raw(AppendBytes(nil, make([]byte, 64))),
+		Raw(AppendInt(nil, 7)),
+	}
+	for _, m := range msgs {
+		if err := fw.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enc := buf.Bytes()
+
+	fr := NewFrameReader(iotest.OneByteReader(bytes.NewReader(enc)))
+	for i := range msgs {
+		var r Raw
+		if err := fr.Decode(&r); err != nil {
+			t.Fatalf("frame %d: %s", i, err)
+		}
+		if !bytes.Equal(r, msgs[i]) {
+			t.Errorf("frame %d: got %x", i, []byte(r))
+		}
+	}
+	if _, err := fr.NextFrame(); err != io.EOF {
+		t.Errorf("expected io.EOF; got %v", err)
+	}
+
+	// corrupt the first message; the
+	// other frames can still be read
+	bad := append([]byte{}, enc...)
+	bad[frameStreamHeaderSize+frameHeaderSize+1] ^= 0x20
+	fr = NewFrameReader(bytes.NewReader(bad))
+	fr.SetMaxFrameSize(32)
+	var d intDecoder
+	if err := fr.DecodeMsg(&d); err == nil {
+		t.Error("expected a ChecksumError")
+	} else if ce, ok := err.(ChecksumError); !ok || !ce.Resumable() {
+		t.Errorf("expected a ChecksumError; got %v", err)
+	}
+	if _, err := fr.NextFrame(); err == nil {
+		t.Error("expected a LimitError")
+	} else if _, ok := err.(LimitError); !ok {
+		t.Errorf("expected a LimitError; got %T", err)
+	}
+	if err := fr.DecodeMsg(&d); err != nil || d.v != 7 {
+		t.Errorf("after a ChecksumError: got %d, %v", d.v, err)
+	}
+
+	// compressed frames are checked before
+	// they're decompressed
+	buf.Reset()
+	fw = NewFrameWriter(&buf)
+	fw.SetChecksum(true)
+	fw.SetCompression(flateCompressor{}, 0)
+	if err := fw.Encode(msgs[1]); err != nil {
+		t.Fatal(err)
+	}
+	bad = buf.Bytes()
+	bad[len(bad)-frameChecksumSize-1] ^= 0xff
+	fr = NewFrameReader(bytes.NewReader(bad))
+	fr.SetCompressors(flateCompressor{})
+	if _, err := fr.NextFrame(); err == nil {
+		t.Error("expected a ChecksumError")
+	} else if _, ok := err.(ChecksumError); !ok {
+		t.Errorf("expected a ChecksumError; got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+//go:build go1.18
+
+package msgp
+
+// The functions in this file read and write
+// containers of types with generated methods,
+// which usually have pointer receivers: for a
+// slice of T, the methods of *T are used, so
+// for a generated type Foo,
+//
+//	err := msgp.WriteSlice(w, foos)       // foos is a []Foo
+//	foos, err := msgp.ReadSlice[Foo](r, nil)
+//
+// encode and decode the slice as an array, just
+// as the code generator would for a []Foo field.
+
+// WriteSlice writes s as an array, using
+// the EncodeMsg method of each element.
+func WriteSlice[T any, PT interface {
+	*T
+	Encodable
+}](w *Writer, s []T) error {
+	if err := w.WriteArrayHeader(uint32(len(s))); err != nil {
+		return err
+	}
+	for i := range s {
+		if err := PT(&s[i]).EncodeMsg(w); err != nil {
+			return WrapError(err, i)
+		}
+	}
+	return nil
+}
+
+// ReadSlice reads an array into a new slice,
+// using the DecodeMsg method of each element.
+// If newT is non-nil, each element is decoded
+// into the value it returns, which lets the
+// caller initialize the elements first (or
+// reuse them); otherwise elements are decoded
+// into zero values.
+func ReadSlice[T any, PT interface {
+	*T
+	Decodable
+}](r *Reader, newT func() PT) ([]T, error) {
+	sz, err := r.ReadArrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	// the header can't be checked against the
+	// data left, so the slice grows as it's read
+	s := make([]T, 0, capHint(sz))
+	for i := 0; i < int(sz); i++ {
+		var t T
+		p := PT(&t)
+		if newT != nil {
+			p = newT()
+		}
+		if err := p.DecodeMsg(r); err != nil {
+			return nil, WrapError(err, i)
+		}
+		s = append(s, *p)
+	}
+	return s, nil
+}
+
+// AppendSlice appends s to b as an array, using
+// the MarshalMsg method of each element.
+func AppendSlice[T any, PT interface {
+	*T
+	Marshaler
+}](b []byte, s []T) ([]byte, error) {
+	b = AppendArrayHeader(b, uint32(len(s)))
+	for i := range s {
+		var err error
+		if b, err = PT(&s[i]).MarshalMsg(b); err != nil {
+			return b, WrapError(err, i)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalSlice reads an array from b into
+// a new slice, using the UnmarshalMsg method
+// of each element, and returns the remaining
+// bytes. newT is used as for ReadSlice.
+func UnmarshalSlice[T any, PT interface {
+	*T
+	Unmarshaler
+}](b []byte, newT func() PT) ([]T, []byte, error) {
+	sz, o, err := ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, b, err
+	}
+	// every element takes at least one byte
+	if uint64(sz) > uint64(len(o)) {
+		return nil, b, ErrShortBytes
+	}
+	s := make([]T, sz)
+	for i := range s {
+		p := PT(&s[i])
+		if newT != nil {
+			p = newT()
+		}
+		if o, err = p.UnmarshalMsg(o); err != nil {
+			return nil, b, WrapError(err, i)
+		}
+		s[i] = *p
+	}
+	return s, o, nil
+}
+
+// TypeCodec writes and reads values of type T. The
+// map functions below use one for the keys
+// and one for the values; StringCodec and the
+// others like it cover the builtin types, and
+// MsgCodec covers types with generated methods.
+type TypeCodec[T any] struct {
+	Write func(w *Writer, v T) error
+	Read  func(r *Reader) (T, error)
+}
+
+// Codecs for builtin types
+var (
+	StringCodec  = TypeCodec[string]{(*Writer).WriteString, (*Reader).ReadString}
+	IntCodec     = TypeCodec[int]{(*Writer).WriteInt, (*Reader).ReadInt}
+	Int64Codec   = TypeCodec[int64]{(*Writer).WriteInt64, (*Reader).ReadInt64}
+	Uint64Codec  = TypeCodec[uint64]{(*Writer).WriteUint64, (*Reader).ReadUint64}
+	Float64Codec = TypeCodec[float64]{(*Writer).WriteFloat64, (*Reader).ReadFloat64}
+	BoolCodec    = TypeCodec[bool]{(*Writer).WriteBool, (*Reader).ReadBool}
+	IntfCodec    = TypeCodec[interface{}]{(*Writer).WriteIntf, (*Reader).ReadIntf}
+)
+
+// MsgCodec returns a TypeCodec for a type with
+// generated methods, which uses the EncodeMsg
+// and DecodeMsg methods of *T.
+func MsgCodec[T any, PT interface {
+	*T
+	Encodable
+	Decodable
+}]() TypeCodec[T] {
+	return TypeCodec[T]{
+		Write: func(w *Writer, v T) error { return PT(&v).EncodeMsg(w) },
+		Read: func(r *Reader) (v T, err error) {
+			err = PT(&v).DecodeMsg(r)
+			return
+		},
+	}
+}
+
+// WriteMap writes m as a map, using kc to write
+// the keys and vc to write the values. The entries
+// are written in map iteration order, so, unlike
+// WriteMapStrIntf, WriteMap doesn't sort them when
+// the Writer has SortMaps set.
+func WriteMap[K comparable, V any](w *Writer, m map[K]V, kc TypeCodec[K], vc TypeCodec[V]) error {
+	if err := w.WriteMapHeader(uint32(len(m))); err != nil {
+		return err
+	}
+	for k, v := range m {
+		if err := kc.Write(w, k); err != nil {
+			return err
+		}
+		if err := vc.Write(w, v); err != nil {
+			return WrapError(err, k)
+		}
+	}
+	return nil
+}
+
+// ReadMap reads a map into m, using kc to read
+// the keys and vc to read the values, and returns
+// it. If m is nil, a new map is allocated; otherwise
+// its entries are deleted first, as with
+// ReadMapStrIntf.
+func ReadMap[K comparable, V any](r *Reader, m map[K]V, kc TypeCodec[K], vc TypeCodec[V]) (map[K]V, error) {
+	sz, err := r.ReadMapHeader()
+	if err != nil {
+		return m, err
+	}
+	if err = r.enter(); err != nil {
+		return m, err
+	}
+	defer r.leave()
+	if m == nil {
+		m = make(map[K]V, capHint(sz))
+	} else {
+		for k := range m {
+			delete(m, k)
+		}
+	}
+	for i := uint32(0); i < sz; i++ {
+		k, err := kc.Read(r)
+		if err != nil {
+			return m, err
+		}
+		v, err := vc.Read(r)
+		if err != nil {
+			return m, WrapError(err, k)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,94 @@
+//go:build go1.18
+
+package msgp
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSlices(t *testing.T) {
+	in := []concreteStr{{s: "a"}, {s: "bc"}, {s: ""}}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := WriteSlice(w, in); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	b, err := AppendSlice(nil, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, buf.Bytes()) {
+		t.Errorf("AppendSlice: got %x, want %x", b, buf.Bytes())
+	}
+
+	out, err := ReadSlice[concreteStr](NewReader(&buf), nil)
+	if err != nil || !reflect.DeepEqual(out, in) {
+		t.Errorf("ReadSlice: got %v, %v", out, err)
+	}
+	calls := 0
+	newT := func() *concreteStr { calls++; return &concreteStr{s: "old"} }
+	out, o, err := UnmarshalSlice(append(b, 0xc0), newT)
+	if err != nil || !reflect.DeepEqual(out, in) || len(o) != 1 || calls != len(in) {
+		t.Errorf("UnmarshalSlice: got %v, %x, %v after %d calls", out, o, err, calls)
+	}
+
+	// errors have the index of the element
+	bad := AppendInt(AppendString(AppendArrayHeader(nil, 2), "x"), 1)
+	if _, err := ReadSlice[concreteStr](NewReader(bytes.NewReader(bad)), nil); ErrorPath(err) != "[1]" {
+		t.Errorf("ReadSlice: got %v at %q", err, ErrorPath(err))
+	}
+	if _, _, err := UnmarshalSlice[concreteStr](bad, nil); ErrorPath(err) != "[1]" {
+		t.Errorf("UnmarshalSlice: got %v at %q", err, ErrorPath(err))
+	}
+	if _, _, err := UnmarshalSlice[concreteStr](AppendArrayHeader(nil, 1<<20), nil); err != ErrShortBytes {
+		t.Errorf("expected ErrShortBytes; got %v", err)
+	}
+
+	// a huge header isn't allocated up front
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = ReadSlice[concreteStr](NewReader(bytes.NewReader(AppendArrayHeader(nil, math.MaxUint32))), nil)
+	runtime.ReadMemStats(&after)
+	if ErrorPath(err) != "[0]" {
+		t.Errorf("ReadSlice: got %v at %q", err, ErrorPath(err))
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("ReadSlice allocated %d bytes", n)
+	}
+}
+
+func TestMaps(t *testing.T) {
+	in := map[string]concreteStr{"a": {s: "x"}, "b": {s: ""}}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := WriteMap(w, in, StringCodec, MsgCodec[concreteStr]()); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	old := map[string]concreteStr{"c": {s: "stale"}}
+	out, err := ReadMap(NewReader(&buf), old, StringCodec, MsgCodec[concreteStr]())
+	if err != nil || !reflect.DeepEqual(out, in) || !reflect.DeepEqual(old, in) {
+		t.Errorf("ReadMap: got %v, %v", out, err)
+	}
+
+	ints := map[int64]bool{-1: true, 300: false}
+	w.Reset(&buf)
+	if err := WriteMap(w, ints, Int64Codec, BoolCodec); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if got, err := ReadMap[int64, bool](NewReader(&buf), nil, Int64Codec, BoolCodec); err != nil || !reflect.DeepEqual(got, ints) {
+		t.Errorf("ReadMap: got %v, %v", got, err)
+	}
+
+	// errors have the key of the entry
+	bad := AppendInt(AppendString(AppendMapHeader(nil, 1), "k"), 1)
+	if _, err := ReadMap[string, concreteStr](NewReader(bytes.NewReader(bad)), nil, StringCodec, MsgCodec[concreteStr]()); ErrorPath(err) != "k" {
+		t.Errorf("ReadMap: got %v at %q", err, ErrorPath(err))
+	}
+}
@@ -0,0 +1,152 @@
+package msgp
+
+// Stats describes the contents of
+// an object, as reported by Inspect.
+type Stats struct {
+	// Size is the encoded size
+	// of the object in bytes.
+	Size int
+
+	// Types holds the number of objects of
+	// each type, counting the object itself and
+	// every object nested within it, including
+	// map keys. Extensions that NextType knows
+	// about are counted under their own types.
+	Types map[Type]int
+
+	// StrBytes and BinBytes are the total
+	// lengths of the contents of the 'str' and
+	// 'bin' objects, not counting their headers.
+	StrBytes, BinBytes int64
+
+	// MaxDepth is the deepest nesting of
+	// maps and arrays, which is zero for an
+	// object that is not a map or array.
+	MaxDepth int
+
+	// LargestMap and LargestArray are the
+	// map and the array with the most entries.
+	// Their Size is zero if there are none.
+	LargestMap, LargestArray ContainerStats
+}
+
+// ContainerStats describes
+// a map or array within an
+// object inspected by Inspect.
+type ContainerStats struct {
+	// Path leads to the container as described
+	// for Get: each element is either a map key
+	// or an array index. Keys that are 'str' or
+	// 'bin' are strings; other keys are the Raw
+	// encoding of the key.
+	Path []interface{}
+
+	// Len is the number of
+	// elements or map entries.
+	Len int
+
+	// Size is the encoded size of
+	// the container, including its header.
+	Size int
+}
+
+// Inspect walks the first object in 'b'
+// without decoding it and returns statistics
+// about its contents, such as the number of
+// objects of each type and the largest maps
+// and arrays, which is useful for finding out
+// what makes a message large. If the object is
+// malformed, Inspect returns the statistics
+// gathered before the error was found.
+func Inspect(b []byte) (Stats, error) {
+	s := Stats{Types: make(map[Type]int)}
+	in := inspector{s: &s}
+	rest, err := in.walk(b, 0)
+	if err != nil {
+		return s, err
+	}
+	s.Size = len(b) - len(rest)
+	return s, nil
+}
+
+type inspector struct {
+	s    *Stats
+	path []interface{}
+}
+
+// walk records the object at the beginning
+// of 'b' and returns the bytes that follow it
+func (in *inspector) walk(b []byte, depth int) ([]byte, error) {
+	t := NextType(b)
+	in.s.Types[t]++
+	switch t {
+	case StrType:
+		s, o, err := ReadStringZC(b)
+		in.s.StrBytes += int64(len(s))
+		return o, in.wrap(err)
+	case BinType:
+		s, o, err := ReadBytesZC(b)
+		in.s.BinBytes += int64(len(s))
+		return o, in.wrap(err)
+	case MapType, ArrayType:
+	default:
+		o, err := Skip(b)
+		return o, in.wrap(err)
+	}
+
+	depth++
+	if depth > in.s.MaxDepth {
+		in.s.MaxDepth = depth
+	}
+	var sz uint32
+	var o []byte
+	var err error
+	if t == MapType {
+		sz, o, err = ReadMapHeaderBytes(b)
+	} else {
+		sz, o, err = ReadArrayHeaderBytes(b)
+	}
+	if err != nil {
+		return b, in.wrap(err)
+	}
+	for i := uint32(0); i < sz; i++ {
+		var elem interface{} = int(i)
+		if t == MapType {
+			key := o
+			if o, err = in.walk(o, depth); err != nil {
+				return b, err
+			}
+			if k, _, err := ReadMapKeyZC(key); err == nil {
+				elem = string(k)
+			} else {
+				elem = Raw(key[:len(key)-len(o)])
+			}
+		}
+		in.path = append(in.path, elem)
+		o, err = in.walk(o, depth)
+		in.path = in.path[:len(in.path)-1]
+		if err != nil {
+			return b, err
+		}
+	}
+
+	largest := &in.s.LargestArray
+	if t == MapType {
+		largest = &in.s.LargestMap
+	}
+	if largest.Size == 0 || int(sz) > largest.Len {
+		*largest = ContainerStats{
+			Path: append([]interface{}{}, in.path...),
+			Len:  int(sz),
+			Size: len(b) - len(o),
+		}
+	}
+	return o, nil
+}
+
+func (in *inspector) wrap(err error) error {
+	if err == nil || len(in.path) == 0 {
+		return err
+	}
+	return WrapError(err, in.path...)
+}
@@ -0,0 +1,86 @@
+package msgp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	// {"a": [1, 2, {"b": bin(10), 5: "xyz"}], "t": time, "e": []}
+	var b []byte
+	b = AppendMapHeader(b, 3)
+	b = AppendString(b, "a")
+	arr := len(b)
+	b = AppendArrayHeader(b, 3)
+	b = AppendInt(b, 1)
+	b = AppendUint(b, 2)
+	inner := len(b)
+	b = AppendMapHeader(b, 2)
+	b = AppendString(b, "b")
+	b = AppendBytes(b, make([]byte, 10))
+	five := len(b)
+	b = AppendInt(b, 5)
+	b = AppendString(b, "xyz")
+	innerEnd := len(b)
+	arrEnd := len(b)
+	b = AppendString(b, "t")
+	b = AppendTime(b, time.Unix(1, 0))
+	b = AppendString(b, "e")
+	b = AppendArrayHeader(b, 0)
+	size := len(b)
+	b = AppendNil(b) // not part of the object
+
+	s, err := Inspect(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Stats{
+		Size: size,
+		Types: map[Type]int{
+			MapType:   2,
+			ArrayType: 2,
+			StrType:   5,
+			BinType:   1,
+			IntType:   3, // positive fixints are 'int'
+			TimeType:  1,
+		},
+		StrBytes: 7,
+		BinBytes: 10,
+		MaxDepth: 3,
+		LargestMap: ContainerStats{
+			Path: []interface{}{},
+			Len:  3,
+			Size: size,
+		},
+		LargestArray: ContainerStats{
+			Path: []interface{}{"a"},
+			Len:  3,
+			Size: arrEnd - arr,
+		},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got  %+v\nwant %+v", s, want)
+	}
+
+	// the inner map has a non-string key
+	s, err = Inspect(b[inner:innerEnd])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.LargestMap.Len != 2 || len(s.LargestMap.Path) != 0 {
+		t.Errorf("got %+v", s.LargestMap)
+	}
+
+	if _, err = Inspect(b[:innerEnd-1]); err == nil {
+		t.Error("expected an error for truncated input")
+	}
+	bad := append([]byte{}, b...)
+	bad[five] = 0xc1
+	if _, err = Inspect(bad); err == nil {
+		t.Fatal("expected an error for an invalid prefix")
+	}
+	if want := "a[2]"; ErrorPath(err) != want {
+		t.Errorf("error %q: got path %q, want %q", err, ErrorPath(err), want)
+	}
+}
@@ -4,13 +4,17 @@ package msgp
 	integer encoding utilities
 	(inline-able)
 
-	TODO(tinylib): there are faster,
-	albeit non-portable solutions
-	to the code below. implement
-	byteswap?
+	Each function checks the length of 'b'
+	once, up front, so that the compiler can
+	drop the per-byte bounds checks and merge
+	the byte loads and stores into single
+	byte-swapped loads and stores on the
+	architectures that support them (amd64,
+	arm64, and others).
    ---------------------------------- */
 
 func putMint64(b []byte, i int64) {
+	_ = b[8] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = mint64
 	b[1] = byte(i >> 56)
 	b[2] = byte(i >> 48)
@@ -23,6 +27,7 @@ func putMint64(b []byte, i int64) {
 }
 
 func getMint64(b []byte) int64 {
+	_ = b[8] // bounds check hint to compiler; see golang.org/issue/14808
 	return (int64(b[1]) << 56) | (int64(b[2]) << 48) |
 		(int64(b[3]) << 40) | (int64(b[4]) << 32) |
 		(int64(b[5]) << 24) | (int64(b[6]) << 16) |
@@ -30,6 +35,7 @@ func getMint64(b []byte) int64 {
 }
 
 func putMint32(b []byte, i int32) {
+	_ = b[4] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = mint32
 	b[1] = byte(i >> 24)
 	b[2] = byte(i >> 16)
@@ -38,29 +44,35 @@ func putMint32(b []byte, i int32) {
 }
 
 func getMint32(b []byte) int32 {
+	_ = b[4] // bounds check hint to compiler; see golang.org/issue/14808
 	return (int32(b[1]) << 24) | (int32(b[2]) << 16) | (int32(b[3]) << 8) | (int32(b[4]))
 }
 
 func putMint16(b []byte, i int16) {
+	_ = b[2] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = mint16
 	b[1] = byte(i >> 8)
 	b[2] = byte(i)
 }
 
 func getMint16(b []byte) (i int16) {
+	_ = b[2] // bounds check hint to compiler; see golang.org/issue/14808
 	return (int16(b[1]) << 8) | int16(b[2])
 }
 
 func putMint8(b []byte, i int8) {
+	_ = b[1] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = mint8
 	b[1] = byte(i)
 }
 
 func getMint8(b []byte) (i int8) {
+	_ = b[1] // bounds check hint to compiler; see golang.org/issue/14808
 	return int8(b[1])
 }
 
 func putMuint64(b []byte, u uint64) {
+	_ = b[8] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = muint64
 	b[1] = byte(u >> 56)
 	b[2] = byte(u >> 48)
@@ -73,6 +85,7 @@ func putMuint64(b []byte, u uint64) {
 }
 
 func getMuint64(b []byte) uint64 {
+	_ = b[8] // bounds check hint to compiler; see golang.org/issue/14808
 	return (uint64(b[1]) << 56) | (uint64(b[2]) << 48) |
 		(uint64(b[3]) << 40) | (uint64(b[4]) << 32) |
 		(uint64(b[5]) << 24) | (uint64(b[6]) << 16) |
@@ -80,6 +93,7 @@ func getMuint64(b []byte) uint64 {
 }
 
 func putMuint32(b []byte, u uint32) {
+	_ = b[4] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = muint32
 	b[1] = byte(u >> 24)
 	b[2] = byte(u >> 16)
@@ -88,29 +102,35 @@ func putMuint32(b []byte, u uint32) {
 }
 
 func getMuint32(b []byte) uint32 {
+	_ = b[4] // bounds check hint to compiler; see golang.org/issue/14808
 	return (uint32(b[1]) << 24) | (uint32(b[2]) << 16) | (uint32(b[3]) << 8) | (uint32(b[4]))
 }
 
 func putMuint16(b []byte, u uint16) {
+	_ = b[2] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = muint16
 	b[1] = byte(u >> 8)
 	b[2] = byte(u)
 }
 
 func getMuint16(b []byte) uint16 {
+	_ = b[2] // bounds check hint to compiler; see golang.org/issue/14808
 	return (uint16(b[1]) << 8) | uint16(b[2])
 }
 
 func putMuint8(b []byte, u uint8) {
+	_ = b[1] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = muint8
 	b[1] = byte(u)
 }
 
 func getMuint8(b []byte) uint8 {
+	_ = b[1] // bounds check hint to compiler; see golang.org/issue/14808
 	return uint8(b[1])
 }
 
 func getUnix(b []byte) (sec int64, nsec int32) {
+	_ = b[11] // bounds check hint to compiler; see golang.org/issue/14808
 	sec = (int64(b[0]) << 56) | (int64(b[1]) << 48) |
 		(int64(b[2]) << 40) | (int64(b[3]) << 32) |
 		(int64(b[4]) << 24) | (int64(b[5]) << 16) |
@@ -121,6 +141,7 @@ func getUnix(b []byte) (sec int64, nsec int32) {
 }
 
 func putUnix(b []byte, sec int64, nsec int32) {
+	_ = b[11] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = byte(sec >> 56)
 	b[1] = byte(sec >> 48)
 	b[2] = byte(sec >> 40)
@@ -141,12 +162,14 @@ func putUnix(b []byte, sec int64, nsec int32) {
 
 // write prefix and uint8
 func prefixu8(b []byte, pre byte, sz uint8) {
+	_ = b[1] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = pre
 	b[1] = byte(sz)
 }
 
 // write prefix and big-endian uint16
 func prefixu16(b []byte, pre byte, sz uint16) {
+	_ = b[2] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = pre
 	b[1] = byte(sz >> 8)
 	b[2] = byte(sz)
@@ -154,6 +177,7 @@ func prefixu16(b []byte, pre byte, sz uint16) {
 
 // write prefix and big-endian uint32
 func prefixu32(b []byte, pre byte, sz uint32) {
+	_ = b[4] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = pre
 	b[1] = byte(sz >> 24)
 	b[2] = byte(sz >> 16)
@@ -162,6 +186,7 @@ func prefixu32(b []byte, pre byte, sz uint32) {
 }
 
 func prefixu64(b []byte, pre byte, sz uint64) {
+	_ = b[8] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = pre
 	b[1] = byte(sz >> 56)
 	b[2] = byte(sz >> 48)
@@ -0,0 +1,64 @@
+package msgp
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestIntegerHelpers(t *testing.T) {
+	var b [12]byte
+	putMuint64(b[:], 0x0102030405060708)
+	if b[0] != muint64 || binary.BigEndian.Uint64(b[1:]) != 0x0102030405060708 {
+		t.Errorf("putMuint64: got %x", b[:9])
+	}
+	if u := getMuint64(b[:]); u != 0x0102030405060708 {
+		t.Errorf("getMuint64: got %x", u)
+	}
+	putMint32(b[:], -2)
+	if i := getMint32(b[:]); b[0] != mint32 || i != -2 {
+		t.Errorf("putMint32/getMint32: got %x, %d", b[:5], i)
+	}
+	prefixu16(b[:], mstr16, 0xabcd)
+	if b[0] != mstr16 || binary.BigEndian.Uint16(b[1:]) != 0xabcd {
+		t.Errorf("prefixu16: got %x", b[:3])
+	}
+	prefixu32(b[:], mbin32, 0xdeadbeef)
+	if b[0] != mbin32 || binary.BigEndian.Uint32(b[1:]) != 0xdeadbeef {
+		t.Errorf("prefixu32: got %x", b[:5])
+	}
+	putUnix(b[:], -1, 999999999)
+	if sec, nsec := getUnix(b[:]); sec != -1 || nsec != 999999999 {
+		t.Errorf("putUnix/getUnix: got %d, %d", sec, nsec)
+	}
+}
+
+// These measure the helpers that write and read
+// the big-endian integers in headers and numbers,
+// which should compile to single byte-swapped
+// loads and stores where the CPU has them.
+
+func BenchmarkPrefixu32(b *testing.B) {
+	var buf [5]byte
+	for i := 0; i < b.N; i++ {
+		prefixu32(buf[:], mmap32, uint32(i))
+	}
+}
+
+func BenchmarkPutMuint64(b *testing.B) {
+	var buf [9]byte
+	for i := 0; i < b.N; i++ {
+		putMuint64(buf[:], uint64(i))
+	}
+}
+
+func BenchmarkGetMuint64(b *testing.B) {
+	var buf [9]byte
+	putMuint64(buf[:], 0x0102030405060708)
+	var u uint64
+	for i := 0; i < b.N; i++ {
+		u += getMuint64(buf[:])
+	}
+	if u == 1 {
+		b.Log(u)
+	}
+}
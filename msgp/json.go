@@ -2,10 +2,10 @@ package msgp
 
 import (
 	"bufio"
-	"encoding/base64"
 	"encoding/json"
 	"io"
 	"strconv"
+	"time"
 	"unicode/utf8"
 )
 
@@ -61,6 +61,10 @@ func CopyToJSON(dst io.Writer, src io.Reader) (n int64, err error) {
 // JSON to 'w' until the underlying reader returns io.EOF. It returns
 // the number of bytes written, and an error if it stopped before EOF.
 func (r *Reader) WriteToJSON(w io.Writer) (n int64, err error) {
+	return r.writeToJSON(w, nil)
+}
+
+func (r *Reader) writeToJSON(w io.Writer, o *ToJSONOptions) (n int64, err error) {
 	var j jsWriter
 	var bf *bufio.Writer
 	if jsw, ok := w.(jsWriter); ok {
@@ -69,6 +73,10 @@ func (r *Reader) WriteToJSON(w io.Writer) (n int64, err error) {
 		bf = bufio.NewWriter(w)
 		j = bf
 	}
+	if o != nil && (o.pretty() || o.SortKeys) {
+		return r.writeRawJSON(j, bf, o)
+	}
+	j = withOptions(j, o)
 	var nn int
 	for err == nil {
 		nn, err = rwNext(j, r)
@@ -87,6 +95,67 @@ func (r *Reader) WriteToJSON(w io.Writer) (n int64, err error) {
 	return
 }
 
+// writeRawJSON is WriteToJSON for output
+// that needs whole objects at a time: each object
+// is copied into memory and translated as a slice
+func (r *Reader) writeRawJSON(j jsWriter, bf *bufio.Writer, o *ToJSONOptions) (n int64, err error) {
+	var raw []byte
+	cw := &countWriter{jsWriter: j}
+	out := withOptions(cw, o)
+	for first := true; ; first = false {
+		raw = raw[:0]
+		err = appendNext(r, &raw)
+		if err != nil {
+			break
+		}
+		if !first && o.pretty() {
+			if err = cw.WriteByte('\n'); err != nil {
+				break
+			}
+		}
+		_, r.scratch, err = writeNext(out, raw, r.scratch)
+		if err != nil {
+			break
+		}
+	}
+	n = cw.n
+	if err == io.EOF {
+		err = nil
+	}
+	if bf != nil {
+		if ferr := bf.Flush(); err == nil {
+			err = ferr
+		}
+	}
+	return
+}
+
+// countWriter counts the bytes written to a jsWriter
+type countWriter struct {
+	jsWriter
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.jsWriter.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countWriter) WriteByte(b byte) error {
+	err := c.jsWriter.WriteByte(b)
+	if err == nil {
+		c.n++
+	}
+	return err
+}
+
+func (c *countWriter) WriteString(s string) (int, error) {
+	n, err := c.jsWriter.WriteString(s)
+	c.n += int64(n)
+	return n, err
+}
+
 func rwNext(w jsWriter, src *Reader) (int, error) {
 	t, err := src.NextType()
 	if err != nil {
@@ -104,6 +173,10 @@ func rwMap(dst jsWriter, src *Reader) (n int, err error) {
 	if err != nil {
 		return
 	}
+	if err = src.enter(); err != nil {
+		return
+	}
+	defer src.leave()
 
 	if sz == 0 {
 		return dst.WriteString("{}")
@@ -168,6 +241,10 @@ func rwArray(dst jsWriter, src *Reader) (n int, err error) {
 	if err != nil {
 		return
 	}
+	if err = src.enter(); err != nil {
+		return
+	}
+	defer src.leave()
 	comma := false
 	for i := uint32(0); i < sz; i++ {
 		if comma {
@@ -206,8 +283,9 @@ func rwFloat32(dst jsWriter, src *Reader) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	src.scratch = strconv.AppendFloat(src.scratch[:0], float64(f), 'f', -1, 64)
-	return dst.Write(src.scratch)
+	var n int
+	n, src.scratch, err = writeJSONFloat(dst, float64(f), 32, src.scratch)
+	return n, err
 }
 
 func rwFloat64(dst jsWriter, src *Reader) (int, error) {
@@ -215,8 +293,9 @@ func rwFloat64(dst jsWriter, src *Reader) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	src.scratch = strconv.AppendFloat(src.scratch[:0], f, 'f', -1, 32)
-	return dst.Write(src.scratch)
+	var n int
+	n, src.scratch, err = writeJSONFloat(dst, f, 64, src.scratch)
+	return n, err
 }
 
 func rwInt(dst jsWriter, src *Reader) (int, error) {
@@ -253,11 +332,9 @@ func rwTime(dst jsWriter, src *Reader) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	bts, err := t.MarshalJSON()
-	if err != nil {
-		return 0, err
-	}
-	return dst.Write(bts)
+	var n int
+	n, src.scratch, err = writeJSONTime(dst, t, src.scratch)
+	return n, err
 }
 
 func rwExtension(dst jsWriter, src *Reader) (n int, err error) {
@@ -266,9 +343,26 @@ func rwExtension(dst jsWriter, src *Reader) (n int, err error) {
 		return 0, err
 	}
 
+	if f, ok := src.registry().LookupJSON(et); ok {
+		var sz, hdr int
+		if _, sz, hdr, err = src.peekExtensionHeader(); err != nil {
+			return
+		}
+		var p []byte
+		if p, err = src.peek(hdr + sz); err != nil {
+			return
+		}
+		n, src.scratch, err = writeJSONExt(dst, f, p[hdr:], src.scratch)
+		if err != nil {
+			return
+		}
+		_, err = src.R.Skip(hdr + sz)
+		return
+	}
+
 	// registered extensions can override
 	// the JSON encoding
-	if j, ok := extensionReg[et]; ok {
+	if j, ok := src.registry().Lookup(et); ok {
 		var bts []byte
 		e := j()
 		err = src.ReadExtension(e)
@@ -282,6 +376,15 @@ func rwExtension(dst jsWriter, src *Reader) (n int, err error) {
 		return dst.Write(bts)
 	}
 
+	if et == TimestampExtension {
+		var t time.Time
+		if t, err = src.ReadTimestamp(); err != nil {
+			return
+		}
+		n, src.scratch, err = writeJSONTime(dst, t, src.scratch)
+		return
+	}
+
 	e := RawExtension{}
 	e.Type = et
 	err = src.ReadExtension(&e)
@@ -296,7 +399,7 @@ func rwExtension(dst jsWriter, src *Reader) (n int, err error) {
 	}
 	n++
 
-	nn, err = dst.WriteString(`"type:"`)
+	nn, err = dst.WriteString(`"type":`)
 	n += nn
 	if err != nil {
 		return
@@ -309,25 +412,22 @@ func rwExtension(dst jsWriter, src *Reader) (n int, err error) {
 		return
 	}
 
-	nn, err = dst.WriteString(`,"data":"`)
+	nn, err = dst.WriteString(`,"data":`)
 	n += nn
 	if err != nil {
 		return
 	}
 
-	enc := base64.NewEncoder(base64.StdEncoding, dst)
-
-	nn, err = enc.Write(e.Data)
+	nn, src.scratch, err = writeJSONBin(dst, e.Data, false, src.scratch)
 	n += nn
 	if err != nil {
 		return
 	}
-	err = enc.Close()
+	err = dst.WriteByte('}')
 	if err != nil {
 		return
 	}
-	nn, err = dst.WriteString(`"}`)
-	n += nn
+	n++
 	return
 }
 
@@ -348,19 +448,19 @@ func rwString(dst jsWriter, src *Reader) (n int, err error) {
 
 	switch lead {
 	case mstr8:
-		p, err = src.R.Next(2)
+		p, err = src.next(2)
 		if err != nil {
 			return
 		}
 		read = int(uint8(p[1]))
 	case mstr16:
-		p, err = src.R.Next(3)
+		p, err = src.next(3)
 		if err != nil {
 			return
 		}
 		read = int(big.Uint16(p[1:]))
 	case mstr32:
-		p, err = src.R.Next(5)
+		p, err = src.next(5)
 		if err != nil {
 			return
 		}
@@ -370,7 +470,10 @@ func rwString(dst jsWriter, src *Reader) (n int, err error) {
 		return
 	}
 write:
-	p, err = src.R.Next(read)
+	if err = src.checkShort(int64(read)); err != nil {
+		return
+	}
+	p, err = src.next(read)
 	if err != nil {
 		return
 	}
@@ -379,31 +482,18 @@ write:
 }
 
 func rwBytes(dst jsWriter, src *Reader) (n int, err error) {
-	var nn int
-	err = dst.WriteByte('"')
-	if err != nil {
-		return
-	}
-	n++
 	src.scratch, err = src.ReadBytes(src.scratch[:0])
 	if err != nil {
 		return
 	}
-	enc := base64.NewEncoder(base64.StdEncoding, dst)
-	nn, err = enc.Write(src.scratch)
-	n += nn
-	if err != nil {
-		return
-	}
-	err = enc.Close()
-	if err != nil {
-		return
+	// encode into the spare capacity after the data,
+	// and keep the encoding buffer if it had to grow
+	data := src.scratch
+	var enc []byte
+	n, enc, err = writeJSONBin(dst, data, false, data[len(data):])
+	if cap(enc) > cap(src.scratch) {
+		src.scratch = enc
 	}
-	err = dst.WriteByte('"')
-	if err != nil {
-		return
-	}
-	n++
 	return
 }
 
@@ -418,10 +508,11 @@ func rwquoted(dst jsWriter, s []byte) (n int, err error) {
 		return
 	}
 	n++
+	noHTML := jsonOptions(dst).DisableHTMLEscape
 	start := 0
 	for i := 0; i < len(s); {
 		if b := s[i]; b < utf8.RuneSelf {
-			if 0x20 <= b && b != '\\' && b != '"' && b != '<' && b != '>' && b != '&' {
+			if 0x20 <= b && b != '\\' && b != '"' && (noHTML || b != '<' && b != '>' && b != '&') {
 				i++
 				continue
 			}
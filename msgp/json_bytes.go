@@ -2,7 +2,6 @@ package msgp
 
 import (
 	"bufio"
-	"encoding/base64"
 	"encoding/json"
 	"io"
 	"strconv"
@@ -39,6 +38,10 @@ func init() {
 // no errors are encountered, the length of the returned
 // slice will be zero.
 func UnmarshalAsJSON(w io.Writer, msg []byte) ([]byte, error) {
+	return unmarshalAsJSON(w, msg, nil)
+}
+
+func unmarshalAsJSON(w io.Writer, msg []byte, o *ToJSONOptions) ([]byte, error) {
 	var (
 		scratch []byte
 		cast    bool
@@ -51,8 +54,14 @@ func UnmarshalAsJSON(w io.Writer, msg []byte) ([]byte, error) {
 	} else {
 		dst = bufio.NewWriterSize(w, 512)
 	}
-	for len(msg) > 0 && err == nil {
-		msg, scratch, err = writeNext(dst, msg, scratch)
+	out := withOptions(dst, o)
+	for first := true; len(msg) > 0 && err == nil; first = false {
+		if !first && o != nil && o.pretty() {
+			if err = out.WriteByte('\n'); err != nil {
+				break
+			}
+		}
+		msg, scratch, err = writeNext(out, msg, scratch)
 	}
 	if !cast && err == nil {
 		err = dst.(*bufio.Writer).Flush()
@@ -85,6 +94,10 @@ func rwArrayBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error
 	if err != nil {
 		return msg, scratch, err
 	}
+	if sz == 0 {
+		_, err = w.WriteString("[]")
+		return msg, scratch, err
+	}
 	err = w.WriteByte('[')
 	if err != nil {
 		return msg, scratch, err
@@ -96,11 +109,23 @@ func rwArrayBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error
 				return msg, scratch, err
 			}
 		}
+		delta := 0
+		if i == 0 {
+			delta = 1
+		}
+		err = writeJSONNewline(w, delta)
+		if err != nil {
+			return msg, scratch, err
+		}
 		msg, scratch, err = writeNext(w, msg, scratch)
 		if err != nil {
 			return msg, scratch, err
 		}
 	}
+	err = writeJSONNewline(w, -1)
+	if err != nil {
+		return msg, scratch, err
+	}
 	err = w.WriteByte(']')
 	return msg, scratch, err
 }
@@ -110,42 +135,95 @@ func rwMapBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error)
 	if err != nil {
 		return msg, scratch, err
 	}
+	if sz == 0 {
+		_, err = w.WriteString("{}")
+		return msg, scratch, err
+	}
+	if jsonOptions(w).SortKeys {
+		return rwSortedMapBytes(w, msg, sz, scratch)
+	}
 	err = w.WriteByte('{')
 	if err != nil {
 		return msg, scratch, err
 	}
 	for i := uint32(0); i < sz; i++ {
-		if i != 0 {
-			err = w.WriteByte(',')
-			if err != nil {
-				return msg, scratch, err
-			}
-		}
-		msg, scratch, err = rwMapKeyBytes(w, msg, scratch)
+		msg, scratch, err = rwMapEntryBytes(w, msg, i == 0, scratch)
 		if err != nil {
 			return msg, scratch, err
 		}
-		err = w.WriteByte(':')
+	}
+	err = writeJSONNewline(w, -1)
+	if err != nil {
+		return msg, scratch, err
+	}
+	err = w.WriteByte('}')
+	return msg, scratch, err
+}
+
+func rwSortedMapBytes(w jsWriter, msg []byte, sz uint32, scratch []byte) ([]byte, []byte, error) {
+	ents, msg, err := sortedEntries(msg, sz)
+	if err != nil {
+		return msg, scratch, err
+	}
+	err = w.WriteByte('{')
+	if err != nil {
+		return msg, scratch, err
+	}
+	for i := range ents {
+		_, scratch, err = rwMapEntryBytes(w, ents[i].entry, i == 0, scratch)
 		if err != nil {
 			return msg, scratch, err
 		}
-		msg, scratch, err = writeNext(w, msg, scratch)
+	}
+	err = writeJSONNewline(w, -1)
+	if err != nil {
+		return msg, scratch, err
+	}
+	err = w.WriteByte('}')
+	return msg, scratch, err
+}
+
+// rwMapEntryBytes writes the key and value
+// at the beginning of msg, preceded by a
+// separator unless it is the first entry
+func rwMapEntryBytes(w jsWriter, msg []byte, first bool, scratch []byte) ([]byte, []byte, error) {
+	var err error
+	delta := 1
+	if !first {
+		delta = 0
+		err = w.WriteByte(',')
 		if err != nil {
 			return msg, scratch, err
 		}
 	}
-	err = w.WriteByte('}')
-	return msg, scratch, err
+	err = writeJSONNewline(w, delta)
+	if err != nil {
+		return msg, scratch, err
+	}
+	msg, scratch, err = rwMapKeyBytes(w, msg, scratch)
+	if err != nil {
+		return msg, scratch, err
+	}
+	err = writeJSONColon(w)
+	if err != nil {
+		return msg, scratch, err
+	}
+	return writeNext(w, msg, scratch)
 }
 
 func rwMapKeyBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
-	msg, scratch, err := rwStringBytes(w, msg, scratch)
+	o, scratch, err := rwStringBytes(w, msg, scratch)
 	if err != nil {
 		if tperr, ok := err.(TypeError); ok && tperr.Encoded == BinType {
-			return rwBytesBytes(w, msg, scratch)
+			var bts []byte
+			bts, o, err = ReadBytesZC(msg)
+			if err != nil {
+				return o, scratch, err
+			}
+			_, scratch, err = writeJSONBin(w, bts, true, scratch)
 		}
 	}
-	return msg, scratch, err
+	return o, scratch, err
 }
 
 func rwStringBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
@@ -162,22 +240,7 @@ func rwBytesBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error
 	if err != nil {
 		return msg, scratch, err
 	}
-	l := base64.StdEncoding.EncodedLen(len(bts))
-	if cap(scratch) >= l {
-		scratch = scratch[0:l]
-	} else {
-		scratch = make([]byte, l)
-	}
-	base64.StdEncoding.Encode(scratch, bts)
-	err = w.WriteByte('"')
-	if err != nil {
-		return msg, scratch, err
-	}
-	_, err = w.Write(scratch)
-	if err != nil {
-		return msg, scratch, err
-	}
-	err = w.WriteByte('"')
+	_, scratch, err = writeJSONBin(w, bts, false, scratch)
 	return msg, scratch, err
 }
 
@@ -223,27 +286,6 @@ func rwUintBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error)
 	return msg, scratch, err
 }
 
-func rwFloatBytes(w jsWriter, msg []byte, f64 bool, scratch []byte) ([]byte, []byte, error) {
-	var f float64
-	var err error
-	var sz int
-	if f64 {
-		sz = 64
-		f, msg, err = ReadFloat64Bytes(msg)
-	} else {
-		sz = 32
-		var v float32
-		v, msg, err = ReadFloat32Bytes(msg)
-		f = float64(v)
-	}
-	if err != nil {
-		return msg, scratch, err
-	}
-	scratch = strconv.AppendFloat(scratch, f, 'f', -1, sz)
-	_, err = w.Write(scratch)
-	return msg, scratch, err
-}
-
 func rwFloat32Bytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
 	var f float32
 	var err error
@@ -251,8 +293,7 @@ func rwFloat32Bytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, err
 	if err != nil {
 		return msg, scratch, err
 	}
-	scratch = strconv.AppendFloat(scratch[:0], float64(f), 'f', -1, 32)
-	_, err = w.Write(scratch)
+	_, scratch, err = writeJSONFloat(w, float64(f), 32, scratch)
 	return msg, scratch, err
 }
 
@@ -263,8 +304,7 @@ func rwFloat64Bytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, err
 	if err != nil {
 		return msg, scratch, err
 	}
-	scratch = strconv.AppendFloat(scratch[:0], f, 'f', -1, 64)
-	_, err = w.Write(scratch)
+	_, scratch, err = writeJSONFloat(w, f, 64, scratch)
 	return msg, scratch, err
 }
 
@@ -275,11 +315,7 @@ func rwTimeBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error)
 	if err != nil {
 		return msg, scratch, err
 	}
-	bts, err := t.MarshalJSON()
-	if err != nil {
-		return msg, scratch, err
-	}
-	_, err = w.Write(bts)
+	_, scratch, err = writeJSONTime(w, t, scratch)
 	return msg, scratch, err
 }
 
@@ -298,17 +334,26 @@ func rwExtensionBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, e
 		if err != nil {
 			return msg, scratch, err
 		}
-		bts, err := tm.MarshalJSON()
+		_, scratch, err = writeJSONTime(w, tm, scratch)
+		return msg, scratch, err
+	}
+
+	reg := jsonOptions(w).registry()
+	if f, ok := reg.LookupJSON(et); ok {
+		_, sz, o, err := ReadExtensionHeaderBytes(msg)
 		if err != nil {
 			return msg, scratch, err
 		}
-		_, err = w.Write(bts)
-		return msg, scratch, err
+		if len(o) < sz {
+			return msg, scratch, ErrShortBytes
+		}
+		_, scratch, err = writeJSONExt(w, f, o[:sz], scratch)
+		return o[sz:], scratch, err
 	}
 
 	// if the extension is registered,
 	// use its canonical JSON form
-	if f, ok := extensionReg[et]; ok {
+	if f, ok := reg.Lookup(et); ok {
 		e := f()
 		msg, err = ReadExtensionBytes(msg, e)
 		if err != nil {
@@ -322,6 +367,16 @@ func rwExtensionBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, e
 		return msg, scratch, err
 	}
 
+	if et == TimestampExtension {
+		var tm time.Time
+		tm, msg, err = ReadTimestampBytes(msg)
+		if err != nil {
+			return msg, scratch, err
+		}
+		_, scratch, err = writeJSONTime(w, tm, scratch)
+		return msg, scratch, err
+	}
+
 	// otherwise, write `{"type": <num>, "data": "<base64data>"}`
 	r := RawExtension{}
 	r.Type = et
@@ -343,21 +398,14 @@ func writeExt(w jsWriter, r RawExtension, scratch []byte) ([]byte, error) {
 	if err != nil {
 		return scratch, err
 	}
-	_, err = w.WriteString(`,"data":"`)
+	_, err = w.WriteString(`,"data":`)
 	if err != nil {
 		return scratch, err
 	}
-	l := base64.StdEncoding.EncodedLen(len(r.Data))
-	if cap(scratch) >= l {
-		scratch = scratch[0:l]
-	} else {
-		scratch = make([]byte, l)
-	}
-	base64.StdEncoding.Encode(scratch, r.Data)
-	_, err = w.Write(scratch)
+	_, scratch, err = writeJSONBin(w, r.Data, false, scratch)
 	if err != nil {
 		return scratch, err
 	}
-	_, err = w.WriteString(`"}`)
+	err = w.WriteByte('}')
 	return scratch, err
 }
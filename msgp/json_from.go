@@ -0,0 +1,213 @@
+package msgp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// JSONNumberMode selects how JSON
+// numbers are translated to MessagePack.
+type JSONNumberMode uint8
+
+const (
+	// JSONNumbersAuto translates numbers written
+	// without a fraction or exponent to 'int'
+	// (or 'uint', if they overflow an int64), and
+	// everything else to 'float64'.
+	JSONNumbersAuto JSONNumberMode = iota
+
+	// JSONNumbersIntegral is like JSONNumbersAuto,
+	// except that numbers with a fraction or exponent
+	// are also translated to integers when their value
+	// is integral and fits in an int64 (e.g. "1.0" or "1e3").
+	JSONNumbersIntegral
+
+	// JSONNumbersFloat translates all numbers
+	// to 'float64', which is how encoding/json
+	// decodes numbers into an interface{}.
+	JSONNumbersFloat
+)
+
+// FromJSONOptions configures the
+// translation of JSON to MessagePack.
+// The zero value is the default used by
+// CopyFromJSON and AppendFromJSON.
+type FromJSONOptions struct {
+	// Numbers selects how numbers are translated.
+	Numbers JSONNumberMode
+}
+
+// CopyFromJSON reads JSON values from 'src' until
+// EOF and writes them to 'dst' as MessagePack, with
+// objects translated to maps with 'str' keys. Each
+// top-level value is written once it has been read
+// entirely. It returns the number of bytes written.
+func CopyFromJSON(dst io.Writer, src io.Reader) (n int64, err error) {
+	return FromJSONOptions{}.Copy(dst, src)
+}
+
+// AppendFromJSON appends the MessagePack
+// translation of every JSON value in 'js' to 'b'.
+func AppendFromJSON(b []byte, js []byte) ([]byte, error) {
+	return FromJSONOptions{}.Append(b, js)
+}
+
+// Copy is like CopyFromJSON, but it uses the options in o.
+func (o FromJSONOptions) Copy(dst io.Writer, src io.Reader) (n int64, err error) {
+	dec := json.NewDecoder(src)
+	dec.UseNumber()
+	var buf []byte
+	for {
+		buf, err = o.appendNext(buf[:0], dec)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		var nn int
+		nn, err = dst.Write(buf)
+		n += int64(nn)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Append is like AppendFromJSON, but it uses the options in o.
+func (o FromJSONOptions) Append(b []byte, js []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	var err error
+	for {
+		b, err = o.appendNext(b, dec)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return b, err
+		}
+	}
+}
+
+// appendNext appends the next JSON value in dec
+func (o FromJSONOptions) appendNext(b []byte, dec *json.Decoder) ([]byte, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return b, err
+	}
+	return o.appendToken(b, tok, dec)
+}
+
+func (o FromJSONOptions) appendToken(b []byte, tok json.Token, dec *json.Decoder) ([]byte, error) {
+	switch tok := tok.(type) {
+	case nil:
+		return AppendNil(b), nil
+	case bool:
+		return AppendBool(b, tok), nil
+	case string:
+		return AppendString(b, tok), nil
+	case json.Number:
+		return o.appendNumber(b, tok)
+	case json.Delim:
+		switch tok {
+		case '{':
+			return o.appendObject(b, dec)
+		case '[':
+			return o.appendArray(b, dec)
+		}
+	}
+	// the decoder doesn't return
+	// closing delimiters out of place
+	return b, fatal
+}
+
+func (o FromJSONOptions) appendObject(b []byte, dec *json.Decoder) ([]byte, error) {
+	start := len(b)
+	var sz uint32
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return b, noEOF(err)
+		}
+		key, _ := tok.(string) // always a string
+		b = AppendString(b, key)
+		tok, err = dec.Token()
+		if err != nil {
+			return b, noEOF(err)
+		}
+		b, err = o.appendToken(b, tok, dec)
+		if err != nil {
+			return b, WrapError(err, key)
+		}
+		sz++
+	}
+	if _, err := dec.Token(); err != nil { // '}'
+		return b, noEOF(err)
+	}
+	var hdr [5]byte
+	return insertHeader(b, start, AppendMapHeader(hdr[:0], sz)), nil
+}
+
+func (o FromJSONOptions) appendArray(b []byte, dec *json.Decoder) ([]byte, error) {
+	start := len(b)
+	var sz uint32
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return b, noEOF(err)
+		}
+		b, err = o.appendToken(b, tok, dec)
+		if err != nil {
+			return b, WrapError(err, sz)
+		}
+		sz++
+	}
+	if _, err := dec.Token(); err != nil { // ']'
+		return b, noEOF(err)
+	}
+	var hdr [5]byte
+	return insertHeader(b, start, AppendArrayHeader(hdr[:0], sz)), nil
+}
+
+// insertHeader inserts hdr into b at start
+func insertHeader(b []byte, start int, hdr []byte) []byte {
+	end := len(b)
+	b = append(b, hdr...)
+	copy(b[start+len(hdr):], b[start:end])
+	copy(b[start:], hdr)
+	return b
+}
+
+func (o FromJSONOptions) appendNumber(b []byte, num json.Number) ([]byte, error) {
+	s := string(num)
+	if o.Numbers != JSONNumbersFloat && !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return AppendInt64(b, i), nil
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return AppendUint64(b, u), nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return b, err
+	}
+	if o.Numbers == JSONNumbersIntegral && f >= -(1<<63) && f < 1<<63 && f == math.Trunc(f) {
+		return AppendInt64(b, int64(f)), nil
+	}
+	return AppendFloat64(b, f), nil
+}
+
+// noEOF turns io.EOF in the
+// middle of a value into io.ErrUnexpectedEOF
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package msgp
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCopyFromJSON(t *testing.T) {
+	js := `{"a": "str", "b": [1, -2, 3.5, true, false, null], "c": {"nested": {}}, "d": []}
+	[18446744073709551615, 1e3, "é"]`
+
+	var buf bytes.Buffer
+	n, err := CopyFromJSON(&buf, strings.NewReader(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("returned %d; wrote %d", n, buf.Len())
+	}
+
+	b := buf.Bytes()
+	v, b, err := ReadIntfBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"a": "str",
+		"b": []interface{}{int64(1), int64(-2), 3.5, true, false, nil},
+		"c": map[string]interface{}{"nested": map[string]interface{}{}},
+		"d": []interface{}{},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got %#v", v)
+	}
+	v, b, err = ReadIntfBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want2 := []interface{}{uint64(math.MaxUint64), 1000.0, "é"}
+	if !reflect.DeepEqual(v, want2) {
+		t.Errorf("got %#v", v)
+	}
+	if len(b) != 0 {
+		t.Errorf("%d bytes left over", len(b))
+	}
+
+	// the keys are in their original order
+	out, err := AppendFromJSON(nil, []byte(`{"z":1,"a":2,"m":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	Raw(out).MapRange(func(k, v Raw) bool {
+		s, _, _ := ReadStringBytes(k)
+		keys = append(keys, s)
+		return true
+	})
+	if strings.Join(keys, "") != "zam" {
+		t.Errorf("got keys %v", keys)
+	}
+}
+
+func TestFromJSONNumbers(t *testing.T) {
+	js := []byte(`[1, 1.0, 1e3, 2.5, -7]`)
+	cases := []struct {
+		mode JSONNumberMode
+		want []interface{}
+	}{
+		{JSONNumbersAuto, []interface{}{int64(1), 1.0, 1000.0, 2.5, int64(-7)}},
+		{JSONNumbersIntegral, []interface{}{int64(1), int64(1), int64(1000), 2.5, int64(-7)}},
+		{JSONNumbersFloat, []interface{}{1.0, 1.0, 1000.0, 2.5, -7.0}},
+	}
+	for _, c := range cases {
+		out, err := FromJSONOptions{Numbers: c.mode}.Append(nil, js)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, _, err := ReadIntfBytes(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v, c.want) {
+			t.Errorf("mode %d: got %#v", c.mode, v)
+		}
+	}
+}
+
+func TestFromJSONRoundTrip(t *testing.T) {
+	// large enough to need 16-bit headers
+	in := make(map[string]interface{})
+	arr := make([]interface{}, 100)
+	for i := range arr {
+		arr[i] = map[string]interface{}{"i": float64(i), "s": strings.Repeat("x", i)}
+	}
+	in["arr"] = arr
+	for i := 0; i < 20; i++ {
+		in[strings.Repeat("k", i+1)] = float64(i) / 3
+	}
+	js, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := AppendFromJSON(nil, js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if _, err := UnmarshalAsJSON(&out, b); err != nil {
+		t.Fatal(err)
+	}
+	var rt map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &rt); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, rt) {
+		t.Error("round trip through MessagePack changed the JSON")
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	for _, js := range []string{
+		`{"a": [1, 2}`,
+		`{"a": 1`,
+		`[1e999]`,
+		`tru`,
+	} {
+		if _, err := AppendFromJSON(nil, []byte(js)); err == nil {
+			t.Errorf("%s: expected an error", js)
+		}
+	}
+	_, err := AppendFromJSON(nil, []byte(`{"a": [0, 1e999]}`))
+	if err == nil || !strings.HasSuffix(err.Error(), "at a/1") {
+		t.Errorf("expected an error at a/1; got %v", err)
+	}
+	if out, err := AppendFromJSON(nil, []byte("  ")); err != nil || len(out) != 0 {
+		t.Errorf("got %x, %v", out, err)
+	}
+}
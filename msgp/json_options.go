@@ -0,0 +1,369 @@
+package msgp
+
+import (
+	"encoding/base64"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// JSONBinFormat selects how 'bin' objects
+// (and extension data) are written as JSON.
+type JSONBinFormat uint8
+
+const (
+	// JSONBinBase64 writes binary data as a
+	// string in standard base64 encoding.
+	JSONBinBase64 JSONBinFormat = iota
+
+	// JSONBinHex writes binary data as a
+	// string of lower-case hexadecimal digits.
+	JSONBinHex
+
+	// JSONBinArray writes binary data as an
+	// array of numbers. Since map keys have to be
+	// strings, binary map keys are still written
+	// in base64.
+	JSONBinArray
+)
+
+// JSONTimeFormat selects how times are written as JSON.
+type JSONTimeFormat uint8
+
+const (
+	// JSONTimeRFC3339 writes times as strings
+	// in the format used by time.Time.MarshalJSON,
+	// or in ToJSONOptions.TimeLayout if it is set.
+	JSONTimeRFC3339 JSONTimeFormat = iota
+
+	// JSONTimeUnix writes times as the
+	// integer number of seconds since the epoch.
+	JSONTimeUnix
+
+	// JSONTimeUnixMilli writes times as the integer
+	// number of milliseconds since the epoch.
+	JSONTimeUnixMilli
+
+	// JSONTimeUnixNano writes times as the integer
+	// number of nanoseconds since the epoch.
+	JSONTimeUnixNano
+)
+
+// JSONFloatPolicy selects how NaN and the
+// infinities, which have no representation in
+// JSON, are written.
+type JSONFloatPolicy uint8
+
+const (
+	// JSONFloatLiteral writes NaN, +Inf and -Inf
+	// as bare words. The output is not valid JSON,
+	// but some parsers accept it.
+	JSONFloatLiteral JSONFloatPolicy = iota
+
+	// JSONFloatNull writes them as null.
+	JSONFloatNull
+
+	// JSONFloatString writes them as the
+	// strings "NaN", "+Inf" and "-Inf".
+	JSONFloatString
+
+	// JSONFloatError stops the translation
+	// with a NonFiniteError.
+	JSONFloatError
+)
+
+// NonFiniteError is returned when a
+// translation to JSON with the JSONFloatError
+// policy encounters NaN or an infinity.
+type NonFiniteError struct {
+	Value float64
+}
+
+// Error implements the error interface
+func (n NonFiniteError) Error() string {
+	return "msgp: " + strconv.FormatFloat(n.Value, 'g', -1, 64) + " can't be represented in JSON"
+}
+
+// Resumable is always 'true' for NonFiniteErrors
+func (n NonFiniteError) Resumable() bool { return true }
+
+// ToJSONOptions configures the translation
+// of MessagePack to JSON. The zero value is
+// the default used by CopyToJSON and UnmarshalAsJSON.
+type ToJSONOptions struct {
+	// Bin selects how binary data is written.
+	Bin JSONBinFormat
+
+	// Time selects how times are written.
+	Time JSONTimeFormat
+
+	// TimeLayout, if set, is the layout
+	// (as in time.Time.Format) of the strings
+	// written for times under JSONTimeRFC3339.
+	TimeLayout string
+
+	// NonFinite selects how NaN and
+	// the infinities are written.
+	NonFinite JSONFloatPolicy
+
+	// DisableHTMLEscape stops '<', '>' and '&'
+	// in strings from being escaped. By default
+	// they are escaped (as in encoding/json) so
+	// that the output can be embedded in HTML.
+	DisableHTMLEscape bool
+
+	// Prefix and Indent, if either is set, cause
+	// each element of an array or map to begin on a
+	// new line, as in json.MarshalIndent. Each line
+	// begins with Prefix followed by one copy of
+	// Indent for each level of nesting. Top-level
+	// values are separated by newlines.
+	Prefix string
+	Indent string
+
+	// SortKeys causes the entries of maps
+	// to be written in increasing order of
+	// their keys, rather than in the order
+	// in which they were encoded.
+	SortKeys bool
+
+	// Registry, if set, is used instead of
+	// DefaultRegistry by Unmarshal to find the
+	// extensions that have a JSON encoding.
+	// (Copy uses the Registry set on its Reader.)
+	Registry *Registry
+}
+
+func (o *ToJSONOptions) registry() *Registry {
+	if o.Registry != nil {
+		return o.Registry
+	}
+	return DefaultRegistry
+}
+
+// pretty returns whether the output is indented
+func (o *ToJSONOptions) pretty() bool {
+	return o.Prefix != "" || o.Indent != ""
+}
+
+// Copy is like CopyToJSON, but it uses the options in o.
+func (o ToJSONOptions) Copy(dst io.Writer, src io.Reader) (n int64, err error) {
+	r := NewReader(src)
+	n, err = r.writeToJSON(dst, &o)
+	freeR(r)
+	return
+}
+
+// Unmarshal is like UnmarshalAsJSON, but it uses the options in o.
+func (o ToJSONOptions) Unmarshal(w io.Writer, msg []byte) ([]byte, error) {
+	return unmarshalAsJSON(w, msg, &o)
+}
+
+var defaultToJSON ToJSONOptions
+
+// jsonWriter carries the options for a
+// translation along with its destination
+type jsonWriter struct {
+	jsWriter
+	opts  *ToJSONOptions
+	depth int // nesting level, for indentation
+}
+
+// withOptions returns w, wrapped so that
+// jsonOptions(w) returns o if o isn't the default
+func withOptions(w jsWriter, o *ToJSONOptions) jsWriter {
+	if o == nil || *o == defaultToJSON {
+		return w
+	}
+	return &jsonWriter{jsWriter: w, opts: o}
+}
+
+// jsonOptions returns the options for
+// a translation that is writing to w
+func jsonOptions(w jsWriter) *ToJSONOptions {
+	if j, ok := w.(*jsonWriter); ok {
+		return j.opts
+	}
+	return &defaultToJSON
+}
+
+// writeJSONNewline begins a new line for an element
+// of a map or array, after moving 'delta' levels
+// of nesting deeper (or shallower, if negative).
+// It does nothing unless the output is indented.
+func writeJSONNewline(w jsWriter, delta int) error {
+	j, ok := w.(*jsonWriter)
+	if !ok {
+		return nil
+	}
+	j.depth += delta
+	if !j.opts.pretty() {
+		return nil
+	}
+	err := j.WriteByte('\n')
+	if err != nil {
+		return err
+	}
+	_, err = j.WriteString(j.opts.Prefix)
+	for i := 0; i < j.depth && err == nil; i++ {
+		_, err = j.WriteString(j.opts.Indent)
+	}
+	return err
+}
+
+// writeJSONColon writes the
+// separator after a map key
+func writeJSONColon(w jsWriter) error {
+	if jsonOptions(w).pretty() {
+		_, err := w.WriteString(": ")
+		return err
+	}
+	return w.WriteByte(':')
+}
+
+// jsonEntry is a map entry whose
+// key and value haven't been translated yet
+type jsonEntry struct {
+	key   []byte // the contents of the key
+	entry []byte // the encoded key and value
+}
+
+// sortedEntries returns the sz entries
+// of the map at the beginning of msg and
+// the bytes that follow them, sorted by key
+func sortedEntries(msg []byte, sz uint32) ([]jsonEntry, []byte, error) {
+	// don't trust sz for the allocation;
+	// each entry takes at least two bytes
+	n := len(msg) / 2
+	if uint64(sz) < uint64(n) {
+		n = int(sz)
+	}
+	ents := make([]jsonEntry, 0, n)
+	for i := uint32(0); i < sz; i++ {
+		key, rest, err := ReadMapKeyZC(msg)
+		if err != nil {
+			return nil, msg, err
+		}
+		rest, err = Skip(rest)
+		if err != nil {
+			return nil, msg, err
+		}
+		ents = append(ents, jsonEntry{key: key, entry: msg[:len(msg)-len(rest)]})
+		msg = rest
+	}
+	sort.SliceStable(ents, func(i, j int) bool {
+		return string(ents[i].key) < string(ents[j].key)
+	})
+	return ents, msg, nil
+}
+
+// writeJSONBin writes 'data' to w in the selected
+// format. 'scratch' is used to encode the data
+// and must not overlap with it.
+func writeJSONBin(w jsWriter, data []byte, key bool, scratch []byte) (int, []byte, error) {
+	format := jsonOptions(w).Bin
+	if format == JSONBinArray && !key {
+		return writeJSONBinArray(w, data, scratch)
+	}
+	var l int
+	if format == JSONBinHex {
+		l = len(data) * 2
+	} else {
+		l = base64.StdEncoding.EncodedLen(len(data))
+	}
+	l += 2
+	if cap(scratch) >= l {
+		scratch = scratch[0:l]
+	} else {
+		scratch = make([]byte, l)
+	}
+	scratch[0] = '"'
+	if format == JSONBinHex {
+		for i, b := range data {
+			scratch[1+2*i] = hex[b>>4]
+			scratch[2+2*i] = hex[b&0xF]
+		}
+	} else {
+		base64.StdEncoding.Encode(scratch[1:], data)
+	}
+	scratch[l-1] = '"'
+	n, err := w.Write(scratch)
+	return n, scratch, err
+}
+
+func writeJSONBinArray(w jsWriter, data []byte, scratch []byte) (int, []byte, error) {
+	scratch = append(scratch[0:0], '[')
+	for i, b := range data {
+		if i != 0 {
+			scratch = append(scratch, ',')
+		}
+		scratch = strconv.AppendUint(scratch, uint64(b), 10)
+	}
+	scratch = append(scratch, ']')
+	n, err := w.Write(scratch)
+	return n, scratch, err
+}
+
+// writeJSONFloat writes f, which was
+// decoded from a float of size 'bits'
+func writeJSONFloat(w jsWriter, f float64, bits int, scratch []byte) (int, []byte, error) {
+	scratch = scratch[0:0]
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		switch jsonOptions(w).NonFinite {
+		case JSONFloatNull:
+			n, err := w.Write(null)
+			return n, scratch, err
+		case JSONFloatString:
+			scratch = append(scratch, '"')
+			scratch = strconv.AppendFloat(scratch, f, 'f', -1, bits)
+			scratch = append(scratch, '"')
+			n, err := w.Write(scratch)
+			return n, scratch, err
+		case JSONFloatError:
+			return 0, scratch, NonFiniteError{Value: f}
+		}
+	}
+	scratch = strconv.AppendFloat(scratch, f, 'f', -1, bits)
+	n, err := w.Write(scratch)
+	return n, scratch, err
+}
+
+// writeJSONTime writes t in the selected format
+func writeJSONTime(w jsWriter, t time.Time, scratch []byte) (int, []byte, error) {
+	o := jsonOptions(w)
+	switch o.Time {
+	case JSONTimeUnix:
+		scratch = strconv.AppendInt(scratch[0:0], t.Unix(), 10)
+	case JSONTimeUnixMilli:
+		scratch = strconv.AppendInt(scratch[0:0], t.UnixNano()/int64(time.Millisecond), 10)
+	case JSONTimeUnixNano:
+		scratch = strconv.AppendInt(scratch[0:0], t.UnixNano(), 10)
+	default:
+		if o.TimeLayout != "" {
+			scratch = t.AppendFormat(scratch[0:0], o.TimeLayout)
+			n, err := rwquoted(w, scratch)
+			return n, scratch, err
+		}
+		bts, err := t.MarshalJSON()
+		if err != nil {
+			return 0, scratch, err
+		}
+		n, err := w.Write(bts)
+		return n, scratch, err
+	}
+	n, err := w.Write(scratch)
+	return n, scratch, err
+}
+
+// writeJSONExt writes the data of an
+// extension as rendered by f
+func writeJSONExt(w jsWriter, f ExtensionJSONFunc, data []byte, scratch []byte) (int, []byte, error) {
+	out, err := f(scratch[0:0], data)
+	if err != nil {
+		return 0, scratch, err
+	}
+	n, err := w.Write(out)
+	return n, out, err
+}
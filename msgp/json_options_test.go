@@ -0,0 +1,197 @@
+package msgp
+
+import (
+	"bytes"
+	enchex "encoding/hex"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestToJSONOptions(t *testing.T) {
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	ext, err := AppendExtension(nil, &RawExtension{Type: 50, Data: []byte{0xab}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		opts ToJSONOptions
+		in   []byte
+		want string
+	}{
+		{ToJSONOptions{}, AppendBytes(nil, []byte{1, 0xfe}), `"Af4="`},
+		{ToJSONOptions{Bin: JSONBinHex}, AppendBytes(nil, []byte{1, 0xfe}), `"01fe"`},
+		{ToJSONOptions{Bin: JSONBinArray}, AppendBytes(nil, []byte{1, 0xfe}), `[1,254]`},
+		{ToJSONOptions{Bin: JSONBinArray}, AppendBytes(nil, nil), `[]`},
+		{ToJSONOptions{Bin: JSONBinHex}, ext, `{"type":50,"data":"ab"}`},
+		{ToJSONOptions{}, AppendTime(nil, tm), `"2020-01-02T03:04:05.006Z"`},
+		{ToJSONOptions{Time: JSONTimeUnix}, AppendTime(nil, tm), `1577934245`},
+		{ToJSONOptions{Time: JSONTimeUnixMilli}, AppendTime(nil, tm), `1577934245006`},
+		{ToJSONOptions{Time: JSONTimeUnixNano}, AppendTime(nil, tm), `1577934245006000000`},
+		{ToJSONOptions{TimeLayout: "2006-01-02"}, AppendTime(nil, tm), `"2020-01-02"`},
+		{ToJSONOptions{}, AppendFloat64(nil, math.NaN()), `NaN`},
+		{ToJSONOptions{NonFinite: JSONFloatNull}, AppendFloat64(nil, math.Inf(1)), `null`},
+		{ToJSONOptions{NonFinite: JSONFloatString}, AppendFloat32(nil, float32(math.Inf(-1))), `"-Inf"`},
+		{ToJSONOptions{NonFinite: JSONFloatNull}, AppendFloat64(nil, 1.5), `1.5`},
+		{ToJSONOptions{}, AppendString(nil, "<a&b>"), `"\u003ca\u0026b\u003e"`},
+		{ToJSONOptions{DisableHTMLEscape: true}, AppendString(nil, "<a&b>\n"), `"<a&b>\n"`},
+	}
+	for i, c := range cases {
+		var buf bytes.Buffer
+		if _, err := c.opts.Unmarshal(&buf, c.in); err != nil {
+			t.Fatalf("case %d: %s", i, err)
+		}
+		if buf.String() != c.want {
+			t.Errorf("case %d: Unmarshal wrote %s; want %s", i, buf.String(), c.want)
+		}
+		buf.Reset()
+		if _, err := c.opts.Copy(&buf, bytes.NewReader(c.in)); err != nil {
+			t.Fatalf("case %d: %s", i, err)
+		}
+		if buf.String() != c.want {
+			t.Errorf("case %d: Copy wrote %s; want %s", i, buf.String(), c.want)
+		}
+	}
+}
+
+func TestToJSONNonFiniteError(t *testing.T) {
+	b := AppendArrayHeader(nil, 2)
+	b = AppendFloat64(b, 1)
+	b = AppendFloat32(b, float32(math.NaN()))
+	o := ToJSONOptions{NonFinite: JSONFloatError}
+	var buf bytes.Buffer
+	_, err := o.Unmarshal(&buf, b)
+	if nf, ok := err.(NonFiniteError); !ok || !math.IsNaN(nf.Value) {
+		t.Errorf("Unmarshal: got %v; want a NonFiniteError", err)
+	}
+	_, err = o.Copy(&buf, bytes.NewReader(b))
+	if _, ok := Cause(err).(NonFiniteError); !ok {
+		t.Errorf("Copy: got %v; want a NonFiniteError", err)
+	}
+	if err != nil && err.Error() != "msgp: NaN can't be represented in JSON" {
+		t.Errorf("got message %q", err.Error())
+	}
+}
+
+func TestToJSONBinKeys(t *testing.T) {
+	b := AppendMapHeader(nil, 1)
+	b = AppendBytes(b, []byte{1, 2})
+	b = AppendBytes(b, []byte{3})
+	var buf bytes.Buffer
+	if _, err := (ToJSONOptions{Bin: JSONBinArray}).Unmarshal(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"AQI=":[3]}`; buf.String() != want {
+		t.Errorf("got %s; want %s", buf.String(), want)
+	}
+}
+
+func TestFloatJSONPrecision(t *testing.T) {
+	var buf bytes.Buffer
+	b := AppendFloat64(nil, 0.1234567890123)
+	if _, err := CopyToJSON(&buf, bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "0.1234567890123" {
+		t.Errorf("got %s", buf.String())
+	}
+}
+
+func TestToJSONIndent(t *testing.T) {
+	b := AppendMapHeader(nil, 4)
+	b = AppendString(b, "z")
+	b = AppendArrayHeader(b, 2)
+	b = AppendInt(b, 1)
+	b = AppendMapHeader(b, 0)
+	b = AppendString(b, "a")
+	b = AppendArrayHeader(b, 0)
+	b = AppendString(b, "m")
+	b = AppendMapHeader(b, 2)
+	b = AppendString(b, "y")
+	b = AppendNil(b)
+	b = AppendString(b, "x")
+	b = AppendBool(b, true)
+	b = AppendString(b, "b")
+	b = AppendString(b, "bin key")
+	b = AppendInt(b, 2)
+
+	cases := []struct {
+		opts ToJSONOptions
+		want string
+	}{
+		{ToJSONOptions{SortKeys: true},
+			`{"a":[],"b":"bin key","m":{"x":true,"y":null},"z":[1,{}]}2`},
+		{ToJSONOptions{Indent: "  "},
+			"{\n  \"z\": [\n    1,\n    {}\n  ],\n  \"a\": [],\n  \"m\": {\n    \"y\": null,\n    \"x\": true\n  },\n  \"b\": \"bin key\"\n}\n2"},
+		{ToJSONOptions{Indent: "\t", Prefix: "#", SortKeys: true},
+			"{\n#\t\"a\": [],\n#\t\"b\": \"bin key\",\n#\t\"m\": {\n#\t\t\"x\": true,\n#\t\t\"y\": null\n#\t},\n#\t\"z\": [\n#\t\t1,\n#\t\t{}\n#\t]\n#}\n2"},
+	}
+	for i, c := range cases {
+		var buf bytes.Buffer
+		if _, err := c.opts.Unmarshal(&buf, b); err != nil {
+			t.Fatalf("case %d: %s", i, err)
+		}
+		if buf.String() != c.want {
+			t.Errorf("case %d: Unmarshal wrote\n%s\nwant\n%s", i, buf.String(), c.want)
+		}
+		buf.Reset()
+		n, err := c.opts.Copy(&buf, bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("case %d: %s", i, err)
+		}
+		if buf.String() != c.want {
+			t.Errorf("case %d: Copy wrote\n%s\nwant\n%s", i, buf.String(), c.want)
+		}
+		if n != int64(buf.Len()) {
+			t.Errorf("case %d: Copy returned %d; wrote %d", i, n, buf.Len())
+		}
+	}
+}
+
+func TestExtensionJSON(t *testing.T) {
+	RegisterExtensionJSON(51, func(b []byte, data []byte) ([]byte, error) {
+		b = append(b, '"')
+		b = append(b, enchex.EncodeToString(data)...)
+		return append(b, '"'), nil
+	})
+	defer RegisterExtensionJSON(51, nil)
+	// the renderer is used even if the type is registered
+	DefaultRegistry.Register(51, func() Extension { return new(RawExtension) })
+	defer DefaultRegistry.Unregister(51)
+
+	var b []byte
+	b = AppendArrayHeader(b, 3)
+	b, _ = AppendExtension(b, &RawExtension{Type: 51, Data: []byte{1, 2, 0xff}})
+	b = AppendTimestamp(b, time.Unix(1577934245, 0))
+	b, _ = AppendExtension(b, &RawExtension{Type: 52, Data: []byte{1}})
+	want := `["0102ff","` + time.Unix(1577934245, 0).Format(time.RFC3339) + `",{"type":52,"data":"AQ=="}]`
+
+	var buf bytes.Buffer
+	if _, err := UnmarshalAsJSON(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("UnmarshalAsJSON wrote %s; want %s", buf.String(), want)
+	}
+	buf.Reset()
+	if _, err := CopyToJSON(&buf, bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("CopyToJSON wrote %s; want %s", buf.String(), want)
+	}
+
+	// registries are independent
+	reg := NewRegistry()
+	reg.RegisterJSON(52, func(b []byte, data []byte) ([]byte, error) {
+		return strconv.AppendInt(b, int64(data[0]), 10), nil
+	})
+	buf.Reset()
+	if _, err := (ToJSONOptions{Registry: reg}).Unmarshal(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"type":51,"data":"AQL/"},"` + time.Unix(1577934245, 0).Format(time.RFC3339) + `",1]`; buf.String() != want {
+		t.Errorf("got %s; want %s", buf.String(), want)
+	}
+}
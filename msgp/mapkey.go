@@ -0,0 +1,167 @@
+package msgp
+
+import (
+	"bytes"
+	"encoding"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// The functions in this file encode the keys
+// of maps that WriteIntf and AppendIntf handle
+// by reflection. Keys may be strings, bools,
+// numbers, or Extensions, and are encoded as
+// such unless they are to be stringified (see
+// Writer.SetStringKeys), in which case they
+// are converted to strings the way encoding/json
+// does: numbers and bools are formatted, and
+// encoding.TextMarshalers are marshaled.
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// keyString returns k converted to a string
+func keyString(k reflect.Value) (string, error) {
+	k = keyElem(k)
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := asInterface(k, textMarshalerType); ok {
+		b, err := tm.(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(k.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(k.Float(), 'g', -1, k.Type().Bits()), nil
+	}
+	return "", &ErrUnsupportedType{T: k.Type()}
+}
+
+// appendMapKey appends the key k to b,
+// as a string if str is set
+func appendMapKey(b []byte, k reflect.Value, str bool) ([]byte, error) {
+	k = keyElem(k)
+	if str {
+		s, err := keyString(k)
+		if err != nil {
+			return b, err
+		}
+		return AppendString(b, s), nil
+	}
+	if e, ok := asInterface(k, extensionType); ok {
+		return AppendExtension(b, e.(Extension))
+	}
+	switch k.Kind() {
+	case reflect.String:
+		return AppendString(b, k.String()), nil
+	case reflect.Bool:
+		return AppendBool(b, k.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return AppendInt64(b, k.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return AppendUint64(b, k.Uint()), nil
+	case reflect.Float32:
+		return AppendFloat32(b, float32(k.Float())), nil
+	case reflect.Float64:
+		return AppendFloat64(b, k.Float()), nil
+	}
+	return b, &ErrUnsupportedType{T: k.Type()}
+}
+
+// writeMapKey is appendMapKey for a Writer,
+// with its StringKeys option in place of str
+func (mw *Writer) writeMapKey(k reflect.Value) error {
+	k = keyElem(k)
+	if mw.strKeys {
+		s, err := keyString(k)
+		if err != nil {
+			return err
+		}
+		return mw.WriteString(s)
+	}
+	if e, ok := asInterface(k, extensionType); ok {
+		return mw.WriteExtension(e.(Extension))
+	}
+	switch k.Kind() {
+	case reflect.String:
+		return mw.WriteString(k.String())
+	case reflect.Bool:
+		return mw.WriteBool(k.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mw.WriteInt64(k.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return mw.WriteUint64(k.Uint())
+	case reflect.Float32:
+		return mw.WriteFloat32(float32(k.Float()))
+	case reflect.Float64:
+		return mw.WriteFloat64(k.Float())
+	}
+	return &ErrUnsupportedType{T: k.Type()}
+}
+
+// keyElem returns the value in k,
+// if k is a non-nil interface
+func keyElem(k reflect.Value) reflect.Value {
+	if k.Kind() == reflect.Interface && !k.IsNil() {
+		return k.Elem()
+	}
+	return k
+}
+
+// sortMapKeys sorts ks in their natural order:
+// numerically for numbers, false before true for
+// bools, and otherwise by their encoding (which
+// orders strings lexically, and is used for all
+// keys of interface type). Keys that are to be
+// stringified are sorted as strings.
+func sortMapKeys(ks []reflect.Value, str bool) {
+	if len(ks) < 2 {
+		return
+	}
+	enc := make([][]byte, len(ks))
+	for i := range ks {
+		if str {
+			s, _ := keyString(ks[i])
+			enc[i] = []byte(s)
+		} else if ks[i].Kind() == reflect.String {
+			enc[i] = []byte(ks[i].String())
+		} else {
+			enc[i], _ = appendMapKey(nil, ks[i], false)
+		}
+	}
+	k := ks[0].Kind()
+	sort.Sort(keySorter{ks: ks, enc: enc, less: func(i, j int) bool {
+		if !str {
+			switch k {
+			case reflect.Bool:
+				return !ks[i].Bool() && ks[j].Bool()
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return ks[i].Int() < ks[j].Int()
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				return ks[i].Uint() < ks[j].Uint()
+			case reflect.Float32, reflect.Float64:
+				return ks[i].Float() < ks[j].Float()
+			}
+		}
+		return bytes.Compare(enc[i], enc[j]) < 0
+	}})
+}
+
+type keySorter struct {
+	ks   []reflect.Value
+	enc  [][]byte
+	less func(i, j int) bool
+}
+
+func (s keySorter) Len() int           { return len(s.ks) }
+func (s keySorter) Less(i, j int) bool { return s.less(i, j) }
+func (s keySorter) Swap(i, j int) {
+	s.ks[i], s.ks[j] = s.ks[j], s.ks[i]
+	s.enc[i], s.enc[j] = s.enc[j], s.enc[i]
+}
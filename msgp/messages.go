@@ -0,0 +1,130 @@
+package msgp
+
+import (
+	"io"
+	"time"
+)
+
+// ForEachMessage calls fn with each of the objects
+// in r, which holds a stream of concatenated messages
+// (such as a file written by successive calls to
+// Encode), until fn returns an error or the stream
+// ends. It returns nil at the end of the stream,
+// io.ErrUnexpectedEOF if the stream ends in the
+// middle of a message, and otherwise the first
+// error from reading r or from fn.
+//
+// msg is only valid until fn returns; the same
+// memory is reused for each message. If r is a
+// *Reader, it is used directly (and its limits
+// apply to each message); otherwise, r is read
+// through a buffer, so it shouldn't be read from
+// afterwards. See Messages for an iterator version.
+func ForEachMessage(r io.Reader, fn func(msg Raw) error) error {
+	m, free := messageReader(r)
+	defer free()
+	var msg Raw
+	for {
+		ok, err := m.nextMessage(&msg)
+		if !ok {
+			return err
+		}
+		if err = fn(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// messageReader returns r as a *Reader, and
+// a function to call when it is done with
+func messageReader(r io.Reader) (*Reader, func()) {
+	if m, ok := r.(*Reader); ok {
+		return m, func() {}
+	}
+	m := NewReader(r)
+	return m, func() { freeR(m) }
+}
+
+// nextMessage reads the next object into msg.
+// It returns false at the end of the stream or
+// if there is an error.
+func (m *Reader) nextMessage(msg *Raw) (bool, error) {
+	m.BeginMessage()
+	if _, err := m.R.Peek(1); err != nil {
+		if err == io.EOF {
+			err = nil
+		}
+		return false, err
+	}
+	*msg = (*msg)[:0]
+	var t time.Time
+	if m.metrics != nil {
+		t = time.Now()
+	}
+	err := appendNext(m, (*[]byte)(msg))
+	if m.metrics != nil {
+		m.metrics.read(int64(len(*msg)), time.Since(t), err)
+	}
+	if err != nil {
+		return false, noEOF(err)
+	}
+	return true, nil
+}
+
+// EncodeAll writes msgs to w one after another,
+// as successive calls to Encode would, but through
+// a single Writer, so that small messages are
+// flushed together rather than one at a time.
+// An error from a message is wrapped with its
+// index, and nothing after it is written. If w is
+// a *Writer, it is used directly, and the caller
+// is responsible for flushing it.
+func EncodeAll(w io.Writer, msgs ...Encodable) error {
+	mw, ok := w.(*Writer)
+	if !ok {
+		mw = NewWriter(w)
+		defer freeW(mw)
+	}
+	for i, e := range msgs {
+		if err := mw.Encode(e); err != nil {
+			return WrapError(err, i)
+		}
+	}
+	if ok {
+		return nil
+	}
+	return mw.Flush()
+}
+
+// DecodeAll decodes each of the messages in r
+// into a value returned by factory and calls fn
+// with it, until fn returns an error or the stream
+// ends. It returns nil at the end of the stream,
+// and otherwise the first error from decoding
+// (as an OffsetError, as with Decode) or from fn.
+// Unlike with ForEachMessage, the messages aren't
+// copied out of the buffer before being decoded.
+//
+// factory may return the same value each time, to
+// reuse it, if fn doesn't keep it. If r is a *Reader,
+// it is used directly; otherwise, r is read through
+// a buffer, so it shouldn't be read from afterwards.
+func DecodeAll(r io.Reader, factory func() Decodable, fn func(Decodable) error) error {
+	m, free := messageReader(r)
+	defer free()
+	for {
+		if _, err := m.R.Peek(1); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		d := factory()
+		if err := m.Decode(d); err != nil {
+			return withOffset(noEOF(err), m.Offset())
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+}
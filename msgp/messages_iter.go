@@ -0,0 +1,41 @@
+//go:build go1.23
+
+package msgp
+
+import (
+	"io"
+	"iter"
+)
+
+// Messages returns an iterator over the objects in
+// r, which holds a stream of concatenated messages,
+// as described for ForEachMessage. If reading fails
+// (including when the stream ends in the middle of
+// a message), the error is yielded with a nil Raw
+// and the iteration ends.
+//
+// Each Raw is only valid until the next iteration:
+//
+//	for msg, err := range msgp.Messages(f) {
+//		if err != nil {
+//			return err
+//		}
+//		handle(msg)
+//	}
+func Messages(r io.Reader) iter.Seq2[Raw, error] {
+	return func(yield func(Raw, error) bool) {
+		m, free := messageReader(r)
+		defer free()
+		var msg Raw
+		for {
+			ok, err := m.nextMessage(&msg)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !ok || !yield(msg, nil) {
+				return
+			}
+		}
+	}
+}
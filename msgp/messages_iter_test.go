@@ -0,0 +1,38 @@
+//go:build go1.23
+
+package msgp
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestMessages(t *testing.T) {
+	stream := messageStream(10)
+	var got []byte
+	for msg, err := range Messages(bytes.NewReader(stream)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msg...)
+	}
+	if !bytes.Equal(got, stream) {
+		t.Errorf("got %x", got)
+	}
+
+	count := 0
+	for range Messages(bytes.NewReader(stream)) {
+		if count++; count == 3 {
+			break
+		}
+	}
+
+	var last error
+	for _, err := range Messages(bytes.NewReader(stream[:len(stream)-2])) {
+		last = err
+	}
+	if last != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF; got %v", last)
+	}
+}
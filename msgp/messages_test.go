@@ -0,0 +1,107 @@
+package msgp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// messageStream returns a stream of
+// n concatenated messages
+func messageStream(n int) []byte {
+	var b []byte
+	for i := 0; i < n; i++ {
+		b = AppendMapHeader(b, 1)
+		b = AppendString(b, "i")
+		b = AppendInt(b, i)
+	}
+	return AppendNil(b)
+}
+
+func TestForEachMessage(t *testing.T) {
+	stream := messageStream(100)
+	var got []byte
+	count := 0
+	err := ForEachMessage(struct{ io.Reader }{bytes.NewReader(stream)}, func(msg Raw) error {
+		got = append(got, msg...)
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 101 || !bytes.Equal(got, stream) {
+		t.Errorf("got %d messages; %x", count, got)
+	}
+
+	// errors from fn stop the iteration
+	stop := errors.New("stop")
+	count = 0
+	err = ForEachMessage(bytes.NewReader(stream), func(Raw) error {
+		count++
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("got %v after %d messages", err, count)
+	}
+
+	// a truncated message is an error
+	err = ForEachMessage(bytes.NewReader(stream[:len(stream)-3]), func(Raw) error { return nil })
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF; got %v", err)
+	}
+
+	// a *Reader is used as-is, with its limits
+	m := NewReaderWithOptions(bytes.NewReader(stream), ReaderOptions{MaxMessageSize: 2})
+	err = ForEachMessage(m, func(Raw) error { return nil })
+	if _, ok := err.(LimitError); !ok {
+		t.Errorf("expected a LimitError; got %v", err)
+	}
+}
+
+func TestEncodeDecodeAll(t *testing.T) {
+	var buf bytes.Buffer
+	msgs := []Encodable{&streamInt{v: 1}, &streamInt{v: -2}, &streamInt{v: 300}}
+	if err := EncodeAll(&buf, msgs...); err != nil {
+		t.Fatal(err)
+	}
+	want := AppendInt(AppendInt(AppendInt(nil, 1), -2), 300)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("got %x, want %x", buf.Bytes(), want)
+	}
+
+	var got []int64
+	factory := func() Decodable { return new(streamInt) }
+	err := DecodeAll(bytes.NewReader(want), factory, func(d Decodable) error {
+		got = append(got, d.(*streamInt).v)
+		return nil
+	})
+	if err != nil || len(got) != 3 || got[0] != 1 || got[1] != -2 || got[2] != 300 {
+		t.Errorf("DecodeAll: got %v, %v", got, err)
+	}
+
+	// errors from fn stop the iteration
+	stop := errors.New("stop")
+	count := 0
+	err = DecodeAll(bytes.NewReader(want), factory, func(Decodable) error {
+		count++
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("got %v after %d messages", err, count)
+	}
+
+	// a stream that ends in the middle of a message
+	err = DecodeAll(bytes.NewReader(want[:len(want)-1]), factory, func(Decodable) error { return nil })
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF; got %v", err)
+	}
+
+	// errors from a message have its index
+	err = EncodeAll(ioutil.Discard, &streamInt{}, badEncodable{})
+	if !errors.Is(err, errBadEncodable) || ErrorPath(err) != "[1]" {
+		t.Errorf("EncodeAll: got %v at %q", err, ErrorPath(err))
+	}
+}
@@ -0,0 +1,155 @@
+package msgp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Metrics collects measurements of the data that
+// Readers and Writers handle, so that they can be
+// fed to a metrics system. Attach it with
+// ReaderOptions.Metrics and WriterOptions.Metrics
+// (or SetMetrics). A Metrics is safe for concurrent
+// use, and may be shared by any number of Readers
+// and Writers.
+//
+// Bytes are counted as they are read from the
+// underlying io.Reader, which may be ahead of what
+// has been decoded, and as they are written to the
+// underlying io.Writer. Messages are counted and
+// timed by Reader.Decode, ForEachMessage and
+// Messages, and by Writer.Encode.
+type Metrics struct {
+	// updated atomically; kept first
+	// for alignment on 32-bit platforms
+	bytesRead, bytesWritten  int64
+	msgsRead, msgsWritten    int64
+	readErrs, writeErrs      int64
+	maxRead, maxWritten      int64
+	decodeNanos, encodeNanos int64
+
+	// OnRead and OnWrite, if set, are called after
+	// each message is read or written, with its size
+	// in bytes, the time that it took to decode or
+	// encode, and the error, if any. They must not
+	// be changed once the Metrics is in use.
+	OnRead  func(size int64, d time.Duration, err error)
+	OnWrite func(size int64, d time.Duration, err error)
+}
+
+// MetricsSnapshot holds the values of
+// a Metrics at one point in time.
+type MetricsSnapshot struct {
+	BytesRead, BytesWritten int64
+
+	// MessagesRead and MessagesWritten
+	// include the messages that failed,
+	// which are also counted by ReadErrors
+	// and WriteErrors, respectively.
+	MessagesRead, MessagesWritten int64
+	ReadErrors, WriteErrors       int64
+
+	// MaxMessageRead and MaxMessageWritten
+	// are the sizes of the largest messages.
+	MaxMessageRead, MaxMessageWritten int64
+
+	// DecodeTime and EncodeTime are the
+	// total times spent on the messages.
+	DecodeTime, EncodeTime time.Duration
+}
+
+// Snapshot returns the current values of m.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	return MetricsSnapshot{
+		BytesRead:         atomic.LoadInt64(&m.bytesRead),
+		BytesWritten:      atomic.LoadInt64(&m.bytesWritten),
+		MessagesRead:      atomic.LoadInt64(&m.msgsRead),
+		MessagesWritten:   atomic.LoadInt64(&m.msgsWritten),
+		ReadErrors:        atomic.LoadInt64(&m.readErrs),
+		WriteErrors:       atomic.LoadInt64(&m.writeErrs),
+		MaxMessageRead:    atomic.LoadInt64(&m.maxRead),
+		MaxMessageWritten: atomic.LoadInt64(&m.maxWritten),
+		DecodeTime:        time.Duration(atomic.LoadInt64(&m.decodeNanos)),
+		EncodeTime:        time.Duration(atomic.LoadInt64(&m.encodeNanos)),
+	}
+}
+
+// message records a message in the counters that
+// follow msgs, and calls fn if it is set
+func (m *Metrics) message(msgs, errs, max, nanos *int64, fn func(int64, time.Duration, error), size int64, d time.Duration, err error) {
+	atomic.AddInt64(msgs, 1)
+	if err != nil {
+		atomic.AddInt64(errs, 1)
+	}
+	for {
+		old := atomic.LoadInt64(max)
+		if size <= old || atomic.CompareAndSwapInt64(max, old, size) {
+			break
+		}
+	}
+	atomic.AddInt64(nanos, int64(d))
+	if fn != nil {
+		fn(size, d, err)
+	}
+}
+
+func (m *Metrics) read(size int64, d time.Duration, err error) {
+	m.message(&m.msgsRead, &m.readErrs, &m.maxRead, &m.decodeNanos, m.OnRead, size, d, err)
+}
+
+func (m *Metrics) written(size int64, d time.Duration, err error) {
+	m.message(&m.msgsWritten, &m.writeErrs, &m.maxWritten, &m.encodeNanos, m.OnWrite, size, d, err)
+}
+
+// SetMetrics sets the Metrics that m reports
+// to. A nil Metrics turns reporting off.
+func (m *Reader) SetMetrics(mt *Metrics) {
+	m.metrics = mt
+	m.cr.metrics = mt
+}
+
+// Decode marks the beginning of a message (see
+// BeginMessage) and decodes it into d. It is the
+// same as calling d.DecodeMsg(m), except that the
+// message is reported to the Reader's Metrics.
+func (m *Reader) Decode(d Decodable) error {
+	m.BeginMessage()
+	if m.metrics == nil {
+		return d.DecodeMsg(m)
+	}
+	start, t := m.Offset(), time.Now()
+	err := d.DecodeMsg(m)
+	m.metrics.read(m.Offset()-start, time.Since(t), err)
+	return err
+}
+
+// SetMetrics sets the Metrics that mw reports
+// to. A nil Metrics turns reporting off.
+func (mw *Writer) SetMetrics(mt *Metrics) { mw.metrics = mt }
+
+// Encode writes e to mw. It is the same as
+// calling e.EncodeMsg(mw), except that the
+// message is reported to the Writer's Metrics.
+// Like EncodeMsg, it does not flush mw.
+func (mw *Writer) Encode(e Encodable) error {
+	if mw.metrics == nil {
+		return e.EncodeMsg(mw)
+	}
+	start, t := mw.total(), time.Now()
+	err := e.EncodeMsg(mw)
+	mw.metrics.written(mw.total()-start, time.Since(t), err)
+	return err
+}
+
+// total returns the number of bytes
+// written to mw since it was created,
+// including those still in the buffer
+func (mw *Writer) total() int64 { return mw.flushed + int64(mw.wloc) }
+
+// wrote counts n bytes written to mw.w
+func (mw *Writer) wrote(n int) {
+	mw.flushed += int64(n)
+	if mw.metrics != nil {
+		atomic.AddInt64(&mw.metrics.bytesWritten, int64(n))
+	}
+}
@@ -0,0 +1,92 @@
+package msgp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	var mt Metrics
+	var writes, reads []int64
+	mt.OnWrite = func(size int64, d time.Duration, err error) { writes = append(writes, size) }
+	mt.OnRead = func(size int64, d time.Duration, err error) { reads = append(reads, size) }
+
+	small := Raw(AppendString(nil, "hi"))
+	large := Raw(AppendBytes(nil, make([]byte, 100)))
+
+	var buf bytes.Buffer
+	w := NewWriterWithOptions(&buf, WriterOptions{BufferSize: 32, Metrics: &mt})
+	for _, msg := range []Raw{small, large, small} {
+		if err := w.Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+	total := int64(buf.Len())
+
+	r := NewReaderWithOptions(bytes.NewReader(buf.Bytes()), ReaderOptions{Metrics: &mt})
+	var msg Raw
+	for i := 0; i < 3; i++ {
+		if err := r.Decode(&msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Decode(&msg); err == nil {
+		t.Fatal("expected an error at the end of the stream")
+	}
+
+	s := mt.Snapshot()
+	want := MetricsSnapshot{
+		BytesRead:         total,
+		BytesWritten:      total,
+		MessagesRead:      4,
+		MessagesWritten:   3,
+		ReadErrors:        1,
+		MaxMessageRead:    int64(len(large)),
+		MaxMessageWritten: int64(len(large)),
+		DecodeTime:        s.DecodeTime,
+		EncodeTime:        s.EncodeTime,
+	}
+	if s != want {
+		t.Errorf("got  %+v\nwant %+v", s, want)
+	}
+	sizes := []int64{int64(len(small)), int64(len(large)), int64(len(small))}
+	if len(writes) != 3 || writes[0] != sizes[0] || writes[1] != sizes[1] || writes[2] != sizes[2] {
+		t.Errorf("OnWrite got sizes %v", writes)
+	}
+	if len(reads) != 4 || reads[0] != sizes[0] || reads[1] != sizes[1] || reads[2] != sizes[2] {
+		t.Errorf("OnRead got sizes %v", reads)
+	}
+
+	// ForEachMessage reports each message
+	var mt2 Metrics
+	r = NewReaderWithOptions(bytes.NewReader(buf.Bytes()), ReaderOptions{Metrics: &mt2})
+	if err := ForEachMessage(r, func(Raw) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if s := mt2.Snapshot(); s.MessagesRead != 3 || s.BytesRead != total || s.MaxMessageRead != int64(len(large)) {
+		t.Errorf("got %+v", s)
+	}
+
+	// without Metrics, nothing is counted
+	s = mt2.Snapshot()
+	r.Reset(bytes.NewReader(buf.Bytes()))
+	r.SetMetrics(nil)
+	if err := r.Decode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if mt2.Snapshot() != s {
+		t.Error("counted a read without Metrics")
+	}
+}
+
+func BenchmarkWriterEncodeMetrics(b *testing.B) {
+	var mt Metrics
+	msg := Raw(AppendString(nil, "hello"))
+	w := NewWriterWithOptions(Nowhere, WriterOptions{Metrics: &mt})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.Encode(msg)
+	}
+}
@@ -0,0 +1,229 @@
+package msgprpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// ErrShutdown is returned by calls on
+// a Client that has been closed, or whose
+// connection has failed.
+var ErrShutdown = errors.New("msgprpc: connection is shut down")
+
+// ServerError is the error returned by a call
+// when the server responds with an error. It holds
+// the error object sent by the server, which is
+// usually a string.
+type ServerError struct {
+	Value interface{}
+}
+
+func (e ServerError) Error() string {
+	if s, ok := e.Value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(e.Value)
+}
+
+// Call is an outstanding or completed request.
+type Call struct {
+	Method string        // the name of the method
+	Args   []interface{} // the parameters of the request
+	Reply  interface{}   // the result is decoded into Reply
+	Error  error         // set when the call is complete
+	Done   chan *Call    // receives the Call when it completes
+}
+
+func (c *Call) done() {
+	select {
+	case c.Done <- c:
+	default:
+		// the caller gave us an unbuffered
+		// or full channel; don't block the
+		// read loop on it
+	}
+}
+
+// Client is a msgpack-rpc client. Any number
+// of calls may be in progress at once, from
+// any number of goroutines.
+type Client struct {
+	conn io.ReadWriteCloser
+	r    *msgp.Reader
+
+	wmu sync.Mutex // guards w
+	w   *msgp.Writer
+
+	mu       sync.Mutex // guards the fields below
+	seq      uint32
+	pending  map[uint32]*Call
+	closing  bool  // Close has been called
+	shutdown error // the read loop has exited
+}
+
+// NewClient returns a *Client that sends requests
+// over conn, and starts a goroutine that reads the
+// responses. The Client owns conn, and closes it
+// when the Client is closed.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	c := &Client{
+		conn:    conn,
+		r:       msgp.NewReader(conn),
+		w:       msgp.NewWriter(conn),
+		pending: make(map[uint32]*Call),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Call invokes method with args and waits
+// for the response, which is decoded into reply
+// with msgp.Unmarshal unless reply is nil. A nil
+// result leaves reply unchanged.
+//
+// Each argument is encoded with its MarshalMsg
+// method if it implements msgp.Marshaler, and with
+// msgp.Marshal otherwise.
+func (c *Client) Call(method string, reply interface{}, args ...interface{}) error {
+	call := <-c.Go(method, reply, make(chan *Call, 1), args...).Done
+	return call.Error
+}
+
+// Go invokes method asynchronously and returns
+// the Call, which is sent on done when it completes.
+// If done is nil, a new channel is allocated. Otherwise,
+// done must be buffered, or the Call may be dropped.
+func (c *Client) Go(method string, reply interface{}, done chan *Call, args ...interface{}) *Call {
+	if done == nil {
+		done = make(chan *Call, 1)
+	}
+	call := &Call{Method: method, Args: args, Reply: reply, Done: done}
+	params, err := AppendParams(msgp.GetBuffer(), args...)
+	if err != nil {
+		msgp.PutBuffer(params)
+		call.Error = err
+		call.done()
+		return call
+	}
+
+	c.mu.Lock()
+	if c.closing || c.shutdown != nil {
+		c.mu.Unlock()
+		call.Error = ErrShutdown
+		call.done()
+		return call
+	}
+	id := c.seq
+	c.seq++
+	c.pending[id] = call
+	c.mu.Unlock()
+
+	err = c.send(Message{Type: Request, MsgID: id, Method: method, Params: params})
+	msgp.PutBuffer(params)
+	if err != nil {
+		c.mu.Lock()
+		_, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		// the read loop may have already
+		// completed the call with its own error
+		if ok {
+			call.Error = err
+			call.done()
+		}
+	}
+	return call
+}
+
+// Notify sends a notification, which
+// has no response, for method with args.
+func (c *Client) Notify(method string, args ...interface{}) error {
+	c.mu.Lock()
+	closed := c.closing || c.shutdown != nil
+	c.mu.Unlock()
+	if closed {
+		return ErrShutdown
+	}
+	params, err := AppendParams(msgp.GetBuffer(), args...)
+	if err == nil {
+		err = c.send(Message{Type: Notification, Method: method, Params: params})
+	}
+	msgp.PutBuffer(params)
+	return err
+}
+
+func (c *Client) send(m Message) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	err := m.EncodeMsg(c.w)
+	if err == nil {
+		err = c.w.Flush()
+	}
+	return err
+}
+
+// Close closes the connection. Calls that
+// are still in progress fail with ErrShutdown.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closing {
+		c.mu.Unlock()
+		return ErrShutdown
+	}
+	c.closing = true
+	c.mu.Unlock()
+	return c.conn.Close()
+}
+
+func (c *Client) readLoop() {
+	var (
+		m   Message
+		err error
+	)
+	for {
+		if err = m.DecodeMsg(c.r); err != nil {
+			break
+		}
+		if m.Type != Response {
+			// the spec allows a server to
+			// send notifications and requests
+			// to the client; we ignore them
+			continue
+		}
+		c.mu.Lock()
+		call := c.pending[m.MsgID]
+		delete(c.pending, m.MsgID)
+		c.mu.Unlock()
+		if call == nil {
+			continue
+		}
+		switch {
+		case len(m.Error) > 0:
+			v, _, derr := msgp.ReadIntfBytes(m.Error)
+			if derr != nil {
+				call.Error = derr
+			} else {
+				call.Error = ServerError{Value: v}
+			}
+		case call.Reply != nil && len(m.Result) > 0:
+			call.Error = msgp.Unmarshal(m.Result, call.Reply)
+		}
+		call.done()
+	}
+
+	c.mu.Lock()
+	if c.closing || err == io.EOF {
+		err = ErrShutdown
+	}
+	c.shutdown = err
+	for id, call := range c.pending {
+		delete(c.pending, id)
+		call.Error = err
+		call.done()
+	}
+	c.mu.Unlock()
+}
@@ -0,0 +1,312 @@
+package msgprpc
+
+import (
+	"fmt"
+	"io"
+	"net/rpc"
+	"sync"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// The codecs in this file let net/rpc use
+// msgpack-rpc messages in place of gob. A
+// request's single argument is sent as a params
+// array of one element, and the service method
+// ("Service.Method") is sent as the method name,
+// so a net/rpc server using NewServerCodec can
+// also answer msgpack-rpc clients in other languages,
+// provided their methods take one parameter.
+//
+// Arguments and replies are encoded with their
+// EncodeMsg and DecodeMsg methods if they implement
+// msgp.Encodable and msgp.Decodable, and with
+// msgp.Marshal and msgp.Unmarshal otherwise.
+
+// codec holds the state shared
+// by the client and server codecs
+type codec struct {
+	conn io.ReadWriteCloser
+	r    *msgp.Reader
+	w    *msgp.Writer
+	rbuf []byte // scratch space for decoding by reflection
+	wbuf []byte // scratch space for encoding by reflection
+}
+
+func newCodec(conn io.ReadWriteCloser) codec {
+	return codec{conn: conn, r: msgp.NewReader(conn), w: msgp.NewWriter(conn)}
+}
+
+func (c *codec) writeValue(v interface{}) error {
+	if e, ok := v.(msgp.Encodable); ok {
+		return e.EncodeMsg(c.w)
+	}
+	var err error
+	c.wbuf, err = appendValue(c.wbuf[:0], v)
+	if err != nil {
+		return err
+	}
+	_, err = c.w.Write(c.wbuf)
+	return err
+}
+
+// readValue reads the next object into v,
+// or skips it if v is nil. A nil object leaves
+// v unchanged unless v is msgp.Decodable.
+func (c *codec) readValue(v interface{}) error {
+	if v == nil {
+		return c.r.Skip()
+	}
+	if d, ok := v.(msgp.Decodable); ok {
+		return d.DecodeMsg(c.r)
+	}
+	raw := msgp.Raw(c.rbuf[:0])
+	err := raw.DecodeMsg(c.r)
+	c.rbuf = raw
+	if err != nil || len(raw) == 0 {
+		return err
+	}
+	return msgp.Unmarshal(raw, v)
+}
+
+// skipRest skips the last n
+// elements of a message
+func (c *codec) skipRest(n uint32) error {
+	for ; n > 0; n-- {
+		if err := c.r.Skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *codec) flush() error {
+	if err := c.w.Flush(); err != nil {
+		c.conn.Close()
+		return err
+	}
+	return nil
+}
+
+func (c *codec) Close() error { return c.conn.Close() }
+
+// readHeader reads the type of the next
+// message, checking its number of elements
+func (c *codec) readHeader() (MessageType, error) {
+	sz, err := c.r.ReadArrayHeader()
+	if err != nil {
+		return 0, err
+	}
+	if sz < 3 {
+		return 0, ErrBadMessage{Reason: fmt.Sprintf("array of %d elements", sz)}
+	}
+	t, err := c.r.ReadInt()
+	if err != nil {
+		return 0, msgp.WrapError(err, "Type")
+	}
+	return MessageType(t), checkHeader(sz, MessageType(t))
+}
+
+type serverCodec struct {
+	codec
+}
+
+// NewServerCodec returns an rpc.ServerCodec that
+// reads msgpack-rpc requests from conn and writes
+// responses to it. Notifications are ignored, since
+// net/rpc has no way to run a method without replying.
+func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return &serverCodec{codec: newCodec(conn)}
+}
+
+// ServeRPCConn runs the net/rpc DefaultServer
+// on conn using a server codec.
+func ServeRPCConn(conn io.ReadWriteCloser) {
+	rpc.ServeCodec(NewServerCodec(conn))
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	for {
+		t, err := c.readHeader()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case Request:
+			id, err := c.r.ReadUint32()
+			if err != nil {
+				return msgp.WrapError(err, "MsgID")
+			}
+			r.ServiceMethod, err = c.r.ReadString()
+			if err != nil {
+				return msgp.WrapError(err, "Method")
+			}
+			r.Seq = uint64(id)
+			return nil
+		case Response:
+			err = c.skipRest(3)
+		default:
+			err = c.skipRest(2)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *serverCodec) ReadRequestBody(body interface{}) error {
+	sz, err := c.r.ReadArrayHeader()
+	if err != nil {
+		return msgp.WrapError(err, "Params")
+	}
+	switch sz {
+	case 0:
+		return nil
+	case 1:
+		if err = c.readValue(body); err != nil {
+			return msgp.WrapError(err, "Params", 0)
+		}
+		return nil
+	default:
+		// consume the params, so that the
+		// next request can still be read
+		if err = c.skipRest(sz); err != nil {
+			return err
+		}
+		return msgp.WrapError(msgp.ArrayError{Wanted: 1, Got: sz}, "Params")
+	}
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	err := c.w.WriteArrayHeader(4)
+	if err == nil {
+		err = c.w.WriteInt(int(Response))
+	}
+	if err == nil {
+		err = c.w.WriteUint32(uint32(r.Seq))
+	}
+	if err != nil {
+		return err
+	}
+	if r.Error != "" {
+		err = c.w.WriteString(r.Error)
+		if err == nil {
+			err = c.w.WriteNil()
+		}
+	} else {
+		err = c.w.WriteNil()
+		if err == nil {
+			err = c.writeValue(body)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return c.flush()
+}
+
+type clientCodec struct {
+	codec
+
+	mu      sync.Mutex
+	pending map[uint32]uint64 // net/rpc sequence numbers by message ID
+}
+
+// NewClientCodec returns an rpc.ClientCodec
+// that writes msgpack-rpc requests to conn and
+// reads responses from it.
+func NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return &clientCodec{codec: newCodec(conn), pending: make(map[uint32]uint64)}
+}
+
+// NewRPCClient returns an *rpc.Client
+// that uses a client codec on conn.
+func NewRPCClient(conn io.ReadWriteCloser) *rpc.Client {
+	return rpc.NewClientWithCodec(NewClientCodec(conn))
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	// message IDs are only 32 bits wide, so
+	// keep the full sequence number to restore
+	// it when the response arrives
+	id := uint32(r.Seq)
+	c.mu.Lock()
+	c.pending[id] = r.Seq
+	c.mu.Unlock()
+
+	err := c.w.WriteArrayHeader(4)
+	if err == nil {
+		err = c.w.WriteInt(int(Request))
+	}
+	if err == nil {
+		err = c.w.WriteUint32(id)
+	}
+	if err == nil {
+		err = c.w.WriteString(r.ServiceMethod)
+	}
+	if err == nil {
+		err = c.w.WriteArrayHeader(1)
+	}
+	if err == nil {
+		err = c.writeValue(body)
+	}
+	if err != nil {
+		return err
+	}
+	return c.flush()
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	for {
+		t, err := c.readHeader()
+		if err != nil {
+			return err
+		}
+		if t != Response {
+			// requests and notifications
+			// from the server are ignored
+			n := uint32(3)
+			if t == Notification {
+				n = 2
+			}
+			if err = c.skipRest(n); err != nil {
+				return err
+			}
+			continue
+		}
+		id, err := c.r.ReadUint32()
+		if err != nil {
+			return msgp.WrapError(err, "MsgID")
+		}
+		c.mu.Lock()
+		r.Seq = c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+
+		r.Error = ""
+		if c.r.IsNil() {
+			err = c.r.ReadNil()
+		} else {
+			var v interface{}
+			if v, err = c.r.ReadIntf(); err == nil {
+				r.Error = ServerError{Value: v}.Error()
+				// net/rpc treats an empty
+				// error as success
+				if r.Error == "" {
+					r.Error = "msgprpc: empty error"
+				}
+			}
+		}
+		if err != nil {
+			return msgp.WrapError(err, "Error")
+		}
+		return nil
+	}
+}
+
+func (c *clientCodec) ReadResponseBody(body interface{}) error {
+	if err := c.readValue(body); err != nil {
+		return msgp.WrapError(err, "Result")
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package msgprpc
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+type Args struct {
+	A int `msg:"a"`
+	B int `msg:"b"`
+}
+
+type Arith struct{}
+
+func (Arith) Add(args *Args, sum *int) error {
+	*sum = args.A + args.B
+	return nil
+}
+
+func (Arith) Div(args *Args, quo *int) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	*quo = args.A / args.B
+	return nil
+}
+
+// Echo uses a type with generated-style methods
+func (Arith) Echo(in *msgp.Raw, out *msgp.Raw) error {
+	*out = append((*out)[:0], *in...)
+	return nil
+}
+
+func newArithServer(t *testing.T) *rpc.Server {
+	srv := rpc.NewServer()
+	if err := srv.Register(Arith{}); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestRPCCodec(t *testing.T) {
+	srv := newArithServer(t)
+	sc, cc := net.Pipe()
+	go srv.ServeCodec(NewServerCodec(sc))
+	cl := NewRPCClient(cc)
+	defer cl.Close()
+
+	var sum int
+	if err := cl.Call("Arith.Add", &Args{A: 3, B: 4}, &sum); err != nil {
+		t.Fatal(err)
+	}
+	if sum != 7 {
+		t.Errorf("got %d; want 7", sum)
+	}
+
+	var quo int
+	err := cl.Call("Arith.Div", &Args{A: 1}, &quo)
+	if _, ok := err.(rpc.ServerError); !ok || err.Error() != "divide by zero" {
+		t.Errorf("expected rpc.ServerError; got %v", err)
+	}
+
+	// the connection is still usable after an error
+	in := msgp.Raw(msgp.AppendString(nil, "hello"))
+	var out msgp.Raw
+	if err = cl.Call("Arith.Echo", &in, &out); err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(in) {
+		t.Errorf("got %x; want %x", []byte(out), []byte(in))
+	}
+
+	calls := make([]*rpc.Call, 10)
+	for i := range calls {
+		calls[i] = cl.Go("Arith.Add", &Args{A: i, B: i}, new(int), nil)
+	}
+	for i := range calls {
+		c := <-calls[i].Done
+		if c.Error != nil {
+			t.Fatal(c.Error)
+		}
+		if *c.Reply.(*int) != 2*i {
+			t.Errorf("call %d: got %d", i, *c.Reply.(*int))
+		}
+	}
+}
+
+// a msgpack-rpc Client can call
+// a net/rpc server using the codec
+func TestRPCCodecInterop(t *testing.T) {
+	srv := newArithServer(t)
+	sc, cc := net.Pipe()
+	go srv.ServeCodec(NewServerCodec(sc))
+	cl := NewClient(cc)
+	defer cl.Close()
+
+	var sum int
+	if err := cl.Call("Arith.Add", &sum, Args{A: 1, B: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if sum != 3 {
+		t.Errorf("got %d; want 3", sum)
+	}
+	// notifications are dropped without a reply
+	if err := cl.Notify("Arith.Add", Args{}); err != nil {
+		t.Fatal(err)
+	}
+	err := cl.Call("Arith.Add", &sum, 1, 2)
+	if !errors.As(err, new(ServerError)) {
+		t.Errorf("expected ServerError for two params; got %v", err)
+	}
+}
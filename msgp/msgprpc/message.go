@@ -0,0 +1,346 @@
+// Package msgprpc implements the msgpack-rpc protocol
+// (https://github.com/msgpack-rpc/msgpack-rpc/blob/master/spec.md)
+// on top of the msgp runtime, so that Go programs can
+// act as clients and servers for the msgpack-rpc
+// implementations available in other languages.
+//
+// Each message is a MessagePack array:
+//
+//	request:      [0, msgid, method, params]
+//	response:     [1, msgid, error, result]
+//	notification: [2, method, params]
+//
+// A connection is multiplexed: a client may have
+// many requests outstanding at once, and the server
+// may answer them in any order. Responses are matched
+// to requests by their message ID.
+//
+// The package also provides codecs that let
+// net/rpc clients and servers use msgpack-rpc
+// messages in place of gob; see NewServerCodec
+// and NewClientCodec.
+package msgprpc
+
+import (
+	"fmt"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// MessageType is the first element of
+// every msgpack-rpc message.
+type MessageType int
+
+const (
+	Request      MessageType = 0
+	Response     MessageType = 1
+	Notification MessageType = 2
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case Request:
+		return "request"
+	case Response:
+		return "response"
+	case Notification:
+		return "notification"
+	default:
+		return fmt.Sprintf("MessageType(%d)", int(t))
+	}
+}
+
+// Message is a single msgpack-rpc message.
+// Which fields are meaningful depends on Type:
+// requests use MsgID, Method, and Params; responses
+// use MsgID, Error, and Result; notifications use
+// Method and Params. Params, when present, must be
+// an encoded array. An empty Error or Result is
+// encoded as nil.
+//
+// Message implements msgp.Encodable, msgp.Decodable,
+// msgp.Marshaler, msgp.Unmarshaler, and msgp.Sizer, so
+// it can be used with a transport other than the
+// Client and Server in this package.
+type Message struct {
+	Type   MessageType
+	MsgID  uint32
+	Method string
+	Params msgp.Raw
+	Error  msgp.Raw
+	Result msgp.Raw
+}
+
+// ErrBadMessage is returned when a message
+// doesn't have the shape of a msgpack-rpc message.
+type ErrBadMessage struct {
+	Reason string
+}
+
+func (e ErrBadMessage) Error() string {
+	return "msgprpc: bad message: " + e.Reason
+}
+
+// params returns m.Params, or an
+// empty array if it is unset
+func (m *Message) params() msgp.Raw {
+	if len(m.Params) == 0 {
+		return msgp.Raw{0x90}
+	}
+	return m.Params
+}
+
+func (m *Message) arraySize() (uint32, error) {
+	switch m.Type {
+	case Request, Response:
+		return 4, nil
+	case Notification:
+		return 3, nil
+	default:
+		return 0, ErrBadMessage{Reason: "unknown type " + m.Type.String()}
+	}
+}
+
+// EncodeMsg implements msgp.Encodable
+func (m *Message) EncodeMsg(w *msgp.Writer) error {
+	sz, err := m.arraySize()
+	if err != nil {
+		return err
+	}
+	if err = w.WriteArrayHeader(sz); err != nil {
+		return err
+	}
+	if err = w.WriteInt(int(m.Type)); err != nil {
+		return err
+	}
+	switch m.Type {
+	case Request:
+		if err = w.WriteUint32(m.MsgID); err != nil {
+			return err
+		}
+		if err = w.WriteString(m.Method); err != nil {
+			return err
+		}
+		return m.params().EncodeMsg(w)
+	case Response:
+		if err = w.WriteUint32(m.MsgID); err != nil {
+			return err
+		}
+		if err = m.Error.EncodeMsg(w); err != nil {
+			return err
+		}
+		return m.Result.EncodeMsg(w)
+	default:
+		if err = w.WriteString(m.Method); err != nil {
+			return err
+		}
+		return m.params().EncodeMsg(w)
+	}
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (m *Message) MarshalMsg(b []byte) ([]byte, error) {
+	sz, err := m.arraySize()
+	if err != nil {
+		return b, err
+	}
+	o := msgp.AppendArrayHeader(b, sz)
+	o = msgp.AppendInt(o, int(m.Type))
+	switch m.Type {
+	case Request:
+		o = msgp.AppendUint32(o, m.MsgID)
+		o = msgp.AppendString(o, m.Method)
+		return m.params().MarshalMsg(o)
+	case Response:
+		o = msgp.AppendUint32(o, m.MsgID)
+		o, _ = m.Error.MarshalMsg(o)
+		return m.Result.MarshalMsg(o)
+	default:
+		o = msgp.AppendString(o, m.Method)
+		return m.params().MarshalMsg(o)
+	}
+}
+
+// checkHeader checks that a message with
+// sz elements of type t is well-formed
+func checkHeader(sz uint32, t MessageType) error {
+	var want uint32
+	switch t {
+	case Request, Response:
+		want = 4
+	case Notification:
+		want = 3
+	default:
+		return ErrBadMessage{Reason: "unknown type " + t.String()}
+	}
+	if sz != want {
+		return ErrBadMessage{Reason: fmt.Sprintf("%s has %d elements; want %d", t, sz, want)}
+	}
+	return nil
+}
+
+// DecodeMsg implements msgp.Decodable
+func (m *Message) DecodeMsg(r *msgp.Reader) error {
+	sz, err := r.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	if sz < 3 {
+		return ErrBadMessage{Reason: fmt.Sprintf("array of %d elements", sz)}
+	}
+	t, err := r.ReadInt()
+	if err != nil {
+		return msgp.WrapError(err, "Type")
+	}
+	m.Type = MessageType(t)
+	if err = checkHeader(sz, m.Type); err != nil {
+		return err
+	}
+	m.MsgID, m.Method = 0, ""
+	m.Params, m.Error, m.Result = m.Params[:0], m.Error[:0], m.Result[:0]
+	switch m.Type {
+	case Request:
+		if m.MsgID, err = r.ReadUint32(); err != nil {
+			return msgp.WrapError(err, "MsgID")
+		}
+		if m.Method, err = r.ReadString(); err != nil {
+			return msgp.WrapError(err, "Method")
+		}
+		if err = m.Params.DecodeMsg(r); err != nil {
+			return msgp.WrapError(err, "Params")
+		}
+	case Response:
+		if m.MsgID, err = r.ReadUint32(); err != nil {
+			return msgp.WrapError(err, "MsgID")
+		}
+		if err = m.Error.DecodeMsg(r); err != nil {
+			return msgp.WrapError(err, "Error")
+		}
+		if err = m.Result.DecodeMsg(r); err != nil {
+			return msgp.WrapError(err, "Result")
+		}
+	default:
+		if m.Method, err = r.ReadString(); err != nil {
+			return msgp.WrapError(err, "Method")
+		}
+		if err = m.Params.DecodeMsg(r); err != nil {
+			return msgp.WrapError(err, "Params")
+		}
+	}
+	return nil
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (m *Message) UnmarshalMsg(b []byte) ([]byte, error) {
+	sz, o, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return b, err
+	}
+	if sz < 3 {
+		return b, ErrBadMessage{Reason: fmt.Sprintf("array of %d elements", sz)}
+	}
+	t, o, err := msgp.ReadIntBytes(o)
+	if err != nil {
+		return b, msgp.WrapError(err, "Type")
+	}
+	m.Type = MessageType(t)
+	if err = checkHeader(sz, m.Type); err != nil {
+		return b, err
+	}
+	m.MsgID, m.Method = 0, ""
+	m.Params, m.Error, m.Result = m.Params[:0], m.Error[:0], m.Result[:0]
+	switch m.Type {
+	case Request:
+		if m.MsgID, o, err = msgp.ReadUint32Bytes(o); err != nil {
+			return b, msgp.WrapError(err, "MsgID")
+		}
+		if m.Method, o, err = msgp.ReadStringBytes(o); err != nil {
+			return b, msgp.WrapError(err, "Method")
+		}
+		if o, err = m.Params.UnmarshalMsg(o); err != nil {
+			return b, msgp.WrapError(err, "Params")
+		}
+	case Response:
+		if m.MsgID, o, err = msgp.ReadUint32Bytes(o); err != nil {
+			return b, msgp.WrapError(err, "MsgID")
+		}
+		if o, err = m.Error.UnmarshalMsg(o); err != nil {
+			return b, msgp.WrapError(err, "Error")
+		}
+		if o, err = m.Result.UnmarshalMsg(o); err != nil {
+			return b, msgp.WrapError(err, "Result")
+		}
+	default:
+		if m.Method, o, err = msgp.ReadStringBytes(o); err != nil {
+			return b, msgp.WrapError(err, "Method")
+		}
+		if o, err = m.Params.UnmarshalMsg(o); err != nil {
+			return b, msgp.WrapError(err, "Params")
+		}
+	}
+	return o, nil
+}
+
+// Msgsize implements msgp.Sizer
+func (m *Message) Msgsize() int {
+	s := msgp.ArrayHeaderSize + msgp.IntSize + msgp.Uint32Size + msgp.StringPrefixSize + len(m.Method)
+	return s + m.params().Msgsize() + m.Error.Msgsize() + m.Result.Msgsize()
+}
+
+// AppendParams appends an array holding
+// args to b, encoding each argument as
+// described for Client.Call.
+func AppendParams(b []byte, args ...interface{}) ([]byte, error) {
+	b = msgp.AppendArrayHeader(b, uint32(len(args)))
+	var err error
+	for i := range args {
+		b, err = appendValue(b, args[i])
+		if err != nil {
+			return b, msgp.WrapError(err, i)
+		}
+	}
+	return b, nil
+}
+
+// appendValue appends v using its MarshalMsg
+// method if it has one, or reflection otherwise
+func appendValue(b []byte, v interface{}) ([]byte, error) {
+	if m, ok := v.(msgp.Marshaler); ok {
+		return m.MarshalMsg(b)
+	}
+	enc, err := msgp.Marshal(v)
+	if err != nil {
+		return b, err
+	}
+	return append(b, enc...), nil
+}
+
+// DecodeParams decodes the elements of the
+// params array into args, in order, using
+// msgp.Unmarshal. It is an error for params to
+// have more elements than there are args; if it
+// has fewer, the remaining args are left unchanged,
+// which allows for optional trailing parameters.
+func DecodeParams(params msgp.Raw, args ...interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	sz, o, err := msgp.ReadArrayHeaderBytes(params)
+	if err != nil {
+		return err
+	}
+	if int(sz) > len(args) {
+		return msgp.ArrayError{Wanted: uint32(len(args)), Got: sz}
+	}
+	for i := 0; i < int(sz); i++ {
+		rest, err := msgp.Skip(o)
+		if err != nil {
+			return msgp.WrapError(err, i)
+		}
+		if err = msgp.Unmarshal(o[:len(o)-len(rest)], args[i]); err != nil {
+			return msgp.WrapError(err, i)
+		}
+		o = rest
+	}
+	return nil
+}
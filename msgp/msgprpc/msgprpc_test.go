@@ -0,0 +1,161 @@
+package msgprpc
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	params, err := AppendParams(nil, 1, "two", []int{3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []Message{
+		{Type: Request, MsgID: 7, Method: "add", Params: params},
+		{Type: Response, MsgID: 7, Result: msgp.AppendInt(nil, 3)},
+		{Type: Response, MsgID: 8, Error: msgp.AppendString(nil, "oops")},
+		{Type: Notification, Method: "log", Params: params},
+	}
+	for i := range msgs {
+		b, err := msgs[i].MarshalMsg(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > msgs[i].Msgsize() {
+			t.Errorf("message %d: Msgsize %d < %d", i, msgs[i].Msgsize(), len(b))
+		}
+		var buf bytes.Buffer
+		w := msgp.NewWriter(&buf)
+		if err = msgs[i].EncodeMsg(w); err != nil {
+			t.Fatal(err)
+		}
+		w.Flush()
+		if !bytes.Equal(buf.Bytes(), b) {
+			t.Errorf("message %d: EncodeMsg and MarshalMsg differ", i)
+		}
+
+		var m Message
+		left, err := m.UnmarshalMsg(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(left) != 0 {
+			t.Errorf("message %d: %d bytes left over", i, len(left))
+		}
+		var d Message
+		if err = d.DecodeMsg(msgp.NewReader(&buf)); err != nil {
+			t.Fatal(err)
+		}
+		for _, got := range []Message{m, d} {
+			if got.Type != msgs[i].Type || got.MsgID != msgs[i].MsgID || got.Method != msgs[i].Method ||
+				!bytes.Equal(got.Params, msgs[i].Params) || !bytes.Equal(got.Error, msgs[i].Error) ||
+				!bytes.Equal(got.Result, msgs[i].Result) {
+				t.Errorf("message %d: got %+v; want %+v", i, got, msgs[i])
+			}
+		}
+	}
+
+	// wrong number of elements for the type
+	b := msgp.AppendArrayHeader(nil, 3)
+	b = msgp.AppendInt(b, int(Request))
+	b = msgp.AppendUint32(b, 1)
+	b = msgp.AppendString(b, "x")
+	var m Message
+	if _, err := m.UnmarshalMsg(b); !errors.As(err, new(ErrBadMessage)) {
+		t.Errorf("expected ErrBadMessage; got %v", err)
+	}
+}
+
+func TestDecodeParams(t *testing.T) {
+	params, err := AppendParams(nil, 1, "two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		a int
+		b string
+		c = 5.0
+	)
+	if err = DecodeParams(params, &a, &b, &c); err != nil {
+		t.Fatal(err)
+	}
+	if a != 1 || b != "two" || c != 5.0 {
+		t.Errorf("got %d %q %g", a, b, c)
+	}
+	if err = DecodeParams(params, &a); err == nil {
+		t.Error("expected an error for too many params")
+	}
+}
+
+func TestClientServer(t *testing.T) {
+	srv := NewServer()
+	srv.Register("add", func(params msgp.Raw) (interface{}, error) {
+		var a, b int
+		if err := DecodeParams(params, &a, &b); err != nil {
+			return nil, err
+		}
+		return a + b, nil
+	})
+	srv.Register("fail", func(params msgp.Raw) (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	notified := make(chan string, 1)
+	srv.Register("note", func(params msgp.Raw) (interface{}, error) {
+		var s string
+		err := DecodeParams(params, &s)
+		notified <- s
+		return nil, err
+	})
+
+	sc, cc := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		srv.ServeConn(sc)
+		close(done)
+	}()
+	cl := NewClient(cc)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var sum int
+			if err := cl.Call("add", &sum, i, 100); err != nil {
+				t.Error(err)
+				return
+			}
+			if sum != i+100 {
+				t.Errorf("add(%d, 100) = %d", i, sum)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	err := cl.Call("fail", nil)
+	if se, ok := err.(ServerError); !ok || se.Error() != "failed" {
+		t.Errorf("expected ServerError \"failed\"; got %v", err)
+	}
+	if err = cl.Call("missing", nil); !errors.As(err, new(ServerError)) {
+		t.Errorf("expected ServerError; got %v", err)
+	}
+	if err = cl.Notify("note", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if s := <-notified; s != "hi" {
+		t.Errorf("notified with %q", s)
+	}
+
+	if err = cl.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if err = cl.Call("add", nil, 1, 2); err != ErrShutdown {
+		t.Errorf("expected ErrShutdown; got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package msgprpc
+
+import (
+	"io"
+	"net"
+	"sync"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// A Handler handles the requests and
+// notifications for one method. params is
+// the encoded array of parameters, which can
+// be decoded with DecodeParams. The result is
+// encoded as described for Client.Call; if err is
+// non-nil, its message is sent as the error instead.
+// The response to a notification is discarded.
+//
+// Handlers for the requests on a connection are
+// run concurrently, each in its own goroutine.
+type Handler func(params msgp.Raw) (result interface{}, err error)
+
+// Server dispatches msgpack-rpc requests
+// and notifications to registered Handlers.
+// The zero value is ready to use.
+type Server struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// NewServer returns an empty *Server.
+func NewServer() *Server { return &Server{} }
+
+// Register sets the handler for method,
+// replacing any existing handler.
+func (s *Server) Register(method string, h Handler) {
+	s.mu.Lock()
+	if s.handlers == nil {
+		s.handlers = make(map[string]Handler)
+	}
+	s.handlers[method] = h
+	s.mu.Unlock()
+}
+
+func (s *Server) handler(method string) Handler {
+	s.mu.RLock()
+	h := s.handlers[method]
+	s.mu.RUnlock()
+	return h
+}
+
+// Serve accepts connections on l and serves
+// each one in a new goroutine. It returns
+// when l.Accept returns an error.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves requests on conn until
+// the client hangs up or sends a malformed
+// message, and then closes conn. It returns
+// once every outstanding request has been
+// answered.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	var (
+		r   = msgp.NewReader(conn)
+		w   = msgp.NewWriter(conn)
+		wmu sync.Mutex
+		wg  sync.WaitGroup
+	)
+	for {
+		m := new(Message)
+		if err := m.DecodeMsg(r); err != nil {
+			break
+		}
+		if m.Type == Response {
+			// we never send requests,
+			// so there is nothing to match
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := s.call(m)
+			defer msgp.PutBuffer(res.Result)
+			if m.Type == Notification {
+				return
+			}
+			wmu.Lock()
+			err := res.EncodeMsg(w)
+			if err == nil {
+				err = w.Flush()
+			}
+			wmu.Unlock()
+			if err != nil {
+				conn.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	conn.Close()
+}
+
+// call runs the handler for req and
+// returns the response to send
+func (s *Server) call(req *Message) *Message {
+	res := &Message{Type: Response, MsgID: req.MsgID}
+	h := s.handler(req.Method)
+	if h == nil {
+		res.Error = msgp.AppendString(nil, "msgprpc: method not found: "+req.Method)
+		return res
+	}
+	v, err := h(req.Params)
+	if err == nil {
+		res.Result, err = appendValue(msgp.GetBuffer(), v)
+	}
+	if err != nil {
+		res.Error = msgp.AppendString(nil, err.Error())
+		res.Result = res.Result[:0]
+	}
+	return res
+}
@@ -0,0 +1,251 @@
+//go:build go1.18
+
+package msgp
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// Network addresses are encoded as a 'bin' object
+// holding the address in network byte order:
+//
+//	IP, netip.Addr:  4 bytes (IPv4) or 16 bytes (IPv6),
+//	                 followed by the zone, if any
+//	netip.AddrPort:  the address, then the port as a
+//	                 2-byte big-endian integer, then the zone
+//
+// so an IPv4 address takes 6 bytes, and an address
+// and port 8, including the header. The zero Addr
+// and AddrPort, and a nil IP, are encoded as an
+// empty 'bin'. IPv4 addresses in a 16-byte net.IP
+// are encoded in 4 bytes, but IPv4-mapped IPv6
+// netip.Addrs are kept in 16, as netip distinguishes
+// them.
+
+// IPSize is the maximum encoded size of a net.IP.
+const IPSize = 2 + net.IPv6len
+
+// AddrError is returned when a 'bin' object
+// read as an address has a size that doesn't
+// match any of the encodings of that address
+// type. The object is consumed.
+type AddrError struct {
+	Size int
+	ctx  string
+}
+
+// Error implements the error interface
+func (a AddrError) Error() string {
+	str := fmt.Sprintf("msgp: %d bytes is not an encoded address", a.Size)
+	if a.ctx != "" {
+		str += " at " + a.ctx
+	}
+	return str
+}
+
+// Resumable is always 'true' for AddrErrors
+func (a AddrError) Resumable() bool { return true }
+
+func (a AddrError) withContext(ctx string) error { a.ctx = addCtx(a.ctx, ctx); return a }
+
+// appendIPData appends the encoding of ip
+// without the 'bin' header
+func appendIPData(b []byte, ip net.IP) []byte {
+	if ip4 := ip.To4(); ip4 != nil {
+		return append(b, ip4...)
+	}
+	return append(b, ip...)
+}
+
+func appendAddrData(b []byte, a netip.Addr) []byte {
+	if !a.IsValid() {
+		return b
+	}
+	if a.Is4() {
+		a4 := a.As4()
+		return append(b, a4[:]...)
+	}
+	a16 := a.As16()
+	return append(append(b, a16[:]...), a.Zone()...)
+}
+
+func appendAddrPortData(b []byte, ap netip.AddrPort) []byte {
+	a := ap.Addr()
+	if !a.IsValid() {
+		return b
+	}
+	if a.Is4() {
+		a4 := a.As4()
+		b = append(b, a4[:]...)
+	} else {
+		a16 := a.As16()
+		b = append(b, a16[:]...)
+	}
+	b = append(b, byte(ap.Port()>>8), byte(ap.Port()))
+	if a.Is6() {
+		b = append(b, a.Zone()...)
+	}
+	return b
+}
+
+func parseIP(p []byte) (net.IP, error) {
+	switch len(p) {
+	case 0:
+		return nil, nil
+	case net.IPv4len, net.IPv6len:
+		return append(net.IP(nil), p...), nil
+	}
+	return nil, AddrError{Size: len(p)}
+}
+
+func parseAddr(p []byte) (netip.Addr, error) {
+	switch {
+	case len(p) == 0:
+		return netip.Addr{}, nil
+	case len(p) == 4:
+		var a4 [4]byte
+		copy(a4[:], p)
+		return netip.AddrFrom4(a4), nil
+	case len(p) >= 16:
+		var a16 [16]byte
+		copy(a16[:], p)
+		a := netip.AddrFrom16(a16)
+		if len(p) > 16 {
+			a = a.WithZone(string(p[16:]))
+		}
+		return a, nil
+	}
+	return netip.Addr{}, AddrError{Size: len(p)}
+}
+
+func parseAddrPort(p []byte) (netip.AddrPort, error) {
+	switch {
+	case len(p) == 0:
+		return netip.AddrPort{}, nil
+	case len(p) == 4+2:
+		a, _ := parseAddr(p[:4])
+		return netip.AddrPortFrom(a, big.Uint16(p[4:])), nil
+	case len(p) >= 16+2:
+		var a16 [16]byte
+		copy(a16[:], p)
+		a := netip.AddrFrom16(a16)
+		if len(p) > 16+2 {
+			a = a.WithZone(string(p[16+2:]))
+		}
+		return netip.AddrPortFrom(a, big.Uint16(p[16:])), nil
+	}
+	return netip.AddrPort{}, AddrError{Size: len(p)}
+}
+
+// AppendIP appends ip to b as 'bin'.
+func AppendIP(b []byte, ip net.IP) []byte {
+	var buf [net.IPv6len]byte
+	return AppendBytes(b, appendIPData(buf[:0], ip))
+}
+
+// ReadIPBytes reads a net.IP from b
+// and returns the remaining bytes.
+// Possible errors:
+// - ErrShortBytes (too few bytes)
+// - TypeError{} (not 'bin')
+// - AddrError{} (not 0, 4 or 16 bytes long)
+func ReadIPBytes(b []byte) (net.IP, []byte, error) {
+	p, o, err := ReadBytesZC(b)
+	if err != nil {
+		return nil, b, err
+	}
+	ip, err := parseIP(p)
+	return ip, o, err
+}
+
+// AppendAddr appends a to b as 'bin'.
+func AppendAddr(b []byte, a netip.Addr) []byte {
+	var buf [32]byte
+	return AppendBytes(b, appendAddrData(buf[:0], a))
+}
+
+// ReadAddrBytes reads a netip.Addr from b
+// and returns the remaining bytes.
+// Possible errors:
+// - ErrShortBytes (too few bytes)
+// - TypeError{} (not 'bin')
+// - AddrError{} (not an encoded address)
+func ReadAddrBytes(b []byte) (netip.Addr, []byte, error) {
+	p, o, err := ReadBytesZC(b)
+	if err != nil {
+		return netip.Addr{}, b, err
+	}
+	a, err := parseAddr(p)
+	return a, o, err
+}
+
+// AppendAddrPort appends ap to b as 'bin'.
+func AppendAddrPort(b []byte, ap netip.AddrPort) []byte {
+	var buf [32]byte
+	return AppendBytes(b, appendAddrPortData(buf[:0], ap))
+}
+
+// ReadAddrPortBytes reads a netip.AddrPort
+// from b and returns the remaining bytes.
+// Possible errors:
+// - ErrShortBytes (too few bytes)
+// - TypeError{} (not 'bin')
+// - AddrError{} (not an encoded address and port)
+func ReadAddrPortBytes(b []byte) (netip.AddrPort, []byte, error) {
+	p, o, err := ReadBytesZC(b)
+	if err != nil {
+		return netip.AddrPort{}, b, err
+	}
+	ap, err := parseAddrPort(p)
+	return ap, o, err
+}
+
+// WriteIP writes ip as 'bin'.
+func (mw *Writer) WriteIP(ip net.IP) error {
+	var buf [net.IPv6len]byte
+	return mw.WriteBytes(appendIPData(buf[:0], ip))
+}
+
+// ReadIP reads a net.IP from the reader.
+func (m *Reader) ReadIP() (net.IP, error) {
+	var buf [net.IPv6len]byte
+	p, err := m.ReadBytes(buf[:0])
+	if err != nil {
+		return nil, err
+	}
+	return parseIP(p)
+}
+
+// WriteAddr writes a as 'bin'.
+func (mw *Writer) WriteAddr(a netip.Addr) error {
+	var buf [32]byte
+	return mw.WriteBytes(appendAddrData(buf[:0], a))
+}
+
+// ReadAddr reads a netip.Addr from the reader.
+func (m *Reader) ReadAddr() (netip.Addr, error) {
+	var buf [32]byte
+	p, err := m.ReadBytes(buf[:0])
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return parseAddr(p)
+}
+
+// WriteAddrPort writes ap as 'bin'.
+func (mw *Writer) WriteAddrPort(ap netip.AddrPort) error {
+	var buf [32]byte
+	return mw.WriteBytes(appendAddrPortData(buf[:0], ap))
+}
+
+// ReadAddrPort reads a netip.AddrPort from the reader.
+func (m *Reader) ReadAddrPort() (netip.AddrPort, error) {
+	var buf [32]byte
+	p, err := m.ReadBytes(buf[:0])
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	return parseAddrPort(p)
+}
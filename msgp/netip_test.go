@@ -0,0 +1,89 @@
+//go:build go1.18
+
+package msgp
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestAddrs(t *testing.T) {
+	addrs := []string{"", "10.1.2.3", "::1", "::ffff:10.1.2.3", "fe80::1%eth0"}
+	for _, s := range addrs {
+		var a netip.Addr
+		if s != "" {
+			a = netip.MustParseAddr(s)
+		}
+		ap := netip.AddrPortFrom(a, 8080)
+		if s == "" {
+			ap = netip.AddrPort{}
+		}
+
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.WriteAddr(a)
+		w.WriteAddrPort(ap)
+		w.Flush()
+		b := AppendAddrPort(AppendAddr(nil, a), ap)
+		if !bytes.Equal(b, buf.Bytes()) {
+			t.Errorf("%q: Append: got %x, want %x", s, b, buf.Bytes())
+		}
+
+		ga, o, err := ReadAddrBytes(b)
+		if err != nil || ga != a {
+			t.Errorf("%q: ReadAddrBytes: got %v, %v", s, ga, err)
+		}
+		gap, o, err := ReadAddrPortBytes(o)
+		if err != nil || gap != ap || len(o) != 0 {
+			t.Errorf("%q: ReadAddrPortBytes: got %v, %v", s, gap, err)
+		}
+		r := NewReader(&buf)
+		if ga, err := r.ReadAddr(); err != nil || ga != a {
+			t.Errorf("%q: ReadAddr: got %v, %v", s, ga, err)
+		}
+		if gap, err := r.ReadAddrPort(); err != nil || gap != ap {
+			t.Errorf("%q: ReadAddrPort: got %v, %v", s, gap, err)
+		}
+	}
+
+	// the layout is fixed
+	b := AppendAddrPort(nil, netip.MustParseAddrPort("10.1.2.3:80"))
+	if want := []byte{mbin8, 6, 10, 1, 2, 3, 0, 80}; !bytes.Equal(b, want) {
+		t.Errorf("got %x, want %x", b, want)
+	}
+	if _, _, err := ReadAddrBytes(AppendBytes(nil, make([]byte, 5))); err == nil {
+		t.Error("expected an AddrError")
+	} else if _, ok := err.(AddrError); !ok {
+		t.Errorf("expected an AddrError; got %v", err)
+	}
+}
+
+func TestIPs(t *testing.T) {
+	for _, ip := range []net.IP{nil, net.IPv4(10, 1, 2, 3), net.ParseIP("2001:db8::1")} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.WriteIP(ip)
+		w.Flush()
+		b := AppendIP(nil, ip)
+		if !bytes.Equal(b, buf.Bytes()) {
+			t.Errorf("%v: AppendIP: got %x, want %x", ip, b, buf.Bytes())
+		}
+		if len(b) > IPSize {
+			t.Errorf("%v: %d bytes is more than IPSize", ip, len(b))
+		}
+		got, _, err := ReadIPBytes(b)
+		if err != nil || !got.Equal(ip) {
+			t.Errorf("%v: ReadIPBytes: got %v, %v", ip, got, err)
+		}
+		if got, err := NewReader(&buf).ReadIP(); err != nil || !got.Equal(ip) {
+			t.Errorf("%v: ReadIP: got %v, %v", ip, got, err)
+		}
+	}
+
+	// IPv4 addresses have the same encoding as netip.Addrs
+	if a, b := AppendIP(nil, net.IPv4(10, 1, 2, 3)), AppendAddr(nil, netip.MustParseAddr("10.1.2.3")); !bytes.Equal(a, b) {
+		t.Errorf("got %x and %x", a, b)
+	}
+}
@@ -1,8 +1,11 @@
 package msgp
 
 import (
+	"encoding/json"
+	"errors"
 	"math"
 	"strconv"
+	"strings"
 )
 
 // The portable parts of the Number implementation
@@ -225,6 +228,15 @@ func (n *Number) Msgsize() int {
 	}
 }
 
+// CloneMsg returns a copy of n
+func (n *Number) CloneMsg() *Number {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	return &c
+}
+
 // MarshalJSON implements json.Marshaler
 func (n *Number) MarshalJSON() ([]byte, error) {
 	t := n.Type()
@@ -233,7 +245,10 @@ func (n *Number) MarshalJSON() ([]byte, error) {
 	}
 	out := make([]byte, 0, 32)
 	switch t {
-	case Float32Type, Float64Type:
+	case Float32Type:
+		f, _ := n.Float()
+		return strconv.AppendFloat(out, f, 'f', -1, 32), nil
+	case Float64Type:
 		f, _ := n.Float()
 		return strconv.AppendFloat(out, f, 'f', -1, 64), nil
 	case IntType:
@@ -252,7 +267,10 @@ func (n *Number) String() string {
 	switch n.typ {
 	case InvalidType:
 		return "0"
-	case Float32Type, Float64Type:
+	case Float32Type:
+		f, _ := n.Float()
+		return strconv.FormatFloat(f, 'f', -1, 32)
+	case Float64Type:
 		f, _ := n.Float()
 		return strconv.FormatFloat(f, 'f', -1, 64)
 	case IntType:
@@ -265,3 +283,105 @@ func (n *Number) String() string {
 		panic("(*Number).typ is invalid")
 	}
 }
+
+// ParseNumber parses s, which may be any number
+// accepted by strconv.ParseInt, ParseUint or ParseFloat.
+// Integers are kept as integers when possible, so that
+// they don't lose precision: negative integers become
+// an int, integers above math.MaxInt64 become a uint,
+// and everything else becomes a float64. The error, if
+// any, is a *strconv.NumError.
+func ParseNumber(s string) (Number, error) {
+	var n Number
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		n.AsInt(i)
+		return n, nil
+	}
+	if len(s) > 0 && s[0] != '-' {
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			n.AsUint(u)
+			return n, nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return n, err
+	}
+	n.AsFloat64(f)
+	return n, nil
+}
+
+// AsJSONNumber sets the number to the value
+// of j, as parsed by ParseNumber.
+func (n *Number) AsJSONNumber(j json.Number) error {
+	v, err := ParseNumber(string(j))
+	if err != nil {
+		return err
+	}
+	*n = v
+	return nil
+}
+
+// parseJSONNumber parses j as ParseNumber does.
+// If strs is set, it returns str = true instead
+// for an integer too large for an int64 or uint64,
+// which would lose precision as a float64, and for
+// a number too large for a float64.
+func parseJSONNumber(j json.Number, strs bool) (n Number, str bool, err error) {
+	n, err = ParseNumber(string(j))
+	if !strs {
+		return n, false, err
+	}
+	if err != nil {
+		return n, errors.Is(err, strconv.ErrRange), err
+	}
+	return n, n.typ == Float64Type && !strings.ContainsAny(string(j), ".eE"), nil
+}
+
+// AppendJSONNumber appends j as an int, a uint or a
+// float64, whichever ParseNumber parses it as. If strs
+// is set, integers too large for an int64 or a uint64,
+// and numbers too large for a float64, are appended
+// as strings; otherwise, the integers are rounded to
+// a float64, and the numbers cause an error. An invalid
+// number causes an error either way; the error is a
+// *strconv.NumError.
+func AppendJSONNumber(b []byte, j json.Number, strs bool) ([]byte, error) {
+	n, str, err := parseJSONNumber(j, strs)
+	if str {
+		return AppendString(b, string(j)), nil
+	}
+	if err != nil {
+		return b, err
+	}
+	return n.MarshalMsg(b)
+}
+
+// WriteJSONNumber writes j as AppendJSONNumber
+// does, with strs set by SetJSONNumberStrings.
+func (mw *Writer) WriteJSONNumber(j json.Number) error {
+	n, str, err := parseJSONNumber(j, mw.numStrs)
+	if str {
+		return mw.WriteString(string(j))
+	}
+	if err != nil {
+		return err
+	}
+	return n.EncodeMsg(mw)
+}
+
+// JSONNumber returns the number as a json.Number.
+// Integers, including those above math.MaxInt64,
+// are written without loss of precision.
+func (n *Number) JSONNumber() json.Number {
+	return json.Number(n.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (n *Number) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	return n.AsJSONNumber(json.Number(b))
+}
@@ -2,6 +2,8 @@ package msgp
 
 import (
 	"bytes"
+	"encoding/json"
+	"math"
 	"testing"
 )
 
@@ -92,3 +94,125 @@ func TestNumber(t *testing.T) {
 	}
 
 }
+
+func TestParseNumber(t *testing.T) {
+	cases := []struct {
+		in  string
+		typ Type
+		out string
+	}{
+		{"0", IntType, "0"},
+		{"-12", IntType, "-12"},
+		{"9223372036854775807", IntType, "9223372036854775807"},
+		{"18446744073709551615", UintType, "18446744073709551615"},
+		{"1.5", Float64Type, "1.5"},
+		{"-1e3", Float64Type, "-1000"},
+		{"18446744073709551616", Float64Type, "18446744073709552000"},
+	}
+	for _, c := range cases {
+		n, err := ParseNumber(c.in)
+		if err != nil {
+			t.Errorf("%s: %s", c.in, err)
+			continue
+		}
+		if n.Type() != c.typ || n.String() != c.out {
+			t.Errorf("%s: got %s %s; want %s %s", c.in, n.Type(), n.String(), c.typ, c.out)
+		}
+	}
+	if _, err := ParseNumber("12abc"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestNumberJSON(t *testing.T) {
+	type doc struct {
+		A Number `json:"a"`
+		B Number `json:"b"`
+		C Number `json:"c"`
+	}
+	in := []byte(`{"a":18446744073709551615,"b":-3,"c":0.25}`)
+	var d doc
+	if err := json.Unmarshal(in, &d); err != nil {
+		t.Fatal(err)
+	}
+	if u, ok := d.A.Uint(); !ok || u != math.MaxUint64 {
+		t.Errorf("a: got %s", d.A.String())
+	}
+	if i, ok := d.B.Int(); !ok || i != -3 {
+		t.Errorf("b: got %s", d.B.String())
+	}
+	if f, ok := d.C.Float(); !ok || f != 0.25 {
+		t.Errorf("c: got %s", d.C.String())
+	}
+	out, err := json.Marshal(&d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, in) {
+		t.Errorf("got %s; want %s", out, in)
+	}
+
+	// through msgpack and back
+	b, err := d.A.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n Number
+	if _, err = n.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if n.JSONNumber() != "18446744073709551615" {
+		t.Errorf("got %s", n.JSONNumber())
+	}
+	if err = n.AsJSONNumber(json.Number("-7")); err != nil {
+		t.Fatal(err)
+	}
+	if i, ok := n.Int(); !ok || i != -7 {
+		t.Errorf("got %s", n.String())
+	}
+
+	var f Number
+	f.AsFloat32(0.1)
+	if f.String() != "0.1" || f.JSONNumber() != "0.1" {
+		t.Errorf("float32 formatted as %s", f.String())
+	}
+}
+
+func TestWriteJSONNumber(t *testing.T) {
+	big := "123456789012345678901234567890"
+	for _, tt := range []struct {
+		in        json.Number
+		strs      bool
+		want      []byte
+		wantError bool
+	}{
+		{in: "-3", want: AppendInt64(nil, -3)},
+		{in: "18446744073709551615", want: AppendUint64(nil, math.MaxUint64)},
+		{in: "1.5", want: AppendFloat64(nil, 1.5)},
+		{in: json.Number(big), want: AppendFloat64(nil, 1.2345678901234568e29)},
+		{in: json.Number(big), strs: true, want: AppendString(nil, big)},
+		{in: "1e400", wantError: true},
+		{in: "1e400", strs: true, want: AppendString(nil, "1e400")},
+		{in: "x", strs: true, wantError: true},
+	} {
+		b, err := AppendJSONNumber(nil, tt.in, tt.strs)
+		if (err != nil) != tt.wantError || !bytes.Equal(b, tt.want) {
+			t.Errorf("AppendJSONNumber(%q, %v): got %x, %v; want %x", tt.in, tt.strs, b, err, tt.want)
+		}
+		var buf bytes.Buffer
+		w := NewWriterWithOptions(&buf, WriterOptions{JSONNumberStrings: tt.strs})
+		err = w.WriteIntf(tt.in)
+		w.Flush()
+		if (err != nil) != tt.wantError || !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("WriteIntf(%q, %v): got %x, %v; want %x", tt.in, tt.strs, buf.Bytes(), err, tt.want)
+		}
+	}
+
+	b, err := AppendIntf(nil, map[string]interface{}{"n": json.Number("7")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := AppendInt(AppendString(AppendMapHeader(nil, 1), "n"), 7); !bytes.Equal(b, want) {
+		t.Errorf("AppendIntf: got %x, want %x", b, want)
+	}
+}
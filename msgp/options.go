@@ -0,0 +1,572 @@
+package msgp
+
+import (
+	"io"
+	"math"
+	"reflect"
+	"unicode/utf8"
+)
+
+// ReaderOptions configures the limits and
+// behaviors of a Reader created with
+// NewReaderWithOptions. The zero value
+// imposes no limits, and yields a Reader
+// that behaves like one returned by NewReader.
+type ReaderOptions struct {
+	// BufferSize is the size of the read
+	// buffer. If it is zero, the default
+	// size is used.
+	BufferSize int
+
+	// MaxDepth is the maximum nesting depth
+	// of maps and arrays that may be traversed
+	// by the methods that consume whole objects
+	// (Skip, CopyNext, ReadIntf, ReadMapStrIntf,
+	// and WriteToJSON). Zero means no limit.
+	MaxDepth int
+
+	// MaxElements is the maximum number of
+	// elements that an array or map header
+	// may declare. Zero means no limit.
+	MaxElements uint32
+
+	// MaxMessageSize sets the Reader's
+	// MaxMessageSize; see SetMaxMessageSize.
+	MaxMessageSize int64
+
+	// MaxStringLength and MaxBinLength
+	// are the maximum sizes of 'str' and
+	// 'bin' objects, respectively, that
+	// may be read. Zero means no limit.
+	MaxStringLength uint32
+	MaxBinLength    uint32
+
+	// StrictUTF8 causes ReadString,
+	// ReadStringAsBytes, and ReadMapKey
+	// to return a UTF8Error if the string
+	// is not valid UTF-8.
+	StrictUTF8 bool
+
+	// OldSpec allows the methods that
+	// read 'bin' objects to accept 'str'
+	// objects, which is how binary data is
+	// represented by encoders that implement
+	// the old MessagePack specification (where
+	// both were encoded as 'raw').
+	OldSpec bool
+
+	// StrBinInterchange lets 'str' and 'bin'
+	// stand in for each other, as they do in
+	// messages from encoders that don't distinguish
+	// text from binary data: the methods that read
+	// 'bin' objects accept 'str' objects (as with
+	// OldSpec), and those that read strings accept
+	// 'bin' objects, which are subject to StrictUTF8
+	// and MaxBinLength.
+	StrBinInterchange bool
+
+	// NilAsEmpty causes the methods that read
+	// map and array headers, strings, and 'bin'
+	// objects to accept nil, which is read as an
+	// empty map or array, the empty string, or
+	// a nil []byte. Encoders that write nil slices
+	// and maps as nil (as Marshal does) can then be
+	// read by generated DecodeMsg methods.
+	NilAsEmpty bool
+
+	// Coercions sets the conversions between
+	// types that the Reader makes; see SetCoercions.
+	Coercions Coercions
+
+	// Intf is the policy used by ReadIntf;
+	// see SetIntfPolicy.
+	Intf IntfPolicy
+
+	// Registry is the extension registry
+	// used by ReadIntf; see SetRegistry.
+	Registry *Registry
+
+	// TimeFormat sets the Reader's
+	// TimeFormat; see SetTimeFormat.
+	TimeFormat TimeFormat
+
+	// Metrics, if set, receives measurements
+	// of what the Reader reads; see Metrics.
+	Metrics *Metrics
+}
+
+// NewReaderWithOptions returns a *Reader
+// that reads from r and enforces the
+// provided options.
+func NewReaderWithOptions(r io.Reader, opts ReaderOptions) *Reader {
+	var m *Reader
+	if opts.BufferSize > 0 {
+		m = NewReaderSize(r, opts.BufferSize)
+	} else {
+		m = NewReader(r)
+	}
+	m.maxDepth = opts.MaxDepth
+	m.maxElements = opts.MaxElements
+	m.maxStr = opts.MaxStringLength
+	m.maxBin = opts.MaxBinLength
+	m.SetMaxMessageSize(opts.MaxMessageSize)
+	m.strictUTF8 = opts.StrictUTF8
+	m.nilEmpty = opts.NilAsEmpty
+	m.oldSpec = opts.OldSpec
+	m.strBin = opts.StrBinInterchange
+	m.coerce = opts.Coercions
+	m.intf = opts.Intf
+	m.reg = opts.Registry
+	m.timeFmt = opts.TimeFormat
+	m.SetMetrics(opts.Metrics)
+	return m
+}
+
+// WriterOptions configures the behavior
+// of a Writer created with NewWriterWithOptions.
+// The zero value yields a Writer that behaves
+// like one returned by NewWriter.
+type WriterOptions struct {
+	// BufferSize is the size of the write
+	// buffer. If it is zero, the default
+	// size is used.
+	BufferSize int
+
+	// OldSpec causes the Writer to produce
+	// output that can be read by decoders that
+	// implement the old MessagePack specification:
+	// []byte is written using the 'str' types,
+	// and 'str8' is never used.
+	OldSpec bool
+
+	// CompactFloats sets the Writer's
+	// CompactFloats option; see SetCompactFloats.
+	CompactFloats bool
+
+	// SortMaps sets the Writer's SortMaps option.
+	SortMaps bool
+
+	// NilPointerError sets the Writer's
+	// NilPointerError option; see SetNilPointerError.
+	NilPointerError bool
+
+	// Timestamps sets the Writer's
+	// Timestamps option; see SetTimestamps.
+	Timestamps bool
+
+	// StringKeys sets the Writer's
+	// StringKeys option; see SetStringKeys.
+	StringKeys bool
+
+	// JSONNumberStrings sets the Writer's
+	// JSONNumberStrings option; see SetJSONNumberStrings.
+	JSONNumberStrings bool
+
+	// TimeFormat sets the Writer's
+	// TimeFormat; see SetTimeFormat.
+	TimeFormat TimeFormat
+
+	// Metrics, if set, receives measurements
+	// of what the Writer writes; see Metrics.
+	Metrics *Metrics
+}
+
+// NewWriterWithOptions returns a *Writer
+// that writes to w with the provided options.
+func NewWriterWithOptions(w io.Writer, opts WriterOptions) *Writer {
+	var mw *Writer
+	if opts.BufferSize > 0 {
+		mw = NewWriterSize(w, opts.BufferSize)
+	} else {
+		mw = popWriter(w)
+	}
+	mw.oldSpec = opts.OldSpec
+	mw.compactFloats = opts.CompactFloats
+	mw.sortMaps = opts.SortMaps
+	mw.nilPtrErr = opts.NilPointerError
+	mw.timestamps = opts.Timestamps
+	mw.strKeys = opts.StringKeys
+	mw.numStrs = opts.JSONNumberStrings
+	mw.timeFmt = opts.TimeFormat
+	mw.metrics = opts.Metrics
+	return mw
+}
+
+// VmihailencoReaderOptions and VmihailencoWriterOptions
+// return options for exchanging messages with programs
+// that use github.com/vmihailenco/msgpack, such as during
+// a migration from one to the other.
+//
+// That package writes nil slices, maps and pointers
+// as nil, which the Reader then reads as empty, and
+// it writes time.Time as a standard timestamp, which
+// the Writer then writes as well. (ReadTime accepts
+// both kinds of times regardless.) Structs need no
+// options: like that package, generated code and
+// Marshal encode structs as maps keyed by field name,
+// and honor `msgpack` tags when there is no `msg` tag.
+func VmihailencoReaderOptions() ReaderOptions {
+	return ReaderOptions{NilAsEmpty: true}
+}
+
+// VmihailencoWriterOptions returns options for writing
+// messages to be read with github.com/vmihailenco/msgpack;
+// see VmihailencoReaderOptions.
+func VmihailencoWriterOptions() WriterOptions {
+	return WriterOptions{Timestamps: true}
+}
+
+// SetTimeFormat sets the representations of
+// time.Time, besides the time and timestamp
+// extensions, that ReadTime (and so generated
+// DecodeMsg methods) accepts; see ReadTimeFormatBytes.
+func (m *Reader) SetTimeFormat(f TimeFormat) { m.timeFmt = f }
+
+// SetMaxDepth sets the maximum nesting depth
+// of maps and arrays traversed by Skip, CopyNext,
+// ReadIntf, ReadMapStrIntf, and WriteToJSON.
+// Exceeding it causes a LimitError. Zero
+// means no limit.
+func (m *Reader) SetMaxDepth(n int) { m.maxDepth = n }
+
+// SetMaxElements sets the maximum number of
+// elements that an array or map header may
+// declare. Exceeding it causes a LimitError.
+// Zero means no limit.
+//
+// Generated DecodeMsg methods allocate slices
+// and maps using the size in the header, so
+// this protects them from being asked to allocate
+// huge objects by small (malicious) messages.
+func (m *Reader) SetMaxElements(n uint32) { m.maxElements = n }
+
+// SetMaxStringLength sets the maximum length
+// of a 'str' object. Exceeding it causes a
+// LimitError. Zero means no limit.
+func (m *Reader) SetMaxStringLength(n uint32) { m.maxStr = n }
+
+// SetMaxBinLength sets the maximum length
+// of a 'bin' object. Exceeding it causes a
+// LimitError. Zero means no limit.
+func (m *Reader) SetMaxBinLength(n uint32) { m.maxBin = n }
+
+// SetMaxMessageSize limits each message read
+// from the Reader to n bytes; reading past the limit
+// causes a LimitError whose Size is n+1 (since the
+// real size of the message isn't known). A message
+// begins when the limit is set, when the Reader is
+// Reset, and at each call to BeginMessage, which
+// should be called before reading each message of
+// a stream. Zero means no limit.
+//
+// Unlike the other limits, this one bounds the
+// total size of a message, however it is read, so
+// it guards against messages that are large because
+// they have many elements spread across many maps
+// and arrays. The Reader never buffers data from
+// its source beyond the end of the limit.
+func (m *Reader) SetMaxMessageSize(n int64) {
+	m.maxMsg = n
+	m.BeginMessage()
+}
+
+// BeginMessage marks the current position
+// of the Reader as the start of a message for
+// the purposes of SetMaxMessageSize. It has no
+// effect if there is no message size limit.
+func (m *Reader) BeginMessage() {
+	m.cr.max = m.maxMsg
+	m.cr.limit = 0
+	if m.maxMsg > 0 {
+		m.cr.limit = m.Offset() + m.maxMsg
+	}
+}
+
+// resetOptions clears all of the
+// options set on a Reader
+func (m *Reader) resetOptions() {
+	m.maxDepth = 0
+	m.maxElements = 0
+	m.maxStr = 0
+	m.maxBin = 0
+	m.SetMaxMessageSize(0)
+	m.strictUTF8 = false
+	m.nilEmpty = false
+	m.oldSpec = false
+	m.strBin = false
+	m.coerce = Coercions{}
+	m.intf = IntfPolicy{}
+	m.reg = nil
+	m.timeFmt = TimeFormatExt
+	m.SetMetrics(nil)
+	m.depth = 0
+}
+
+// enter is called before reading the
+// elements of a map or array, and leave
+// is called afterwards
+func (m *Reader) enter() error {
+	if m.maxDepth > 0 && m.depth >= m.maxDepth {
+		return LimitError{Limit: "depth", Size: uint64(m.depth + 1), Max: uint64(m.maxDepth)}
+	}
+	m.depth++
+	return nil
+}
+
+func (m *Reader) leave() { m.depth-- }
+
+// checkElements returns an error if
+// sz exceeds the element limit
+func (m *Reader) checkElements(sz uint32) error {
+	if m.maxElements > 0 && sz > m.maxElements {
+		return LimitError{Limit: "elements", Size: uint64(sz), Max: uint64(m.maxElements)}
+	}
+	return nil
+}
+
+// checkObjects is checkElements for the
+// number of objects returned by getSize
+// or getNextSize for lead, which counts
+// both the keys and values of maps
+func (m *Reader) checkObjects(lead byte, o uintptr) error {
+	if m.maxElements == 0 {
+		return nil
+	}
+	if isfixmap(lead) || lead == mmap16 || lead == mmap32 {
+		o /= 2
+	}
+	return m.checkElements(uint32(o))
+}
+
+// checkStr and checkBin return an error if
+// sz exceeds the string or bin length limit,
+// or the data left in a slice (see checkShort)
+func (m *Reader) checkStr(sz uint32) error {
+	if m.maxStr > 0 && sz > m.maxStr {
+		return LimitError{Limit: "string length", Size: uint64(sz), Max: uint64(m.maxStr)}
+	}
+	return m.checkShort(int64(sz))
+}
+
+func (m *Reader) checkBin(sz uint32) error {
+	if m.maxBin > 0 && sz > m.maxBin {
+		return LimitError{Limit: "bin length", Size: uint64(sz), Max: uint64(m.maxBin)}
+	}
+	return m.checkShort(int64(sz))
+}
+
+// checkUTF8 returns an error if b is
+// not valid UTF-8 and the Reader is strict
+func (m *Reader) checkUTF8(b []byte) error {
+	if m.strictUTF8 && !utf8.Valid(b) {
+		return UTF8Error{}
+	}
+	return nil
+}
+
+// readNilEmpty skips the next object and
+// returns true if it is nil and the Reader
+// reads nil as empty (see NilAsEmpty)
+func (m *Reader) readNilEmpty() bool {
+	if !m.nilEmpty {
+		return false
+	}
+	p, err := m.R.Peek(1)
+	if err != nil || p[0] != mnil {
+		return false
+	}
+	m.R.Skip(1)
+	return true
+}
+
+// IntMode selects the Go type that
+// ReadIntf uses for integers.
+type IntMode uint8
+
+const (
+	// IntDefault decodes signed integers
+	// as int64 and unsigned integers as uint64,
+	// according to their encoding.
+	IntDefault IntMode = iota
+
+	// IntPreferInt64 decodes all integers
+	// as int64, except for unsigned integers
+	// that overflow an int64.
+	IntPreferInt64
+
+	// IntPreferUint64 decodes all integers
+	// as uint64, except for negative integers.
+	IntPreferUint64
+
+	// IntAsNumber decodes all integers as Number.
+	IntAsNumber
+)
+
+// FloatMode selects the Go type that
+// ReadIntf uses for floating-point numbers.
+type FloatMode uint8
+
+const (
+	// FloatDefault decodes float32 and
+	// float64 objects as float32 and float64,
+	// according to their encoding.
+	FloatDefault FloatMode = iota
+
+	// FloatWiden decodes all floating-point
+	// numbers as float64.
+	FloatWiden
+
+	// FloatAsNumber decodes all floating-point
+	// numbers as Number, which remembers the
+	// size of the encoding.
+	FloatAsNumber
+)
+
+// ExtMode selects what ReadIntf does with
+// extensions whose types are not registered
+// (see Registry).
+type ExtMode uint8
+
+const (
+	// ExtAsRaw decodes unregistered
+	// extensions as *RawExtension, which
+	// can be written out again unchanged.
+	ExtAsRaw ExtMode = iota
+
+	// ExtSkip skips unregistered
+	// extensions and decodes them as nil.
+	ExtSkip
+
+	// ExtError skips unregistered extensions
+	// and returns an UnknownExtensionError.
+	ExtError
+)
+
+// IntfPolicy controls how ReadIntf (and
+// ReadMapStrIntf) materialize values. The
+// zero value is the default behavior.
+type IntfPolicy struct {
+	// IntfMapKeys causes maps to be decoded
+	// as map[interface{}]interface{}, with their
+	// keys decoded like any other value, rather
+	// than as map[string]interface{}. (Keys that
+	// decode as slices or maps cause an
+	// ErrUnsupportedType.) It does not apply
+	// to ReadMapStrIntf.
+	IntfMapKeys bool
+
+	// Ints selects the type used for integers.
+	Ints IntMode
+
+	// Floats selects the type used for
+	// floating-point numbers.
+	Floats FloatMode
+
+	// BinAsString causes 'bin' objects to
+	// be decoded as strings rather than []byte.
+	BinAsString bool
+
+	// UnknownExt selects what to do with
+	// extensions that are not registered.
+	UnknownExt ExtMode
+}
+
+// SetIntfPolicy sets the policy used by
+// ReadIntf and ReadMapStrIntf.
+func (m *Reader) SetIntfPolicy(p IntfPolicy) { m.intf = p }
+
+// readIntfInt reads a signed or
+// unsigned integer according to the policy
+func (m *Reader) readIntfInt(t Type) (i interface{}, err error) {
+	if t == UintType {
+		var u uint64
+		u, err = m.ReadUint64()
+		if err != nil {
+			return
+		}
+		switch m.intf.Ints {
+		case IntPreferInt64:
+			if u <= math.MaxInt64 {
+				return int64(u), nil
+			}
+		case IntAsNumber:
+			var n Number
+			n.AsUint(u)
+			return n, nil
+		}
+		return u, nil
+	}
+	var s int64
+	s, err = m.ReadInt64()
+	if err != nil {
+		return
+	}
+	switch m.intf.Ints {
+	case IntPreferUint64:
+		if s >= 0 {
+			return uint64(s), nil
+		}
+	case IntAsNumber:
+		var n Number
+		n.AsInt(s)
+		return n, nil
+	}
+	return s, nil
+}
+
+// readIntfFloat reads a float32 or
+// float64 according to the policy
+func (m *Reader) readIntfFloat(t Type) (i interface{}, err error) {
+	var n Number
+	if t == Float32Type {
+		var f float32
+		f, err = m.ReadFloat32()
+		switch m.intf.Floats {
+		case FloatWiden:
+			return float64(f), err
+		case FloatAsNumber:
+			n.AsFloat32(f)
+			return n, err
+		}
+		return f, err
+	}
+	var f float64
+	f, err = m.ReadFloat64()
+	if m.intf.Floats == FloatAsNumber {
+		n.AsFloat64(f)
+		return n, err
+	}
+	return f, err
+}
+
+// readMapIntfIntf reads a map
+// as a map[interface{}]interface{}
+func (m *Reader) readMapIntfIntf() (mp map[interface{}]interface{}, err error) {
+	var sz uint32
+	sz, err = m.ReadMapHeader()
+	if err != nil {
+		return
+	}
+	if err = m.enter(); err != nil {
+		return
+	}
+	defer m.leave()
+	mp = make(map[interface{}]interface{}, capHint(sz))
+	for i := uint32(0); i < sz; i++ {
+		var key, val interface{}
+		key, err = m.ReadIntf()
+		if err != nil {
+			return
+		}
+		if key != nil && !reflect.TypeOf(key).Comparable() {
+			err = &ErrUnsupportedType{T: reflect.TypeOf(key)}
+			return
+		}
+		val, err = m.ReadIntf()
+		if err != nil {
+			return
+		}
+		mp[key] = val
+	}
+	return
+}
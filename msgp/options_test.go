@@ -0,0 +1,630 @@
+package msgp
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// nested returns n nested arrays
+func nested(n int) []byte {
+	var b []byte
+	for i := 0; i < n; i++ {
+		b = AppendArrayHeader(b, 1)
+	}
+	return AppendNil(b)
+}
+
+func TestReaderMaxDepth(t *testing.T) {
+	opts := ReaderOptions{MaxDepth: 3}
+	ops := map[string]func(m *Reader) error{
+		"Skip": func(m *Reader) error { return m.Skip() },
+		"ReadIntf": func(m *Reader) error {
+			_, err := m.ReadIntf()
+			return err
+		},
+		"CopyNext": func(m *Reader) error {
+			_, err := m.CopyNext(ioutil.Discard)
+			return err
+		},
+		"WriteToJSON": func(m *Reader) error {
+			_, err := m.WriteToJSON(ioutil.Discard)
+			return err
+		},
+	}
+	for name, op := range ops {
+		for _, n := range []int{3, 1, 0} {
+			m := NewReaderWithOptions(bytes.NewReader(nested(n)), opts)
+			if err := op(m); err != nil {
+				t.Errorf("%s: depth %d: %v", name, n, err)
+			}
+			if m.depth != 0 {
+				t.Errorf("%s: depth %d: ended at depth %d", name, n, m.depth)
+			}
+		}
+		m := NewReaderWithOptions(bytes.NewReader(nested(4)), opts)
+		err := op(m)
+		if le, ok := err.(LimitError); !ok || le.Limit != "depth" || le.Size != 4 || le.Max != 3 {
+			t.Errorf("%s: expected a depth LimitError; got %v", name, err)
+		}
+		if m.depth != 0 {
+			t.Errorf("%s: ended at depth %d after an error", name, m.depth)
+		}
+
+		// no limit by default
+		if err := op(NewReader(bytes.NewReader(nested(100)))); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	// nested maps count too
+	var b []byte
+	for i := 0; i < 4; i++ {
+		b = AppendMapHeader(b, 1)
+		b = AppendString(b, "k")
+	}
+	b = AppendNil(b)
+	m := NewReaderWithOptions(bytes.NewReader(b), opts)
+	if _, err := m.ReadIntf(); err == nil {
+		t.Error("expected an error for nested maps")
+	}
+}
+
+func TestReaderMaxElements(t *testing.T) {
+	opts := ReaderOptions{MaxElements: 20}
+	var ok, big bytes.Buffer
+	w := NewWriter(&ok)
+	w.WriteArrayHeader(20)
+	for i := 0; i < 20; i++ {
+		w.WriteNil()
+	}
+	w.Flush()
+	w.Reset(&big)
+	w.WriteMapHeader(21)
+	w.Flush()
+
+	m := NewReaderWithOptions(bytes.NewReader(ok.Bytes()), opts)
+	if sz, err := m.ReadArrayHeader(); err != nil || sz != 20 {
+		t.Errorf("got %d, %v", sz, err)
+	}
+	m = NewReaderWithOptions(bytes.NewReader(ok.Bytes()), opts)
+	if err := m.Skip(); err != nil {
+		t.Error(err)
+	}
+
+	m = NewReaderWithOptions(bytes.NewReader(big.Bytes()), opts)
+	_, err := m.ReadMapHeader()
+	if le, ok := err.(LimitError); !ok || le.Limit != "elements" || le.Size != 21 {
+		t.Errorf("expected an elements LimitError; got %v", err)
+	}
+	m = NewReaderWithOptions(bytes.NewReader(big.Bytes()), opts)
+	if err := m.Skip(); err == nil {
+		t.Error("expected Skip to fail")
+	}
+
+	m = NewReaderWithOptions(bytes.NewReader(AppendArrayHeader(nil, 3)), ReaderOptions{MaxElements: 2})
+	if _, err := m.ReadArrayHeader(); err == nil {
+		t.Error("expected an error for a small array")
+	}
+}
+
+func TestReaderStrictUTF8(t *testing.T) {
+	bad := AppendStringFromBytes(nil, []byte{'a', 0xff, 'b'})
+	good := AppendString(nil, "héllo")
+
+	m := NewReader(bytes.NewReader(bad))
+	if _, err := m.ReadString(); err != nil {
+		t.Errorf("expected no check by default; got %v", err)
+	}
+
+	opts := ReaderOptions{StrictUTF8: true}
+	m = NewReaderWithOptions(bytes.NewReader(good), opts)
+	if s, err := m.ReadString(); err != nil || s != "héllo" {
+		t.Errorf("got %q, %v", s, err)
+	}
+	m = NewReaderWithOptions(bytes.NewReader(bad), opts)
+	if _, err := m.ReadString(); err == nil {
+		t.Error("expected ReadString to fail")
+	} else if _, ok := err.(UTF8Error); !ok {
+		t.Errorf("expected a UTF8Error; got %T", err)
+	}
+	m = NewReaderWithOptions(bytes.NewReader(bad), opts)
+	if _, err := m.ReadMapKey(nil); err == nil {
+		t.Error("expected ReadMapKey to fail")
+	}
+
+	// bin keys are not checked
+	m = NewReaderWithOptions(bytes.NewReader(AppendBytes(nil, []byte{0xff})), opts)
+	if _, err := m.ReadMapKey(nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOldSpec(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriterWithOptions(&buf, WriterOptions{OldSpec: true})
+	long := bytes.Repeat([]byte{'x'}, 100)
+	w.WriteBytes([]byte("raw"))
+	w.WriteBytes(long)
+	w.WriteString(string(long))
+	w.WriteBytesHeader(3)
+	w.Write([]byte("hdr"))
+	w.Flush()
+
+	b := buf.Bytes()
+	if b[0] != wfixstr(3) {
+		t.Errorf("expected fixstr; got %x", b[0])
+	}
+	for _, lead := range b {
+		switch lead {
+		case mstr8, mbin8, mbin16, mbin32:
+			t.Fatalf("unexpected prefix %x in %x", lead, b)
+		}
+	}
+
+	// a strict reader rejects 'str' for 'bin'
+	m := NewReader(bytes.NewReader(b))
+	if _, err := m.ReadBytes(nil); err == nil {
+		t.Error("expected ReadBytes to fail")
+	}
+
+	m = NewReaderWithOptions(bytes.NewReader(b), ReaderOptions{OldSpec: true})
+	if out, err := m.ReadBytes(nil); err != nil || string(out) != "raw" {
+		t.Errorf("got %q, %v", out, err)
+	}
+	into := make([]byte, 100)
+	if err := m.ReadExactBytes(into); err != nil || !bytes.Equal(into, long) {
+		t.Errorf("got %q, %v", into, err)
+	}
+	if s, err := m.ReadString(); err != nil || s != string(long) {
+		t.Errorf("got %q, %v", s, err)
+	}
+	if sz, err := m.ReadBytesHeader(); err != nil || sz != 3 {
+		t.Errorf("got %d, %v", sz, err)
+	}
+
+	// an empty fixstr can be the last byte
+	for name, opts := range map[string]ReaderOptions{
+		"OldSpec":           {OldSpec: true},
+		"StrBinInterchange": {StrBinInterchange: true},
+	} {
+		m = NewReaderWithOptions(bytes.NewReader([]byte{wfixstr(0)}), opts)
+		if out, err := m.ReadBytes(nil); err != nil || len(out) != 0 {
+			t.Errorf("%s: got %q, %v", name, out, err)
+		}
+	}
+
+	// the options don't survive the pool
+	pushWriter(w)
+	w = NewWriter(&buf)
+	if w.oldSpec {
+		t.Error("pooled writer kept its options")
+	}
+}
+
+func TestStrBinInterchange(t *testing.T) {
+	var b []byte
+	b = AppendBytes(b, []byte("bin"))
+	b = AppendBytes(b, []byte("bin"))
+	b = AppendBytes(b, bytes.Repeat([]byte{'y'}, 300))
+	b = AppendBytes(b, []byte("hdr"))
+	b = AppendString(b, "str")
+	b = AppendString(b, "str")
+	b = AppendBytes(b, []byte{0xff})
+
+	m := NewReader(bytes.NewReader(b))
+	if _, err := m.ReadString(); err == nil {
+		t.Error("expected ReadString to fail")
+	}
+
+	opts := ReaderOptions{StrBinInterchange: true, StrictUTF8: true}
+	m = NewReaderWithOptions(bytes.NewReader(b), opts)
+	if s, err := m.ReadString(); err != nil || s != "bin" {
+		t.Errorf("got %q, %v", s, err)
+	}
+	if s, err := m.ReadStringAsBytes(nil); err != nil || string(s) != "bin" {
+		t.Errorf("got %q, %v", s, err)
+	}
+	if s, err := m.ReadStringZC(); err != nil || len(s) != 300 {
+		t.Errorf("got %q, %v", s, err)
+	}
+	if sz, err := m.ReadStringHeader(); err != nil || sz != 3 {
+		t.Errorf("got %d, %v", sz, err)
+	}
+	m.R.Skip(3)
+	if out, err := m.ReadBytes(nil); err != nil || string(out) != "str" {
+		t.Errorf("got %q, %v", out, err)
+	}
+	if sz, err := m.ReadBytesHeader(); err != nil || sz != 3 {
+		t.Errorf("got %d, %v", sz, err)
+	}
+	m.R.Skip(3)
+	if _, err := m.ReadString(); err != (UTF8Error{}) {
+		t.Errorf("expected a UTF8Error; got %v", err)
+	}
+
+	// bin limits apply to bin read as strings
+	opts = ReaderOptions{StrBinInterchange: true, MaxBinLength: 2}
+	m = NewReaderWithOptions(bytes.NewReader(b), opts)
+	if _, err := m.ReadString(); err == nil {
+		t.Error("expected a LimitError")
+	}
+}
+
+func TestCompactFloats(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriterWithOptions(&buf, WriterOptions{CompactFloats: true})
+	w.WriteFloat64(1.5)
+	w.WriteFloat64(0.1)
+	w.WriteFloat64(math.Inf(-1))
+	w.Flush()
+
+	m := NewReader(&buf)
+	for _, want := range []struct {
+		typ Type
+		val float64
+	}{
+		{Float32Type, 1.5},
+		{Float64Type, 0.1},
+		{Float32Type, math.Inf(-1)},
+	} {
+		typ, err := m.NextType()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != want.typ {
+			t.Errorf("%v: got type %s", want.val, typ)
+		}
+		if err := m.Skip(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVmihailencoOptions(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Unix(1700000000, 0)
+	w := NewWriterWithOptions(&buf, VmihailencoWriterOptions())
+	w.WriteTime(now)
+	w.WriteIntf(now)
+	for i := 0; i < 4; i++ {
+		w.WriteNil()
+	}
+	w.WriteString("after")
+	w.Flush()
+
+	b := buf.Bytes()
+	if b[0] != mfixext4 || int8(b[1]) != TimestampExtension {
+		t.Fatalf("expected a timestamp; got %x", b[:2])
+	}
+	m := NewReaderWithOptions(bytes.NewReader(b), VmihailencoReaderOptions())
+	for i := 0; i < 2; i++ {
+		if tm, err := m.ReadTime(); err != nil || !tm.Equal(now) {
+			t.Errorf("got %v, %v", tm, err)
+		}
+	}
+	if sz, err := m.ReadArrayHeader(); err != nil || sz != 0 {
+		t.Errorf("array: got %d, %v", sz, err)
+	}
+	if sz, err := m.ReadMapHeader(); err != nil || sz != 0 {
+		t.Errorf("map: got %d, %v", sz, err)
+	}
+	if s, err := m.ReadString(); err != nil || s != "" {
+		t.Errorf("string: got %q, %v", s, err)
+	}
+	if out, err := m.ReadBytes(nil); err != nil || out != nil {
+		t.Errorf("bytes: got %x, %v", out, err)
+	}
+	if s, err := m.ReadString(); err != nil || s != "after" {
+		t.Errorf("got %q, %v", s, err)
+	}
+
+	// without the option, nil is an error
+	if _, err := NewReader(bytes.NewReader(AppendNil(nil))).ReadArrayHeader(); err == nil {
+		t.Error("expected an error reading nil as an array")
+	}
+	// ReadTimeBytes accepts timestamps too
+	if tm, _, err := ReadTimeBytes(AppendTimestamp(nil, now)); err != nil || !tm.Equal(now) {
+		t.Errorf("got %v, %v", tm, err)
+	}
+}
+
+func TestReaderPoolResetsOptions(t *testing.T) {
+	m := NewReaderWithOptions(bytes.NewReader(nil), ReaderOptions{MaxDepth: 1, StrictUTF8: true})
+	freeR(m)
+	for i := 0; i < 10; i++ {
+		m = NewReader(bytes.NewReader(nil))
+		if m.maxDepth != 0 || m.strictUTF8 {
+			t.Fatal("pooled reader kept its options")
+		}
+	}
+}
+
+func TestReaderLengthLimits(t *testing.T) {
+	str := AppendString(nil, "0123456789")
+	bin := AppendBytes(nil, []byte("0123456789"))
+
+	ops := []struct {
+		name string
+		in   []byte
+		op   func(m *Reader) error
+	}{
+		{"ReadString", str, func(m *Reader) error { _, err := m.ReadString(); return err }},
+		{"ReadStringAsBytes", str, func(m *Reader) error { _, err := m.ReadStringAsBytes(nil); return err }},
+		{"ReadStringHeader", str, func(m *Reader) error { _, err := m.ReadStringHeader(); return err }},
+		{"ReadMapKey", str, func(m *Reader) error { _, err := m.ReadMapKey(nil); return err }},
+		{"ReadMapKeyPtr", str, func(m *Reader) error { _, err := m.ReadMapKeyPtr(); return err }},
+		{"ReadBytes", bin, func(m *Reader) error { _, err := m.ReadBytes(nil); return err }},
+		{"ReadBytesHeader", bin, func(m *Reader) error { _, err := m.ReadBytesHeader(); return err }},
+		{"ReadIntf(str)", str, func(m *Reader) error { _, err := m.ReadIntf(); return err }},
+		{"ReadIntf(bin)", bin, func(m *Reader) error { _, err := m.ReadIntf(); return err }},
+	}
+	for _, o := range ops {
+		m := NewReader(bytes.NewReader(o.in))
+		m.SetMaxStringLength(10)
+		m.SetMaxBinLength(10)
+		if err := o.op(m); err != nil {
+			t.Errorf("%s: %v", o.name, err)
+		}
+
+		m = NewReader(bytes.NewReader(o.in))
+		m.SetMaxStringLength(9)
+		m.SetMaxBinLength(9)
+		err := o.op(m)
+		if le, ok := err.(LimitError); !ok || le.Size != 10 || le.Max != 9 {
+			t.Errorf("%s: expected a LimitError; got %v", o.name, err)
+		}
+	}
+
+	// the limits are independent
+	m := NewReader(bytes.NewReader(bin))
+	m.SetMaxStringLength(1)
+	if _, err := m.ReadBytes(nil); err != nil {
+		t.Error(err)
+	}
+
+	// a huge key is rejected before it is allocated
+	key := []byte{0x81, mstr32, 0x10, 0, 0, 0, 'a'}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	m = NewReader(bytes.NewReader(key))
+	m.SetMaxStringLength(16)
+	m.ReadMapHeader()
+	_, err := m.ReadMapKeyPtr()
+	runtime.ReadMemStats(&after)
+	if _, ok := err.(LimitError); !ok {
+		t.Errorf("ReadMapKeyPtr: expected a LimitError; got %v", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("ReadMapKeyPtr allocated %d bytes", n)
+	}
+}
+
+func TestReaderMaxMessageSize(t *testing.T) {
+	small := AppendArrayHeader(nil, 3)
+	for i := 0; i < 3; i++ {
+		small = AppendInt(small, 1000)
+	}
+	large := AppendArrayHeader(nil, 100)
+	for i := 0; i < 100; i++ {
+		large = AppendInt(large, 1000)
+	}
+	var stream []byte
+	stream = append(stream, small...)
+	stream = append(stream, small...)
+	stream = append(stream, large...)
+
+	sources := map[string]func() io.Reader{
+		"seeker":     func() io.Reader { return bytes.NewReader(stream) },
+		"non-seeker": func() io.Reader { return struct{ io.Reader }{bytes.NewReader(stream)} },
+	}
+	for name, src := range sources {
+		m := NewReaderWithOptions(src(), ReaderOptions{MaxMessageSize: int64(len(small))})
+		for i := 0; i < 2; i++ {
+			m.BeginMessage()
+			if err := m.Skip(); err != nil {
+				t.Fatalf("%s: message %d: %v", name, i, err)
+			}
+		}
+		m.BeginMessage()
+		err := m.Skip()
+		if le, ok := err.(LimitError); !ok || le.Max != uint64(len(small)) {
+			t.Errorf("%s: expected a LimitError; got %v", name, err)
+		}
+
+		// without BeginMessage, the messages add up
+		m = NewReader(src())
+		m.SetMaxMessageSize(int64(len(small)) + 1)
+		m.Skip()
+		if err := m.Skip(); err == nil {
+			t.Errorf("%s: expected an error for the second message", name)
+		}
+	}
+}
+
+func TestReaderSetters(t *testing.T) {
+	// a tiny message that claims to hold 2^32-1 elements
+	b := AppendArrayHeader(nil, math.MaxUint32)
+	m := NewReader(bytes.NewReader(b))
+	m.SetMaxElements(1 << 16)
+	_, err := m.ReadIntf()
+	if le, ok := err.(LimitError); !ok || le.Limit != "elements" {
+		t.Errorf("expected an elements LimitError; got %v", err)
+	}
+
+	m = NewReader(bytes.NewReader(nested(5)))
+	m.SetMaxDepth(4)
+	if err := m.Skip(); err == nil {
+		t.Error("expected an error")
+	} else if Resumable(err) {
+		t.Error("LimitErrors should not be resumable")
+	}
+}
+
+func TestIntfPolicy(t *testing.T) {
+	b := AppendArrayHeader(nil, 5)
+	b = AppendInt64(b, -1)
+	b = AppendInt64(b, 1)
+	b = AppendUint64(b, 200)
+	b = AppendUint64(b, math.MaxUint64)
+	b = AppendBytes(b, []byte("bin"))
+
+	cases := []struct {
+		policy IntfPolicy
+		want   []interface{}
+	}{
+		{IntfPolicy{}, []interface{}{int64(-1), int64(1), uint64(200), uint64(math.MaxUint64), []byte("bin")}},
+		{IntfPolicy{Ints: IntPreferInt64}, []interface{}{int64(-1), int64(1), int64(200), uint64(math.MaxUint64), []byte("bin")}},
+		{IntfPolicy{Ints: IntPreferUint64}, []interface{}{int64(-1), uint64(1), uint64(200), uint64(math.MaxUint64), []byte("bin")}},
+		{IntfPolicy{BinAsString: true}, []interface{}{int64(-1), int64(1), uint64(200), uint64(math.MaxUint64), "bin"}},
+	}
+	for _, c := range cases {
+		m := NewReaderWithOptions(bytes.NewReader(b), ReaderOptions{Intf: c.policy})
+		out, err := m.ReadIntf()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, c.want) {
+			t.Errorf("%+v: got %#v, want %#v", c.policy, out, c.want)
+		}
+	}
+
+	m := NewReader(bytes.NewReader(b))
+	m.SetIntfPolicy(IntfPolicy{Ints: IntAsNumber})
+	out, err := m.ReadIntf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nums := out.([]interface{})
+	if n, ok := nums[0].(Number); !ok || n.Type() != IntType {
+		t.Errorf("got %#v", nums[0])
+	} else if i, _ := n.Int(); i != -1 {
+		t.Errorf("got %d", i)
+	}
+	if n, ok := nums[3].(Number); !ok || n.Type() != UintType {
+		t.Errorf("got %#v", nums[3])
+	} else if u, _ := n.Uint(); u != math.MaxUint64 {
+		t.Errorf("got %d", u)
+	}
+}
+
+func TestIntfPolicyFloats(t *testing.T) {
+	b := AppendArrayHeader(nil, 2)
+	b = AppendFloat32(b, 1.5)
+	b = AppendFloat64(b, 0.1)
+
+	cases := []struct {
+		mode FloatMode
+		want []interface{}
+	}{
+		{FloatDefault, []interface{}{float32(1.5), 0.1}},
+		{FloatWiden, []interface{}{1.5, 0.1}},
+	}
+	for _, c := range cases {
+		m := NewReaderWithOptions(bytes.NewReader(b), ReaderOptions{Intf: IntfPolicy{Floats: c.mode}})
+		out, err := m.ReadIntf()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, c.want) {
+			t.Errorf("%d: got %#v, want %#v", c.mode, out, c.want)
+		}
+	}
+
+	m := NewReader(bytes.NewReader(b))
+	m.SetIntfPolicy(IntfPolicy{Floats: FloatAsNumber})
+	out, err := m.ReadIntf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []Type{Float32Type, Float64Type} {
+		n, ok := out.([]interface{})[i].(Number)
+		if !ok || n.Type() != want {
+			t.Errorf("%d: got %#v", i, out.([]interface{})[i])
+		}
+	}
+}
+
+func TestIntfPolicyUnknownExt(t *testing.T) {
+	b := AppendArrayHeader(nil, 2)
+	b, _ = AppendExtension(b, &RawExtension{Type: 66, Data: []byte("data")})
+	b = AppendInt(b, 1)
+
+	cases := []struct {
+		mode ExtMode
+		want []interface{}
+	}{
+		{ExtAsRaw, []interface{}{&RawExtension{Type: 66, Data: []byte("data")}, int64(1)}},
+		{ExtSkip, []interface{}{nil, int64(1)}},
+	}
+	for _, c := range cases {
+		m := NewReaderWithOptions(bytes.NewReader(b), ReaderOptions{Intf: IntfPolicy{UnknownExt: c.mode}})
+		out, err := m.ReadIntf()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, c.want) {
+			t.Errorf("%d: got %#v, want %#v", c.mode, out, c.want)
+		}
+	}
+
+	m := NewReader(bytes.NewReader(b[1:]))
+	m.SetIntfPolicy(IntfPolicy{UnknownExt: ExtError})
+	_, err := m.ReadIntf()
+	if e, ok := err.(UnknownExtensionError); !ok || e.Type != 66 || !Resumable(err) {
+		t.Fatalf("got error %v", err)
+	}
+	// the extension was skipped
+	if i, err := m.ReadInt(); err != nil || i != 1 {
+		t.Errorf("got %d, %v after the error", i, err)
+	}
+
+	// registered extensions are not affected
+	reg := NewRegistry()
+	reg.Register(66, func() Extension { return new(RawExtension) })
+	m = NewReaderWithOptions(bytes.NewReader(b[1:]), ReaderOptions{Registry: reg, Intf: IntfPolicy{UnknownExt: ExtError}})
+	if _, err := m.ReadIntf(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIntfPolicyMapKeys(t *testing.T) {
+	b := AppendMapHeader(nil, 3)
+	b = AppendInt(b, 1)
+	b = AppendString(b, "one")
+	b = AppendString(b, "two")
+	b = AppendMapHeader(b, 1)
+	b = AppendBool(b, true)
+	b = AppendNil(b)
+	b = AppendBytes(b, []byte("bin"))
+	b = AppendInt(b, 3)
+	m := NewReader(bytes.NewReader(b))
+	m.SetIntfPolicy(IntfPolicy{IntfMapKeys: true, BinAsString: true})
+	out, err := m.ReadIntf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[interface{}]interface{}{
+		int64(1): "one",
+		"two":    map[interface{}]interface{}{true: nil},
+		"bin":    int64(3),
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("got %#v", out)
+	}
+
+	// unhashable keys
+	b = AppendMapHeader(nil, 1)
+	b = AppendArrayHeader(b, 0)
+	b = AppendNil(b)
+	m = NewReader(bytes.NewReader(b))
+	m.SetIntfPolicy(IntfPolicy{IntfMapKeys: true})
+	if _, err := m.ReadIntf(); err == nil {
+		t.Error("expected an error for an array key")
+	}
+}
@@ -0,0 +1,153 @@
+package msgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+)
+
+var errEncoderClosed = errors.New("msgp: Encode called after Close")
+
+// ParallelEncoder marshals messages on a pool
+// of worker goroutines and writes them to an
+// io.Writer as frames (see FrameWriter), in
+// the order in which they were passed to Encode.
+// It is meant for bulk exports and the like,
+// where encoding on a single goroutine can't
+// keep up with the writer. The output can be
+// read with a FrameReader.
+//
+// Encode and Close must not be called
+// concurrently with each other.
+type ParallelEncoder struct {
+	w     io.Writer
+	jobs  chan *encodeJob
+	order chan *encodeJob // jobs in submission order
+	done  chan struct{}   // closed when the writer is done
+
+	mu     sync.Mutex
+	err    error // the first error
+	closed bool
+}
+
+// encodeJob is one message being encoded
+type encodeJob struct {
+	m    Marshaler
+	buf  []byte
+	err  error
+	done chan struct{}
+}
+
+// NewParallelEncoder returns a *ParallelEncoder
+// that writes to w, with the given number of
+// workers. If workers is not positive,
+// runtime.GOMAXPROCS(0) is used.
+func NewParallelEncoder(w io.Writer, workers int) *ParallelEncoder {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	p := &ParallelEncoder{
+		w:     w,
+		jobs:  make(chan *encodeJob, workers),
+		order: make(chan *encodeJob, 2*workers),
+		done:  make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	go p.write()
+	return p
+}
+
+// Encode queues m to be marshaled and written.
+// It blocks while too many messages are waiting
+// to be written. m must not be modified until it
+// has been written, which has happened once Close
+// returns. Encode returns the first error that has
+// occurred so far, after which nothing more is
+// written.
+func (p *ParallelEncoder) Encode(m Marshaler) error {
+	if err := p.Err(); err != nil {
+		return err
+	}
+	if p.closed {
+		return errEncoderClosed
+	}
+	j := &encodeJob{m: m, done: make(chan struct{})}
+	p.order <- j
+	p.jobs <- j
+	return nil
+}
+
+// Close writes the messages that are still
+// queued, stops the workers, and returns the
+// first error that occurred. It doesn't close
+// the underlying writer.
+func (p *ParallelEncoder) Close() error {
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+		close(p.order)
+	}
+	<-p.done
+	return p.Err()
+}
+
+// Err returns the first error that has
+// occurred while marshaling or writing.
+func (p *ParallelEncoder) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *ParallelEncoder) setErr(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+}
+
+// work marshals jobs, leaving room
+// for the frame header
+func (p *ParallelEncoder) work() {
+	for j := range p.jobs {
+		sz := DefaultBufferSize
+		if s, ok := j.m.(Sizer); ok {
+			sz = frameHeaderSize + s.Msgsize()
+		}
+		j.buf, j.err = j.m.MarshalMsg(append(GetBufferSize(sz), 0, 0, 0, 0))
+		if j.err == nil {
+			n := len(j.buf) - frameHeaderSize
+			// as in FrameWriter.flush, the largest
+			// length is reserved for stream headers
+			if max := uint64(frameStreamMarker - 1); uint64(n) > max {
+				j.err = LimitError{Limit: "frame size", Size: uint64(n), Max: max}
+			} else {
+				binary.BigEndian.PutUint32(j.buf, uint32(n))
+			}
+		}
+		close(j.done)
+	}
+}
+
+// write writes the jobs in order, and
+// discards them after the first error
+func (p *ParallelEncoder) write() {
+	defer close(p.done)
+	for j := range p.order {
+		<-j.done
+		err := j.err
+		if err == nil && p.Err() == nil {
+			_, err = p.w.Write(j.buf)
+		}
+		if err != nil {
+			p.setErr(err)
+		}
+		PutBuffer(j.buf)
+		j.m, j.buf = nil, nil
+	}
+}
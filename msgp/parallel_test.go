@@ -0,0 +1,63 @@
+package msgp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// intMsg is a Marshaler for an int
+type intMsg int
+
+func (i intMsg) MarshalMsg(b []byte) ([]byte, error) {
+	if i < 0 {
+		return b, errors.New("negative")
+	}
+	return AppendInt(b, int(i)), nil
+}
+
+func TestParallelEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewParallelEncoder(&buf, 4)
+	for i := 0; i < 1000; i++ {
+		if err := p.Encode(intMsg(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Encode(intMsg(0)); err == nil {
+		t.Error("expected an error from Encode after Close")
+	}
+
+	fr := NewFrameReader(&buf)
+	for i := 0; i < 1000; i++ {
+		msg, err := fr.NextFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _, err := ReadIntBytes(msg); err != nil || v != i {
+			t.Fatalf("frame %d: got %d, %v", i, v, err)
+		}
+	}
+	if _, err := fr.NextFrame(); err != io.EOF {
+		t.Errorf("expected io.EOF; got %v", err)
+	}
+
+	// after an error, nothing more is written
+	buf.Reset()
+	p = NewParallelEncoder(&buf, 2)
+	p.Encode(intMsg(1))
+	p.Encode(intMsg(-1))
+	for i := 0; i < 100; i++ {
+		p.Encode(intMsg(2))
+	}
+	if err := p.Close(); err == nil || err.Error() != "negative" {
+		t.Errorf("expected the marshaling error; got %v", err)
+	}
+	if msg, err := NewFrameReader(&buf).NextFrame(); err != nil || len(msg) != 1 || buf.Len() != 0 {
+		t.Errorf("expected a single frame; got %x, %v, and %d more bytes", msg, err, buf.Len())
+	}
+}
@@ -2,14 +2,25 @@
 
 package msgp
 
-// let's just assume appengine
-// uses 64-bit hardware...
-const smallint = false
+import "math/bits"
 
+// This file holds the portable versions of the
+// definitions in unsafe.go, for environments that
+// don't allow package unsafe (App Engine, or any
+// build with the 'purego' tag). They copy the data
+// rather than sharing its memory, so they are slower,
+// but everything else in the package works the same.
+
+const smallint = bits.UintSize == 32
+
+// UnsafeString returns the byte slice as a string.
+// In this build, the string is a copy of b.
 func UnsafeString(b []byte) string {
 	return string(b)
 }
 
+// UnsafeBytes returns the string as a byte slice.
+// In this build, the slice is a copy of s.
 func UnsafeBytes(s string) []byte {
 	return []byte(s)
 }
@@ -0,0 +1,161 @@
+package msgp
+
+import (
+	"io"
+)
+
+// Validate returns an error if r does not
+// contain exactly one complete MessagePack
+// object. An empty Raw (which represents
+// 'nil') is valid.
+func (r Raw) Validate() error {
+	if len(r) == 0 {
+		return nil
+	}
+	_, err := Validate(r)
+	return err
+}
+
+// Elements returns the elements of the array
+// in r. The returned values point into r.
+// A nil array (or an empty Raw) has no elements.
+func (r Raw) Elements() ([]Raw, error) {
+	if len(r) == 0 || IsNil(r) {
+		return nil, nil
+	}
+	sz, o, err := ReadArrayHeaderBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	// don't trust sz for allocation, since
+	// every element is at least one byte
+	if int(sz) > len(o) {
+		return nil, ErrShortBytes
+	}
+	out := make([]Raw, sz)
+	for i := range out {
+		var next []byte
+		next, err = Skip(o)
+		if err != nil {
+			return nil, WrapError(err, i)
+		}
+		out[i] = Raw(o[:len(o)-len(next)])
+		o = next
+	}
+	return out, nil
+}
+
+// MapRange calls fn for each key and value
+// of the map in r, in the order in which they
+// were encoded, until fn returns false. The
+// values passed to fn point into r. A nil map
+// (or an empty Raw) has no entries.
+func (r Raw) MapRange(fn func(key, value Raw) bool) error {
+	if len(r) == 0 || IsNil(r) {
+		return nil
+	}
+	sz, o, err := ReadMapHeaderBytes(r)
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < sz; i++ {
+		var k, v []byte
+		k, err = Skip(o)
+		if err != nil {
+			return err
+		}
+		v, err = Skip(k)
+		if err != nil {
+			return err
+		}
+		key := Raw(o[:len(o)-len(k)])
+		if !fn(key, Raw(k[:len(k)-len(v)])) {
+			return nil
+		}
+		o = v
+	}
+	return nil
+}
+
+// WriteTo implements io.WriterTo. It writes
+// the contents of r to w, or 'nil' if r is empty.
+func (r Raw) WriteTo(w io.Writer) (int64, error) {
+	b := []byte(r)
+	if len(b) == 0 {
+		b = []byte{mnil}
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// rawChunk is the most data that ReadFrom
+// allocates before it has been read, so that
+// a hostile header can't cause a huge allocation
+const rawChunk = 64 * 1024
+
+// ReadFrom implements io.ReaderFrom. It sets
+// r to the next object read from src, and
+// returns the number of bytes read. Unlike the
+// usual io.ReaderFrom, it stops at the end of the
+// object rather than reading to io.EOF, and it
+// returns io.EOF only if src is empty. Nothing past
+// the end of the object is read from src, so src
+// should be buffered if it isn't a *Reader.
+// As in DecodeMsg, a 'nil' object leaves r empty.
+func (r *Raw) ReadFrom(src io.Reader) (int64, error) {
+	*r = (*r)[:0]
+	var err error
+	if m, ok := src.(*Reader); ok {
+		err = appendNext(m, (*[]byte)(r))
+	} else {
+		err = readNextRaw(src, (*[]byte)(r))
+	}
+	n := int64(len(*r))
+	if IsNil(*r) {
+		*r = (*r)[:0]
+	}
+	return n, err
+}
+
+// readNextRaw appends the next object
+// in src to *d, reading only its bytes
+func readNextRaw(src io.Reader, d *[]byte) error {
+	for objects := uintptr(1); objects > 0; objects-- {
+		start := len(*d)
+		var i int
+		*d, i = ensure(*d, 1)
+		if _, err := io.ReadFull(src, (*d)[i:]); err != nil {
+			if err == io.EOF && start > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		spec := &sizes[(*d)[i]]
+		if spec.size == 0 {
+			return InvalidPrefixError((*d)[i])
+		}
+		// read the rest of the header
+		*d, i = ensure(*d, int(spec.size)-1)
+		if _, err := io.ReadFull(src, (*d)[i:]); err != nil {
+			return noEOF(err)
+		}
+		sz, o, err := getSize((*d)[start:])
+		if err != nil {
+			return err
+		}
+		objects += o
+		// and then the data, a chunk at a time
+		for rem := int(sz) - int(spec.size); rem > 0; {
+			chunk := rem
+			if chunk > rawChunk {
+				chunk = rawChunk
+			}
+			*d, i = ensure(*d, chunk)
+			if _, err := io.ReadFull(src, (*d)[i:]); err != nil {
+				return noEOF(err)
+			}
+			rem -= chunk
+		}
+	}
+	return nil
+}
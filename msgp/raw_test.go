@@ -2,6 +2,8 @@ package msgp
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
 	"testing"
 	"time"
 )
@@ -111,3 +113,188 @@ func TestNullRaw(t *testing.T) {
 		t.Fatal("compare")
 	}
 }
+
+func TestRawValidate(t *testing.T) {
+	bts := AppendMapHeader(nil, 1)
+	bts = AppendString(bts, "key")
+	bts = AppendArrayHeader(bts, 2)
+	bts = AppendInt(bts, 1)
+	bts = AppendString(bts, "two")
+
+	if err := Raw(bts).Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := Raw(nil).Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := Raw(bts[:len(bts)-1]).Validate(); err != ErrShortBytes {
+		t.Errorf("expected ErrShortBytes; got %v", err)
+	}
+	if err := Raw(AppendNil(bts)).Validate(); err != ErrTrailingBytes {
+		t.Errorf("expected ErrTrailingBytes; got %v", err)
+	}
+	if err := Raw([]byte{0xc1}).Validate(); err == nil {
+		t.Error("expected an error for an invalid prefix")
+	}
+}
+
+func TestRawJSON(t *testing.T) {
+	type wrapper struct {
+		R Raw
+		N Raw
+	}
+	bts := AppendMapHeader(nil, 1)
+	bts = AppendString(bts, "key")
+	bts = AppendArrayHeader(bts, 2)
+	bts = AppendInt(bts, 1)
+	bts = AppendBool(bts, true)
+
+	out, err := json.Marshal(wrapper{R: Raw(bts)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"R":{"key":[1,true]},"N":null}` {
+		t.Errorf("got %s", out)
+	}
+}
+
+func TestRawElements(t *testing.T) {
+	bts := AppendArrayHeader(nil, 3)
+	bts = AppendInt(bts, 1)
+	bts = AppendMapHeader(bts, 1)
+	bts = AppendString(bts, "a")
+	bts = AppendString(bts, "b")
+	bts = AppendNil(bts)
+
+	els, err := Raw(bts).Elements()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(els) != 3 {
+		t.Fatalf("got %d elements", len(els))
+	}
+	if i, _, err := ReadIntBytes(els[0]); err != nil || i != 1 {
+		t.Errorf("element 0: got %d, %v", i, err)
+	}
+	if m, _, err := ReadMapStrIntfBytes(els[1], nil); err != nil || m["a"] != "b" {
+		t.Errorf("element 1: got %v, %v", m, err)
+	}
+	if !IsNil(els[2]) || len(els[2]) != 1 {
+		t.Errorf("element 2: got %x", []byte(els[2]))
+	}
+
+	if els, err := Raw(nil).Elements(); err != nil || els != nil {
+		t.Errorf("got %v, %v", els, err)
+	}
+	if _, err := Raw(AppendInt(nil, 1)).Elements(); err == nil {
+		t.Error("expected an error for an int")
+	}
+	// a header that claims more elements than are present
+	if _, err := Raw(AppendArrayHeader(nil, 1<<30)).Elements(); err != ErrShortBytes {
+		t.Errorf("expected ErrShortBytes; got %v", err)
+	}
+}
+
+func TestRawMapRange(t *testing.T) {
+	bts := AppendMapHeader(nil, 3)
+	for _, k := range []string{"a", "b", "c"} {
+		bts = AppendString(bts, k)
+		bts = AppendString(bts, k+k)
+	}
+
+	var keys, vals []string
+	err := Raw(bts).MapRange(func(k, v Raw) bool {
+		ks, _, _ := ReadStringBytes(k)
+		vs, _, _ := ReadStringBytes(v)
+		keys = append(keys, ks)
+		vals = append(vals, vs)
+		return ks != "b"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" || vals[1] != "bb" {
+		t.Errorf("got %v: %v", keys, vals)
+	}
+
+	if err := Raw(bts[:len(bts)-1]).MapRange(func(k, v Raw) bool { return true }); err == nil {
+		t.Error("expected an error for a truncated map")
+	}
+	if err := Raw(AppendNil(nil)).MapRange(func(k, v Raw) bool {
+		t.Error("called for a nil map")
+		return true
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRawWriterToReaderFrom(t *testing.T) {
+	var msg []byte
+	msg = AppendMapHeader(msg, 2)
+	msg = AppendString(msg, "a")
+	msg = AppendArrayHeader(msg, 2)
+	msg = AppendBytes(msg, RandBytes(100000))
+	msg = AppendTime(msg, time.Now())
+	msg = AppendString(msg, "b")
+	msg = AppendFloat32(msg, 1.5)
+
+	var stream []byte
+	stream = append(stream, msg...)
+	stream = AppendNil(stream)
+	stream = AppendInt(stream, 42)
+
+	sources := map[string]func() io.Reader{
+		"plain":  func() io.Reader { return bytes.NewReader(stream) },
+		"Reader": func() io.Reader { return NewReader(bytes.NewReader(stream)) },
+	}
+	for name, mk := range sources {
+		src := mk()
+		var r Raw
+		n, err := r.ReadFrom(src)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if n != int64(len(msg)) || !bytes.Equal(r, msg) {
+			t.Errorf("%s: read %d bytes; want %d", name, n, len(msg))
+		}
+		var out bytes.Buffer
+		if n, err = r.WriteTo(&out); err != nil || n != int64(len(msg)) {
+			t.Errorf("%s: WriteTo: %d, %v", name, n, err)
+		}
+		if !bytes.Equal(out.Bytes(), msg) {
+			t.Errorf("%s: WriteTo wrote different bytes", name)
+		}
+
+		// nil comes back as an empty Raw
+		if n, err = r.ReadFrom(src); err != nil || n != 1 || len(r) != 0 {
+			t.Errorf("%s: nil: got %d, %v, %x", name, n, err, []byte(r))
+		}
+		out.Reset()
+		r.WriteTo(&out)
+		if !bytes.Equal(out.Bytes(), []byte{mnil}) {
+			t.Errorf("%s: empty Raw wrote %x", name, out.Bytes())
+		}
+
+		if _, err = r.ReadFrom(src); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if i, _, err := ReadIntBytes(r); err != nil || i != 42 {
+			t.Errorf("%s: got %d, %v", name, i, err)
+		}
+		if _, err = r.ReadFrom(src); err != io.EOF {
+			t.Errorf("%s: got %v at the end; want io.EOF", name, err)
+		}
+	}
+
+	// a truncated message
+	var r Raw
+	if _, err := r.ReadFrom(bytes.NewReader(msg[:len(msg)-2])); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v; want io.ErrUnexpectedEOF", err)
+	}
+	// a header claiming far more data than there is
+	huge := AppendMapHeader(nil, 1)
+	huge = append(huge, mbin32, 0xff, 0xff, 0xff, 0xff)
+	if _, err := r.ReadFrom(bytes.NewReader(huge)); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v; want io.ErrUnexpectedEOF", err)
+	}
+}
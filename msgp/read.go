@@ -1,9 +1,11 @@
 package msgp
 
 import (
+	"bytes"
 	"io"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/philhofer/fwd"
@@ -99,10 +101,12 @@ type Decodable interface {
 	DecodeMsg(*Reader) error
 }
 
-// Decode decodes 'd' from 'r'.
+// Decode decodes 'd' from 'r'. Errors other than
+// io.EOF are returned as an OffsetError giving
+// the position in 'r' at which they happened.
 func Decode(r io.Reader, d Decodable) error {
 	rd := NewReader(r)
-	err := d.DecodeMsg(rd)
+	err := withOffset(d.DecodeMsg(rd), rd.Offset())
 	freeR(rd)
 	return err
 }
@@ -113,17 +117,111 @@ func Decode(r io.Reader, d Decodable) error {
 func NewReader(r io.Reader) *Reader {
 	p := readerPool.Get().(*Reader)
 	if p.R == nil {
-		p.R = fwd.NewReader(r)
+		p.R = fwd.NewReader(p.source(r))
 	} else {
-		p.R.Reset(r)
+		p.R.Reset(p.source(r))
 	}
+	p.resetOptions()
 	return p
 }
 
 // NewReaderSize returns a *Reader with a buffer of the given size.
 // (This is vastly preferable to passing the decoder a reader that is already buffered.)
 func NewReaderSize(r io.Reader, sz int) *Reader {
-	return &Reader{R: fwd.NewReaderSize(r, sz)}
+	m := &Reader{}
+	m.R = fwd.NewReaderSize(m.source(r), sz)
+	return m
+}
+
+// NewReaderFromBytes returns a *Reader that reads
+// the MessagePack objects in b. The Reader uses b
+// itself as its buffer rather than copying it, so
+// objects of any size can be read without refilling,
+// and b must not be modified while the Reader is in use.
+// The Reader never writes to b: if the data ends in
+// the middle of an object, the unread bytes are copied
+// into a buffer of the Reader's own (as long as R isn't
+// used directly), and a str, bin or ext object (or a
+// map key, or any object read as a Raw or by CopyNext)
+// that claims to be longer than the rest of b causes
+// an ErrShortBytes without anything being allocated.
+//
+// Calling Reset on the Reader gives it a buffer of
+// its own, so that b is not overwritten by the new source.
+func NewReaderFromBytes(b []byte) *Reader {
+	m := &Reader{shared: true}
+	m.R = fwd.NewReaderBuf(m.source(&bytesSource{b: b}), b[:0:len(b)])
+	return m
+}
+
+// bytesSource is the io.Reader behind NewReaderFromBytes
+type bytesSource struct {
+	b []byte // the data not yet handed to the buffer
+}
+
+func (s *bytesSource) Read(p []byte) (int, error) {
+	if len(s.b) == 0 {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	var n int
+	if &p[0] == &s.b[0] {
+		// the buffer is b; the data is already there
+		n = len(s.b)
+	} else {
+		n = copy(p, s.b)
+	}
+	s.b = s.b[n:]
+	return n, nil
+}
+
+// peek and next are R.Peek and R.Next for reads
+// of more than one byte, which R may only be able
+// to satisfy by moving the unread data to the front
+// of its buffer before reading more. A shared Reader
+// (see NewReaderFromBytes) first moves to a buffer of
+// its own, so that the caller's slice isn't written.
+// Reads of a single byte never move anything, since
+// R only reads more once its buffer is empty.
+func (m *Reader) peek(n int) ([]byte, error) {
+	if m.shared && n > m.R.Buffered() {
+		m.unshare()
+	}
+	return m.R.Peek(n)
+}
+
+func (m *Reader) next(n int) ([]byte, error) {
+	if m.shared && n > m.R.Buffered() {
+		m.unshare()
+	}
+	return m.R.Next(n)
+}
+
+// unshare gives a shared Reader a buffer of its
+// own, which the unread data is copied into as
+// soon as R reads more
+func (m *Reader) unshare() {
+	if m.R.Buffered() == 0 {
+		// nothing would be moved; this is
+		// either the first read or the end
+		return
+	}
+	rest, _ := m.R.Peek(m.R.Buffered())
+	m.R = fwd.NewReader(io.MultiReader(bytes.NewReader(rest), &m.cr))
+	m.shared = false
+}
+
+// checkShort returns ErrShortBytes if m reads
+// from a slice (see NewReaderFromBytes) with
+// fewer than sz bytes left, so that a corrupt
+// length can't cause a huge allocation
+func (m *Reader) checkShort(sz int64) error {
+	if src, ok := m.cr.r.(*bytesSource); ok && sz > int64(m.R.Buffered()+len(src.b)) {
+		return ErrShortBytes
+	}
+	return nil
 }
 
 // Reader wraps an io.Reader and provides
@@ -139,6 +237,98 @@ type Reader struct {
 	// within R.
 	R       *fwd.Reader
 	scratch []byte
+
+	// options; see ReaderOptions
+	maxDepth    int
+	maxElements uint32
+	maxStr      uint32
+	maxBin      uint32
+	strictUTF8  bool
+	nilEmpty    bool
+	maxMsg      int64
+	oldSpec     bool
+	strBin      bool
+	coerce      Coercions
+	intf        IntfPolicy
+	reg         *Registry
+	timeFmt     TimeFormat
+	metrics     *Metrics
+
+	depth  int      // current depth; see enter()
+	shared bool     // R's buffer belongs to the caller; see NewReaderFromBytes
+	keys   []string // keys seen by ReadIntfInto, for each level
+
+	cr countingReader // the source of R; see Offset
+}
+
+// countingReader counts the bytes read from r,
+// and refuses to read past limit, if it is set,
+// to enforce the message size limit of max bytes
+type countingReader struct {
+	r       io.Reader
+	n       int64
+	limit   int64
+	max     int64
+	metrics *Metrics
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if c.limit > 0 {
+		if c.n >= c.limit {
+			return 0, c.limitError()
+		}
+		if int64(len(p)) > c.limit-c.n {
+			p = p[:c.limit-c.n]
+		}
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.metrics != nil {
+		atomic.AddInt64(&c.metrics.bytesRead, int64(n))
+	}
+	return n, err
+}
+
+func (c *countingReader) limitError() error {
+	return LimitError{Limit: "message size", Size: uint64(c.max) + 1, Max: uint64(c.max)}
+}
+
+// countingSeeker is a countingReader
+// whose source is also an io.Seeker,
+// so that R can seek past skipped data
+type countingSeeker struct {
+	*countingReader
+}
+
+func (c countingSeeker) Seek(offset int64, whence int) (int64, error) {
+	if c.limit > 0 && whence == io.SeekCurrent && c.n+offset > c.limit {
+		return 0, c.limitError()
+	}
+	n, err := c.r.(io.Seeker).Seek(offset, whence)
+	if err == nil && whence == io.SeekCurrent {
+		c.n += offset
+	}
+	return n, err
+}
+
+// source returns the reader
+// that R should read r through
+func (m *Reader) source(r io.Reader) io.Reader {
+	m.cr = countingReader{r: r, limit: m.maxMsg, max: m.maxMsg, metrics: m.metrics}
+	if _, ok := r.(io.Seeker); ok {
+		return countingSeeker{&m.cr}
+	}
+	return &m.cr
+}
+
+// Offset returns the number of bytes that have
+// been consumed from the Reader since it was created
+// or last Reset, including any read with Read, Skip
+// and the like. (Bytes that are buffered but not yet
+// consumed don't count.) It is only meaningful for
+// Readers created by this package.
+func (m *Reader) Offset() int64 {
+	return m.cr.n - int64(m.R.Buffered())
 }
 
 // Read implements `io.Reader`
@@ -147,12 +337,24 @@ func (m *Reader) Read(p []byte) (int, error) {
 }
 
 // CopyNext reads the next object from m without decoding it and writes it to w.
-// It avoids unnecessary copies internally.
+// It avoids unnecessary copies internally. Maps and arrays are copied
+// whole, so passing a *Writer as w lets a proxy re-encode part of a
+// message while passing the rest of it through unchanged; objects too
+// large for m's buffer are read straight into the Writer's buffer.
 func (m *Reader) CopyNext(w io.Writer) (int64, error) {
-	sz, o, err := getNextSize(m.R)
+	sz, o, err := getNextSize(m)
 	if err != nil {
 		return 0, err
 	}
+	if err = m.checkShort(int64(sz)); err != nil {
+		return 0, err
+	}
+	if o > 0 {
+		p, _ := m.R.Peek(1)
+		if err = m.checkObjects(p[0], o); err != nil {
+			return 0, err
+		}
+	}
 
 	var n int64
 	// Opportunistic optimization: if we can fit the whole thing in the m.R
@@ -161,7 +363,7 @@ func (m *Reader) CopyNext(w io.Writer) (int64, error) {
 	if int(sz) <= m.R.BufferSize() {
 		var nn int
 		var buf []byte
-		buf, err = m.R.Next(int(sz))
+		buf, err = m.next(int(sz))
 		if err != nil {
 			if err == io.ErrUnexpectedEOF {
 				err = ErrShortBytes
@@ -185,6 +387,12 @@ func (m *Reader) CopyNext(w io.Writer) (int64, error) {
 	}
 
 	// for maps and slices, read elements
+	if o > 0 {
+		if err = m.enter(); err != nil {
+			return n, err
+		}
+		defer m.leave()
+	}
 	for x := uintptr(0); x < o; x++ {
 		var n2 int64
 		n2, err = m.CopyNext(w)
@@ -202,7 +410,16 @@ func (m *Reader) ReadFull(p []byte) (int, error) {
 }
 
 // Reset resets the underlying reader.
-func (m *Reader) Reset(r io.Reader) { m.R.Reset(r) }
+// Any options set on the Reader are retained.
+func (m *Reader) Reset(r io.Reader) {
+	if m.shared {
+		m.R = fwd.NewReader(m.source(r))
+		m.shared = false
+	} else {
+		m.R.Reset(m.source(r))
+	}
+	m.depth = 0
+}
 
 // Buffered returns the number of bytes currently in the read buffer.
 func (m *Reader) Buffered() int { return m.R.Buffered() }
@@ -244,6 +461,53 @@ func (m *Reader) IsNil() bool {
 	return err == nil && p[0] == mnil
 }
 
+// PeekType is the same as NextType: it
+// returns the type of the next object
+// without consuming any bytes.
+func (m *Reader) PeekType() (Type, error) { return m.NextType() }
+
+// PeekMapHeader returns the size of the
+// next object, which must be a map, without
+// consuming it. It returns a TypeError if the
+// next object is not a map.
+func (m *Reader) PeekMapHeader() (uint32, error) {
+	return m.peekContainerHeader(MapType)
+}
+
+// PeekArrayHeader returns the size of the
+// next object, which must be an array, without
+// consuming it. It returns a TypeError if the
+// next object is not an array.
+func (m *Reader) PeekArrayHeader() (uint32, error) {
+	return m.peekContainerHeader(ArrayType)
+}
+
+func (m *Reader) peekContainerHeader(t Type) (uint32, error) {
+	p, err := m.R.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	lead := p[0]
+	switch {
+	case t == MapType && isfixmap(lead):
+		return uint32(rfixmap(lead)), nil
+	case t == ArrayType && isfixarray(lead):
+		return uint32(rfixarray(lead)), nil
+	case t == MapType && lead == mmap16, t == ArrayType && lead == marray16:
+		if p, err = m.peek(3); err != nil {
+			return 0, err
+		}
+		return uint32(big.Uint16(p[1:])), nil
+	case t == MapType && lead == mmap32, t == ArrayType && lead == marray32:
+		if p, err = m.peek(5); err != nil {
+			return 0, err
+		}
+		return big.Uint32(p[1:]), nil
+	default:
+		return 0, badPrefix(t, lead)
+	}
+}
+
 // getNextSize returns the size of the next object on the wire.
 // returns (obj size, obj elements, error)
 // only maps and arrays have non-zero obj elements
@@ -251,8 +515,8 @@ func (m *Reader) IsNil() bool {
 //
 // use uintptr b/c it's guaranteed to be large enough
 // to hold whatever we can fit in memory.
-func getNextSize(r *fwd.Reader) (uintptr, uintptr, error) {
-	b, err := r.Peek(1)
+func getNextSize(m *Reader) (uintptr, uintptr, error) {
+	b, err := m.R.Peek(1)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -265,7 +529,7 @@ func getNextSize(r *fwd.Reader) (uintptr, uintptr, error) {
 	if mode >= 0 {
 		return uintptr(size), uintptr(mode), nil
 	}
-	b, err = r.Peek(int(size))
+	b, err = m.peek(int(size))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -292,19 +556,74 @@ func getNextSize(r *fwd.Reader) (uintptr, uintptr, error) {
 // Skip skips over the next object, regardless of
 // its type. If it is an array or map, the whole array
 // or map will be skipped.
-func (m *Reader) Skip() error {
+func (m *Reader) Skip() error { return m.skip(nil) }
+
+// SkipMax is like Skip, but it returns a LimitError
+// rather than skipping an object that has more than
+// maxDepth levels of nested maps and arrays or that
+// takes up more than maxBytes bytes. (Zero means no
+// limit.) Since the limit is checked before the data
+// is read, it bounds the amount of work that can be
+// caused by a hostile header. The limits set on the
+// Reader also apply.
+func (m *Reader) SkipMax(maxDepth int, maxBytes int64) error {
+	return m.skip(&skipLimit{maxDepth: maxDepth, maxBytes: maxBytes})
+}
+
+// SkipStats describes an object
+// skipped by Reader.SkipInfo
+type SkipStats struct {
+	// Size is the encoded size
+	// of the object in bytes.
+	Size int64
+
+	// Elements is the number of objects
+	// skipped: the object itself, plus every
+	// element of the arrays and every key and
+	// value of the maps nested within it.
+	Elements int64
+
+	// Depth is the deepest nesting of maps
+	// and arrays, which is zero for an object
+	// that is not a map or array.
+	Depth int
+}
+
+// SkipInfo is like Skip, but it also
+// returns the size, the number of elements
+// and the depth of the object it skipped.
+// If an error is returned, the statistics
+// cover the part of the object that was
+// skipped before the error.
+func (m *Reader) SkipInfo() (SkipStats, error) {
+	var lim skipLimit
+	err := m.skip(&lim)
+	return SkipStats{Size: lim.bytes, Elements: lim.objects, Depth: lim.deepest}, err
+}
+
+// skipLimit tracks the progress
+// of SkipMax and SkipInfo
+type skipLimit struct {
+	depth, maxDepth int
+	bytes, maxBytes int64
+	objects         int64
+	deepest         int
+}
+
+func (m *Reader) skip(lim *skipLimit) error {
 	var (
-		v   uintptr // bytes
-		o   uintptr // objects
-		err error
-		p   []byte
+		v    uintptr // bytes
+		o    uintptr // objects
+		err  error
+		p    []byte
+		lead byte
 	)
 
 	// we can use the faster
 	// method if we have enough
 	// buffered data
 	if m.R.Buffered() >= 5 {
-		p, err = m.R.Peek(5)
+		p, err = m.peek(5)
 		if err != nil {
 			return err
 		}
@@ -312,11 +631,38 @@ func (m *Reader) Skip() error {
 		if err != nil {
 			return err
 		}
+		lead = p[0]
 	} else {
-		v, o, err = getNextSize(m.R)
+		v, o, err = getNextSize(m)
 		if err != nil {
 			return err
 		}
+		if o > 0 {
+			p, _ = m.R.Peek(1)
+			lead = p[0]
+		}
+	}
+	if o > 0 {
+		if err = m.checkObjects(lead, o); err != nil {
+			return err
+		}
+	}
+	if lim != nil {
+		lim.bytes += int64(v)
+		lim.objects++
+		if lim.maxBytes > 0 && lim.bytes > lim.maxBytes {
+			return LimitError{Limit: "skipped bytes", Size: uint64(lim.bytes), Max: uint64(lim.maxBytes)}
+		}
+		if o > 0 {
+			if lim.maxDepth > 0 && lim.depth >= lim.maxDepth {
+				return LimitError{Limit: "depth", Size: uint64(lim.depth + 1), Max: uint64(lim.maxDepth)}
+			}
+			lim.depth++
+			if lim.depth > lim.deepest {
+				lim.deepest = lim.depth
+			}
+			defer func() { lim.depth-- }()
+		}
 	}
 
 	// 'v' is always non-zero
@@ -327,8 +673,14 @@ func (m *Reader) Skip() error {
 	}
 
 	// for maps and slices, skip elements
+	if o > 0 {
+		if err = m.enter(); err != nil {
+			return err
+		}
+		defer m.leave()
+	}
 	for x := uintptr(0); x < o; x++ {
-		err = m.Skip()
+		err = m.skip(lim)
 		if err != nil {
 			return err
 		}
@@ -342,6 +694,9 @@ func (m *Reader) Skip() error {
 // It will return a TypeError{} if the next
 // object is not a map.
 func (m *Reader) ReadMapHeader() (sz uint32, err error) {
+	if m.readNilEmpty() {
+		return
+	}
 	var p []byte
 	var lead byte
 	p, err = m.R.Peek(1)
@@ -352,27 +707,30 @@ func (m *Reader) ReadMapHeader() (sz uint32, err error) {
 	if isfixmap(lead) {
 		sz = uint32(rfixmap(lead))
 		_, err = m.R.Skip(1)
+		if err == nil {
+			err = m.checkElements(sz)
+		}
 		return
 	}
 	switch lead {
 	case mmap16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return
 		}
 		sz = uint32(big.Uint16(p[1:]))
-		return
 	case mmap32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return
 		}
 		sz = big.Uint32(p[1:])
-		return
 	default:
 		err = badPrefix(MapType, lead)
 		return
 	}
+	err = m.checkElements(sz)
+	return
 }
 
 // ReadMapKey reads either a 'str' or 'bin' field from
@@ -389,6 +747,139 @@ func (m *Reader) ReadMapKey(scratch []byte) ([]byte, error) {
 	return out, nil
 }
 
+// ReadMapKeyInto reads a 'str' or 'bin' map key
+// into buf, replacing its contents, and returns
+// the result. buf is grown if it is too small, so
+// passing the returned slice back in on the next
+// call means keys are read without allocating once
+// buf is large enough. The result can be compared
+// against the expected keys with MatchKey.
+func (m *Reader) ReadMapKeyInto(buf []byte) ([]byte, error) {
+	p, err := m.R.Peek(1)
+	if err != nil {
+		return buf[:0], err
+	}
+	lead := p[0]
+	var sz uint32
+	str := true
+	switch {
+	case isfixstr(lead), lead == mstr8, lead == mstr16, lead == mstr32:
+		sz, err = m.ReadStringHeader()
+	case lead == mbin8, lead == mbin16, lead == mbin32:
+		str = false
+		sz, err = m.ReadBytesHeader()
+	default:
+		return buf[:0], badPrefix(StrType, lead)
+	}
+	if err != nil {
+		return buf[:0], err
+	}
+	if uint32(cap(buf)) < sz {
+		buf = make([]byte, sz)
+	} else {
+		buf = buf[:sz]
+	}
+	if _, err = m.R.ReadFull(buf); err != nil {
+		return buf[:0], err
+	}
+	if str {
+		err = m.checkUTF8(buf)
+	}
+	return buf, err
+}
+
+// MatchKey returns the index of the first of
+// the candidates that is equal to b, or -1 if
+// none is. It doesn't allocate, so it can be used
+// to dispatch on map keys read as []byte:
+//
+//	key, err = r.ReadMapKeyInto(key)
+//	...
+//	switch msgp.MatchKey(key, "id", "name") {
+//	case 0:
+//		...
+//	}
+func MatchKey(b []byte, candidates ...string) int {
+	for i := range candidates {
+		if string(b) == candidates[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// ForEachArray reads an array, calling fn for
+// each of its elements with the Reader positioned
+// at the element, which fn should read. If fn
+// returns without reading anything, the element
+// is skipped. Errors from fn are returned with
+// the index of the element (see WrapError).
+func (m *Reader) ForEachArray(fn func(r *Reader) error) error {
+	sz, err := m.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	if err = m.enter(); err != nil {
+		return err
+	}
+	defer m.leave()
+	for i := uint32(0); i < sz; i++ {
+		off := m.Offset()
+		err = fn(m)
+		if err == nil && m.Offset() == off {
+			err = m.Skip()
+		}
+		if err != nil {
+			return WrapError(err, i)
+		}
+	}
+	return nil
+}
+
+// ForEachMap reads a map whose keys are 'str' or
+// 'bin', calling fn for each of its entries with
+// the key and with the Reader positioned at the
+// value, which fn should read. If fn returns without
+// reading anything, the value is skipped. key is only
+// valid until fn returns; the same memory is reused
+// for each key. Errors from fn are returned with
+// the key (see WrapError).
+//
+//	err := r.ForEachMap(func(key []byte, r *msgp.Reader) (err error) {
+//		switch msgp.MatchKey(key, "id", "name") {
+//		case 0:
+//			t.ID, err = r.ReadInt64()
+//		case 1:
+//			t.Name, err = r.ReadString()
+//		}
+//		return
+//	})
+func (m *Reader) ForEachMap(fn func(key []byte, r *Reader) error) error {
+	sz, err := m.ReadMapHeader()
+	if err != nil {
+		return err
+	}
+	if err = m.enter(); err != nil {
+		return err
+	}
+	defer m.leave()
+	var key []byte
+	for i := uint32(0); i < sz; i++ {
+		if key, err = m.ReadMapKeyInto(key); err != nil {
+			return err
+		}
+		off := m.Offset()
+		err = fn(key, m)
+		if err == nil && m.Offset() == off {
+			err = m.Skip()
+		}
+		if err != nil {
+			return WrapError(err, string(key))
+		}
+	}
+	return nil
+}
+
 // MapKeyPtr returns a []byte pointing to the contents
 // of a valid map key. The key cannot be empty, and it
 // must be shorter than the total buffer size of the
@@ -411,19 +902,19 @@ func (m *Reader) ReadMapKeyPtr() ([]byte, error) {
 	}
 	switch lead {
 	case mstr8, mbin8:
-		p, err = m.R.Next(2)
+		p, err = m.next(2)
 		if err != nil {
 			return nil, err
 		}
 		read = int(p[1])
 	case mstr16, mbin16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return nil, err
 		}
 		read = int(big.Uint16(p[1:]))
 	case mstr32, mbin32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return nil, err
 		}
@@ -435,13 +926,58 @@ fill:
 	if read == 0 {
 		return nil, ErrShortBytes
 	}
-	return m.R.Next(read)
+	if err = m.checkStr(uint32(read)); err != nil {
+		return nil, err
+	}
+	return m.next(read)
+}
+
+// maxPrealloc is the most elements that are
+// allocated up front for a map or array read from
+// a stream, since the size in its header can't be
+// checked against the amount of data remaining
+const maxPrealloc = 1024
+
+// capHint returns the capacity to allocate
+// for a map or array of sz elements
+func capHint(sz uint32) int {
+	if sz > maxPrealloc {
+		return maxPrealloc
+	}
+	return int(sz)
+}
+
+// ReadArrayHeaderMax is like ReadArrayHeader, but it
+// returns a LimitError if the array has more than max
+// elements, so that the caller can safely allocate
+// space for all of them. The header is consumed
+// either way.
+func (m *Reader) ReadArrayHeaderMax(max uint32) (sz uint32, err error) {
+	sz, err = m.ReadArrayHeader()
+	if err == nil && sz > max {
+		err = LimitError{Limit: "elements", Size: uint64(sz), Max: uint64(max)}
+	}
+	return
+}
+
+// ReadMapHeaderMax is like ReadMapHeader, but it
+// returns a LimitError if the map has more than max
+// entries. The header is consumed either way.
+func (m *Reader) ReadMapHeaderMax(max uint32) (sz uint32, err error) {
+	sz, err = m.ReadMapHeader()
+	if err == nil && sz > max {
+		err = LimitError{Limit: "elements", Size: uint64(sz), Max: uint64(max)}
+	}
+	return
 }
 
 // ReadArrayHeader reads the next object as an
 // array header and returns the size of the array
 // and the number of bytes read.
 func (m *Reader) ReadArrayHeader() (sz uint32, err error) {
+	if m.readNilEmpty() {
+		return
+	}
 	var lead byte
 	var p []byte
 	p, err = m.R.Peek(1)
@@ -452,29 +988,32 @@ func (m *Reader) ReadArrayHeader() (sz uint32, err error) {
 	if isfixarray(lead) {
 		sz = uint32(rfixarray(lead))
 		_, err = m.R.Skip(1)
+		if err == nil {
+			err = m.checkElements(sz)
+		}
 		return
 	}
 	switch lead {
 	case marray16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return
 		}
 		sz = uint32(big.Uint16(p[1:]))
-		return
 
 	case marray32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return
 		}
 		sz = big.Uint32(p[1:])
-		return
 
 	default:
 		err = badPrefix(ArrayType, lead)
 		return
 	}
+	err = m.checkElements(sz)
+	return
 }
 
 // ReadNil reads a 'nil' MessagePack byte from the reader
@@ -495,23 +1034,23 @@ func (m *Reader) ReadNil() error {
 // it will be up-cast to a float64.)
 func (m *Reader) ReadFloat64() (f float64, err error) {
 	var p []byte
-	p, err = m.R.Peek(9)
-	if err != nil {
+	p, err = m.peek(9)
+	if len(p) > 0 && p[0] != mfloat64 {
 		// we'll allow a coversion from float32 to float64,
 		// since we don't lose any precision
-		if err == io.EOF && len(p) > 0 && p[0] == mfloat32 {
+		if p[0] == mfloat32 {
 			ef, err := m.ReadFloat32()
 			return float64(ef), err
 		}
+		if m.coerce != (Coercions{}) {
+			return m.coerceFloat(p[0], Float64Type)
+		}
+		if err == nil {
+			err = badPrefix(Float64Type, p[0])
+		}
 		return
 	}
-	if p[0] != mfloat64 {
-		// see above
-		if p[0] == mfloat32 {
-			ef, err := m.ReadFloat32()
-			return float64(ef), err
-		}
-		err = badPrefix(Float64Type, p[0])
+	if err != nil {
 		return
 	}
 	f = math.Float64frombits(getMuint64(p))
@@ -522,7 +1061,12 @@ func (m *Reader) ReadFloat64() (f float64, err error) {
 // ReadFloat32 reads a float32 from the reader
 func (m *Reader) ReadFloat32() (f float32, err error) {
 	var p []byte
-	p, err = m.R.Peek(5)
+	p, err = m.peek(5)
+	if len(p) > 0 && p[0] != mfloat32 && m.coerce != (Coercions{}) {
+		var f64 float64
+		f64, err = m.coerceFloat(p[0], Float32Type)
+		return float32(f64), err
+	}
 	if err != nil {
 		return
 	}
@@ -547,6 +1091,9 @@ func (m *Reader) ReadBool() (b bool, err error) {
 		b = true
 	case mfalse:
 	default:
+		if m.coerce != (Coercions{}) {
+			return m.coerceBool(p[0])
+		}
 		err = badPrefix(BoolType, p[0])
 		return
 	}
@@ -554,7 +1101,11 @@ func (m *Reader) ReadBool() (b bool, err error) {
 	return
 }
 
-// ReadInt64 reads an int64 from the reader
+// ReadInt64 reads an int64 from the reader.
+// Unsigned integers are accepted too, as long
+// as they fit, so there is no need to check
+// which family the encoder chose; one that is
+// too large causes a UintOverflow.
 func (m *Reader) ReadInt64() (i int64, err error) {
 	var p []byte
 	var lead byte
@@ -573,10 +1124,14 @@ func (m *Reader) ReadInt64() (i int64, err error) {
 		_, err = m.R.Skip(1)
 		return
 	}
+	if m.coerce.StrictSign && getType(lead) == UintType {
+		err = badPrefix(IntType, lead)
+		return
+	}
 
 	switch lead {
 	case mint8:
-		p, err = m.R.Next(2)
+		p, err = m.next(2)
 		if err != nil {
 			return
 		}
@@ -584,7 +1139,7 @@ func (m *Reader) ReadInt64() (i int64, err error) {
 		return
 
 	case muint8:
-		p, err = m.R.Next(2)
+		p, err = m.next(2)
 		if err != nil {
 			return
 		}
@@ -592,7 +1147,7 @@ func (m *Reader) ReadInt64() (i int64, err error) {
 		return
 
 	case mint16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return
 		}
@@ -600,7 +1155,7 @@ func (m *Reader) ReadInt64() (i int64, err error) {
 		return
 
 	case muint16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return
 		}
@@ -608,7 +1163,7 @@ func (m *Reader) ReadInt64() (i int64, err error) {
 		return
 
 	case mint32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return
 		}
@@ -616,7 +1171,7 @@ func (m *Reader) ReadInt64() (i int64, err error) {
 		return
 
 	case muint32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return
 		}
@@ -624,7 +1179,7 @@ func (m *Reader) ReadInt64() (i int64, err error) {
 		return
 
 	case mint64:
-		p, err = m.R.Next(9)
+		p, err = m.next(9)
 		if err != nil {
 			return
 		}
@@ -632,19 +1187,22 @@ func (m *Reader) ReadInt64() (i int64, err error) {
 		return
 
 	case muint64:
-		p, err = m.R.Next(9)
+		p, err = m.next(9)
 		if err != nil {
 			return
 		}
 		u := getMuint64(p)
 		if u > math.MaxInt64 {
-			err = UintOverflow{Value: u, FailedBitsize: 64}
+			err = UintOverflow{Value: u, FailedBitsize: 64, Signed: true}
 			return
 		}
 		i = int64(u)
 		return
 
 	default:
+		if m.coerce != (Coercions{}) {
+			return m.coerceInt(lead)
+		}
 		err = badPrefix(IntType, lead)
 		return
 	}
@@ -700,7 +1258,10 @@ func (m *Reader) ReadInt() (i int, err error) {
 	return
 }
 
-// ReadUint64 reads a uint64 from the reader
+// ReadUint64 reads a uint64 from the reader.
+// Signed integers are accepted too, as long as
+// they aren't negative; a negative one causes
+// a UintBelowZero.
 func (m *Reader) ReadUint64() (u uint64, err error) {
 	var p []byte
 	var lead byte
@@ -714,9 +1275,13 @@ func (m *Reader) ReadUint64() (u uint64, err error) {
 		_, err = m.R.Skip(1)
 		return
 	}
+	if m.coerce.StrictSign && getType(lead) == IntType {
+		err = badPrefix(UintType, lead)
+		return
+	}
 	switch lead {
 	case mint8:
-		p, err = m.R.Next(2)
+		p, err = m.next(2)
 		if err != nil {
 			return
 		}
@@ -729,7 +1294,7 @@ func (m *Reader) ReadUint64() (u uint64, err error) {
 		return
 
 	case muint8:
-		p, err = m.R.Next(2)
+		p, err = m.next(2)
 		if err != nil {
 			return
 		}
@@ -737,7 +1302,7 @@ func (m *Reader) ReadUint64() (u uint64, err error) {
 		return
 
 	case mint16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return
 		}
@@ -750,7 +1315,7 @@ func (m *Reader) ReadUint64() (u uint64, err error) {
 		return
 
 	case muint16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return
 		}
@@ -758,7 +1323,7 @@ func (m *Reader) ReadUint64() (u uint64, err error) {
 		return
 
 	case mint32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return
 		}
@@ -771,7 +1336,7 @@ func (m *Reader) ReadUint64() (u uint64, err error) {
 		return
 
 	case muint32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return
 		}
@@ -779,7 +1344,7 @@ func (m *Reader) ReadUint64() (u uint64, err error) {
 		return
 
 	case mint64:
-		p, err = m.R.Next(9)
+		p, err = m.next(9)
 		if err != nil {
 			return
 		}
@@ -792,7 +1357,7 @@ func (m *Reader) ReadUint64() (u uint64, err error) {
 		return
 
 	case muint64:
-		p, err = m.R.Next(9)
+		p, err = m.next(9)
 		if err != nil {
 			return
 		}
@@ -800,6 +1365,9 @@ func (m *Reader) ReadUint64() (u uint64, err error) {
 		return
 
 	default:
+		if m.coerce != (Coercions{}) {
+			return m.coerceUint(lead)
+		}
 		if isnfixint(lead) {
 			err = UintBelowZero{Value: int64(rnfixint(lead))}
 		} else {
@@ -879,9 +1447,12 @@ func (m *Reader) ReadByte() (b byte, err error) {
 // from the reader and returns its value. It may
 // use 'scratch' for storage if it is non-nil.
 func (m *Reader) ReadBytes(scratch []byte) (b []byte, err error) {
+	if m.readNilEmpty() {
+		return
+	}
 	var p []byte
 	var lead byte
-	p, err = m.R.Peek(2)
+	p, err = m.R.Peek(1)
 	if err != nil {
 		return
 	}
@@ -889,24 +1460,33 @@ func (m *Reader) ReadBytes(scratch []byte) (b []byte, err error) {
 	var read int64
 	switch lead {
 	case mbin8:
+		p, err = m.next(2)
+		if err != nil {
+			return
+		}
 		read = int64(p[1])
-		m.R.Skip(2)
 	case mbin16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return
 		}
 		read = int64(big.Uint16(p[1:]))
 	case mbin32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return
 		}
 		read = int64(big.Uint32(p[1:]))
 	default:
+		if (m.oldSpec || m.strBin) && isstr(lead) {
+			return m.readStringAsBytes(scratch)
+		}
 		err = badPrefix(BinType, lead)
 		return
 	}
+	if err = m.checkBin(uint32(read)); err != nil {
+		return
+	}
 	if int64(cap(scratch)) < read {
 		b = make([]byte, read)
 	} else {
@@ -922,6 +1502,9 @@ func (m *Reader) ReadBytes(scratch []byte) (b []byte, err error) {
 // 'sz' bytes from the reader in an application-specific
 // way.
 func (m *Reader) ReadBytesHeader() (sz uint32, err error) {
+	if m.readNilEmpty() {
+		return
+	}
 	var p []byte
 	p, err = m.R.Peek(1)
 	if err != nil {
@@ -929,30 +1512,32 @@ func (m *Reader) ReadBytesHeader() (sz uint32, err error) {
 	}
 	switch p[0] {
 	case mbin8:
-		p, err = m.R.Next(2)
+		p, err = m.next(2)
 		if err != nil {
 			return
 		}
 		sz = uint32(p[1])
-		return
 	case mbin16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return
 		}
 		sz = uint32(big.Uint16(p[1:]))
-		return
 	case mbin32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return
 		}
 		sz = uint32(big.Uint32(p[1:]))
-		return
 	default:
+		if (m.oldSpec || m.strBin) && isstr(p[0]) {
+			return m.ReadStringHeader()
+		}
 		err = badPrefix(BinType, p[0])
 		return
 	}
+	err = m.checkBin(sz)
+	return
 }
 
 // ReadExactBytes reads a MessagePack 'bin'-encoded
@@ -960,46 +1545,164 @@ func (m *Reader) ReadBytesHeader() (sz uint32, err error) {
 // ArrayError will be returned if the object is not
 // exactly the length of the input slice.
 func (m *Reader) ReadExactBytes(into []byte) error {
-	p, err := m.R.Peek(2)
+	sz, hdr, err := m.peekBinHeader()
 	if err != nil {
 		return err
 	}
+	if int64(sz) != int64(len(into)) {
+		return ArrayError{Wanted: uint32(len(into)), Got: sz}
+	}
+	m.R.Skip(hdr)
+	_, err = m.R.ReadFull(into)
+	return err
+}
+
+// peekBinHeader returns the size of the next
+// 'bin' object (or 'str' object, if the Reader
+// accepts the old spec) and of its header,
+// without consuming them
+func (m *Reader) peekBinHeader() (sz uint32, hdr int, err error) {
+	var p []byte
+	p, err = m.R.Peek(1)
+	if err != nil {
+		return
+	}
 	lead := p[0]
-	var read int64 // bytes to read
-	var skip int   // prefix size to skip
 	switch lead {
 	case mbin8:
-		read = int64(p[1])
-		skip = 2
+		hdr = 2
 	case mbin16:
-		p, err = m.R.Peek(3)
-		if err != nil {
-			return err
-		}
-		read = int64(big.Uint16(p[1:]))
-		skip = 3
+		hdr = 3
 	case mbin32:
-		p, err = m.R.Peek(5)
-		if err != nil {
-			return err
+		hdr = 5
+	default:
+		if (m.oldSpec || m.strBin) && isstr(lead) {
+			return m.peekStringHeader()
 		}
-		read = int64(big.Uint32(p[1:]))
-		skip = 5
+		err = badPrefix(BinType, lead)
+		return
+	}
+	p, err = m.peek(hdr)
+	if err != nil {
+		return
+	}
+	switch hdr {
+	case 2:
+		sz = uint32(p[1])
+	case 3:
+		sz = uint32(big.Uint16(p[1:]))
 	default:
-		return badPrefix(BinType, lead)
+		sz = big.Uint32(p[1:])
 	}
-	if read != int64(len(into)) {
-		return ArrayError{Wanted: uint32(len(into)), Got: uint32(read)}
+	return
+}
+
+// ReadBytesInto reads a MessagePack 'bin' object
+// into the beginning of buf and returns its length.
+// If buf is too small, the object is left unread
+// and a BufferSizeError with the size of the object
+// is returned, so the read can be retried with a
+// large enough buffer.
+func (m *Reader) ReadBytesInto(buf []byte) (n int, err error) {
+	if m.readNilEmpty() {
+		return
 	}
-	m.R.Skip(skip)
-	_, err = m.R.ReadFull(into)
-	return err
+	sz, hdr, err := m.peekBinHeader()
+	if err != nil {
+		return
+	}
+	if err = m.checkBin(sz); err != nil {
+		return
+	}
+	if int64(sz) > int64(len(buf)) {
+		return 0, BufferSizeError{Need: int(sz), Have: len(buf)}
+	}
+	m.R.Skip(hdr)
+	return m.R.ReadFull(buf[:sz])
+}
+
+// ReadStringInto is like ReadBytesInto, but it
+// reads a MessagePack 'str' object.
+func (m *Reader) ReadStringInto(buf []byte) (n int, err error) {
+	if m.readNilEmpty() {
+		return
+	}
+	sz, hdr, err := m.peekStringHeader()
+	if err != nil {
+		return
+	}
+	if err = m.checkStr(sz); err != nil {
+		return
+	}
+	if int64(sz) > int64(len(buf)) {
+		return 0, BufferSizeError{Need: int(sz), Have: len(buf)}
+	}
+	m.R.Skip(hdr)
+	n, err = m.R.ReadFull(buf[:sz])
+	if err == nil {
+		err = m.checkUTF8(buf[:n])
+	}
+	return
+}
+
+// ReadBytesTo copies the contents of the next
+// object, which must be a 'bin' or a 'str', to w
+// and returns the number of bytes written. The
+// contents are passed to w a buffer at a time, so
+// an object of any size can be copied to a file
+// or a connection without being held in memory.
+// The contents of a 'str' are not checked for
+// valid UTF-8, even if the Reader requires it.
+func (m *Reader) ReadBytesTo(w io.Writer) (n int64, err error) {
+	p, err := m.R.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	var sz uint32
+	if isstr(p[0]) {
+		sz, err = m.ReadStringHeader()
+	} else {
+		sz, err = m.ReadBytesHeader()
+	}
+	if err != nil {
+		return 0, err
+	}
+	for rem := int(sz); rem > 0; {
+		chunk := rem
+		if bs := m.R.BufferSize(); chunk > bs {
+			chunk = bs
+		}
+		if p, err = m.next(chunk); err != nil {
+			return n, noEOF(err)
+		}
+		nn, err := w.Write(p)
+		n += int64(nn)
+		if err == nil && nn < chunk {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return n, err
+		}
+		rem -= chunk
+	}
+	return n, nil
 }
 
 // ReadStringAsBytes reads a MessagePack 'str' (utf-8) string
 // and returns its value as bytes. It may use 'scratch' for storage
 // if it is non-nil.
 func (m *Reader) ReadStringAsBytes(scratch []byte) (b []byte, err error) {
+	if m.readNilEmpty() {
+		return
+	}
+	b, err = m.readStringAsBytes(scratch)
+	if err == nil {
+		err = m.checkUTF8(b)
+	}
+	return
+}
+
+func (m *Reader) readStringAsBytes(scratch []byte) (b []byte, err error) {
 	var p []byte
 	var lead byte
 	p, err = m.R.Peek(1)
@@ -1017,28 +1720,34 @@ func (m *Reader) ReadStringAsBytes(scratch []byte) (b []byte, err error) {
 
 	switch lead {
 	case mstr8:
-		p, err = m.R.Next(2)
+		p, err = m.next(2)
 		if err != nil {
 			return
 		}
 		read = int64(uint8(p[1]))
 	case mstr16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return
 		}
 		read = int64(big.Uint16(p[1:]))
 	case mstr32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return
 		}
 		read = int64(big.Uint32(p[1:]))
 	default:
+		if m.strBin && isbin(lead) {
+			return m.ReadBytes(scratch)
+		}
 		err = badPrefix(StrType, lead)
 		return
 	}
 fill:
+	if err = m.checkStr(uint32(read)); err != nil {
+		return
+	}
 	if int64(cap(scratch)) < read {
 		b = make([]byte, read)
 	} else {
@@ -1053,6 +1762,9 @@ fill:
 // for dealing with the next 'sz' bytes from
 // the reader in an application-specific manner.
 func (m *Reader) ReadStringHeader() (sz uint32, err error) {
+	if m.readNilEmpty() {
+		return
+	}
 	var p []byte
 	p, err = m.R.Peek(1)
 	if err != nil {
@@ -1062,38 +1774,86 @@ func (m *Reader) ReadStringHeader() (sz uint32, err error) {
 	if isfixstr(lead) {
 		sz = uint32(rfixstr(lead))
 		m.R.Skip(1)
+		err = m.checkStr(sz)
 		return
 	}
 	switch lead {
 	case mstr8:
-		p, err = m.R.Next(2)
+		p, err = m.next(2)
 		if err != nil {
 			return
 		}
 		sz = uint32(p[1])
-		return
 	case mstr16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return
 		}
 		sz = uint32(big.Uint16(p[1:]))
-		return
 	case mstr32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return
 		}
 		sz = big.Uint32(p[1:])
+	default:
+		if m.strBin && isbin(lead) {
+			return m.ReadBytesHeader()
+		}
+		err = badPrefix(StrType, lead)
+		return
+	}
+	err = m.checkStr(sz)
+	return
+}
+
+// peekStringHeader returns the size of the
+// next 'str' object and the size of its header
+// without consuming it
+func (m *Reader) peekStringHeader() (sz uint32, hdr int, err error) {
+	var p []byte
+	p, err = m.R.Peek(1)
+	if err != nil {
 		return
+	}
+	lead := p[0]
+	if isfixstr(lead) {
+		return uint32(rfixstr(lead)), 1, nil
+	}
+	switch lead {
+	case mstr8:
+		hdr = 2
+	case mstr16:
+		hdr = 3
+	case mstr32:
+		hdr = 5
 	default:
+		if m.strBin && isbin(lead) {
+			return m.peekBinHeader()
+		}
 		err = badPrefix(StrType, lead)
 		return
 	}
+	p, err = m.peek(hdr)
+	if err != nil {
+		return
+	}
+	switch hdr {
+	case 2:
+		sz = uint32(p[1])
+	case 3:
+		sz = uint32(big.Uint16(p[1:]))
+	default:
+		sz = big.Uint32(p[1:])
+	}
+	return
 }
 
 // ReadString reads a utf-8 string from the reader
 func (m *Reader) ReadString() (s string, err error) {
+	if m.readNilEmpty() {
+		return
+	}
 	var p []byte
 	var lead byte
 	var read int64
@@ -1111,24 +1871,35 @@ func (m *Reader) ReadString() (s string, err error) {
 
 	switch lead {
 	case mstr8:
-		p, err = m.R.Next(2)
+		p, err = m.next(2)
 		if err != nil {
 			return
 		}
 		read = int64(uint8(p[1]))
 	case mstr16:
-		p, err = m.R.Next(3)
+		p, err = m.next(3)
 		if err != nil {
 			return
 		}
 		read = int64(big.Uint16(p[1:]))
 	case mstr32:
-		p, err = m.R.Next(5)
+		p, err = m.next(5)
 		if err != nil {
 			return
 		}
 		read = int64(big.Uint32(p[1:]))
 	default:
+		if m.strBin && isbin(lead) {
+			var b []byte
+			if b, err = m.ReadBytes(nil); err != nil {
+				return
+			}
+			if err = m.checkUTF8(b); err != nil {
+				return
+			}
+			s = UnsafeString(b)
+			return
+		}
 		err = badPrefix(StrType, lead)
 		return
 	}
@@ -1137,6 +1908,9 @@ fill:
 		s, err = "", nil
 		return
 	}
+	if err = m.checkStr(uint32(read)); err != nil {
+		return
+	}
 	// reading into the memory
 	// that will become the string
 	// itself has vastly superior
@@ -1159,14 +1933,52 @@ fill:
 	if err != nil {
 		return
 	}
+	if err = m.checkUTF8(out); err != nil {
+		return
+	}
 	s = UnsafeString(out)
 	return
 }
 
+// ReadStringAsBytesZC reads a MessagePack 'str' object
+// and returns its contents as a slice that points into
+// the Reader's buffer, avoiding an allocation and a copy.
+// The returned slice is only valid until the next method
+// call on the Reader, and it must not be modified. Strings
+// that are larger than the buffer are copied into a new
+// allocation.
+func (m *Reader) ReadStringAsBytesZC() (b []byte, err error) {
+	var sz uint32
+	sz, err = m.ReadStringHeader()
+	if err != nil {
+		return
+	}
+	if int(sz) > m.R.BufferSize() {
+		b = make([]byte, sz)
+		_, err = m.R.ReadFull(b)
+	} else {
+		b, err = m.next(int(sz))
+	}
+	if err != nil {
+		return
+	}
+	err = m.checkUTF8(b)
+	return
+}
+
+// ReadStringZC is like ReadStringAsBytesZC, but it
+// returns a string. The string is only valid until the
+// next method call on the Reader; callers that need to
+// retain it must copy it.
+func (m *Reader) ReadStringZC() (string, error) {
+	b, err := m.ReadStringAsBytesZC()
+	return UnsafeString(b), err
+}
+
 // ReadComplex64 reads a complex64 from the reader
 func (m *Reader) ReadComplex64() (f complex64, err error) {
 	var p []byte
-	p, err = m.R.Peek(10)
+	p, err = m.peek(10)
 	if err != nil {
 		return
 	}
@@ -1187,7 +1999,7 @@ func (m *Reader) ReadComplex64() (f complex64, err error) {
 // ReadComplex128 reads a complex128 from the reader
 func (m *Reader) ReadComplex128() (f complex128, err error) {
 	var p []byte
-	p, err = m.R.Peek(18)
+	p, err = m.peek(18)
 	if err != nil {
 		return
 	}
@@ -1213,6 +2025,10 @@ func (m *Reader) ReadMapStrIntf(mp map[string]interface{}) (err error) {
 	if err != nil {
 		return
 	}
+	if err = m.enter(); err != nil {
+		return
+	}
+	defer m.leave()
 	for key := range mp {
 		delete(mp, key)
 	}
@@ -1232,15 +2048,203 @@ func (m *Reader) ReadMapStrIntf(mp map[string]interface{}) (err error) {
 	return
 }
 
+// ReadMapStrRaw reads a MessagePack map into a
+// map[string]Raw without decoding the values, so
+// that the caller can decode just the ones it
+// needs, e.g. to route a message on one of its
+// fields. Nil values are read as empty Raws, as
+// with Raw.DecodeMsg. (You must pass a non-nil
+// map into the function.)
+func (m *Reader) ReadMapStrRaw(mp map[string]Raw) (err error) {
+	var sz uint32
+	sz, err = m.ReadMapHeader()
+	if err != nil {
+		return
+	}
+	for key := range mp {
+		delete(mp, key)
+	}
+	for i := uint32(0); i < sz; i++ {
+		var key string
+		var val Raw
+		key, err = m.ReadString()
+		if err != nil {
+			return
+		}
+		err = val.DecodeMsg(m)
+		if err != nil {
+			return
+		}
+		mp[key] = val
+	}
+	return
+}
+
+// ReadIntfInto is like ReadMapStrIntf, but it reuses
+// the storage in dst where it can, which saves
+// allocations when similar messages are read into
+// the same map over and over: entries are replaced
+// in place, and a map[string]interface{} or
+// []interface{} value in dst is reused for a map
+// or array under the same key (see ReadIntfSliceInto).
+// Entries of dst whose keys are not in the map
+// are deleted.
+func (m *Reader) ReadIntfInto(dst map[string]interface{}) (err error) {
+	var sz uint32
+	sz, err = m.ReadMapHeader()
+	if err != nil {
+		return
+	}
+	if err = m.enter(); err != nil {
+		return
+	}
+	defer m.leave()
+
+	// remember the keys that are read,
+	// in case some old ones need deleting
+	track := len(dst) > 0
+	base := len(m.keys)
+	for i := uint32(0); i < sz; i++ {
+		var key string
+		var val interface{}
+		key, err = m.ReadString()
+		if err != nil {
+			break
+		}
+		val, err = m.readIntfReuse(dst[key])
+		if err != nil {
+			break
+		}
+		dst[key] = val
+		if track {
+			m.keys = append(m.keys, key)
+		}
+	}
+	if err == nil && track && len(dst) != len(m.keys)-base {
+		seen := make(map[string]struct{}, len(m.keys)-base)
+		for _, key := range m.keys[base:] {
+			seen[key] = struct{}{}
+		}
+		for key := range dst {
+			if _, ok := seen[key]; !ok {
+				delete(dst, key)
+			}
+		}
+	}
+	for i := range m.keys[base:] {
+		m.keys[base+i] = ""
+	}
+	m.keys = m.keys[:base]
+	return
+}
+
+// ReadIntfSliceInto reads an array like ReadIntf
+// and returns it, reusing the storage of dst where
+// it can: the array is read into dst's backing
+// array if it is large enough, and each map or
+// array element reuses a map[string]interface{}
+// or []interface{} at the same index of dst (see
+// ReadIntfInto).
+func (m *Reader) ReadIntfSliceInto(dst []interface{}) (out []interface{}, err error) {
+	var sz uint32
+	sz, err = m.ReadArrayHeader()
+	if err != nil {
+		return dst, err
+	}
+	if err = m.enter(); err != nil {
+		return dst, err
+	}
+	defer m.leave()
+	out = dst[:0]
+	if cap(out) < int(sz) {
+		out = make([]interface{}, 0, capHint(sz))
+	}
+	for j := uint32(0); j < sz; j++ {
+		var old, v interface{}
+		if int(j) < len(dst) {
+			old = dst[j]
+		}
+		v, err = m.readIntfReuse(old)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, v)
+	}
+	// drop references to old elements
+	for j := len(out); j < len(dst); j++ {
+		dst[j] = nil
+	}
+	return out, nil
+}
+
+// readIntfReuse reads the next object like ReadIntf,
+// reusing old if it is a container of the same kind
+func (m *Reader) readIntfReuse(old interface{}) (interface{}, error) {
+	if old == nil {
+		return m.ReadIntf()
+	}
+	t, err := m.NextType()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case MapType:
+		if mp, ok := old.(map[string]interface{}); ok && !m.intf.IntfMapKeys {
+			return mp, m.ReadIntfInto(mp)
+		}
+	case ArrayType:
+		if s, ok := old.([]interface{}); ok {
+			return m.ReadIntfSliceInto(s)
+		}
+	}
+	return m.ReadIntf()
+}
+
 // ReadTime reads a time.Time object from the reader.
-// The returned time's location will be set to time.Local.
+// MessagePack timestamps (extension type -1), as
+// written by WriteTimestamp and other implementations,
+// are accepted as well, and so are the representations
+// of the Reader's TimeFormat (see SetTimeFormat).
+// The returned time's location will be set to time.Local,
+// except for times read from RFC 3339 strings, which keep
+// their offset, and times written with TimeFormatZoned,
+// which keep their location if the Reader's TimeFormat
+// is TimeFormatZoned as well.
 func (m *Reader) ReadTime() (t time.Time, err error) {
 	var p []byte
-	p, err = m.R.Peek(15)
+	p, err = m.R.Peek(1)
 	if err != nil {
 		return
 	}
-	if p[0] != mext8 || p[1] != 12 {
+	if m.timeFmt != TimeFormatExt {
+		switch getType(p[0]) {
+		case IntType, UintType:
+			if m.timeFmt.isUnix() {
+				var n int64
+				if n, err = m.ReadInt64(); err != nil {
+					return
+				}
+				return m.timeFmt.fromUnix(n), nil
+			}
+		case StrType:
+			var s string
+			if s, err = m.ReadString(); err != nil {
+				return
+			}
+			return RFC3339ToTime(s)
+		}
+	}
+	if p[0] != mext8 {
+		return m.ReadTimestamp()
+	}
+	p, err = m.peek(3)
+	if err != nil {
+		return
+	}
+	if p[1] == 12 && int8(p[2]) == TimestampExtension {
+		return m.ReadTimestamp()
+	}
+	if p[1] != 12 && p[1] < zonedTimeSize {
 		err = badPrefix(TimeType, p[0])
 		return
 	}
@@ -1248,16 +2252,64 @@ func (m *Reader) ReadTime() (t time.Time, err error) {
 		err = errExt(int8(p[2]), TimeExtension)
 		return
 	}
-	sec, nsec := getUnix(p[3:])
-	t = time.Unix(sec, int64(nsec)).Local()
-	_, err = m.R.Skip(15)
+	sz := 3 + int(p[1])
+	p, err = m.peek(sz)
+	if err != nil {
+		return
+	}
+	t = timeFromExt(p[3:], m.timeFmt == TimeFormatZoned)
+	_, err = m.R.Skip(sz)
+	return
+}
+
+// ReadTimeFlexible reads a time in any of the
+// representations in 'accept'; see ReadTimeFlexibleBytes.
+// If the Reader's TimeFormat is TimeFormatZoned, times
+// written by WriteTime in that format keep their location.
+func (m *Reader) ReadTimeFlexible(accept TimeReprs) (t time.Time, err error) {
+	var p []byte
+	p, err = m.R.Peek(1)
+	if err != nil {
+		return
+	}
+	switch sizes[p[0]].typ {
+	case IntType, UintType:
+		if accept&TimeReprUnix != 0 {
+			var n int64
+			if n, err = m.ReadInt64(); err != nil {
+				return
+			}
+			return UnixToTime(n), nil
+		}
+	case StrType:
+		if accept&TimeReprRFC3339 != 0 {
+			var s string
+			if s, err = m.ReadString(); err != nil {
+				return
+			}
+			return RFC3339ToTime(s)
+		}
+	case ExtensionType:
+		var typ int8
+		if typ, err = m.peekExtensionType(); err != nil {
+			return
+		}
+		if !accept.hasExt(typ) {
+			err = errExt(typ, TimeExtension)
+			return
+		}
+		return m.ReadTime()
+	}
+	err = badPrefix(TimeType, p[0])
 	return
 }
 
 // ReadIntf reads out the next object as a raw interface{}.
 // Arrays are decoded as []interface{}, and maps are decoded
 // as map[string]interface{}. Integers are decoded as int64
-// and unsigned integers are decoded as uint64.
+// and unsigned integers are decoded as uint64. Timestamps
+// (extension type -1) are decoded as time.Time. The
+// Reader's IntfPolicy may change some of these choices.
 func (m *Reader) ReadIntf() (i interface{}, err error) {
 	var t Type
 	t, err = m.NextType()
@@ -1269,16 +2321,25 @@ func (m *Reader) ReadIntf() (i interface{}, err error) {
 		i, err = m.ReadBool()
 		return
 
-	case IntType:
-		i, err = m.ReadInt64()
-		return
-
-	case UintType:
-		i, err = m.ReadUint64()
+	case IntType, UintType:
+		if m.intf.Ints != IntDefault {
+			return m.readIntfInt(t)
+		}
+		if t == IntType {
+			i, err = m.ReadInt64()
+		} else {
+			i, err = m.ReadUint64()
+		}
 		return
 
 	case BinType:
-		i, err = m.ReadBytes(nil)
+		var b []byte
+		b, err = m.ReadBytes(nil)
+		if m.intf.BinAsString {
+			i = UnsafeString(b)
+		} else {
+			i = b
+		}
 		return
 
 	case StrType:
@@ -1303,13 +2364,27 @@ func (m *Reader) ReadIntf() (i interface{}, err error) {
 		if err != nil {
 			return
 		}
-		f, ok := extensionReg[t]
+		if t == TimestampExtension {
+			i, err = m.ReadTimestamp()
+			return
+		}
+		f, ok := m.registry().Lookup(t)
 		if ok {
 			e := f()
 			err = m.ReadExtension(e)
 			i = e
 			return
 		}
+		switch m.intf.UnknownExt {
+		case ExtSkip:
+			err = m.Skip()
+			return
+		case ExtError:
+			if err = m.Skip(); err == nil {
+				err = UnknownExtensionError{Type: t}
+			}
+			return
+		}
 		var e RawExtension
 		e.Type = t
 		err = m.ReadExtension(&e)
@@ -1317,6 +2392,9 @@ func (m *Reader) ReadIntf() (i interface{}, err error) {
 		return
 
 	case MapType:
+		if m.intf.IntfMapKeys {
+			return m.readMapIntfIntf()
+		}
 		mp := make(map[string]interface{})
 		err = m.ReadMapStrIntf(mp)
 		i = mp
@@ -1327,13 +2405,8 @@ func (m *Reader) ReadIntf() (i interface{}, err error) {
 		i = nil
 		return
 
-	case Float32Type:
-		i, err = m.ReadFloat32()
-		return
-
-	case Float64Type:
-		i, err = m.ReadFloat64()
-		return
+	case Float32Type, Float64Type:
+		return m.readIntfFloat(t)
 
 	case ArrayType:
 		var sz uint32
@@ -1342,12 +2415,18 @@ func (m *Reader) ReadIntf() (i interface{}, err error) {
 		if err != nil {
 			return
 		}
-		out := make([]interface{}, int(sz))
-		for j := range out {
-			out[j], err = m.ReadIntf()
+		if err = m.enter(); err != nil {
+			return
+		}
+		defer m.leave()
+		out := make([]interface{}, 0, capHint(sz))
+		for j := uint32(0); j < sz; j++ {
+			var v interface{}
+			v, err = m.ReadIntf()
 			if err != nil {
 				return
 			}
+			out = append(out, v)
 		}
 		i = out
 		return
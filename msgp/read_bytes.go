@@ -123,11 +123,24 @@ func (r Raw) Msgsize() int {
 	return l
 }
 
+// CloneMsg returns a copy of r that
+// does not share memory with r
+func (r *Raw) CloneMsg() *Raw {
+	if r == nil {
+		return nil
+	}
+	c := Raw(append([]byte(nil), *r...))
+	return &c
+}
+
 func appendNext(f *Reader, d *[]byte) error {
-	amt, o, err := getNextSize(f.R)
+	amt, o, err := getNextSize(f)
 	if err != nil {
 		return err
 	}
+	if err = f.checkShort(int64(amt)); err != nil {
+		return err
+	}
 	var i int
 	*d, i = ensure(*d, int(amt))
 	_, err = f.R.ReadFull((*d)[i:])
@@ -145,9 +158,14 @@ func appendNext(f *Reader, d *[]byte) error {
 }
 
 // MarshalJSON implements json.Marshaler
-func (r *Raw) MarshalJSON() ([]byte, error) {
+// by translating r to JSON with UnmarshalAsJSON.
+// An empty Raw is translated as 'null'.
+func (r Raw) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 {
+		return []byte("null"), nil
+	}
 	var buf bytes.Buffer
-	_, err := UnmarshalAsJSON(&buf, []byte(*r))
+	_, err := UnmarshalAsJSON(&buf, []byte(r))
 	return buf.Bytes(), err
 }
 
@@ -211,6 +229,28 @@ func ReadMapKeyZC(b []byte) ([]byte, []byte, error) {
 	return o, x, nil
 }
 
+// ReadArrayHeaderMaxBytes is like ReadArrayHeaderBytes,
+// but it returns a LimitError (and 'b') if the array
+// has more than max elements.
+func ReadArrayHeaderMaxBytes(b []byte, max uint32) (sz uint32, o []byte, err error) {
+	sz, o, err = ReadArrayHeaderBytes(b)
+	if err == nil && sz > max {
+		return sz, b, LimitError{Limit: "elements", Size: uint64(sz), Max: uint64(max)}
+	}
+	return
+}
+
+// ReadMapHeaderMaxBytes is like ReadMapHeaderBytes,
+// but it returns a LimitError (and 'b') if the map
+// has more than max entries.
+func ReadMapHeaderMaxBytes(b []byte, max uint32) (sz uint32, o []byte, err error) {
+	sz, o, err = ReadMapHeaderBytes(b)
+	if err == nil && sz > max {
+		return sz, b, LimitError{Limit: "elements", Size: uint64(sz), Max: uint64(max)}
+	}
+	return
+}
+
 // ReadArrayHeaderBytes attempts to read
 // the array header size off of 'b' and return
 // the size and remaining bytes.
@@ -344,9 +384,11 @@ func ReadBoolBytes(b []byte) (bool, []byte, error) {
 
 // ReadInt64Bytes tries to read an int64
 // from 'b' and return the value and the remaining bytes.
+// Like ReadInt64, it accepts unsigned integers that fit.
 // Possible errors:
 // - ErrShortBytes (too few bytes)
 // - TypeError (not a int)
+// - UintOverflow (an unsigned integer above math.MaxInt64)
 func ReadInt64Bytes(b []byte) (i int64, o []byte, err error) {
 	l := len(b)
 	if l < 1 {
@@ -436,7 +478,7 @@ func ReadInt64Bytes(b []byte) (i int64, o []byte, err error) {
 		}
 		u := getMuint64(b)
 		if u > math.MaxInt64 {
-			err = UintOverflow{Value: u, FailedBitsize: 64}
+			err = UintOverflow{Value: u, FailedBitsize: 64, Signed: true}
 			return
 		}
 		i = int64(u)
@@ -508,9 +550,11 @@ func ReadIntBytes(b []byte) (int, []byte, error) {
 
 // ReadUint64Bytes tries to read a uint64
 // from 'b' and return the value and the remaining bytes.
+// Like ReadUint64, it accepts signed integers that aren't negative.
 // Possible errors:
 // - ErrShortBytes (too few bytes)
 // - TypeError{} (not a uint)
+// - UintBelowZero{} (a negative integer)
 func ReadUint64Bytes(b []byte) (u uint64, o []byte, err error) {
 	l := len(b)
 	if l < 1 {
@@ -963,17 +1007,31 @@ func ReadComplex64Bytes(b []byte) (c complex64, o []byte, err error) {
 
 // ReadTimeBytes reads a time.Time
 // extension object from 'b' and returns the
-// remaining bytes.
+// remaining bytes. MessagePack timestamps
+// (extension type -1) are accepted as well, and
+// so are times written by AppendTimeZoned, which
+// are returned in time.Local like the others
+// (see ReadTimeFormatBytes to keep their zone).
 // Possible errors:
 // - ErrShortBytes (not enough bytes in 'b')
 // - TypeError{} (object not a complex64)
 // - ExtensionTypeError{} (object an extension of the correct size, but not a time.Time)
 func ReadTimeBytes(b []byte) (t time.Time, o []byte, err error) {
+	return readTimeBytes(b, false)
+}
+
+// readTimeBytes implements ReadTimeBytes; if 'zoned'
+// is set, times written by AppendTimeZoned keep
+// their location
+func readTimeBytes(b []byte, zoned bool) (t time.Time, o []byte, err error) {
+	if len(b) > 0 && b[0] != mext8 || len(b) > 2 && int8(b[2]) == TimestampExtension {
+		return ReadTimestampBytes(b)
+	}
 	if len(b) < 15 {
 		err = ErrShortBytes
 		return
 	}
-	if b[0] != mext8 || b[1] != 12 {
+	if b[0] != mext8 || b[1] != 12 && b[1] < zonedTimeSize {
 		err = badPrefix(TimeType, b[0])
 		return
 	}
@@ -981,9 +1039,111 @@ func ReadTimeBytes(b []byte) (t time.Time, o []byte, err error) {
 		err = errExt(int8(b[2]), TimeExtension)
 		return
 	}
-	sec, nsec := getUnix(b[3:])
-	t = time.Unix(sec, int64(nsec)).Local()
-	o = b[15:]
+	sz := 3 + int(b[1])
+	if len(b) < sz {
+		err = ErrShortBytes
+		return
+	}
+	t = timeFromExt(b[3:sz], zoned)
+	o = b[sz:]
+	return
+}
+
+// ReadTimeFlexibleBytes reads a time from b in any
+// of the representations in 'accept', which is useful
+// for messages from other MessagePack libraries that
+// don't agree on how to encode times, and returns
+// the remaining bytes. Times from the extensions and
+// from integers are in time.Local; times parsed from
+// strings keep their offset.
+// Possible errors:
+// - ErrShortBytes (not enough bytes in 'b')
+// - TypeError{} (object not one of the accepted representations)
+// - ExtensionTypeError{} (object an extension other than a time)
+func ReadTimeFlexibleBytes(b []byte, accept TimeReprs) (t time.Time, o []byte, err error) {
+	if len(b) < 1 {
+		return t, b, ErrShortBytes
+	}
+	switch sizes[b[0]].typ {
+	case IntType, UintType:
+		if accept&TimeReprUnix != 0 {
+			var n int64
+			if n, o, err = ReadInt64Bytes(b); err != nil {
+				return t, b, err
+			}
+			return UnixToTime(n), o, nil
+		}
+	case StrType:
+		if accept&TimeReprRFC3339 != 0 {
+			var s []byte
+			if s, o, err = ReadStringZC(b); err != nil {
+				return t, b, err
+			}
+			if t, err = RFC3339ToTime(string(s)); err != nil {
+				return t, b, err
+			}
+			return t, o, nil
+		}
+	case ExtensionType:
+		var typ int8
+		if typ, err = peekExtension(b); err != nil {
+			return t, b, err
+		}
+		if !accept.hasExt(typ) {
+			return t, b, errExt(typ, TimeExtension)
+		}
+		return readTimeBytes(b, false)
+	}
+	return t, b, badPrefix(TimeType, b[0])
+}
+
+// ReadMapStrRawBytes reads a map out of 'b' into a
+// map[string]Raw without decoding the values, and
+// returns the map and the remaining bytes. The Raws
+// point into 'b' rather than being copied. Nil values
+// are read as empty Raws, as with Raw.UnmarshalMsg.
+// If 'old' is non-nil, the values will be read into
+// that map.
+func ReadMapStrRawBytes(b []byte, old map[string]Raw) (v map[string]Raw, o []byte, err error) {
+	var sz uint32
+	sz, o, err = ReadMapHeaderBytes(b)
+	if err != nil {
+		return
+	}
+
+	// every entry takes at least two bytes
+	if uint64(sz)*2 > uint64(len(o)) {
+		err = ErrShortBytes
+		return
+	}
+
+	if old != nil {
+		for key := range old {
+			delete(old, key)
+		}
+		v = old
+	} else {
+		v = make(map[string]Raw, int(sz))
+	}
+
+	for z := uint32(0); z < sz; z++ {
+		var key []byte
+		key, o, err = ReadMapKeyZC(o)
+		if err != nil {
+			return
+		}
+		var rest []byte
+		rest, err = Skip(o)
+		if err != nil {
+			return
+		}
+		val := Raw(o[:len(o)-len(rest)])
+		if IsNil(val) {
+			val = val[:0]
+		}
+		v[string(key)] = val
+		o = rest
+	}
 	return
 }
 
@@ -991,6 +1151,10 @@ func ReadTimeBytes(b []byte) (t time.Time, o []byte, err error) {
 // out of 'b' and returns the map and remaining bytes.
 // If 'old' is non-nil, the values will be read into that map.
 func ReadMapStrIntfBytes(b []byte, old map[string]interface{}) (v map[string]interface{}, o []byte, err error) {
+	return readMapStrIntfBytes(b, old, DefaultRegistry)
+}
+
+func readMapStrIntfBytes(b []byte, old map[string]interface{}, reg *Registry) (v map[string]interface{}, o []byte, err error) {
 	var sz uint32
 	o = b
 	sz, o, err = ReadMapHeaderBytes(o)
@@ -999,6 +1163,12 @@ func ReadMapStrIntfBytes(b []byte, old map[string]interface{}) (v map[string]int
 		return
 	}
 
+	// every entry takes at least two bytes
+	if uint64(sz)*2 > uint64(len(o)) {
+		err = ErrShortBytes
+		return
+	}
+
 	if old != nil {
 		for key := range old {
 			delete(old, key)
@@ -1019,7 +1189,7 @@ func ReadMapStrIntfBytes(b []byte, old map[string]interface{}) (v map[string]int
 			return
 		}
 		var val interface{}
-		val, o, err = ReadIntfBytes(o)
+		val, o, err = readIntfBytes(o, reg)
 		if err != nil {
 			return
 		}
@@ -1032,6 +1202,10 @@ func ReadMapStrIntfBytes(b []byte, old map[string]interface{}) (v map[string]int
 // the next object out of 'b' as a raw interface{} and
 // return the remaining bytes.
 func ReadIntfBytes(b []byte) (i interface{}, o []byte, err error) {
+	return readIntfBytes(b, DefaultRegistry)
+}
+
+func readIntfBytes(b []byte, reg *Registry) (i interface{}, o []byte, err error) {
 	if len(b) < 1 {
 		err = ErrShortBytes
 		return
@@ -1041,7 +1215,7 @@ func ReadIntfBytes(b []byte) (i interface{}, o []byte, err error) {
 
 	switch k {
 	case MapType:
-		i, o, err = ReadMapStrIntfBytes(b, nil)
+		i, o, err = readMapStrIntfBytes(b, nil, reg)
 		return
 
 	case ArrayType:
@@ -1050,10 +1224,15 @@ func ReadIntfBytes(b []byte) (i interface{}, o []byte, err error) {
 		if err != nil {
 			return
 		}
+		// every element takes at least one byte
+		if uint64(sz) > uint64(len(o)) {
+			err = ErrShortBytes
+			return
+		}
 		j := make([]interface{}, int(sz))
 		i = j
 		for d := range j {
-			j[d], o, err = ReadIntfBytes(o)
+			j[d], o, err = readIntfBytes(o, reg)
 			if err != nil {
 				return
 			}
@@ -1098,9 +1277,13 @@ func ReadIntfBytes(b []byte) (i interface{}, o []byte, err error) {
 		if err != nil {
 			return
 		}
+		if t == TimestampExtension {
+			i, o, err = ReadTimestampBytes(b)
+			return
+		}
 		// use a user-defined extension,
 		// if it's been registered
-		f, ok := extensionReg[t]
+		f, ok := reg.Lookup(t)
 		if ok {
 			e := f()
 			o, err = ReadExtensionBytes(b, e)
@@ -1158,6 +1341,47 @@ func Skip(b []byte) ([]byte, error) {
 	return b, nil
 }
 
+// Validate checks that b contains exactly one
+// complete MessagePack object: every prefix is
+// recognized and every length fits within b. It
+// doesn't allocate or recurse, so it is suitable
+// for checking untrusted input. Strings are not
+// checked for valid UTF-8.
+//
+// If b is valid, n is len(b). Otherwise, n is the
+// offset at which the problem was found: for
+// ErrTrailingBytes, this is the size of the object.
+//
+// Possible errors:
+// - ErrShortBytes (truncated object)
+// - InvalidPrefixError (unrecognized type prefix)
+// - ErrTrailingBytes (bytes after the object)
+func Validate(b []byte) (n int, err error) {
+	// containers just add their elements to the
+	// number of objects left to read, so there's
+	// no need to keep track of nesting
+	left := uintptr(1)
+	for left > 0 {
+		// every object is at least one byte
+		if left > uintptr(len(b)-n) {
+			return n, ErrShortBytes
+		}
+		sz, asz, err := getSize(b[n:])
+		if err != nil {
+			return n, err
+		}
+		if sz > uintptr(len(b)-n) {
+			return n, ErrShortBytes
+		}
+		n += int(sz)
+		left += asz - 1
+	}
+	if n != len(b) {
+		return n, ErrTrailingBytes
+	}
+	return n, nil
+}
+
 // returns (skip N bytes, skip M objects, error)
 func getSize(b []byte) (uintptr, uintptr, error) {
 	l := len(b)
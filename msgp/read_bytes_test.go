@@ -715,3 +715,127 @@ func BenchmarkSkipBytes(b *testing.B) {
 		}
 	}
 }
+
+func TestValidate(t *testing.T) {
+	var b []byte
+	b = AppendMapHeader(b, 2)
+	b = AppendString(b, "a")
+	b = AppendArrayHeader(b, 3)
+	b = AppendInt(b, 1)
+	b = AppendBytes(b, []byte("bytes"))
+	b = AppendMapHeader(b, 0)
+	b = AppendString(b, "b")
+	b, _ = AppendExtension(b, &RawExtension{Type: 44, Data: []byte("ext")})
+
+	n, err := Validate(b)
+	if err != nil || n != len(b) {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	for i := 0; i < len(b); i++ {
+		if _, err := Validate(b[:i]); err != ErrShortBytes {
+			t.Errorf("b[:%d]: got %v", i, err)
+		}
+	}
+	if n, err := Validate(append(b, 0xc0)); err != ErrTrailingBytes || n != len(b) {
+		t.Errorf("trailing bytes: got %d, %v", n, err)
+	}
+	// 0xc1 is never used
+	bad := append(AppendArrayHeader(nil, 2), 0x01, 0xc1)
+	if n, err := Validate(bad); err != InvalidPrefixError(0xc1) || n != 2 {
+		t.Errorf("bad prefix: got %d, %v", n, err)
+	}
+	// a huge container in a short message
+	huge := AppendArrayHeader(nil, math.MaxUint32)
+	if _, err := Validate(huge); err != ErrShortBytes {
+		t.Errorf("huge array: got %v", err)
+	}
+}
+
+func BenchmarkValidate(b *testing.B) {
+	buf := AppendMapHeader(nil, 100)
+	for i := 0; i < 100; i++ {
+		buf = AppendString(buf, fmt.Sprintf("key%d", i))
+		buf = AppendArrayHeader(buf, 2)
+		buf = AppendFloat64(buf, float64(i))
+		buf = AppendString(buf, "value")
+	}
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Validate(buf)
+	}
+}
+
+func TestMapStrRaw(t *testing.T) {
+	var b []byte
+	b = AppendMapHeader(b, 3)
+	b = AppendString(b, "type")
+	b = AppendString(b, "order")
+	b = AppendString(b, "body")
+	body := len(b)
+	b = AppendMapHeader(b, 1)
+	b = AppendString(b, "id")
+	b = AppendInt(b, 42)
+	bodyEnd := len(b)
+	b = AppendString(b, "none")
+	b = AppendNil(b)
+
+	check := func(name string, mp map[string]Raw) {
+		t.Helper()
+		if len(mp) != 3 {
+			t.Errorf("%s: got %d entries", name, len(mp))
+		}
+		if typ, _, err := ReadStringBytes(mp["type"]); err != nil || typ != "order" {
+			t.Errorf("%s: type is %q, %v", name, typ, err)
+		}
+		if !bytes.Equal(mp["body"], b[body:bodyEnd]) {
+			t.Errorf("%s: body is %x", name, []byte(mp["body"]))
+		}
+		if r, ok := mp["none"]; !ok || len(r) != 0 {
+			t.Errorf("%s: none is %x", name, []byte(r))
+		}
+	}
+
+	old := map[string]Raw{"stale": nil}
+	mp, o, err := ReadMapStrRawBytes(append(b, 0xc3), old)
+	if err != nil || len(o) != 1 {
+		t.Fatalf("got %x, %v", o, err)
+	}
+	check("ReadMapStrRawBytes", mp)
+
+	mp = make(map[string]Raw)
+	if err = NewReader(bytes.NewReader(b)).ReadMapStrRaw(mp); err != nil {
+		t.Fatal(err)
+	}
+	check("ReadMapStrRaw", mp)
+
+	// and back again, with the nil restored
+	for _, out := range [][]byte{
+		AppendMapStrRaw(nil, mp),
+		func() []byte {
+			var buf bytes.Buffer
+			w := NewWriterWithOptions(&buf, WriterOptions{SortMaps: true})
+			w.WriteMapStrRaw(mp)
+			w.Flush()
+			return buf.Bytes()
+		}(),
+	} {
+		mp2, _, err := ReadMapStrIntfBytes(out, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]interface{}{
+			"type": "order",
+			"body": map[string]interface{}{"id": int64(42)},
+			"none": nil,
+		}
+		if !reflect.DeepEqual(mp2, want) {
+			t.Errorf("got %v, want %v", mp2, want)
+		}
+	}
+
+	if _, _, err = ReadMapStrRawBytes(b[:bodyEnd-1], nil); err == nil {
+		t.Error("expected an error for truncated input")
+	}
+}
@@ -2,11 +2,14 @@ package msgp
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -73,6 +76,115 @@ func TestReadIntf(t *testing.T) {
 
 }
 
+func TestReadIntfInto(t *testing.T) {
+	msgs := []map[string]interface{}{
+		{
+			"id":   int64(1),
+			"tags": []interface{}{"a", "b", "c"},
+			"meta": map[string]interface{}{"x": int64(1), "y": "old"},
+			"gone": true,
+		},
+		{
+			"id":   int64(2),
+			"tags": []interface{}{"d", map[string]interface{}{"e": int64(5)}},
+			"meta": map[string]interface{}{"y": "new"},
+			"list": []interface{}{},
+		},
+		{
+			"id":   int64(3),
+			"tags": "not a list",
+			"meta": map[string]interface{}{"y": "newer", "z": nil},
+		},
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, msg := range msgs {
+		if err := w.WriteIntf(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+
+	r := NewReader(&buf)
+	dst := make(map[string]interface{})
+	var meta map[string]interface{}
+	var tags []interface{}
+	for i, want := range msgs {
+		if err := r.ReadIntfInto(dst); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dst, want) {
+			t.Errorf("message %d: got %v, want %v", i, dst, want)
+		}
+		// the nested map and slice are reused
+		if i > 0 && reflect.ValueOf(dst["meta"]).Pointer() != reflect.ValueOf(meta).Pointer() {
+			t.Errorf("message %d: nested map not reused", i)
+		}
+		if i == 1 && &dst["tags"].([]interface{})[0] != &tags[0] {
+			t.Errorf("message %d: nested slice not reused", i)
+		}
+		meta, _ = dst["meta"].(map[string]interface{})
+		tags, _ = dst["tags"].([]interface{})
+	}
+	if len(r.keys) != 0 {
+		t.Errorf("%d keys left over", len(r.keys))
+	}
+
+	// slices grow as needed, and a short array
+	// doesn't hold on to the old elements
+	b := AppendArrayHeader(nil, 3)
+	b = AppendInt64(b, 1)
+	b = AppendArrayHeader(b, 1)
+	b = AppendString(b, "x")
+	b = AppendNil(b)
+	b = AppendArrayHeader(b, 1)
+	b = AppendString(b, "y")
+	b = AppendArrayHeader(b, 0)
+	r = NewReader(bytes.NewReader(b))
+	out, err := r.ReadIntfSliceInto(make([]interface{}, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{int64(1), []interface{}{"x"}, nil}; !reflect.DeepEqual(out, want) {
+		t.Errorf("got %v, want %v", out, want)
+	}
+	out, err = r.ReadIntfSliceInto(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"y"}; !reflect.DeepEqual(out, want) || out[:3][1] != nil {
+		t.Errorf("got %v, want %v", out, want)
+	}
+	if out, err = r.ReadIntfSliceInto(out); err != nil || len(out) != 0 {
+		t.Errorf("got %v, %v", out, err)
+	}
+}
+
+func BenchmarkReadIntfInto(b *testing.B) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteIntf(map[string]interface{}{
+		"id":   int64(1),
+		"tags": []interface{}{"a", "b", "c"},
+		"meta": map[string]interface{}{"x": int64(1), "y": true},
+	})
+	w.Flush()
+	data := buf.Bytes()
+	rd := bytes.NewReader(data)
+	r := NewReader(rd)
+	dst := make(map[string]interface{})
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rd.Reset(data)
+		r.Reset(rd)
+		if err := r.ReadIntfInto(dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func TestReadMapHeader(t *testing.T) {
 	tests := []struct {
 		Sz uint32
@@ -173,6 +285,215 @@ func BenchmarkReadArrayHeader(b *testing.B) {
 	}
 }
 
+func TestPeekHeaders(t *testing.T) {
+	for _, sz := range []uint32{0, 1, tuint16, tuint32} {
+		data := AppendMapHeader(nil, sz)
+		data = AppendArrayHeader(data, sz)
+		rd := NewReader(bytes.NewReader(data))
+		if typ, err := rd.PeekType(); err != nil || typ != MapType {
+			t.Errorf("size %d: got type %s, %v", sz, typ, err)
+		}
+		if _, err := rd.PeekArrayHeader(); err == nil {
+			t.Errorf("size %d: expected an error peeking a map as an array", sz)
+		}
+		// peeking twice gives the same answer
+		for i := 0; i < 2; i++ {
+			if got, err := rd.PeekMapHeader(); err != nil || got != sz {
+				t.Errorf("size %d: PeekMapHeader got %d, %v", sz, got, err)
+			}
+		}
+		if got, err := rd.ReadMapHeader(); err != nil || got != sz {
+			t.Errorf("size %d: ReadMapHeader got %d, %v", sz, got, err)
+		}
+		if got, err := rd.PeekArrayHeader(); err != nil || got != sz {
+			t.Errorf("size %d: PeekArrayHeader got %d, %v", sz, got, err)
+		}
+		if got, err := rd.ReadArrayHeader(); err != nil || got != sz {
+			t.Errorf("size %d: ReadArrayHeader got %d, %v", sz, got, err)
+		}
+		if _, err := rd.PeekMapHeader(); err != io.EOF {
+			t.Errorf("size %d: expected io.EOF; got %v", sz, err)
+		}
+	}
+	rd := NewReader(bytes.NewReader(AppendArrayHeader(nil, tuint32)[:3]))
+	if _, err := rd.PeekArrayHeader(); err == nil {
+		t.Error("expected an error for a truncated header")
+	}
+}
+
+func TestReadMapKeyInto(t *testing.T) {
+	data := AppendString(nil, "id")
+	data = AppendBytes(data, []byte("name"))
+	data = AppendString(data, "a much longer key than the others")
+	data = AppendString(data, "")
+	data = AppendInt(data, 1)
+	rd := NewReader(bytes.NewReader(data))
+
+	var key []byte
+	var err error
+	for i, want := range []int{0, 1, -1, -1} {
+		key, err = rd.ReadMapKeyInto(key)
+		if err != nil {
+			t.Fatalf("key %d: %s", i, err)
+		}
+		if got := MatchKey(key, "id", "name"); got != want {
+			t.Errorf("key %d (%q): MatchKey = %d; want %d", i, key, got, want)
+		}
+	}
+	if _, err = rd.ReadMapKeyInto(key); err == nil {
+		t.Error("expected an error for an int key")
+	}
+}
+
+func TestForEach(t *testing.T) {
+	data := AppendMapHeader(nil, 3)
+	data = AppendString(data, "id")
+	data = AppendInt(data, 7)
+	data = AppendString(data, "skipped")
+	data = AppendMapStrStr(data, map[string]string{"a": "b"})
+	data = AppendString(data, "tags")
+	data = AppendArrayHeader(data, 2)
+	data = AppendString(data, "x")
+	data = AppendString(data, "y")
+	data = AppendNil(data)
+
+	var id int64
+	var tags []string
+	rd := NewReader(bytes.NewReader(data))
+	err := rd.ForEachMap(func(key []byte, r *Reader) (err error) {
+		switch MatchKey(key, "id", "tags") {
+		case 0:
+			id, err = r.ReadInt64()
+		case 1:
+			err = r.ForEachArray(func(r *Reader) error {
+				s, err := r.ReadString()
+				tags = append(tags, s)
+				return err
+			})
+		}
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 7 || len(tags) != 2 || tags[0] != "x" || tags[1] != "y" {
+		t.Errorf("got %d, %q", id, tags)
+	}
+	if err = rd.ReadNil(); err != nil {
+		t.Errorf("after ForEachMap: %v", err)
+	}
+
+	// errors have the location of the element
+	rd = NewReader(bytes.NewReader(data))
+	err = rd.ForEachMap(func(key []byte, r *Reader) error {
+		if string(key) != "tags" {
+			return nil
+		}
+		return r.ForEachArray(func(r *Reader) error {
+			_, err := r.ReadInt64()
+			return err
+		})
+	})
+	if ErrorPath(err) != "tags[0]" {
+		t.Errorf("got %v at %q", err, ErrorPath(err))
+	}
+}
+
+func BenchmarkReadMapKeyInto(b *testing.B) {
+	data := AppendString(nil, "a typical field name")
+	rd := NewReader(NewEndlessReader(data, b))
+	var key []byte
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key, _ = rd.ReadMapKeyInto(key)
+		if MatchKey(key, "id", "name", "a typical field name") != 2 {
+			b.Fatal("no match")
+		}
+	}
+}
+
+func TestReadBytesTo(t *testing.T) {
+	data := RandBytes(10000)
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	wr.WriteBytes(data)
+	wr.WriteString("hello")
+	wr.WriteInt(1)
+	wr.Flush()
+	enc := buf.Bytes()
+
+	rd := NewReaderSize(bytes.NewReader(enc), 64)
+	var out bytes.Buffer
+	n, err := rd.ReadBytesTo(&out)
+	if err != nil || n != int64(len(data)) || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("bin: copied %d bytes, %v", n, err)
+	}
+	out.Reset()
+	n, err = rd.ReadBytesTo(&out)
+	if err != nil || n != 5 || out.String() != "hello" {
+		t.Fatalf("str: copied %q, %v", out.String(), err)
+	}
+	if _, err = rd.ReadBytesTo(&out); err == nil {
+		t.Error("expected an error for an int")
+	} else if _, ok := err.(TypeError); !ok {
+		t.Errorf("expected a TypeError; got %v", err)
+	}
+
+	rd = NewReaderSize(bytes.NewReader(enc[:5000]), 64)
+	if _, err = rd.ReadBytesTo(ioutil.Discard); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF; got %v", err)
+	}
+}
+
+func TestReadBytesInto(t *testing.T) {
+	data := AppendBytes(nil, []byte("payload"))
+	data = AppendString(data, "text")
+	data = AppendString(data, "")
+	rd := NewReader(bytes.NewReader(data))
+
+	buf := make([]byte, 4)
+	_, err := rd.ReadBytesInto(buf)
+	var serr BufferSizeError
+	if !errors.As(err, &serr) || serr.Need != 7 || serr.Have != 4 {
+		t.Fatalf("expected a BufferSizeError; got %v", err)
+	}
+	if !Resumable(err) {
+		t.Error("BufferSizeError should be resumable")
+	}
+	// the object is still there
+	buf = make([]byte, serr.Need)
+	if n, err := rd.ReadBytesInto(buf); err != nil || string(buf[:n]) != "payload" {
+		t.Errorf("got %q, %v", buf[:n], err)
+	}
+	if n, err := rd.ReadStringInto(buf); err != nil || string(buf[:n]) != "text" {
+		t.Errorf("got %q, %v", buf[:n], err)
+	}
+	if n, err := rd.ReadStringInto(nil); err != nil || n != 0 {
+		t.Errorf("got %d, %v", n, err)
+	}
+
+	rd = NewReader(bytes.NewReader(AppendInt(nil, 1)))
+	if _, err := rd.ReadStringInto(buf); err == nil {
+		t.Error("expected an error for an int")
+	}
+}
+
+func BenchmarkReadBytesInto(b *testing.B) {
+	data := AppendBytes(nil, make([]byte, 64))
+	rd := NewReader(NewEndlessReader(data, b))
+	buf := make([]byte, 128)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rd.ReadBytesInto(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func TestReadNil(t *testing.T) {
 	var buf bytes.Buffer
 	wr := NewWriter(&buf)
@@ -647,6 +968,56 @@ func TestReadString(t *testing.T) {
 	}
 }
 
+func TestReadStringZC(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	sizes := []int{0, 1, 225, 4000, int(math.MaxUint16 + 5)}
+	for _, size := range sizes {
+		in := string(RandBytes(size))
+		wr.WriteString(in)
+		wr.WriteString(in)
+	}
+	wr.Flush()
+
+	rd := NewReaderSize(&buf, 4096)
+	for _, size := range sizes {
+		out, err := rd.ReadStringZC()
+		if err != nil {
+			t.Fatalf("size %d: %s", size, err)
+		}
+		if len(out) != size {
+			t.Errorf("size %d: got length %d", size, len(out))
+		}
+		cp := string([]byte(out))
+		outb, err := rd.ReadStringAsBytesZC()
+		if err != nil {
+			t.Fatalf("size %d: %s", size, err)
+		}
+		if string(outb) != cp {
+			t.Errorf("size %d: strings not equal", size)
+		}
+	}
+
+	rd = NewReader(bytes.NewReader(AppendInt(nil, 1)))
+	if _, err := rd.ReadStringZC(); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func BenchmarkReadStringZC(b *testing.B) {
+	data := AppendString(nil, string(RandBytes(64)))
+	rd := NewReader(NewEndlessReader(data, b))
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := rd.ReadStringZC()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func benchString(size uint32, b *testing.B) {
 	str := string(RandBytes(int(size)))
 	data := make([]byte, 0, len(str)+5)
@@ -935,3 +1306,379 @@ func TestCopyNext(t *testing.T) {
 		t.Fatalf("not equal! %v, %v", buf.Bytes(), w.Bytes())
 	}
 }
+
+func TestNewReaderFromBytes(t *testing.T) {
+	big := string(RandBytes(10000))
+	var b []byte
+	b = AppendString(b, "hello")
+	b = AppendString(b, big)
+	b = AppendInt(b, -7)
+	orig := append([]byte(nil), b...)
+
+	rd := NewReaderFromBytes(b)
+	s, err := rd.ReadStringZC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "hello" {
+		t.Errorf("got %q", s)
+	}
+	bs, err := rd.ReadStringAsBytesZC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != big {
+		t.Error("long string doesn't match")
+	}
+	if &bs[0] != &b[9] {
+		t.Error("string was copied out of b")
+	}
+	i, err := rd.ReadInt64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != -7 {
+		t.Errorf("got %d", i)
+	}
+	if _, err = rd.ReadInt64(); err != io.EOF {
+		t.Errorf("expected io.EOF at the end; got %v", err)
+	}
+
+	// reading a stream after Reset must not write into b
+	rd.Reset(bytes.NewReader(AppendString(nil, "world")))
+	s, err = rd.ReadString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "world" {
+		t.Errorf("got %q", s)
+	}
+	if !bytes.Equal(b, orig) {
+		t.Error("Reset reader wrote into the original slice")
+	}
+
+	// empty and short inputs
+	if _, err = NewReaderFromBytes(nil).ReadInt64(); err != io.EOF {
+		t.Errorf("expected io.EOF; got %v", err)
+	}
+	i, err = NewReaderFromBytes(AppendInt(nil, 3)).ReadInt64()
+	if err != nil || i != 3 {
+		t.Errorf("got %d, %v", i, err)
+	}
+}
+
+func TestReaderOffset(t *testing.T) {
+	var b []byte
+	b = AppendString(b, "hello")
+	b = AppendMapHeader(b, 1)
+	b = AppendString(b, "key")
+	b = AppendBytes(b, RandBytes(5000))
+	end := len(b)
+	b = AppendInt(b, 300)
+
+	readers := map[string]func() *Reader{
+		"stream":   func() *Reader { return NewReaderSize(bytes.NewBuffer(b), 64) },
+		"seeker":   func() *Reader { return NewReaderSize(bytes.NewReader(b), 64) },
+		"pooled":   func() *Reader { return NewReader(bytes.NewBuffer(b)) },
+		"borrowed": func() *Reader { return NewReaderFromBytes(b) },
+	}
+	for name, mk := range readers {
+		rd := mk()
+		if rd.Offset() != 0 {
+			t.Errorf("%s: initial offset %d", name, rd.Offset())
+		}
+		if _, err := rd.ReadString(); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if rd.Offset() != 6 {
+			t.Errorf("%s: offset %d after string; want 6", name, rd.Offset())
+		}
+		if err := rd.Skip(); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if rd.Offset() != int64(end) {
+			t.Errorf("%s: offset %d after map; want %d", name, rd.Offset(), end)
+		}
+		if _, err := rd.ReadInt(); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if rd.Offset() != int64(len(b)) {
+			t.Errorf("%s: offset %d at the end; want %d", name, rd.Offset(), len(b))
+		}
+		rd.Reset(bytes.NewReader(b))
+		if rd.Offset() != 0 {
+			t.Errorf("%s: offset %d after Reset", name, rd.Offset())
+		}
+	}
+}
+
+func TestSkipMax(t *testing.T) {
+	var b []byte
+	b = AppendArrayHeader(b, 2)
+	b = AppendArrayHeader(b, 1)
+	b = AppendMapHeader(b, 1)
+	b = AppendString(b, "k")
+	b = AppendBytes(b, make([]byte, 100))
+	b = AppendNil(b)
+	b = AppendInt(b, 1) // a second object
+
+	cases := []struct {
+		depth int
+		bytes int64
+		limit string // "" if it should succeed
+	}{
+		{0, 0, ""},
+		{3, int64(len(b) - 1), ""},
+		{2, 0, "depth"},
+		{0, int64(len(b) - 2), "skipped bytes"},
+		{0, 50, "skipped bytes"},
+	}
+	for _, c := range cases {
+		rd := NewReader(bytes.NewReader(b))
+		err := rd.SkipMax(c.depth, c.bytes)
+		if c.limit == "" {
+			if err != nil {
+				t.Errorf("SkipMax(%d, %d): %s", c.depth, c.bytes, err)
+				continue
+			}
+			if i, err := rd.ReadInt(); err != nil || i != 1 {
+				t.Errorf("SkipMax(%d, %d): read %d, %v after skipping", c.depth, c.bytes, i, err)
+			}
+			continue
+		}
+		le, ok := err.(LimitError)
+		if !ok || le.Limit != c.limit {
+			t.Errorf("SkipMax(%d, %d): got %v; want a %s limit error", c.depth, c.bytes, err, c.limit)
+			continue
+		}
+		if c.limit == "skipped bytes" && rd.Offset() > c.bytes {
+			t.Errorf("SkipMax(%d, %d): consumed %d bytes", c.depth, c.bytes, rd.Offset())
+		}
+	}
+
+	// the Reader's own limits still apply
+	rd := NewReader(bytes.NewReader(b))
+	rd.SetMaxDepth(1)
+	if _, ok := rd.SkipMax(0, 0).(LimitError); !ok {
+		t.Error("expected the Reader's depth limit to apply")
+	}
+}
+
+func TestSkipInfo(t *testing.T) {
+	var b []byte
+	b = AppendArrayHeader(b, 2)
+	b = AppendArrayHeader(b, 1)
+	b = AppendMapHeader(b, 1)
+	b = AppendString(b, "k")
+	b = AppendBytes(b, make([]byte, 100))
+	b = AppendNil(b)
+	first := len(b)
+	b = AppendInt(b, 1)
+
+	rd := NewReader(bytes.NewReader(b))
+	st, err := rd.SkipInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SkipStats{Size: int64(first), Elements: 6, Depth: 3}
+	if st != want {
+		t.Errorf("got %+v, want %+v", st, want)
+	}
+	st, err = rd.SkipInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = SkipStats{Size: int64(len(b) - first), Elements: 1}
+	if st != want {
+		t.Errorf("got %+v, want %+v", st, want)
+	}
+	if _, err = rd.SkipInfo(); err != io.EOF {
+		t.Errorf("expected io.EOF; got %v", err)
+	}
+}
+
+func TestCopyNextWriter(t *testing.T) {
+	big := RandBytes(5000)
+	var in []byte
+	in = AppendMapHeader(in, 3)
+	in = AppendString(in, "name")
+	in = AppendString(in, "value")
+	in = AppendString(in, "secret")
+	in = AppendString(in, "hunter2")
+	in = AppendString(in, "data")
+	in = AppendArrayHeader(in, 2)
+	in = AppendBytes(in, big)
+	in = AppendInt(in, 1)
+
+	var want []byte
+	want = AppendMapHeader(want, 2)
+	want = AppendString(want, "name")
+	want = AppendString(want, "value")
+	want = AppendString(want, "data")
+	want = AppendArrayHeader(want, 2)
+	want = AppendBytes(want, big)
+	want = AppendInt(want, 1)
+
+	// filter out the "secret" field
+	var out bytes.Buffer
+	rd := NewReaderSize(bytes.NewBuffer(in), 64)
+	wr := NewWriterSize(&out, 128)
+	sz, err := rd.ReadMapHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wr.WriteMapHeader(sz - 1)
+	for i := uint32(0); i < sz; i++ {
+		key, err := rd.ReadString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key == "secret" {
+			if err = rd.Skip(); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		wr.WriteString(key)
+		if _, err = rd.CopyNext(wr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = wr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Error("filtered output doesn't match")
+	}
+}
+
+func TestReadCrossSign(t *testing.T) {
+	cases := []struct {
+		enc  []byte
+		i    int64
+		ierr string // the expected error from ReadInt64
+		u    uint64
+		uerr string // the expected error from ReadUint64
+	}{
+		{AppendUint64(nil, 200), 200, "", 200, ""},
+		{AppendUint64(nil, math.MaxInt64), math.MaxInt64, "", math.MaxInt64, ""},
+		{AppendUint64(nil, math.MaxInt64+1), 0, "msgp: 9223372036854775808 overflows int64", math.MaxInt64 + 1, ""},
+		{AppendInt64(nil, 70000), 70000, "", 70000, ""},
+		{AppendInt64(nil, -1), -1, "", 0, "msgp: attempted to cast int -1 to unsigned"},
+	}
+	errString := func(err error) string {
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	for _, c := range cases {
+		i, err := NewReader(bytes.NewReader(c.enc)).ReadInt64()
+		if i != c.i || errString(err) != c.ierr {
+			t.Errorf("ReadInt64(%x): got %d, %v; want %d, %q", c.enc, i, err, c.i, c.ierr)
+		}
+		i, _, err = ReadInt64Bytes(c.enc)
+		if i != c.i || errString(err) != c.ierr {
+			t.Errorf("ReadInt64Bytes(%x): got %d, %v; want %d, %q", c.enc, i, err, c.i, c.ierr)
+		}
+		u, err := NewReader(bytes.NewReader(c.enc)).ReadUint64()
+		if u != c.u || errString(err) != c.uerr {
+			t.Errorf("ReadUint64(%x): got %d, %v; want %d, %q", c.enc, u, err, c.u, c.uerr)
+		}
+		u, _, err = ReadUint64Bytes(c.enc)
+		if u != c.u || errString(err) != c.uerr {
+			t.Errorf("ReadUint64Bytes(%x): got %d, %v; want %d, %q", c.enc, u, err, c.u, c.uerr)
+		}
+	}
+}
+
+func TestReadHeaderMax(t *testing.T) {
+	b := AppendArrayHeader(nil, 10)
+	b = AppendMapHeader(b, 3)
+	rd := NewReader(bytes.NewReader(b))
+	if _, err := rd.ReadArrayHeaderMax(9); err == nil {
+		t.Error("expected an error for 10 > 9 elements")
+	} else if le, ok := err.(LimitError); !ok || le.Size != 10 || le.Max != 9 {
+		t.Errorf("got %v", err)
+	}
+	if sz, err := rd.ReadMapHeaderMax(3); err != nil || sz != 3 {
+		t.Errorf("got %d, %v", sz, err)
+	}
+
+	sz, o, err := ReadArrayHeaderMaxBytes(b, 10)
+	if err != nil || sz != 10 {
+		t.Fatalf("got %d, %v", sz, err)
+	}
+	if _, rest, err := ReadMapHeaderMaxBytes(o, 2); err == nil {
+		t.Error("expected an error for 3 > 2 entries")
+	} else if len(rest) != len(o) {
+		t.Error("the header was consumed on error")
+	}
+}
+
+func TestReadIntfHugeHeader(t *testing.T) {
+	// a few bytes claiming a huge array
+	// shouldn't cause a huge allocation
+	b := AppendArrayHeader(nil, math.MaxUint32)
+	b = AppendMapHeader(b, math.MaxUint32)
+	b = AppendNil(b)
+	if _, err := NewReader(bytes.NewReader(b)).ReadIntf(); err == nil {
+		t.Error("expected an error from ReadIntf")
+	}
+	if _, _, err := ReadIntfBytes(b); err != ErrShortBytes {
+		t.Errorf("ReadIntfBytes: got %v; want ErrShortBytes", err)
+	}
+	if _, _, err := ReadIntfBytes(b[5:]); err != ErrShortBytes {
+		t.Errorf("ReadIntfBytes: got %v; want ErrShortBytes", err)
+	}
+	rd := NewReader(bytes.NewReader(b))
+	rd.SetIntfPolicy(IntfPolicy{IntfMapKeys: true})
+	if _, err := rd.ReadIntf(); err == nil {
+		t.Error("expected an error from ReadIntf with IntfMapKeys")
+	}
+}
+
+func TestNewReaderFromBytesTruncated(t *testing.T) {
+	hello := strings.Repeat("hello", 10)
+	b := AppendString(nil, hello)
+	b = AppendInt64(b, 1<<40)
+	b = b[:len(b)-3]
+	orig := append([]byte(nil), b...)
+
+	rd := NewReaderFromBytes(b)
+	if s, err := rd.ReadString(); err != nil || s != hello {
+		t.Fatalf("got %q, %v", s, err)
+	}
+	if _, err := rd.ReadInt64(); err == nil {
+		t.Error("expected an error for a truncated int")
+	}
+	if _, err := rd.ReadInt64(); err == nil {
+		t.Error("expected an error for a truncated int")
+	}
+	if !bytes.Equal(b, orig) {
+		t.Error("the Reader wrote into the slice")
+	}
+
+	// lengths longer than the input
+	str := []byte{mstr32, 0xff, 0xff, 0xff, 0xff, 'x'}
+	bin := []byte{mbin32, 0xff, 0xff, 0xff, 0xff, 'x'}
+	ext := []byte{mext32, 0xff, 0xff, 0xff, 0xff, 5, 'x'}
+	key := append([]byte{0x81}, str...)
+	for _, tc := range []struct {
+		name string
+		in   []byte
+		op   func(m *Reader) error
+	}{
+		{"ReadString", str, func(m *Reader) error { _, err := m.ReadString(); return err }},
+		{"ReadBytes", bin, func(m *Reader) error { _, err := m.ReadBytes(nil); return err }},
+		{"ReadExtension", ext, func(m *Reader) error { return m.ReadExtension(&RawExtension{}) }},
+		{"ReadMapKey", key, func(m *Reader) error { m.ReadMapHeader(); _, err := m.ReadMapKey(nil); return err }},
+		{"ReadMapKeyPtr", key, func(m *Reader) error { m.ReadMapHeader(); _, err := m.ReadMapKeyPtr(); return err }},
+		{"Raw", str, func(m *Reader) error { var r Raw; return r.DecodeMsg(m) }},
+		{"CopyNext", str, func(m *Reader) error { _, err := m.CopyNext(ioutil.Discard); return err }},
+	} {
+		if err := tc.op(NewReaderFromBytes(tc.in)); err != ErrShortBytes {
+			t.Errorf("%s: got %v; want ErrShortBytes", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,571 @@
+package msgp
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Marshal returns the MessagePack encoding of v.
+//
+// If v implements Marshaler, its MarshalMsg method
+// is used. Otherwise, v is encoded using reflection,
+// following the same rules as the code generator:
+// structs are encoded as maps keyed by their `msg`
+// tags (falling back to `msgpack` and then the field
+// name), unexported fields and fields tagged "-" are
+// skipped, and the "omitempty" and "flatten" tag options
+// are honored. Maps must have string keys. Any value
+// within v that implements Marshaler is encoded by it.
+//
+// Marshal is a convenience for code that doesn't
+// use the code generator; generated methods are
+// considerably faster.
+func Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(Marshaler); ok {
+		return m.MarshalMsg(nil)
+	}
+	if v == nil {
+		return AppendNil(nil), nil
+	}
+	return appendReflect(nil, reflect.ValueOf(v))
+}
+
+// Unmarshal decodes the MessagePack-encoded data
+// in b into the value pointed to by v, which must be
+// a non-nil pointer. The mapping between MessagePack
+// and Go values is the inverse of Marshal. If v implements
+// Unmarshaler, its UnmarshalMsg method is used.
+//
+// Map keys that don't correspond to a struct
+// field are skipped. Any trailing bytes after the
+// first object in b are ignored.
+func Unmarshal(b []byte, v interface{}) error {
+	if u, ok := v.(Unmarshaler); ok {
+		_, err := u.UnmarshalMsg(b)
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &ErrUnsupportedType{T: reflect.TypeOf(v)}
+	}
+	_, err := readReflect(b, rv.Elem())
+	return err
+}
+
+// MarshalAny returns the MessagePack encoding of v
+// using the fastest method that v (or a pointer to it)
+// supports: its MarshalMsg method if it is a Marshaler,
+// its EncodeMsg method if it is only Encodable, and
+// otherwise reflection, as for Marshal. This gives
+// application code one entry point whether or not
+// a type has been run through the code generator.
+func MarshalAny(v interface{}) ([]byte, error) {
+	if v == nil {
+		return AppendNil(nil), nil
+	}
+	rv := reflect.ValueOf(v)
+	if m, ok := asMarshaler(rv); ok {
+		return m.MarshalMsg(nil)
+	}
+	if e, ok := asInterface(rv, encodableType); ok {
+		var buf bytes.Buffer
+		if err := Encode(&buf, e.(Encodable)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return appendReflect(nil, rv)
+}
+
+// UnmarshalAny decodes the first object in b into
+// the value pointed to by v, which must be a non-nil
+// pointer, and returns the bytes that follow it. Like
+// MarshalAny, it prefers generated code: v's UnmarshalMsg
+// method is used if it is an Unmarshaler, its DecodeMsg
+// method if it is only Decodable, and otherwise v is
+// decoded using reflection, as for Unmarshal.
+func UnmarshalAny(b []byte, v interface{}) ([]byte, error) {
+	switch u := v.(type) {
+	case Unmarshaler:
+		return u.UnmarshalMsg(b)
+	case Decodable:
+		rd := NewReaderFromBytes(b)
+		if err := u.DecodeMsg(rd); err != nil {
+			return b, err
+		}
+		return b[rd.Offset():], nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return b, &ErrUnsupportedType{T: reflect.TypeOf(v)}
+	}
+	return readReflect(b, rv.Elem())
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	encodableType   = reflect.TypeOf((*Encodable)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	extensionType   = reflect.TypeOf((*Extension)(nil)).Elem()
+	isEmptyType     = reflect.TypeOf((*interface{ MsgIsEmpty() bool })(nil)).Elem()
+	timeType        = reflect.TypeOf(time.Time{})
+)
+
+// reflField describes an encoded struct field
+type reflField struct {
+	name      string // encoded name
+	index     []int  // index for reflect.Value.FieldByIndex
+	omitempty bool
+}
+
+// reflStruct is the cached description of a struct type
+type reflStruct struct {
+	fields []reflField
+	byName map[string]int // index into fields
+}
+
+var reflCache = struct {
+	sync.RWMutex
+	m map[reflect.Type]*reflStruct
+}{m: make(map[reflect.Type]*reflStruct)}
+
+// getReflStruct returns the (cached)
+// field list for the struct type t
+func getReflStruct(t reflect.Type) *reflStruct {
+	reflCache.RLock()
+	rs, ok := reflCache.m[t]
+	reflCache.RUnlock()
+	if ok {
+		return rs
+	}
+	rs = &reflStruct{byName: make(map[string]int)}
+	rs.fields = appendReflFields(rs.fields, t, nil)
+	for i := range rs.fields {
+		rs.byName[rs.fields[i].name] = i
+	}
+	reflCache.Lock()
+	reflCache.m[t] = rs
+	reflCache.Unlock()
+	return rs
+}
+
+func appendReflFields(fields []reflField, t reflect.Type, index []int) []reflField {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue // unexported
+		}
+		tag := f.Tag.Get("msg")
+		if tag == "" {
+			tag = f.Tag.Get("msgpack")
+		}
+		parts := strings.Split(tag, ",")
+		if parts[0] == "-" {
+			continue
+		}
+		idx := make([]int, len(index)+1)
+		copy(idx, index)
+		idx[len(index)] = i
+
+		var omitempty, flatten bool
+		for _, p := range parts[1:] {
+			switch p {
+			case "omitempty":
+				omitempty = true
+			case "flatten":
+				flatten = true
+			}
+		}
+		if f.Anonymous && flatten && f.Type.Kind() == reflect.Struct {
+			fields = appendReflFields(fields, f.Type, idx)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue // unexported embedded type
+		}
+		name := parts[0]
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, reflField{name: name, index: idx, omitempty: omitempty})
+	}
+	return fields
+}
+
+// isEmptyValue reports whether v
+// should be left out under omitempty
+func isEmptyValue(v reflect.Value) bool {
+	if v.Type().Implements(isEmptyType) {
+		if v.Kind() != reflect.Ptr || !v.IsNil() {
+			return v.Interface().(interface{ MsgIsEmpty() bool }).MsgIsEmpty()
+		}
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Complex64, reflect.Complex128:
+		return v.Complex() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
+}
+
+// asMarshaler returns v as a Marshaler,
+// if it (or a pointer to it) is one
+func asMarshaler(v reflect.Value) (Marshaler, bool) {
+	i, ok := asInterface(v, marshalerType)
+	if !ok {
+		return nil, false
+	}
+	return i.(Marshaler), true
+}
+
+// asInterface returns v (or a pointer to it)
+// if it implements the interface type it
+func asInterface(v reflect.Value, it reflect.Type) (interface{}, bool) {
+	t := v.Type()
+	if t.Implements(it) {
+		if t.Kind() == reflect.Ptr && v.IsNil() {
+			return nil, false
+		}
+		return v.Interface(), true
+	}
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(it) {
+		if v.CanAddr() {
+			return v.Addr().Interface(), true
+		}
+		p := reflect.New(t)
+		p.Elem().Set(v)
+		return p.Interface(), true
+	}
+	return nil, false
+}
+
+func appendReflect(b []byte, v reflect.Value) ([]byte, error) {
+	if m, ok := asMarshaler(v); ok {
+		return m.MarshalMsg(b)
+	}
+	t := v.Type()
+	switch t {
+	case timeType:
+		return AppendTime(b, v.Interface().(time.Time)), nil
+	}
+	if t.Implements(extensionType) && (t.Kind() != reflect.Ptr || !v.IsNil()) {
+		return AppendExtension(b, v.Interface().(Extension))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return AppendBool(b, v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return AppendInt64(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return AppendUint64(b, v.Uint()), nil
+	case reflect.Float32:
+		return AppendFloat32(b, float32(v.Float())), nil
+	case reflect.Float64:
+		return AppendFloat64(b, v.Float()), nil
+	case reflect.Complex64:
+		return AppendComplex64(b, complex64(v.Complex())), nil
+	case reflect.Complex128:
+		return AppendComplex128(b, v.Complex()), nil
+	case reflect.String:
+		return AppendString(b, v.String()), nil
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return AppendNil(b), nil
+		}
+		return appendReflect(b, v.Elem())
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return AppendNil(b), nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return AppendBytes(b, v.Bytes()), nil
+		}
+		return appendReflectArray(b, v)
+
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			tmp := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(tmp), v)
+			return AppendBytes(b, tmp), nil
+		}
+		return appendReflectArray(b, v)
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return b, &ErrUnsupportedType{T: t}
+		}
+		if v.IsNil() {
+			return AppendNil(b), nil
+		}
+		b = AppendMapHeader(b, uint32(v.Len()))
+		var err error
+		for _, k := range v.MapKeys() {
+			b = AppendString(b, k.String())
+			b, err = appendReflect(b, v.MapIndex(k))
+			if err != nil {
+				return b, WrapError(err, k.String())
+			}
+		}
+		return b, nil
+
+	case reflect.Struct:
+		rs := getReflStruct(t)
+		var n uint32
+		for i := range rs.fields {
+			f := &rs.fields[i]
+			if !f.omitempty || !isEmptyValue(v.FieldByIndex(f.index)) {
+				n++
+			}
+		}
+		b = AppendMapHeader(b, n)
+		var err error
+		for i := range rs.fields {
+			f := &rs.fields[i]
+			fv := v.FieldByIndex(f.index)
+			if f.omitempty && isEmptyValue(fv) {
+				continue
+			}
+			b = AppendString(b, f.name)
+			b, err = appendReflect(b, fv)
+			if err != nil {
+				return b, WrapError(err, f.name)
+			}
+		}
+		return b, nil
+
+	default:
+		return b, &ErrUnsupportedType{T: t}
+	}
+}
+
+func appendReflectArray(b []byte, v reflect.Value) ([]byte, error) {
+	l := v.Len()
+	b = AppendArrayHeader(b, uint32(l))
+	var err error
+	for i := 0; i < l; i++ {
+		b, err = appendReflect(b, v.Index(i))
+		if err != nil {
+			return b, WrapError(err, i)
+		}
+	}
+	return b, nil
+}
+
+// readReflect decodes the next object
+// in b into v, which must be settable
+func readReflect(b []byte, v reflect.Value) (o []byte, err error) {
+	t := v.Type()
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(unmarshalerType) {
+		return v.Addr().Interface().(Unmarshaler).UnmarshalMsg(b)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if IsNil(b) {
+			o, err = ReadNilBytes(b)
+			if err == nil {
+				v.Set(reflect.Zero(t))
+			}
+			return
+		}
+	}
+
+	if t == timeType {
+		var tm time.Time
+		tm, o, err = ReadTimeBytes(b)
+		if err == nil {
+			v.Set(reflect.ValueOf(tm))
+		}
+		return
+	}
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(extensionType) {
+		return ReadExtensionBytes(b, v.Addr().Interface().(Extension))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		var x bool
+		x, o, err = ReadBoolBytes(b)
+		v.SetBool(x)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var x int64
+		x, o, err = ReadInt64Bytes(b)
+		if err == nil && v.OverflowInt(x) {
+			return b, IntOverflow{Value: x, FailedBitsize: t.Bits()}
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var x uint64
+		x, o, err = ReadUint64Bytes(b)
+		if err == nil && v.OverflowUint(x) {
+			return b, UintOverflow{Value: x, FailedBitsize: t.Bits()}
+		}
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		var x float64
+		x, o, err = ReadFloat64Bytes(b)
+		v.SetFloat(x)
+	case reflect.Complex64:
+		var x complex64
+		x, o, err = ReadComplex64Bytes(b)
+		v.SetComplex(complex128(x))
+	case reflect.Complex128:
+		var x complex128
+		x, o, err = ReadComplex128Bytes(b)
+		v.SetComplex(x)
+	case reflect.String:
+		var x string
+		x, o, err = ReadStringBytes(b)
+		v.SetString(x)
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return readReflect(b, v.Elem())
+
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			var x interface{}
+			x, o, err = ReadIntfBytes(b)
+			if err == nil {
+				v.Set(reflect.ValueOf(x))
+			}
+			return
+		}
+		// decode into the existing value, if possible
+		if e := v.Elem(); !v.IsNil() && e.Kind() == reflect.Ptr && !e.IsNil() {
+			return readReflect(b, e.Elem())
+		}
+		return b, &ErrUnsupportedType{T: t}
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			var x []byte
+			x, o, err = ReadBytesBytes(b, v.Bytes())
+			if err == nil {
+				v.SetBytes(x)
+			}
+			return
+		}
+		var sz uint32
+		sz, o, err = ReadArrayHeaderBytes(b)
+		if err != nil {
+			return
+		}
+		if v.Cap() >= int(sz) {
+			v.SetLen(int(sz))
+		} else {
+			v.Set(reflect.MakeSlice(t, int(sz), int(sz)))
+		}
+		for i := 0; i < int(sz); i++ {
+			o, err = readReflect(o, v.Index(i))
+			if err != nil {
+				return o, WrapError(err, i)
+			}
+		}
+
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			into := make([]byte, v.Len())
+			o, err = ReadExactBytes(b, into)
+			if err == nil {
+				reflect.Copy(v, reflect.ValueOf(into))
+			}
+			return
+		}
+		var sz uint32
+		sz, o, err = ReadArrayHeaderBytes(b)
+		if err != nil {
+			return
+		}
+		if int(sz) != v.Len() {
+			return o, ArrayError{Wanted: uint32(v.Len()), Got: sz}
+		}
+		for i := 0; i < int(sz); i++ {
+			o, err = readReflect(o, v.Index(i))
+			if err != nil {
+				return o, WrapError(err, i)
+			}
+		}
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return b, &ErrUnsupportedType{T: t}
+		}
+		var sz uint32
+		sz, o, err = ReadMapHeaderBytes(b)
+		if err != nil {
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		for i := uint32(0); i < sz; i++ {
+			var key []byte
+			key, o, err = ReadMapKeyZC(o)
+			if err != nil {
+				return
+			}
+			val := reflect.New(t.Elem()).Elem()
+			o, err = readReflect(o, val)
+			if err != nil {
+				return o, WrapError(err, string(key))
+			}
+			v.SetMapIndex(reflect.ValueOf(string(key)).Convert(t.Key()), val)
+		}
+
+	case reflect.Struct:
+		rs := getReflStruct(t)
+		var sz uint32
+		sz, o, err = ReadMapHeaderBytes(b)
+		if err != nil {
+			return
+		}
+		for i := uint32(0); i < sz; i++ {
+			var key []byte
+			key, o, err = ReadMapKeyZC(o)
+			if err != nil {
+				return
+			}
+			idx, ok := rs.byName[string(key)]
+			if !ok {
+				o, err = Skip(o)
+				if err != nil {
+					return
+				}
+				continue
+			}
+			f := &rs.fields[idx]
+			o, err = readReflect(o, v.FieldByIndex(f.index))
+			if err != nil {
+				return o, WrapError(err, f.name)
+			}
+		}
+
+	default:
+		return b, &ErrUnsupportedType{T: t}
+	}
+	return
+}
@@ -0,0 +1,280 @@
+package msgp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type reflInner struct {
+	Name  string            `msg:"name"`
+	Tags  []string          `msg:"tags,omitempty"`
+	Attrs map[string]uint16 `msg:"attrs"`
+}
+
+type reflBase struct {
+	ID int64 `msg:"id"`
+}
+
+type reflOuter struct {
+	reflBase `msg:",flatten"`
+	Ratio    float64     `msg:"ratio"`
+	Inner    reflInner   `msg:"inner"`
+	InnerPtr *reflInner  `msg:"inner_ptr"`
+	Data     []byte      `msg:"data"`
+	Fixed    [2]byte     `msg:"fixed"`
+	Ints     [3]int8     `msg:"ints"`
+	When     time.Time   `msg:"when"`
+	Any      interface{} `msg:"any"`
+	Raw      Raw         `msg:"raw"`
+	Legacy   string      `msgpack:"legacy"`
+	Skipped  string      `msg:"-"`
+	Empty    string      `msg:"empty,omitempty"`
+	Default  bool        // encoded by name
+	hidden   int
+	Nested   map[string][]reflInner `msg:"nested"`
+}
+
+func TestReflectRoundTrip(t *testing.T) {
+	in := reflOuter{
+		reflBase: reflBase{ID: -42},
+		Ratio:    0.5,
+		Inner:    reflInner{Name: "in", Tags: []string{"a", "b"}, Attrs: map[string]uint16{"x": 1}},
+		InnerPtr: &reflInner{Name: "ptr"},
+		Data:     []byte("data"),
+		Fixed:    [2]byte{1, 2},
+		Ints:     [3]int8{-1, 0, 1},
+		When:     time.Unix(1530707415, 500),
+		Any:      "hello",
+		Raw:      Raw(AppendInt(nil, 7)),
+		Legacy:   "old",
+		Skipped:  "skipped",
+		Default:  true,
+		Nested:   map[string][]reflInner{"k": {{Name: "n"}}},
+	}
+	b, err := Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// check the encoded keys
+	m, _, err := ReadMapStrIntfBytes(b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"id", "ratio", "inner", "inner_ptr", "data", "fixed", "legacy", "Default", "nested"} {
+		if _, ok := m[k]; !ok {
+			t.Errorf("missing key %q", k)
+		}
+	}
+	for _, k := range []string{"Skipped", "-", "empty", "hidden", "reflBase"} {
+		if _, ok := m[k]; ok {
+			t.Errorf("unexpected key %q", k)
+		}
+	}
+	if tags := m["inner_ptr"].(map[string]interface{}); len(tags) != 2 {
+		t.Errorf("omitempty field encoded: %v", tags)
+	}
+
+	var out reflOuter
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Skipped = ""
+	if !out.When.Equal(in.When) {
+		t.Errorf("time: got %v, want %v", out.When, in.When)
+	}
+	out.When = in.When
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestReflectMatchesGenericEncoding(t *testing.T) {
+	b, err := Marshal(map[string]interface{}{"a": []interface{}{int64(1), "two", nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AppendMapHeader(nil, 1)
+	want = AppendString(want, "a")
+	want = AppendArrayHeader(want, 3)
+	want = AppendInt64(want, 1)
+	want = AppendString(want, "two")
+	want = AppendNil(want)
+	if string(b) != string(want) {
+		t.Errorf("got %x, want %x", b, want)
+	}
+}
+
+func TestReflectNil(t *testing.T) {
+	out := reflInner{Tags: []string{"x"}, Attrs: map[string]uint16{"x": 1}}
+	b := AppendMapHeader(nil, 2)
+	b = AppendString(b, "tags")
+	b = AppendNil(b)
+	b = AppendString(b, "attrs")
+	b = AppendNil(b)
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Tags != nil || out.Attrs != nil {
+		t.Errorf("expected nil fields; got %+v", out)
+	}
+
+	var p *reflInner
+	b, err := Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsNil(b) {
+		t.Errorf("expected nil; got %x", b)
+	}
+}
+
+func TestReflectSkipsUnknownFields(t *testing.T) {
+	b := AppendMapHeader(nil, 3)
+	b = AppendString(b, "unknown")
+	b = AppendArrayHeader(b, 2)
+	b = AppendInt(b, 1)
+	b = AppendString(b, "x")
+	b = AppendString(b, "name")
+	b = AppendString(b, "known")
+	b = AppendString(b, "other")
+	b = AppendMapHeader(b, 0)
+
+	var out reflInner
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "known" {
+		t.Errorf("got name %q", out.Name)
+	}
+}
+
+func TestReflectErrors(t *testing.T) {
+	var in reflInner
+	if err := Unmarshal(nil, in); err == nil {
+		t.Error("expected an error for a non-pointer")
+	}
+	if _, err := Marshal(map[int]string{1: "x"}); err == nil {
+		t.Error("expected an error for a non-string map key")
+	}
+	if _, err := Marshal(make(chan int)); err == nil {
+		t.Error("expected an error for a channel")
+	}
+
+	b := AppendMapHeader(nil, 1)
+	b = AppendString(b, "attrs")
+	b = AppendMapHeader(b, 1)
+	b = AppendString(b, "x")
+	b = AppendInt(b, 70000)
+	err := Unmarshal(b, &in)
+	if _, ok := Cause(err).(UintOverflow); !ok {
+		t.Fatalf("expected UintOverflow; got %v", err)
+	}
+	if want := "attrs/x"; !containsCtx(err.Error(), want) {
+		t.Errorf("error %q does not mention %q", err, want)
+	}
+
+	var arr [3]int8
+	if err := Unmarshal(AppendArrayHeader(nil, 2), &arr); err == nil {
+		t.Error("expected an error for an array of the wrong size")
+	}
+	var i8 int8
+	if err := Unmarshal(AppendInt(nil, 200), &i8); err == nil {
+		t.Error("expected an error for int8 overflow")
+	}
+}
+
+func containsCtx(s, ctx string) bool {
+	return len(s) >= len(ctx) && s[len(s)-len(ctx):] == ctx
+}
+
+func TestReflectUsesMarshaler(t *testing.T) {
+	n := new(Number)
+	n.AsFloat64(3.5)
+	b, err := Marshal(struct{ N Number }{*n})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct{ N Number }
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := out.N.Float(); !ok || f != 3.5 {
+		t.Errorf("got %v", out.N)
+	}
+}
+
+// streamInt only has the streaming methods
+type streamInt struct{ v int64 }
+
+func (s *streamInt) EncodeMsg(w *Writer) error { return w.WriteInt64(s.v) }
+func (s *streamInt) DecodeMsg(r *Reader) (err error) {
+	s.v, err = r.ReadInt64()
+	return
+}
+
+func TestMarshalAny(t *testing.T) {
+	// Marshaler, Encodable, and reflection
+	n := new(Number)
+	n.AsInt(-3)
+	for _, tt := range []struct {
+		in   interface{}
+		want []byte
+	}{
+		{n, AppendInt64(nil, -3)},
+		{*n, AppendInt64(nil, -3)},
+		{&streamInt{v: 9}, AppendInt64(nil, 9)},
+		{streamInt{v: 9}, AppendInt64(nil, 9)},
+		{reflBase{ID: 1}, AppendInt64(AppendString(AppendMapHeader(nil, 1), "id"), 1)},
+		{nil, AppendNil(nil)},
+	} {
+		b, err := MarshalAny(tt.in)
+		if err != nil {
+			t.Errorf("%T: %v", tt.in, err)
+		} else if !reflect.DeepEqual(b, tt.want) {
+			t.Errorf("%T: got %x, want %x", tt.in, b, tt.want)
+		}
+	}
+
+	b := AppendInt64(AppendInt64(nil, 5), 6)
+	var out Number
+	o, err := UnmarshalAny(b, &out)
+	if i, _ := out.Int(); err != nil || i != 5 || len(o) != 1 {
+		t.Errorf("Unmarshaler: got %v, %x, %v", out, o, err)
+	}
+	var si streamInt
+	o, err = UnmarshalAny(o, &si)
+	if err != nil || si.v != 6 || len(o) != 0 {
+		t.Errorf("Decodable: got %d, %x, %v", si.v, o, err)
+	}
+	b, _ = Marshal(reflBase{ID: 7})
+	var rb reflBase
+	if o, err = UnmarshalAny(append(b, 0xc0), &rb); err != nil || rb.ID != 7 || len(o) != 1 {
+		t.Errorf("reflection: got %+v, %x, %v", rb, o, err)
+	}
+	if _, err = UnmarshalAny(AppendString(nil, "x"), &si); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err = UnmarshalAny(b, rb); err == nil {
+		t.Error("expected an error for a non-pointer")
+	}
+}
+
+func BenchmarkReflectMarshal(b *testing.B) {
+	v := reflInner{Name: "bench", Tags: []string{"a", "b", "c"}, Attrs: map[string]uint16{"x": 1, "y": 2}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Marshal(&v)
+	}
+}
+
+func BenchmarkReflectUnmarshal(b *testing.B) {
+	v := reflInner{Name: "bench", Tags: []string{"a", "b", "c"}, Attrs: map[string]uint16{"x": 1, "y": 2}}
+	data, _ := Marshal(&v)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		Unmarshal(data, &v)
+	}
+}
@@ -0,0 +1,135 @@
+package msgp
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultRegistry is the Registry used by
+// RegisterExtension, and by the methods that
+// decode `interface{}` values when no other
+// Registry has been provided.
+var DefaultRegistry = NewRegistry()
+
+// Registry is a set of extensions that can be
+// initialized and returned by the methods that
+// decode `interface{}` values (ReadIntf and
+// ReadIntfBytes) and translated to JSON. It is
+// safe for concurrent use.
+//
+// A Reader uses DefaultRegistry unless another
+// one is set with SetRegistry. Registries are
+// independent: a Reader with its own Registry
+// doesn't see the extensions in DefaultRegistry.
+type Registry struct {
+	mu   sync.RWMutex
+	exts map[int8]func() Extension
+	json map[int8]ExtensionJSONFunc
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		exts: make(map[int8]func() Extension),
+		json: make(map[int8]ExtensionJSONFunc),
+	}
+}
+
+// ExtensionJSONFunc renders the data of an
+// extension as JSON: it should append a single
+// valid JSON value to b and return the result.
+type ExtensionJSONFunc func(b []byte, data []byte) ([]byte, error)
+
+// RegisterExtensionJSON sets the function that
+// renders extensions of type typ as JSON in
+// DefaultRegistry; see Registry.RegisterJSON.
+func RegisterExtensionJSON(typ int8, f ExtensionJSONFunc) {
+	DefaultRegistry.RegisterJSON(typ, f)
+}
+
+// RegisterJSON sets the function that renders
+// extensions of type typ when they are translated
+// to JSON, e.g. by CopyToJSON. It takes precedence
+// over the JSON encoding of an extension registered
+// with Register, and the type doesn't have to be
+// registered at all. A nil f removes the function.
+// It has no effect on time.Time extensions, which
+// are always written as times (see ToJSONOptions.Time).
+//
+// Without a function, MessagePack timestamps are
+// written as times as well, registered extensions
+// with encoding/json, and other extensions as an
+// object holding their type and data, e.g.
+//
+//	{"type":16,"data":"AAECAwQFBgcICQoLDA0ODw=="}
+func (r *Registry) RegisterJSON(typ int8, f ExtensionJSONFunc) {
+	r.mu.Lock()
+	if f == nil {
+		delete(r.json, typ)
+	} else {
+		r.json[typ] = f
+	}
+	r.mu.Unlock()
+}
+
+// LookupJSON returns the function set with
+// RegisterJSON for the extension type typ, if any.
+func (r *Registry) LookupJSON(typ int8) (f ExtensionJSONFunc, ok bool) {
+	r.mu.RLock()
+	f, ok = r.json[typ]
+	r.mu.RUnlock()
+	return
+}
+
+// Register registers the extension type typ;
+// f should return a newly-initialized zero
+// value of the extension. It panics under the
+// same conditions as RegisterExtension.
+func (r *Registry) Register(typ int8, f func() Extension) {
+	switch typ {
+	case Complex64Extension, Complex128Extension, TimeExtension:
+		panic(fmt.Sprint("msgp: forbidden extension type:", typ))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.exts[typ]; ok {
+		panic(fmt.Sprint("msgp: RegisterExtension() called with typ", typ, "more than once"))
+	}
+	r.exts[typ] = f
+}
+
+// Unregister removes the extension type typ,
+// if it is registered.
+func (r *Registry) Unregister(typ int8) {
+	r.mu.Lock()
+	delete(r.exts, typ)
+	r.mu.Unlock()
+}
+
+// Lookup returns the function registered
+// for the extension type typ, if any.
+func (r *Registry) Lookup(typ int8) (f func() Extension, ok bool) {
+	r.mu.RLock()
+	f, ok = r.exts[typ]
+	r.mu.RUnlock()
+	return
+}
+
+// ReadIntfBytes is like the ReadIntfBytes
+// function, but it uses the extensions in r.
+func (r *Registry) ReadIntfBytes(b []byte) (i interface{}, o []byte, err error) {
+	return readIntfBytes(b, r)
+}
+
+// SetRegistry sets the Registry used to
+// decode extensions in ReadIntf and WriteToJSON.
+// A nil Registry means DefaultRegistry.
+func (m *Reader) SetRegistry(r *Registry) { m.reg = r }
+
+// registry returns the Registry used by m
+func (m *Reader) registry() *Registry {
+	if m.reg != nil {
+		return m.reg
+	}
+	return DefaultRegistry
+}
@@ -0,0 +1,104 @@
+package msgp
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+// regExt is an extension used to test registries
+type regExt struct{ data []byte }
+
+func (r *regExt) ExtensionType() int8            { return 77 }
+func (r *regExt) Len() int                       { return len(r.data) }
+func (r *regExt) MarshalBinaryTo(b []byte) error { copy(b, r.data); return nil }
+func (r *regExt) UnmarshalBinary(b []byte) error {
+	r.data = append(r.data[:0], b...)
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(77, func() Extension { return new(regExt) })
+
+	b, err := AppendExtension(nil, &regExt{data: []byte("hi")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// DefaultRegistry doesn't know about type 77
+	v, _, err := ReadIntfBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.(*RawExtension); !ok {
+		t.Errorf("ReadIntfBytes returned %T", v)
+	}
+
+	v, _, err = reg.ReadIntfBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := v.(*regExt); !ok || string(e.data) != "hi" {
+		t.Errorf("Registry.ReadIntfBytes returned %#v", v)
+	}
+
+	r := NewReaderWithOptions(bytes.NewReader(b), ReaderOptions{Registry: reg})
+	v, err = r.ReadIntf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.(*regExt); !ok {
+		t.Errorf("ReadIntf returned %T", v)
+	}
+
+	r.Reset(bytes.NewReader(b))
+	r.SetRegistry(nil)
+	v, err = r.ReadIntf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.(*RawExtension); !ok {
+		t.Errorf("ReadIntf with DefaultRegistry returned %T", v)
+	}
+
+	reg.Unregister(77)
+	if _, ok := reg.Lookup(77); ok {
+		t.Error("type 77 is still registered")
+	}
+	// it can be registered again
+	reg.Register(77, func() Extension { return new(regExt) })
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	reg := NewRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(typ int8) {
+			defer wg.Done()
+			reg.Register(typ, func() Extension { return new(regExt) })
+			for j := 0; j < 100; j++ {
+				reg.Lookup(typ)
+				reg.Lookup(typ + 1)
+			}
+			reg.Unregister(typ)
+		}(int8(10 + i))
+	}
+	wg.Wait()
+}
+
+func TestRegistryPanics(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(20, func() Extension { return new(regExt) })
+	for _, typ := range []int8{20, TimeExtension} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering type %d didn't panic", typ)
+				}
+			}()
+			reg.Register(typ, func() Extension { return new(regExt) })
+		}()
+	}
+}
@@ -0,0 +1,375 @@
+package msgp
+
+import (
+	"sync"
+	"time"
+)
+
+// The following functions convert time.Time
+// to and from the alternative representations
+// that the code generator can select for time.Time
+// fields with a tag option, e.g.
+//
+//	type Event struct {
+//		At time.Time `msg:"at,unixmilli"`
+//	}
+//
+// Times produced from integer representations
+// have their location set to time.Local, just
+// like those returned by ReadTime.
+
+// TimeToUnix returns t as seconds since the Unix epoch.
+func TimeToUnix(t time.Time) int64 { return t.Unix() }
+
+// UnixToTime returns the time corresponding
+// to sec seconds since the Unix epoch.
+func UnixToTime(sec int64) time.Time { return time.Unix(sec, 0) }
+
+// TimeToUnixMilli returns t as milliseconds since the Unix epoch.
+func TimeToUnixMilli(t time.Time) int64 {
+	return t.Unix()*1e3 + int64(t.Nanosecond())/1e6
+}
+
+// UnixMilliToTime returns the time corresponding
+// to ms milliseconds since the Unix epoch.
+func UnixMilliToTime(ms int64) time.Time {
+	sec, rem := ms/1e3, ms%1e3
+	if rem < 0 {
+		sec--
+		rem += 1e3
+	}
+	return time.Unix(sec, rem*1e6)
+}
+
+// TimeToUnixMicro returns t as microseconds since the Unix epoch.
+func TimeToUnixMicro(t time.Time) int64 {
+	return t.Unix()*1e6 + int64(t.Nanosecond())/1e3
+}
+
+// UnixMicroToTime returns the time corresponding
+// to us microseconds since the Unix epoch.
+func UnixMicroToTime(us int64) time.Time {
+	sec, rem := us/1e6, us%1e6
+	if rem < 0 {
+		sec--
+		rem += 1e6
+	}
+	return time.Unix(sec, rem*1e3)
+}
+
+// TimeToUnixNano returns t as nanoseconds since the Unix epoch.
+// The result is undefined if t cannot be represented in an int64
+// (see time.Time.UnixNano).
+func TimeToUnixNano(t time.Time) int64 { return t.UnixNano() }
+
+// UnixNanoToTime returns the time corresponding
+// to ns nanoseconds since the Unix epoch.
+func UnixNanoToTime(ns int64) time.Time { return time.Unix(0, ns) }
+
+// TimeToRFC3339 returns t formatted as an RFC 3339
+// string with sub-second precision. It returns an
+// error if the year of t is outside of [0,9999].
+func TimeToRFC3339(t time.Time) (string, error) {
+	b, err := t.MarshalText()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// RFC3339ToTime parses an RFC 3339 string,
+// with or without sub-second precision. The
+// offset in the string is preserved.
+func RFC3339ToTime(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// TimeFormat selects how a Writer (see SetTimeFormat)
+// or AppendTimeFormat represents a time.Time, using
+// the same representations as the tag options above.
+// The integer formats drop the precision below their
+// unit, and all of the formats other than TimeFormatExt
+// can be read by any MessagePack implementation.
+type TimeFormat uint8
+
+const (
+	// TimeFormatExt writes times with the package's
+	// time extension, or as MessagePack timestamps if
+	// the Writer's Timestamps option is set. This is
+	// the default.
+	TimeFormatExt TimeFormat = iota
+
+	// TimeFormatUnix, TimeFormatUnixMilli and
+	// TimeFormatUnixMicro write times as 'int's
+	// counting seconds, milliseconds and
+	// microseconds, respectively, since the
+	// Unix epoch.
+	TimeFormatUnix
+	TimeFormatUnixMilli
+	TimeFormatUnixMicro
+
+	// TimeFormatRFC3339 writes times as RFC 3339
+	// strings with sub-second precision and the
+	// time's offset from UTC.
+	TimeFormatRFC3339
+
+	// TimeFormatZoned writes times with the time
+	// extension, followed by the time's offset from
+	// UTC and the name of its location (see
+	// AppendTimeZoned). A Reader whose TimeFormat is
+	// TimeFormatZoned restores the location of the
+	// times it reads; other Readers read them as
+	// ordinary times in time.Local.
+	TimeFormatZoned
+)
+
+// unixTime returns t as an integer of the
+// unit of f, which is one of the Unix formats
+func (f TimeFormat) unixTime(t time.Time) int64 {
+	switch f {
+	case TimeFormatUnix:
+		return TimeToUnix(t)
+	case TimeFormatUnixMilli:
+		return TimeToUnixMilli(t)
+	default:
+		return TimeToUnixMicro(t)
+	}
+}
+
+// fromUnix is the inverse of unixTime
+func (f TimeFormat) fromUnix(n int64) time.Time {
+	switch f {
+	case TimeFormatUnix:
+		return UnixToTime(n)
+	case TimeFormatUnixMilli:
+		return UnixMilliToTime(n)
+	default:
+		return UnixMicroToTime(n)
+	}
+}
+
+func (f TimeFormat) isUnix() bool {
+	return f >= TimeFormatUnix && f <= TimeFormatUnixMicro
+}
+
+// AppendTimeFormat appends t to b in format f.
+// With TimeFormatExt, it is the same as AppendTime.
+// It returns an error if f is TimeFormatRFC3339 and
+// the year of t is outside of [0,9999].
+func AppendTimeFormat(b []byte, t time.Time, f TimeFormat) ([]byte, error) {
+	switch {
+	case f.isUnix():
+		return AppendInt64(b, f.unixTime(t)), nil
+	case f == TimeFormatRFC3339:
+		s, err := TimeToRFC3339(t)
+		if err != nil {
+			return b, err
+		}
+		return AppendString(b, s), nil
+	case f == TimeFormatZoned:
+		return AppendTimeZoned(b, t), nil
+	default:
+		return AppendTime(b, t), nil
+	}
+}
+
+// ReadTimeFormatBytes is like ReadTimeBytes, but it
+// also accepts the representations of format f: if f
+// is one of the integer formats, an integer is read as
+// a number of f's units since the Unix epoch, and for
+// any format other than TimeFormatExt, a string is
+// parsed as an RFC 3339 time. If f is TimeFormatZoned,
+// times written by AppendTimeZoned keep their location.
+func ReadTimeFormatBytes(b []byte, f TimeFormat) (t time.Time, o []byte, err error) {
+	if f != TimeFormatExt && len(b) > 0 {
+		switch getType(b[0]) {
+		case IntType, UintType:
+			if f.isUnix() {
+				var n int64
+				n, o, err = ReadInt64Bytes(b)
+				if err != nil {
+					return
+				}
+				return f.fromUnix(n), o, nil
+			}
+		case StrType:
+			var s []byte
+			s, o, err = ReadStringZC(b)
+			if err != nil {
+				return
+			}
+			t, err = RFC3339ToTime(string(s))
+			if err != nil {
+				return t, b, err
+			}
+			return t, o, nil
+		}
+	}
+	return readTimeBytes(b, f == TimeFormatZoned)
+}
+
+// TimeReprs is a set of representations of
+// times, which selects the ones accepted by
+// ReadTimeFlexible and ReadTimeFlexibleBytes.
+type TimeReprs uint8
+
+const (
+	// TimeReprExt is the package's
+	// time extension (see AppendTime).
+	TimeReprExt TimeReprs = 1 << iota
+
+	// TimeReprTimestamp is the MessagePack
+	// timestamp extension (type -1) in any
+	// of its forms.
+	TimeReprTimestamp
+
+	// TimeReprUnix is an 'int' or 'uint'
+	// counting seconds since the Unix epoch.
+	TimeReprUnix
+
+	// TimeReprRFC3339 is a 'str' holding
+	// an RFC 3339 time.
+	TimeReprRFC3339
+
+	// TimeReprAny is all of the above.
+	TimeReprAny = TimeReprExt | TimeReprTimestamp | TimeReprUnix | TimeReprRFC3339
+)
+
+// hasExt returns whether r includes
+// the extension type typ
+func (r TimeReprs) hasExt(typ int8) bool {
+	switch typ {
+	case TimeExtension:
+		return r&TimeReprExt != 0
+	case TimestampExtension:
+		return r&TimeReprTimestamp != 0
+	}
+	return false
+}
+
+// zonedTimeSize is the size of the payload of
+// a zoned time without the location's name
+const zonedTimeSize = 16
+
+// maxZoneName is the longest location name that
+// fits in the payload of an 'ext 8' zoned time
+const maxZoneName = 255 - zonedTimeSize
+
+// AppendTimeZoned appends t to b with the time
+// extension, like AppendTime, but keeps the time's
+// zone: the 12 bytes of the time are followed by
+// a big-endian 32-bit integer holding the offset
+// from UTC in seconds, and then by the name of the
+// time's location, e.g. "America/New_York". For
+// time.Local, which has no portable name, and for
+// names that are too long, the zone's abbreviation
+// (e.g. "EST") is written instead.
+//
+// Times appended this way can be read by
+// ReadTimeBytes, which returns them in time.Local,
+// and ReadTimeFormatBytes with TimeFormatZoned,
+// which restores their location.
+func AppendTimeZoned(b []byte, t time.Time) []byte {
+	name, offset := zoneOf(t)
+	o, n := ensure(b, 3+zonedTimeSize)
+	putZoned(o[n:], t, len(name), offset)
+	return append(o, name...)
+}
+
+// zoneOf returns the name and offset
+// that AppendTimeZoned writes for t
+func zoneOf(t time.Time) (string, int) {
+	abbr, offset := t.Zone()
+	name := t.Location().String()
+	if t.Location() == time.Local || len(name) > maxZoneName {
+		name = abbr
+		if len(name) > maxZoneName {
+			name = name[:maxZoneName]
+		}
+	}
+	return name, offset
+}
+
+// putZoned writes the zoned time extension
+// for t into b, up to the location's name,
+// which is namelen bytes long
+func putZoned(b []byte, t time.Time, namelen int, offset int) {
+	b[0] = mext8
+	b[1] = byte(zonedTimeSize + namelen)
+	b[2] = TimeExtension
+	putUnix(b[3:], t.Unix(), int32(t.Nanosecond()))
+	big.PutUint32(b[15:], uint32(int32(offset)))
+}
+
+// timeFromExt decodes the payload of a time
+// extension written by AppendTime or
+// AppendTimeZoned. The time is in time.Local
+// unless 'zoned' is set and the payload
+// holds a zone.
+func timeFromExt(p []byte, zoned bool) time.Time {
+	sec, nsec := getUnix(p)
+	t := time.Unix(sec, int64(nsec))
+	if !zoned || len(p) < zonedTimeSize {
+		return t.Local()
+	}
+	offset := int(int32(big.Uint32(p[12:])))
+	name := string(p[zonedTimeSize:])
+	if name == "UTC" && offset == 0 {
+		return t.UTC()
+	}
+	// prefer the named location, so that times
+	// computed from the result follow its rules,
+	// as long as it agrees with the stored offset
+	if loc := loadZone(name); loc != nil {
+		if _, off := t.In(loc).Zone(); off == offset {
+			return t.In(loc)
+		}
+	}
+	return t.In(time.FixedZone(name, offset))
+}
+
+// zoneCache holds the results of
+// time.LoadLocation by name, and nil
+// for names that could not be loaded
+var zoneCache sync.Map
+
+// maxZoneCache bounds the number of names
+// that zoneCache remembers, since they come
+// from untrusted input. Once it is full, other
+// names aren't looked up at all, and their
+// times get a fixed zone.
+const maxZoneCache = 1024
+
+var zoneCacheLen struct {
+	sync.Mutex
+	n int
+}
+
+// loadZone returns the location called
+// 'name', or nil if there is none
+func loadZone(name string) *time.Location {
+	if name == "" {
+		return nil
+	}
+	if loc, ok := zoneCache.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	zoneCacheLen.Lock()
+	full := zoneCacheLen.n >= maxZoneCache
+	zoneCacheLen.Unlock()
+	if full {
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || loc.String() != name {
+		loc = nil
+	}
+	zoneCacheLen.Lock()
+	if zoneCacheLen.n < maxZoneCache {
+		if _, loaded := zoneCache.LoadOrStore(name, loc); !loaded {
+			zoneCacheLen.n++
+		}
+	}
+	zoneCacheLen.Unlock()
+	return loc
+}
@@ -0,0 +1,293 @@
+package msgp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTimeUnixConversions(t *testing.T) {
+	times := []time.Time{
+		time.Unix(0, 0),
+		time.Unix(1530707415, 123000000),
+		time.Unix(-1, 999000000), // just before the epoch
+		time.Unix(-1530707415, 1000000),
+	}
+	for _, tm := range times {
+		if got := UnixToTime(TimeToUnix(tm)); !got.Equal(time.Unix(tm.Unix(), 0)) {
+			t.Errorf("unix: %v -> %v", tm, got)
+		}
+		ms := TimeToUnixMilli(tm)
+		if ms != tm.UnixNano()/1e6 {
+			t.Errorf("unixmilli: %v -> %d", tm, ms)
+		}
+		if got := UnixMilliToTime(ms); !got.Equal(tm) {
+			t.Errorf("unixmilli: %v -> %d -> %v", tm, ms, got)
+		}
+		us := TimeToUnixMicro(tm)
+		if us != tm.UnixNano()/1e3 {
+			t.Errorf("unixmicro: %v -> %d", tm, us)
+		}
+		if got := UnixMicroToTime(us); !got.Equal(tm) {
+			t.Errorf("unixmicro: %v -> %d -> %v", tm, us, got)
+		}
+		if got := UnixNanoToTime(TimeToUnixNano(tm)); !got.Equal(tm) {
+			t.Errorf("unixnano: %v -> %v", tm, got)
+		}
+	}
+}
+
+func TestTimeRFC3339Conversions(t *testing.T) {
+	tm := time.Date(2018, 7, 4, 12, 30, 15, 120000000, time.FixedZone("", -7*3600))
+	s, err := TimeToRFC3339(tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "2018-07-04T12:30:15.12-07:00" {
+		t.Errorf("got %q", s)
+	}
+	out, err := RFC3339ToTime(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(tm) {
+		t.Errorf("got %v, want %v", out, tm)
+	}
+	if _, offset := out.Zone(); offset != -7*3600 {
+		t.Errorf("offset %d was not preserved", offset)
+	}
+	if _, err := RFC3339ToTime("2018-07-04"); err == nil {
+		t.Error("expected an error for a date without a time")
+	}
+	if _, err := TimeToRFC3339(time.Date(-1, 1, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("expected an error for a negative year")
+	}
+}
+
+func TestTimeFormats(t *testing.T) {
+	tm := time.Date(2018, 7, 4, 12, 30, 15, 123456789, time.FixedZone("", -7*3600))
+	cases := []struct {
+		f    TimeFormat
+		want []byte
+		back time.Time
+	}{
+		{TimeFormatExt, AppendTime(nil, tm), tm},
+		{TimeFormatUnix, AppendInt64(nil, 1530732615), time.Unix(1530732615, 0)},
+		{TimeFormatUnixMilli, AppendInt64(nil, 1530732615123), time.Unix(1530732615, 123000000)},
+		{TimeFormatUnixMicro, AppendInt64(nil, 1530732615123456), time.Unix(1530732615, 123456000)},
+		{TimeFormatRFC3339, AppendString(nil, "2018-07-04T12:30:15.123456789-07:00"), tm},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		wr := NewWriterWithOptions(&buf, WriterOptions{TimeFormat: c.f})
+		if err := wr.WriteTime(tm); err != nil {
+			t.Fatalf("format %d: %v", c.f, err)
+		}
+		wr.Flush()
+		if !bytes.Equal(buf.Bytes(), c.want) {
+			t.Errorf("format %d: wrote %x, want %x", c.f, buf.Bytes(), c.want)
+		}
+		b, err := AppendTimeFormat(nil, tm, c.f)
+		if err != nil || !bytes.Equal(b, c.want) {
+			t.Errorf("format %d: appended %x, %v", c.f, b, err)
+		}
+
+		rd := NewReaderWithOptions(bytes.NewReader(c.want), ReaderOptions{TimeFormat: c.f})
+		got, err := rd.ReadTime()
+		if err != nil || !got.Equal(c.back) {
+			t.Errorf("format %d: read %v, %v; want %v", c.f, got, err, c.back)
+		}
+		got, rest, err := ReadTimeFormatBytes(c.want, c.f)
+		if err != nil || len(rest) != 0 || !got.Equal(c.back) {
+			t.Errorf("format %d: read %v, %v from bytes; want %v", c.f, got, err, c.back)
+		}
+		// extensions are always accepted
+		got, _, err = ReadTimeFormatBytes(AppendTimestamp(nil, tm), c.f)
+		if err != nil || !got.Equal(tm) {
+			t.Errorf("format %d: read timestamp %v, %v", c.f, got, err)
+		}
+	}
+
+	// integers are only times in the integer formats
+	if _, _, err := ReadTimeFormatBytes(AppendInt64(nil, 1), TimeFormatRFC3339); err == nil {
+		t.Error("expected an error reading an int in TimeFormatRFC3339")
+	}
+	if _, err := NewReader(bytes.NewReader(AppendInt64(nil, 1))).ReadTime(); err == nil {
+		t.Error("expected an error reading an int in TimeFormatExt")
+	}
+	if _, err := AppendTimeFormat(nil, time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC), TimeFormatRFC3339); err == nil {
+		t.Error("expected an error for the year 10000")
+	}
+}
+
+func TestTimeZoned(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tm := time.Date(2021, 3, 14, 1, 30, 0, 500, ny)
+	for _, in := range []time.Time{
+		tm,
+		tm.In(time.FixedZone("XYZ", 5*3600+1800)),
+		tm.UTC(),
+		tm.Local(),
+	} {
+		b := AppendTimeZoned(nil, in)
+		if NextType(b) != TimeType {
+			t.Errorf("%v: NextType is %s", in, NextType(b))
+		}
+		var buf bytes.Buffer
+		wr := NewWriterWithOptions(&buf, WriterOptions{TimeFormat: TimeFormatZoned})
+		wr.WriteTime(in)
+		wr.Flush()
+		if !bytes.Equal(buf.Bytes(), b) {
+			t.Errorf("%v: wrote %x, appended %x", in, buf.Bytes(), b)
+		}
+
+		// the location is only restored
+		// with TimeFormatZoned
+		got, o, err := ReadTimeBytes(b)
+		if err != nil || len(o) != 0 || !got.Equal(in) || got.Location() != time.Local {
+			t.Errorf("%v: ReadTimeBytes returned %v, %v", in, got, err)
+		}
+		got, o, err = ReadTimeFormatBytes(b, TimeFormatZoned)
+		if err != nil || len(o) != 0 {
+			t.Fatalf("%v: %v", in, err)
+		}
+		rd := NewReaderWithOptions(bytes.NewReader(b), ReaderOptions{TimeFormat: TimeFormatZoned})
+		got2, err := rd.ReadTime()
+		if err != nil {
+			t.Fatalf("%v: %v", in, err)
+		}
+		for _, g := range []time.Time{got, got2} {
+			name, off := g.Zone()
+			wname, woff := in.Zone()
+			if !g.Equal(in) || name != wname || off != woff {
+				t.Errorf("got %v, want %v", g, in)
+			}
+		}
+		if in.Location() == ny && got.Location().String() != "America/New_York" {
+			t.Errorf("got location %q", got.Location())
+		}
+		if in.Location() == ny {
+			// zone rules still apply to derived times
+			if _, off := got.Add(time.Hour).Zone(); off != -4*3600 {
+				t.Errorf("got offset %d an hour later", off)
+			}
+		}
+
+		// the reader still reads plain times
+		if _, err := NewReaderWithOptions(bytes.NewReader(AppendTime(nil, in)), ReaderOptions{TimeFormat: TimeFormatZoned}).ReadTime(); err != nil {
+			t.Error(err)
+		}
+		if _, err := Skip(b); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// an offset that disagrees with the
+	// named location is not replaced
+	b := AppendTimeZoned(nil, tm.In(time.FixedZone("America/New_York", 3600)))
+	got, _, err := ReadTimeFormatBytes(b, TimeFormatZoned)
+	if _, off := got.Zone(); err != nil || off != 3600 {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if _, _, err = ReadTimeFormatBytes(b[:len(b)-1], TimeFormatZoned); err != ErrShortBytes {
+		t.Errorf("got error %v for truncated input", err)
+	}
+}
+
+func TestZoneCacheFull(t *testing.T) {
+	const name = "Asia/Kathmandu"
+	if _, err := time.LoadLocation(name); err != nil {
+		t.Skip(err)
+	}
+	if _, ok := zoneCache.Load(name); ok {
+		t.Skip(name + " is already cached")
+	}
+	zoneCacheLen.Lock()
+	n := zoneCacheLen.n
+	zoneCacheLen.n = maxZoneCache
+	zoneCacheLen.Unlock()
+	defer func() {
+		zoneCacheLen.Lock()
+		zoneCacheLen.n = n
+		zoneCacheLen.Unlock()
+	}()
+
+	// once the cache is full, new names
+	// aren't looked up, and the time
+	// keeps its offset in a fixed zone
+	if loc := loadZone(name); loc != nil {
+		t.Errorf("loaded %v with a full cache", loc)
+	}
+	loc, _ := time.LoadLocation(name)
+	tm := time.Date(2021, 3, 14, 1, 30, 0, 0, loc)
+	got, _, err := ReadTimeFormatBytes(AppendTimeZoned(nil, tm), TimeFormatZoned)
+	if _, off := got.Zone(); err != nil || !got.Equal(tm) || off != 5*3600+2700 {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
+
+func TestReadTimeFlexible(t *testing.T) {
+	tm := time.Unix(1700000000, 0)
+	rfc, _ := TimeToRFC3339(tm.UTC())
+	reprs := []struct {
+		r TimeReprs
+		b []byte
+	}{
+		{TimeReprExt, AppendTime(nil, tm)},
+		{TimeReprExt, AppendTimeZoned(nil, tm.UTC())},
+		{TimeReprTimestamp, AppendTimestamp(nil, tm)},
+		{TimeReprTimestamp, AppendTimestamp(nil, time.Unix(1<<40, 1))},
+		{TimeReprUnix, AppendInt64(nil, tm.Unix())},
+		{TimeReprUnix, AppendUint64(nil, uint64(tm.Unix()))},
+		{TimeReprRFC3339, AppendString(nil, rfc)},
+	}
+	for i, tt := range reprs {
+		want, _, err := ReadTimestampBytes(tt.b)
+		if err != nil {
+			want = tm
+		}
+		for _, accept := range []TimeReprs{tt.r, TimeReprAny, TimeReprAny &^ tt.r} {
+			b := append(tt.b, 0xc0)
+			got, o, err := ReadTimeFlexibleBytes(b, accept)
+			rd := NewReader(bytes.NewReader(b))
+			rgot, rerr := rd.ReadTimeFlexible(accept)
+			if accept&tt.r == 0 {
+				if err == nil || rerr == nil {
+					t.Errorf("%d: %x accepted with %04b", i, tt.b, accept)
+				}
+				continue
+			}
+			if err != nil || rerr != nil {
+				t.Errorf("%d: %v, %v", i, err, rerr)
+				continue
+			}
+			if !got.Equal(want) || !rgot.Equal(want) {
+				t.Errorf("%d: got %v and %v, want %v", i, got, rgot, want)
+			}
+			if len(o) != 1 || !rd.IsNil() {
+				t.Errorf("%d: didn't consume the whole time", i)
+			}
+		}
+	}
+
+	for _, b := range [][]byte{
+		AppendFloat64(nil, 1),
+		AppendString(nil, "yesterday"),
+		AppendInt64(nil, 3)[:0],
+		mustExt(AppendExtension(nil, &RawExtension{Type: 42, Data: make([]byte, 12)})),
+	} {
+		if _, _, err := ReadTimeFlexibleBytes(b, TimeReprAny); err == nil {
+			t.Errorf("%x: expected an error", b)
+		}
+	}
+}
+
+func mustExt(b []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
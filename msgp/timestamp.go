@@ -0,0 +1,233 @@
+package msgp
+
+import (
+	"time"
+)
+
+// The functions in this file handle the
+// timestamp extension type (-1) defined by the
+// MessagePack specification, which is understood
+// by most other MessagePack implementations. (The
+// package's own time extension, which is what the
+// code generator uses for time.Time, is written
+// by AppendTime and WriteTime.)
+//
+// There are three forms of timestamp:
+//
+//   - timestamp32: seconds in [0, 2^32) as a uint32;
+//   - timestamp64: nanoseconds in the upper 30 bits and
+//     seconds in [0, 2^34) in the lower 34 bits of a uint64;
+//   - timestamp96: nanoseconds as a uint32 followed
+//     by seconds as an int64.
+//
+// Writing always selects the smallest form that can
+// represent the time without loss.
+
+const (
+	// TimestampMaxSize is the maximum
+	// encoded size of a timestamp.
+	TimestampMaxSize = 15
+)
+
+// timestampSize returns the size of the
+// smallest timestamp that can hold sec and nsec
+func timestampSize(sec int64, nsec uint32) int {
+	if sec>>34 == 0 {
+		if nsec == 0 && sec>>32 == 0 {
+			return 6
+		}
+		return 10
+	}
+	return 15
+}
+
+// putTimestamp writes a timestamp of size
+// timestampSize(sec, nsec) into b
+func putTimestamp(b []byte, sec int64, nsec uint32) {
+	typ := int8(TimestampExtension)
+	switch len(b) {
+	case 6:
+		b[0] = mfixext4
+		b[1] = byte(typ)
+		big.PutUint32(b[2:], uint32(sec))
+	case 10:
+		b[0] = mfixext8
+		b[1] = byte(typ)
+		big.PutUint64(b[2:], uint64(nsec)<<34|uint64(sec))
+	default:
+		b[0] = mext8
+		b[1] = 12
+		b[2] = byte(typ)
+		big.PutUint32(b[3:], nsec)
+		big.PutUint64(b[7:], uint64(sec))
+	}
+}
+
+// AppendTimestamp appends t to b as a
+// MessagePack timestamp (extension type -1).
+func AppendTimestamp(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), uint32(t.Nanosecond())
+	sz := timestampSize(sec, nsec)
+	o, n := ensure(b, sz)
+	putTimestamp(o[n:], sec, nsec)
+	return o
+}
+
+// WriteTimestamp writes t to the writer
+// as a MessagePack timestamp (extension type -1).
+func (mw *Writer) WriteTimestamp(t time.Time) error {
+	sec, nsec := t.Unix(), uint32(t.Nanosecond())
+	sz := timestampSize(sec, nsec)
+	o, err := mw.require(sz)
+	if err != nil {
+		return err
+	}
+	putTimestamp(mw.buf[o:o+sz], sec, nsec)
+	return nil
+}
+
+// ErrTimestampNsec is returned when a timestamp
+// has a nanoseconds field above 999999999, which
+// the MessagePack specification doesn't allow.
+// The timestamp is left unread.
+var ErrTimestampNsec error = errTimestampNsec{}
+
+type errTimestampNsec struct{}
+
+func (errTimestampNsec) Error() string   { return "msgp: timestamp nanoseconds out of range" }
+func (errTimestampNsec) Resumable() bool { return true }
+
+// timestampLen returns the size of the timestamp
+// whose encoding begins with lead, and the size of
+// its extension header, or zero if lead doesn't
+// begin a timestamp
+func timestampLen(lead byte) (sz int, hdr int) {
+	switch lead {
+	case mfixext4:
+		return 6, 2
+	case mfixext8:
+		return 10, 2
+	case mext8:
+		return 15, 3
+	default:
+		return 0, 0
+	}
+}
+
+// checkTimestampHeader checks the extension
+// header at the beginning of b, which must
+// hold the hdr bytes given by timestampLen
+func checkTimestampHeader(b []byte) error {
+	if b[0] != mext8 {
+		if typ := int8(b[1]); typ != TimestampExtension {
+			return errExt(typ, TimestampExtension)
+		}
+		return nil
+	}
+	if typ := int8(b[2]); typ != TimestampExtension && typ != TimeExtension {
+		return errExt(typ, TimestampExtension)
+	}
+	if b[1] != 12 {
+		return badPrefix(TimeType, b[0])
+	}
+	return nil
+}
+
+// getTimestamp decodes the timestamp at the
+// beginning of b, which must hold at least
+// timestampLen(b[0]) bytes and a header that
+// passed checkTimestampHeader
+func getTimestamp(b []byte) (time.Time, error) {
+	var sec, nsec int64
+	switch b[0] {
+	case mfixext4:
+		sec = int64(big.Uint32(b[2:]))
+	case mfixext8:
+		u := big.Uint64(b[2:])
+		sec, nsec = int64(u&(1<<34-1)), int64(u>>34)
+	default:
+		if int8(b[2]) == TimeExtension {
+			s, ns := getUnix(b[3:])
+			return time.Unix(s, int64(ns)).Local(), nil
+		}
+		sec, nsec = int64(big.Uint64(b[7:])), int64(big.Uint32(b[3:]))
+	}
+	if nsec > 999999999 {
+		return time.Time{}, ErrTimestampNsec
+	}
+	return time.Unix(sec, nsec).Local(), nil
+}
+
+// ReadTimestampBytes reads a MessagePack timestamp
+// (extension type -1) in any of its three forms, or
+// a time.Time written by AppendTime, from b and returns
+// the remaining bytes. The returned time's location
+// is set to time.Local.
+//
+// Possible errors:
+// - ErrShortBytes (not enough bytes in 'b')
+// - TypeError{} (object not a timestamp)
+// - ExtensionTypeError{} (object an extension, but not a timestamp)
+// - ErrTimestampNsec (nanoseconds out of range)
+func ReadTimestampBytes(b []byte) (t time.Time, o []byte, err error) {
+	if len(b) < 1 {
+		err = ErrShortBytes
+		return
+	}
+	sz, hdr := timestampLen(b[0])
+	if sz == 0 {
+		err = badPrefix(TimeType, b[0])
+		return
+	}
+	if len(b) < hdr {
+		err = ErrShortBytes
+		return
+	}
+	if err = checkTimestampHeader(b); err != nil {
+		return
+	}
+	if len(b) < sz {
+		err = ErrShortBytes
+		return
+	}
+	t, err = getTimestamp(b)
+	if err != nil {
+		return
+	}
+	o = b[sz:]
+	return
+}
+
+// ReadTimestamp reads a MessagePack timestamp
+// (extension type -1) in any of its three forms, or
+// a time.Time written by WriteTime, from the reader.
+// The returned time's location is set to time.Local.
+func (m *Reader) ReadTimestamp() (t time.Time, err error) {
+	var p []byte
+	p, err = m.R.Peek(1)
+	if err != nil {
+		return
+	}
+	sz, hdr := timestampLen(p[0])
+	if sz == 0 {
+		err = badPrefix(TimeType, p[0])
+		return
+	}
+	p, err = m.peek(hdr)
+	if err != nil {
+		return
+	}
+	if err = checkTimestampHeader(p); err != nil {
+		return
+	}
+	p, err = m.peek(sz)
+	if err != nil {
+		return
+	}
+	t, err = getTimestamp(p)
+	if err != nil {
+		return
+	}
+	_, err = m.R.Skip(sz)
+	return
+}
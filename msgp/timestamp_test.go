@@ -0,0 +1,153 @@
+package msgp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTimestampForms(t *testing.T) {
+	cases := []struct {
+		t   time.Time
+		sz  int
+		hdr byte
+	}{
+		{time.Unix(0, 0), 6, mfixext4},
+		{time.Unix(1<<32-1, 0), 6, mfixext4},
+		{time.Unix(1530707415, 1), 10, mfixext8},
+		{time.Unix(1<<32, 0), 10, mfixext8},
+		{time.Unix(1<<34-1, 999999999), 10, mfixext8},
+		{time.Unix(1<<34, 0), 15, mext8},
+		{time.Unix(-1, 0), 15, mext8},
+		{time.Unix(-1530707415, 500), 15, mext8},
+	}
+	for _, c := range cases {
+		b := AppendTimestamp(nil, c.t)
+		if len(b) != c.sz || b[0] != c.hdr {
+			t.Errorf("%v: got % x", c.t, b)
+			continue
+		}
+		if typ, _ := peekExtension(b); typ != TimestampExtension {
+			t.Errorf("%v: extension type %d", c.t, typ)
+		}
+
+		out, rest, err := ReadTimestampBytes(b)
+		if err != nil {
+			t.Errorf("%v: %v", c.t, err)
+		} else if !out.Equal(c.t) || len(rest) != 0 {
+			t.Errorf("%v: got %v", c.t, out)
+		}
+
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		if err := w.WriteTimestamp(c.t); err != nil {
+			t.Fatal(err)
+		}
+		w.Flush()
+		if !bytes.Equal(buf.Bytes(), b) {
+			t.Errorf("%v: WriteTimestamp wrote % x; AppendTimestamp wrote % x", c.t, buf.Bytes(), b)
+		}
+		out, err = NewReader(&buf).ReadTimestamp()
+		if err != nil {
+			t.Errorf("%v: %v", c.t, err)
+		} else if !out.Equal(c.t) {
+			t.Errorf("%v: got %v", c.t, out)
+		}
+	}
+}
+
+func TestTimestampKnownEncodings(t *testing.T) {
+	// examples encoded by other implementations
+	cases := []struct {
+		enc []byte
+		t   time.Time
+	}{
+		{[]byte{0xd6, 0xff, 0x00, 0x00, 0x00, 0x01}, time.Unix(1, 0)},
+		{[]byte{0xd7, 0xff, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01}, time.Unix(1, 1)},
+		{[]byte{0xc7, 0x0c, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, time.Unix(-1, 0)},
+	}
+	for _, c := range cases {
+		out, _, err := ReadTimestampBytes(c.enc)
+		if err != nil {
+			t.Errorf("% x: %v", c.enc, err)
+		} else if !out.Equal(c.t) {
+			t.Errorf("% x: got %v, want %v", c.enc, out, c.t)
+		}
+	}
+}
+
+func TestTimestampReadsTimeExtension(t *testing.T) {
+	now := time.Now()
+	out, _, err := ReadTimestampBytes(AppendTime(nil, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(now) {
+		t.Errorf("got %v, want %v", out, now)
+	}
+	if _, err := NewReader(bytes.NewReader(AppendTime(nil, now))).ReadTimestamp(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTimestampErrors(t *testing.T) {
+	if _, _, err := ReadTimestampBytes(AppendInt(nil, 1)); err == nil {
+		t.Error("expected an error for an int")
+	}
+	if _, _, err := ReadTimestampBytes(AppendTimestamp(nil, time.Unix(1, 1))[:5]); err != ErrShortBytes {
+		t.Errorf("expected ErrShortBytes; got %v", err)
+	}
+	b := AppendTimestamp(nil, time.Unix(1, 0))
+	b[1] = 7
+	if _, _, err := ReadTimestampBytes(b); err == nil {
+		t.Error("expected an error for the wrong extension type")
+	} else if _, ok := err.(ExtensionTypeError); !ok {
+		t.Errorf("expected an ExtensionTypeError; got %T", err)
+	}
+	c := AppendComplex64(nil, 1)
+	if _, err := NewReader(bytes.NewReader(c)).ReadTimestamp(); err == nil {
+		t.Error("expected an error for a complex64")
+	}
+
+	// an ext8 of another type, shorter than a timestamp96
+	e := []byte{mext8, 1, 9, 0}
+	if _, _, err := ReadTimestampBytes(e); err != (ExtensionTypeError{Got: 9, Want: -1}) {
+		t.Errorf("got %v; want an ExtensionTypeError", err)
+	}
+	if _, err := NewReader(bytes.NewReader(e)).ReadTimestamp(); err != (ExtensionTypeError{Got: 9, Want: -1}) {
+		t.Errorf("got %v; want an ExtensionTypeError", err)
+	}
+
+	// nanoseconds above 999999999
+	ts64 := AppendTimestamp(nil, time.Unix(1, 1))
+	big.PutUint64(ts64[2:], 1e9<<34|1)
+	ts96 := AppendTimestamp(nil, time.Unix(1<<40, 1))
+	big.PutUint32(ts96[3:], 1e9)
+	for _, b := range [][]byte{ts64, ts96} {
+		if _, _, err := ReadTimestampBytes(b); err != ErrTimestampNsec {
+			t.Errorf("% x: got %v; want ErrTimestampNsec", b, err)
+		}
+		if _, err := NewReader(bytes.NewReader(b)).ReadTimestamp(); err != ErrTimestampNsec {
+			t.Errorf("% x: got %v; want ErrTimestampNsec", b, err)
+		}
+	}
+}
+
+func TestTimestampIntf(t *testing.T) {
+	tm := time.Unix(1530707415, 0)
+	b := AppendTimestamp(nil, tm)
+	i, _, err := ReadIntfBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, ok := i.(time.Time); !ok || !out.Equal(tm) {
+		t.Errorf("got %#v", i)
+	}
+	i, err = NewReader(bytes.NewReader(b)).ReadIntf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, ok := i.(time.Time); !ok || !out.Equal(tm) {
+		t.Errorf("got %#v", i)
+	}
+}
@@ -9,7 +9,7 @@ import (
 
 // NOTE:
 // all of the definition in this file
-// should be repeated in appengine.go,
+// should be repeated in purego.go,
 // but without using unsafe
 
 const (
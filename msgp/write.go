@@ -1,11 +1,12 @@
 package msgp
 
 import (
-	"errors"
-	"fmt"
+	"bytes"
+	"encoding/json"
 	"io"
 	"math"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 )
@@ -26,25 +27,82 @@ var (
 	Nowhere io.Writer = nwhere{}
 
 	btsType    = reflect.TypeOf(([]byte)(nil))
-	writerPool = sync.Pool{
-		New: func() interface{} {
-			return &Writer{buf: make([]byte, 2048)}
-		},
-	}
+	writerPool = NewWriterPool(2048)
 )
 
-func popWriter(w io.Writer) *Writer {
-	wr := writerPool.Get().(*Writer)
-	wr.Reset(w)
-	return wr
+func popWriter(w io.Writer) *Writer { return writerPool.Get(w) }
+
+func pushWriter(wr *Writer) { writerPool.Put(wr) }
+
+// WriterPool is a pool of Writers with
+// buffers of the same size, which lets
+// programs that write many short streams
+// (one per request, for example) reuse
+// Writers rather than allocate them.
+// It is safe for concurrent use, and
+// must not be copied after first use.
+type WriterPool struct {
+	size int
+	pool sync.Pool
+}
+
+// NewWriterPool returns a pool of Writers
+// with buffers of sz bytes.
+func NewWriterPool(sz int) *WriterPool {
+	if sz < 18 {
+		sz = 18
+	}
+	return &WriterPool{size: sz}
+}
+
+// Get returns a Writer from the pool
+// (or a new one) that writes to w.
+// Its options are all unset.
+func (p *WriterPool) Get(w io.Writer) *Writer {
+	if wr, ok := p.pool.Get().(*Writer); ok {
+		wr.Reset(w)
+		return wr
+	}
+	return NewWriterSize(w, p.size)
 }
 
-func pushWriter(wr *Writer) {
+// Put returns wr to the pool. Any data that
+// hasn't been flushed is discarded, so call
+// Flush first. wr must not be used afterwards.
+// Writers whose buffer isn't the pool's size
+// are not kept.
+func (p *WriterPool) Put(wr *Writer) {
 	wr.w = nil
 	wr.wloc = 0
-	writerPool.Put(wr)
+	wr.open = wr.open[:0]
+	wr.err = nil
+	wr.oldSpec = false
+	wr.compactFloats = false
+	wr.sortMaps = false
+	wr.nilPtrErr = false
+	wr.timestamps = false
+	wr.strKeys = false
+	wr.numStrs = false
+	wr.timeFmt = TimeFormatExt
+	wr.metrics = nil
+	wr.flushed = 0
+	if cap(wr.buf) == p.size {
+		p.pool.Put(wr)
+	}
 }
 
+// GetWriter returns a Writer that writes to w
+// from a package-level pool of Writers with
+// the default buffer size. It should be
+// returned to the pool with PutWriter.
+func GetWriter(w io.Writer) *Writer { return popWriter(w) }
+
+// PutWriter returns a Writer obtained from
+// GetWriter (or NewWriter) to the package-level
+// pool. Any data that hasn't been flushed is
+// discarded. wr must not be used afterwards.
+func PutWriter(wr *Writer) { pushWriter(wr) }
+
 // freeW frees a writer for use
 // by other processes. It is not necessary
 // to call freeW on a writer. However, maintaining
@@ -108,6 +166,28 @@ type Writer struct {
 	w    io.Writer
 	buf  []byte
 	wloc int
+
+	// positions in buf of the headers of the
+	// containers begun by BeginMap and BeginArray
+	// that haven't been ended; see deferred.go
+	open []openContainer
+
+	// the first error returned by w; once
+	// it is set, nothing more is written
+	err error
+
+	// options; see WriterOptions
+	oldSpec       bool
+	compactFloats bool
+	sortMaps      bool
+	nilPtrErr     bool
+	timestamps    bool
+	strKeys       bool
+	numStrs       bool
+	timeFmt       TimeFormat
+	metrics       *Metrics
+
+	flushed int64 // bytes written to w; see Encode
 }
 
 // NewWriter returns a new *Writer.
@@ -134,7 +214,16 @@ func NewWriterSize(w io.Writer, sz int) *Writer {
 }
 
 // Encode encodes an Encodable to an io.Writer.
+// If w is a *bytes.Buffer and e also implements
+// Marshaler, e is appended to the buffer with
+// MarshalMsg, which is faster than copying it
+// through a Writer.
 func Encode(w io.Writer, e Encodable) error {
+	if buf, ok := w.(*bytes.Buffer); ok {
+		if m, ok := e.(Marshaler); ok {
+			return encodeBuffer(buf, m)
+		}
+	}
 	wr := NewWriter(w)
 	err := e.EncodeMsg(wr)
 	if err == nil {
@@ -144,28 +233,82 @@ func Encode(w io.Writer, e Encodable) error {
 	return err
 }
 
+// encodeBuffer appends m to buf with MarshalMsg,
+// encoding it directly into the free space at the
+// end of buf (which is grown first if m is a Sizer)
+// rather than into a separate buffer.
+func encodeBuffer(buf *bytes.Buffer, m Marshaler) error {
+	if s, ok := m.(Sizer); ok {
+		buf.Grow(s.Msgsize())
+	}
+	b := buf.Bytes()
+	b, err := m.MarshalMsg(b[len(b):])
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
 func (mw *Writer) flush() error {
+	if mw.err != nil {
+		return mw.err
+	}
 	if mw.wloc == 0 {
 		return nil
 	}
+	if len(mw.open) > 0 {
+		// the open containers' headers
+		// can't be written yet, so keep
+		// everything buffered
+		mw.grow(len(mw.buf))
+		return nil
+	}
 	n, err := mw.w.Write(mw.buf[:mw.wloc])
+	mw.wrote(n)
 	if err != nil {
-		if n > 0 {
-			mw.wloc = copy(mw.buf, mw.buf[n:mw.wloc])
-		}
-		return err
+		return mw.fail(err)
 	}
 	mw.wloc = 0
 	return nil
 }
 
+// fail records err as the Writer's error.
+// The buffer is marked full, so that every
+// subsequent write calls flush and returns err.
+func (mw *Writer) fail(err error) error {
+	mw.err = err
+	mw.wloc = len(mw.buf)
+	return err
+}
+
+// Err returns the first error returned by
+// the underlying io.Writer, or nil if there
+// hasn't been one. Once writing has failed,
+// everything written to the Writer (including
+// Flush) returns that error and does nothing,
+// so a sequence of writes can be checked once,
+// at the end, with Err or Flush.
+func (mw *Writer) Err() error { return mw.err }
+
 // Flush flushes all of the buffered
-// data to the underlying writer.
-func (mw *Writer) Flush() error { return mw.flush() }
+// data to the underlying writer. It
+// returns ErrContainerOpen if a container
+// begun with BeginMap or BeginArray
+// hasn't been ended.
+func (mw *Writer) Flush() error {
+	if len(mw.open) > 0 {
+		return ErrContainerOpen
+	}
+	return mw.flush()
+}
 
 // Buffered returns the number bytes in the write buffer
 func (mw *Writer) Buffered() int { return len(mw.buf) - mw.wloc }
 
+// BufferSize returns the capacity of the write buffer.
+func (mw *Writer) BufferSize() int { return len(mw.buf) }
+
 func (mw *Writer) avail() int { return len(mw.buf) - mw.wloc }
 
 func (mw *Writer) bufsize() int { return len(mw.buf) }
@@ -262,42 +405,83 @@ func (mw *Writer) prefix64(b byte, u uint64) error {
 }
 
 // Write implements io.Writer, and writes
-// data directly to the buffer.
+// data directly to the buffer. Data at least
+// as large as the buffer bypasses it, and is
+// written straight to the underlying writer.
 func (mw *Writer) Write(p []byte) (int, error) {
 	l := len(p)
 	if mw.avail() < l {
+		if l >= len(mw.buf) && len(mw.open) == 0 {
+			return mw.writeDirect(p)
+		}
 		if err := mw.flush(); err != nil {
 			return 0, err
 		}
-		if l > len(mw.buf) {
-			return mw.w.Write(p)
+		if l > mw.avail() {
+			mw.grow(l)
 		}
 	}
 	mw.wloc += copy(mw.buf[mw.wloc:], p)
 	return l, nil
 }
 
+// ReadFrom implements io.ReaderFrom, and reads
+// data from r directly into the buffer until io.EOF.
+func (mw *Writer) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	for empty := 0; ; {
+		if mw.avail() == 0 {
+			if err := mw.flush(); err != nil {
+				return n, err
+			}
+		}
+		nn, err := r.Read(mw.buf[mw.wloc:])
+		mw.wloc += nn
+		n += int64(nn)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if nn == 0 {
+			if empty++; empty == 100 {
+				return n, io.ErrNoProgress
+			}
+		} else {
+			empty = 0
+		}
+	}
+}
+
 // implements io.WriteString
 func (mw *Writer) writeString(s string) error {
 	l := len(s)
 	if mw.avail() < l {
+		if l >= len(mw.buf) && len(mw.open) == 0 {
+			return mw.writeDirectString(s)
+		}
 		if err := mw.flush(); err != nil {
 			return err
 		}
-		if l > len(mw.buf) {
-			_, err := io.WriteString(mw.w, s)
-			return err
+		if l > mw.avail() {
+			mw.grow(l)
 		}
 	}
 	mw.wloc += copy(mw.buf[mw.wloc:], s)
 	return nil
 }
 
-// Reset changes the underlying writer used by the Writer
+// Reset changes the underlying writer used by the Writer.
+// Any data that hasn't been flushed, and the error (see
+// Err), are discarded. The buffer and the options set on
+// the Writer are kept.
 func (mw *Writer) Reset(w io.Writer) {
 	mw.buf = mw.buf[:cap(mw.buf)]
 	mw.w = w
 	mw.wloc = 0
+	mw.open = mw.open[:0]
+	mw.err = nil
 }
 
 // WriteMapHeader writes a map header of the given
@@ -333,6 +517,9 @@ func (mw *Writer) WriteNil() error {
 
 // WriteFloat64 writes a float64 to the writer
 func (mw *Writer) WriteFloat64(f float64) error {
+	if mw.compactFloats && float64(float32(f)) == f {
+		return mw.WriteFloat32(float32(f))
+	}
 	return mw.prefix64(mfloat64, math.Float64bits(f))
 }
 
@@ -414,6 +601,9 @@ func (mw *Writer) WriteUint(u uint) error { return mw.WriteUint64(uint64(u)) }
 
 // WriteBytes writes binary as 'bin' to the writer
 func (mw *Writer) WriteBytes(b []byte) error {
+	if mw.oldSpec {
+		return mw.WriteStringFromBytes(b)
+	}
 	sz := uint32(len(b))
 	var err error
 	switch {
@@ -435,6 +625,9 @@ func (mw *Writer) WriteBytes(b []byte) error {
 // of a MessagePack 'bin' object. The user is responsible
 // for then writing 'sz' more bytes into the stream.
 func (mw *Writer) WriteBytesHeader(sz uint32) error {
+	if mw.oldSpec {
+		return mw.WriteStringHeader(sz)
+	}
 	switch {
 	case sz <= math.MaxUint8:
 		return mw.prefix8(mbin8, uint8(sz))
@@ -445,6 +638,39 @@ func (mw *Writer) WriteBytesHeader(sz uint32) error {
 	}
 }
 
+// WriteBytesFrom writes a 'bin' object whose
+// contents are the next sz bytes read from r.
+// The contents are copied through the Writer's
+// buffer, so a large object can be written from
+// a file or a connection without being held in
+// memory. (This is WriteBytesHeader followed by
+// io.CopyN.) If r ends before sz bytes have been
+// read, WriteBytesFrom returns io.ErrUnexpectedEOF,
+// and the object written is incomplete.
+func (mw *Writer) WriteBytesFrom(r io.Reader, sz uint32) error {
+	if err := mw.WriteBytesHeader(sz); err != nil {
+		return err
+	}
+	return mw.copyFrom(r, sz)
+}
+
+// WriteStringFrom is like WriteBytesFrom, but it
+// writes a 'str' object. The bytes read from r
+// should be valid UTF-8; they are not checked.
+func (mw *Writer) WriteStringFrom(r io.Reader, sz uint32) error {
+	if err := mw.WriteStringHeader(sz); err != nil {
+		return err
+	}
+	return mw.copyFrom(r, sz)
+}
+
+// copyFrom copies sz bytes from r,
+// which io.CopyN does with ReadFrom
+func (mw *Writer) copyFrom(r io.Reader, sz uint32) error {
+	_, err := io.CopyN(mw, r, int64(sz))
+	return noEOF(err)
+}
+
 // WriteBool writes a bool to the writer
 func (mw *Writer) WriteBool(b bool) error {
 	if b {
@@ -461,7 +687,7 @@ func (mw *Writer) WriteString(s string) error {
 	switch {
 	case sz <= 31:
 		err = mw.push(wfixstr(uint8(sz)))
-	case sz <= math.MaxUint8:
+	case sz <= math.MaxUint8 && !mw.oldSpec:
 		err = mw.prefix8(mstr8, uint8(sz))
 	case sz <= math.MaxUint16:
 		err = mw.prefix16(mstr16, uint16(sz))
@@ -482,7 +708,7 @@ func (mw *Writer) WriteStringHeader(sz uint32) error {
 	switch {
 	case sz <= 31:
 		return mw.push(wfixstr(uint8(sz)))
-	case sz <= math.MaxUint8:
+	case sz <= math.MaxUint8 && !mw.oldSpec:
 		return mw.prefix8(mstr8, uint8(sz))
 	case sz <= math.MaxUint16:
 		return mw.prefix16(mstr16, uint16(sz))
@@ -499,7 +725,7 @@ func (mw *Writer) WriteStringFromBytes(str []byte) error {
 	switch {
 	case sz <= 31:
 		err = mw.push(wfixstr(uint8(sz)))
-	case sz <= math.MaxUint8:
+	case sz <= math.MaxUint8 && !mw.oldSpec:
 		err = mw.prefix8(mstr8, uint8(sz))
 	case sz <= math.MaxUint16:
 		err = mw.prefix16(mstr16, uint16(sz))
@@ -539,8 +765,84 @@ func (mw *Writer) WriteComplex128(f complex128) error {
 	return nil
 }
 
+// SortMaps sets whether the maps written by
+// WriteMapStrStr, WriteMapStrIntf and WriteIntf
+// have their entries sorted by key, so that equal
+// maps are always written as the same bytes.
+// (Map iteration order is random in Go.)
+// It doesn't affect generated EncodeMsg methods.
+func (mw *Writer) SortMaps(on bool) { mw.sortMaps = on }
+
+// SetCompactFloats sets whether WriteFloat64 writes
+// values that a float32 can represent exactly (such
+// as 1.5, but not 0.1) as a float32, which takes four
+// fewer bytes. Decoders that read a float64 accept
+// either size. It applies to every float64 written
+// by the Writer, including those in generated
+// EncodeMsg methods and WriteIntf.
+func (mw *Writer) SetCompactFloats(on bool) { mw.compactFloats = on }
+
+// SetNilPointerError sets whether WriteIntf
+// returns a NilPointerError when it is passed a
+// nil pointer (including one inside a slice or map),
+// rather than writing nil. This is useful when nil
+// pointers indicate a bug, since they can't be told
+// apart from nil values when the data is read.
+func (mw *Writer) SetNilPointerError(on bool) { mw.nilPtrErr = on }
+
+// SetTimestamps sets whether WriteTime (and so
+// generated EncodeMsg methods and WriteIntf) writes
+// times as MessagePack timestamps (extension type -1),
+// which other implementations understand, rather than
+// with the package's own time extension. ReadTime
+// accepts either.
+func (mw *Writer) SetTimestamps(on bool) { mw.timestamps = on }
+
+// SetTimeFormat sets how WriteTime (and so generated
+// EncodeMsg methods and WriteIntf) writes times. A
+// Reader whose TimeFormat is set to the same format
+// reads them back with ReadTime.
+func (mw *Writer) SetTimeFormat(f TimeFormat) { mw.timeFmt = f }
+
+// SetStringKeys sets whether WriteIntf converts the
+// keys of maps that aren't keyed by strings into
+// strings, the way encoding/json does (so the output
+// can be converted to JSON): numbers and bools are
+// formatted, and keys that implement encoding.TextMarshaler
+// are marshaled. Otherwise, keys are encoded as
+// the values they are.
+func (mw *Writer) SetStringKeys(on bool) { mw.strKeys = on }
+
+// SetJSONNumberStrings sets whether WriteJSONNumber
+// (and so WriteIntf) writes json.Numbers that don't
+// fit in an int64, a uint64 or a float64 as strings,
+// rather than losing precision or failing; see
+// AppendJSONNumber.
+func (mw *Writer) SetJSONNumberStrings(on bool) { mw.numStrs = on }
+
+// writeNilPtr writes a nil pointer of type t
+func (mw *Writer) writeNilPtr(t reflect.Type) error {
+	if mw.nilPtrErr {
+		return NilPointerError{T: t}
+	}
+	return mw.WriteNil()
+}
+
+// nilPtr returns the type of v if v is a nil
+// pointer, whose methods can't be called safely
+func nilPtr(v interface{}) (reflect.Type, bool) {
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr && val.IsNil() {
+		return val.Type(), true
+	}
+	return nil, false
+}
+
 // WriteMapStrStr writes a map[string]string to the writer
 func (mw *Writer) WriteMapStrStr(mp map[string]string) (err error) {
+	if mw.sortMaps {
+		return mw.WriteMapStrStrSorted(mp)
+	}
 	err = mw.WriteMapHeader(uint32(len(mp)))
 	if err != nil {
 		return
@@ -558,8 +860,66 @@ func (mw *Writer) WriteMapStrStr(mp map[string]string) (err error) {
 	return nil
 }
 
+// WriteMapStrStrSorted writes a map[string]string
+// to the writer with its entries sorted by key.
+func (mw *Writer) WriteMapStrStrSorted(mp map[string]string) (err error) {
+	err = mw.WriteMapHeader(uint32(len(mp)))
+	if err != nil {
+		return
+	}
+	for _, key := range strStrKeys(mp) {
+		err = mw.WriteString(key)
+		if err != nil {
+			return
+		}
+		err = mw.WriteString(mp[key])
+		if err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// WriteMapStrRaw writes a map[string]Raw to the writer.
+// Each Raw is written as it is, and empty Raws are
+// written as nil. If the SortMaps option is set, the
+// entries are written in increasing order of their keys.
+func (mw *Writer) WriteMapStrRaw(mp map[string]Raw) (err error) {
+	err = mw.WriteMapHeader(uint32(len(mp)))
+	if err != nil {
+		return
+	}
+	if mw.sortMaps {
+		for _, key := range strRawKeys(mp) {
+			err = mw.WriteString(key)
+			if err != nil {
+				return
+			}
+			err = mp[key].EncodeMsg(mw)
+			if err != nil {
+				return
+			}
+		}
+		return nil
+	}
+	for key, val := range mp {
+		err = mw.WriteString(key)
+		if err != nil {
+			return
+		}
+		err = val.EncodeMsg(mw)
+		if err != nil {
+			return
+		}
+	}
+	return nil
+}
+
 // WriteMapStrIntf writes a map[string]interface to the writer
 func (mw *Writer) WriteMapStrIntf(mp map[string]interface{}) (err error) {
+	if mw.sortMaps {
+		return mw.WriteMapStrIntfSorted(mp)
+	}
 	err = mw.WriteMapHeader(uint32(len(mp)))
 	if err != nil {
 		return
@@ -577,6 +937,54 @@ func (mw *Writer) WriteMapStrIntf(mp map[string]interface{}) (err error) {
 	return
 }
 
+// WriteMapStrIntfSorted writes a map[string]interface{}
+// to the writer with its entries sorted by key. Maps
+// nested inside it are only sorted if SortMaps is set.
+func (mw *Writer) WriteMapStrIntfSorted(mp map[string]interface{}) (err error) {
+	err = mw.WriteMapHeader(uint32(len(mp)))
+	if err != nil {
+		return
+	}
+	for _, key := range strIntfKeys(mp) {
+		err = mw.WriteString(key)
+		if err != nil {
+			return
+		}
+		err = mw.WriteIntf(mp[key])
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func strStrKeys(mp map[string]string) []string {
+	keys := make([]string, 0, len(mp))
+	for key := range mp {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func strRawKeys(mp map[string]Raw) []string {
+	keys := make([]string, 0, len(mp))
+	for key := range mp {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func strIntfKeys(mp map[string]interface{}) []string {
+	keys := make([]string, 0, len(mp))
+	for key := range mp {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // WriteTime writes a time.Time object to the wire.
 //
 // Time is encoded as Unix time, which means that
@@ -591,7 +999,31 @@ func (mw *Writer) WriteMapStrIntf(mp map[string]interface{}) (err error) {
 // binary encoding, because its implementation relies
 // heavily on the internal representation used by the
 // time package.)
+//
+// If the Writer's Timestamps option is set, the time
+// is written as a standard timestamp instead; see
+// WriteTimestamp.
 func (mw *Writer) WriteTime(t time.Time) error {
+	switch {
+	case mw.timeFmt.isUnix():
+		return mw.WriteInt64(mw.timeFmt.unixTime(t))
+	case mw.timeFmt == TimeFormatRFC3339:
+		s, err := TimeToRFC3339(t)
+		if err != nil {
+			return err
+		}
+		return mw.WriteString(s)
+	case mw.timeFmt == TimeFormatZoned:
+		name, offset := zoneOf(t)
+		o, err := mw.require(3 + zonedTimeSize)
+		if err != nil {
+			return err
+		}
+		putZoned(mw.buf[o:], t, len(name), offset)
+		return mw.writeString(name)
+	case mw.timestamps:
+		return mw.WriteTimestamp(t)
+	}
 	t = t.UTC()
 	o, err := mw.require(15)
 	if err != nil {
@@ -607,11 +1039,17 @@ func (mw *Writer) WriteTime(t time.Time) error {
 // WriteIntf writes the concrete type of 'v'.
 // WriteIntf will error if 'v' is not one of the following:
 //  - A bool, float, string, []byte, int, uint, or complex
-//  - A map of supported types (with string keys)
+//  - A json.Number (see WriteJSONNumber)
+//  - A map of supported types, whose keys are strings,
+//    bools, numbers, or Extensions (see SetStringKeys)
 //  - An array or slice of supported types
 //  - A pointer to a supported type
+//  - A struct, which is written as a map, following
+//    the same rules as Marshal
 //  - A type that satisfies the msgp.Encodable interface
 //  - A type that satisfies the msgp.Extension interface
+// A nil pointer is written as nil, without calling
+// its methods, unless SetNilPointerError is set.
 func (mw *Writer) WriteIntf(v interface{}) error {
 	if v == nil {
 		return mw.WriteNil()
@@ -621,8 +1059,14 @@ func (mw *Writer) WriteIntf(v interface{}) error {
 	// preferred interfaces
 
 	case Encodable:
+		if t, ok := nilPtr(v); ok {
+			return mw.writeNilPtr(t)
+		}
 		return v.EncodeMsg(mw)
 	case Extension:
+		if t, ok := nilPtr(v); ok {
+			return mw.writeNilPtr(t)
+		}
 		return mw.WriteExtension(v)
 
 	// concrete types
@@ -659,6 +1103,8 @@ func (mw *Writer) WriteIntf(v interface{}) error {
 		return mw.WriteInt(v)
 	case string:
 		return mw.WriteString(v)
+	case json.Number:
+		return mw.WriteJSONNumber(v)
 	case []byte:
 		return mw.WriteBytes(v)
 	case map[string]string:
@@ -671,35 +1117,37 @@ func (mw *Writer) WriteIntf(v interface{}) error {
 
 	val := reflect.ValueOf(v)
 	if !isSupported(val.Kind()) || !val.IsValid() {
-		return fmt.Errorf("msgp: type %s not supported", val)
+		return &ErrUnsupportedType{T: reflect.TypeOf(v)}
 	}
 
 	switch val.Kind() {
 	case reflect.Ptr:
 		if val.IsNil() {
-			return mw.WriteNil()
+			return mw.writeNilPtr(val.Type())
 		}
 		return mw.WriteIntf(val.Elem().Interface())
 	case reflect.Slice:
 		return mw.writeSlice(val)
 	case reflect.Map:
 		return mw.writeMap(val)
+	case reflect.Struct:
+		return mw.writeStruct(val)
 	}
 	return &ErrUnsupportedType{T: val.Type()}
 }
 
 func (mw *Writer) writeMap(v reflect.Value) (err error) {
-	if v.Type().Key().Kind() != reflect.String {
-		return errors.New("msgp: map keys must be strings")
-	}
 	ks := v.MapKeys()
+	if mw.sortMaps {
+		sortMapKeys(ks, mw.strKeys)
+	}
 	err = mw.WriteMapHeader(uint32(len(ks)))
 	if err != nil {
 		return
 	}
 	for _, key := range ks {
 		val := v.MapIndex(key)
-		err = mw.WriteString(key.String())
+		err = mw.writeMapKey(key)
 		if err != nil {
 			return
 		}
@@ -731,16 +1179,55 @@ func (mw *Writer) writeSlice(v reflect.Value) (err error) {
 	return
 }
 
-func (mw *Writer) writeStruct(v reflect.Value) error {
-	if enc, ok := v.Interface().(Encodable); ok {
-		return enc.EncodeMsg(mw)
+// writeStruct writes a struct as a map, following
+// the same rules as Marshal, unless it (or a pointer
+// to it) is Encodable. Fields that can't be passed
+// to WriteIntf (because they were reached through an
+// unexported embedded struct) are written by Marshal.
+func (mw *Writer) writeStruct(v reflect.Value) (err error) {
+	if enc, ok := asInterface(v, encodableType); ok {
+		return enc.(Encodable).EncodeMsg(mw)
+	}
+	rs := getReflStruct(v.Type())
+	var n uint32
+	for i := range rs.fields {
+		f := &rs.fields[i]
+		if !f.omitempty || !isEmptyValue(v.FieldByIndex(f.index)) {
+			n++
+		}
+	}
+	err = mw.WriteMapHeader(n)
+	if err != nil {
+		return
 	}
-	return fmt.Errorf("msgp: unsupported type: %s", v.Type())
+	for i := range rs.fields {
+		f := &rs.fields[i]
+		fv := v.FieldByIndex(f.index)
+		if f.omitempty && isEmptyValue(fv) {
+			continue
+		}
+		err = mw.WriteString(f.name)
+		if err != nil {
+			return
+		}
+		if fv.CanInterface() {
+			err = mw.WriteIntf(fv.Interface())
+		} else {
+			var b []byte
+			if b, err = appendReflect(nil, fv); err == nil {
+				_, err = mw.Write(b)
+			}
+		}
+		if err != nil {
+			return WrapError(err, f.name)
+		}
+	}
+	return
 }
 
 func (mw *Writer) writeVal(v reflect.Value) error {
 	if !isSupported(v.Kind()) {
-		return fmt.Errorf("msgp: msgp/enc: type %q not supported", v.Type())
+		return &ErrUnsupportedType{T: v.Type()}
 	}
 
 	// shortcut for nil values
@@ -782,7 +1269,7 @@ func (mw *Writer) writeVal(v reflect.Value) error {
 		return mw.writeStruct(v)
 
 	}
-	return fmt.Errorf("msgp: msgp/enc: type %q not supported", v.Type())
+	return &ErrUnsupportedType{T: v.Type()}
 }
 
 // is the reflect.Kind encodable?
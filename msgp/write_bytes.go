@@ -1,6 +1,7 @@
 package msgp
 
 import (
+	"encoding/json"
 	"math"
 	"reflect"
 	"time"
@@ -66,6 +67,16 @@ func AppendFloat64(b []byte, f float64) []byte {
 	return o
 }
 
+// AppendFloat64Compact is like AppendFloat64, but
+// it appends f as a float32 if that represents it
+// exactly, like a Writer with SetCompactFloats.
+func AppendFloat64Compact(b []byte, f float64) []byte {
+	if float64(float32(f)) == f {
+		return AppendFloat32(b, float32(f))
+	}
+	return AppendFloat64(b, f)
+}
+
 // AppendFloat32 appends a float32 to the slice
 func AppendFloat32(b []byte, f float32) []byte {
 	o, n := ensure(b, Float32Size)
@@ -297,6 +308,29 @@ func AppendMapStrStr(b []byte, m map[string]string) []byte {
 	return b
 }
 
+// AppendMapStrStrSorted is like AppendMapStrStr,
+// but it appends the entries sorted by key.
+func AppendMapStrStrSorted(b []byte, m map[string]string) []byte {
+	b = AppendMapHeader(b, uint32(len(m)))
+	for _, key := range strStrKeys(m) {
+		b = AppendString(b, key)
+		b = AppendString(b, m[key])
+	}
+	return b
+}
+
+// AppendMapStrRaw appends a map[string]Raw to the slice
+// as a MessagePack map with 'str'-type keys. Each Raw is
+// appended as it is, and empty Raws are appended as nil.
+func AppendMapStrRaw(b []byte, m map[string]Raw) []byte {
+	b = AppendMapHeader(b, uint32(len(m)))
+	for key, val := range m {
+		b = AppendString(b, key)
+		b, _ = val.MarshalMsg(b)
+	}
+	return b
+}
+
 // AppendMapStrIntf appends a map[string]interface{} to the slice
 // as a MessagePack map with 'str'-type keys.
 func AppendMapStrIntf(b []byte, m map[string]interface{}) ([]byte, error) {
@@ -313,16 +347,54 @@ func AppendMapStrIntf(b []byte, m map[string]interface{}) ([]byte, error) {
 	return b, nil
 }
 
+// AppendMapStrIntfSorted is like AppendMapStrIntf,
+// but it appends the entries sorted by key. Nested
+// maps are not sorted.
+func AppendMapStrIntfSorted(b []byte, m map[string]interface{}) ([]byte, error) {
+	b = AppendMapHeader(b, uint32(len(m)))
+	var err error
+	for _, key := range strIntfKeys(m) {
+		b = AppendString(b, key)
+		b, err = AppendIntf(b, m[key])
+		if err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
 // AppendIntf appends the concrete type of 'i' to the
 // provided []byte. 'i' must be one of the following:
 //  - 'nil'
 //  - A bool, float, string, []byte, int, uint, or complex
-//  - A map[string]interface{} or map[string]string
+//  - A json.Number, which is appended as by AppendJSONNumber
+//    with strs unset: numbers that don't fit are rounded or
+//    cause an error, and are never appended as strings (as
+//    they can be by a Writer; see SetJSONNumberStrings)
+//  - A map[K]T, where T is another supported type, and
+//    K is a string, bool, number, or Extension type
 //  - A []T, where T is another supported type
 //  - A *T, where T is another supported type
+//  - A struct, which is appended as a map, following
+//    the same rules as Marshal
 //  - A type that satisfieds the msgp.Marshaler interface
 //  - A type that satisfies the msgp.Extension interface
+// A nil pointer is appended as nil, without calling its methods.
 func AppendIntf(b []byte, i interface{}) ([]byte, error) {
+	return appendIntf(b, i, false)
+}
+
+// AppendIntfStringKeys is like AppendIntf, but the
+// keys of maps are converted to strings, as described
+// for Writer.SetStringKeys, so that the result can be
+// converted to JSON.
+func AppendIntfStringKeys(b []byte, i interface{}) ([]byte, error) {
+	return appendIntf(b, i, true)
+}
+
+// appendIntf implements AppendIntf,
+// with str selecting string keys
+func appendIntf(b []byte, i interface{}, str bool) ([]byte, error) {
 	if i == nil {
 		return AppendNil(b), nil
 	}
@@ -331,8 +403,14 @@ func AppendIntf(b []byte, i interface{}) ([]byte, error) {
 	// for which we have methods
 	switch i := i.(type) {
 	case Marshaler:
+		if _, ok := nilPtr(i); ok {
+			return AppendNil(b), nil
+		}
 		return i.MarshalMsg(b)
 	case Extension:
+		if _, ok := nilPtr(i); ok {
+			return AppendNil(b), nil
+		}
 		return AppendExtension(b, i)
 	case bool:
 		return AppendBool(b, i), nil
@@ -346,6 +424,8 @@ func AppendIntf(b []byte, i interface{}) ([]byte, error) {
 		return AppendComplex128(b, i), nil
 	case string:
 		return AppendString(b, i), nil
+	case json.Number:
+		return AppendJSONNumber(b, i, false)
 	case []byte:
 		return AppendBytes(b, i), nil
 	case int8:
@@ -371,14 +451,16 @@ func AppendIntf(b []byte, i interface{}) ([]byte, error) {
 	case time.Time:
 		return AppendTime(b, i), nil
 	case map[string]interface{}:
-		return AppendMapStrIntf(b, i)
+		if !str {
+			return AppendMapStrIntf(b, i)
+		}
 	case map[string]string:
 		return AppendMapStrStr(b, i), nil
 	case []interface{}:
 		b = AppendArrayHeader(b, uint32(len(i)))
 		var err error
 		for _, k := range i {
-			b, err = AppendIntf(b, k)
+			b, err = appendIntf(b, k, str)
 			if err != nil {
 				return b, err
 			}
@@ -393,7 +475,21 @@ func AppendIntf(b []byte, i interface{}) ([]byte, error) {
 		l := v.Len()
 		b = AppendArrayHeader(b, uint32(l))
 		for i := 0; i < l; i++ {
-			b, err = AppendIntf(b, v.Index(i).Interface())
+			b, err = appendIntf(b, v.Index(i).Interface(), str)
+			if err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		b = AppendMapHeader(b, uint32(v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			b, err = appendMapKey(b, iter.Key(), str)
+			if err != nil {
+				return b, err
+			}
+			b, err = appendIntf(b, iter.Value().Interface(), str)
 			if err != nil {
 				return b, err
 			}
@@ -403,9 +499,208 @@ func AppendIntf(b []byte, i interface{}) ([]byte, error) {
 		if v.IsNil() {
 			return AppendNil(b), err
 		}
-		b, err = AppendIntf(b, v.Elem().Interface())
+		b, err = appendIntf(b, v.Elem().Interface(), str)
 		return b, err
+	case reflect.Struct:
+		return appendIntfStruct(b, v, str)
 	default:
 		return b, &ErrUnsupportedType{T: v.Type()}
 	}
 }
+
+// appendIntfStruct appends a struct as a map,
+// like appendReflect does, but with its fields
+// appended by appendIntf where possible
+func appendIntfStruct(b []byte, v reflect.Value, str bool) ([]byte, error) {
+	if m, ok := asMarshaler(v); ok {
+		return m.MarshalMsg(b)
+	}
+	rs := getReflStruct(v.Type())
+	var n uint32
+	for i := range rs.fields {
+		f := &rs.fields[i]
+		if !f.omitempty || !isEmptyValue(v.FieldByIndex(f.index)) {
+			n++
+		}
+	}
+	b = AppendMapHeader(b, n)
+	var err error
+	for i := range rs.fields {
+		f := &rs.fields[i]
+		fv := v.FieldByIndex(f.index)
+		if f.omitempty && isEmptyValue(fv) {
+			continue
+		}
+		b = AppendString(b, f.name)
+		if fv.CanInterface() {
+			b, err = appendIntf(b, fv.Interface(), str)
+		} else {
+			b, err = appendReflect(b, fv)
+		}
+		if err != nil {
+			return b, WrapError(err, f.name)
+		}
+	}
+	return b, nil
+}
+
+// The Append*s functions below append a slice as
+// an array. They reserve room for the whole array
+// up front and write the elements in one loop,
+// which is considerably faster than appending
+// the header and each element separately.
+
+// reserve returns b with room for
+// at least sz more bytes
+func reserve(b []byte, sz int) []byte {
+	o, n := ensure(b, sz)
+	return o[:n]
+}
+
+// arrayHeaderSize returns the encoded
+// size of an array header for sz elements
+func arrayHeaderSize(sz int) int {
+	switch {
+	case sz <= 15:
+		return 1
+	case sz <= math.MaxUint16:
+		return 3
+	default:
+		return 5
+	}
+}
+
+// int64Size returns the size of
+// i as encoded by AppendInt64
+func int64Size(i int64) int {
+	switch {
+	case i >= -32 && i <= math.MaxInt8:
+		return 1
+	case i >= math.MinInt8 && i < 0:
+		return 2
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return 3
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return 5
+	default:
+		return 9
+	}
+}
+
+// uint64Size returns the size of
+// u as encoded by AppendUint64
+func uint64Size(u uint64) int {
+	switch {
+	case u <= math.MaxInt8:
+		return 1
+	case u <= math.MaxUint8:
+		return 2
+	case u <= math.MaxUint16:
+		return 3
+	case u <= math.MaxUint32:
+		return 5
+	default:
+		return 9
+	}
+}
+
+// AppendInt64s appends a slice of int64s as an array
+func AppendInt64s(b []byte, s []int64) []byte {
+	sz := arrayHeaderSize(len(s))
+	for _, i := range s {
+		sz += int64Size(i)
+	}
+	b = AppendArrayHeader(reserve(b, sz), uint32(len(s)))
+	for _, i := range s {
+		b = AppendInt64(b, i)
+	}
+	return b
+}
+
+// AppendInts appends a slice of ints as an array
+func AppendInts(b []byte, s []int) []byte {
+	sz := arrayHeaderSize(len(s))
+	for _, i := range s {
+		sz += int64Size(int64(i))
+	}
+	b = AppendArrayHeader(reserve(b, sz), uint32(len(s)))
+	for _, i := range s {
+		b = AppendInt64(b, int64(i))
+	}
+	return b
+}
+
+// AppendInt32s appends a slice of int32s as an array
+func AppendInt32s(b []byte, s []int32) []byte {
+	sz := arrayHeaderSize(len(s))
+	for _, i := range s {
+		sz += int64Size(int64(i))
+	}
+	b = AppendArrayHeader(reserve(b, sz), uint32(len(s)))
+	for _, i := range s {
+		b = AppendInt64(b, int64(i))
+	}
+	return b
+}
+
+// AppendUint64s appends a slice of uint64s as an array
+func AppendUint64s(b []byte, s []uint64) []byte {
+	sz := arrayHeaderSize(len(s))
+	for _, u := range s {
+		sz += uint64Size(u)
+	}
+	b = AppendArrayHeader(reserve(b, sz), uint32(len(s)))
+	for _, u := range s {
+		b = AppendUint64(b, u)
+	}
+	return b
+}
+
+// AppendFloat64s appends a slice of float64s as an array
+func AppendFloat64s(b []byte, s []float64) []byte {
+	b = AppendArrayHeader(reserve(b, arrayHeaderSize(len(s))+len(s)*Float64Size), uint32(len(s)))
+	o, n := ensure(b, len(s)*Float64Size)
+	for _, f := range s {
+		prefixu64(o[n:], mfloat64, math.Float64bits(f))
+		n += Float64Size
+	}
+	return o
+}
+
+// AppendFloat32s appends a slice of float32s as an array
+func AppendFloat32s(b []byte, s []float32) []byte {
+	b = AppendArrayHeader(reserve(b, arrayHeaderSize(len(s))+len(s)*Float32Size), uint32(len(s)))
+	o, n := ensure(b, len(s)*Float32Size)
+	for _, f := range s {
+		prefixu32(o[n:], mfloat32, math.Float32bits(f))
+		n += Float32Size
+	}
+	return o
+}
+
+// AppendBools appends a slice of bools as an array
+func AppendBools(b []byte, s []bool) []byte {
+	b = AppendArrayHeader(reserve(b, arrayHeaderSize(len(s))+len(s)), uint32(len(s)))
+	o, n := ensure(b, len(s))
+	for i, t := range s {
+		if t {
+			o[n+i] = mtrue
+		} else {
+			o[n+i] = mfalse
+		}
+	}
+	return o
+}
+
+// AppendStrings appends a slice of strings as an array
+func AppendStrings(b []byte, s []string) []byte {
+	sz := arrayHeaderSize(len(s))
+	for _, str := range s {
+		sz += StringPrefixSize + len(str)
+	}
+	b = AppendArrayHeader(reserve(b, sz), uint32(len(s)))
+	for _, str := range s {
+		b = AppendString(b, str)
+	}
+	return b
+}
@@ -115,6 +115,34 @@ func TestAppendFloat64(t *testing.T) {
 	}
 }
 
+func TestAppendFloat64Compact(t *testing.T) {
+	for _, f := range []float64{0, 1.5, -1e10, 0.1, math.MaxFloat64, math.Inf(1), math.NaN()} {
+		var buf bytes.Buffer
+		en := NewWriter(&buf)
+		en.SetCompactFloats(true)
+		en.WriteFloat64(f)
+		en.Flush()
+		bts := AppendFloat64Compact(nil, f)
+		if !bytes.Equal(buf.Bytes(), bts) {
+			t.Errorf("for float %g, encoder wrote %x; append wrote %x", f, buf.Bytes(), bts)
+		}
+		want := Float64Size
+		if float64(float32(f)) == f {
+			want = Float32Size
+		}
+		if len(bts) != want {
+			t.Errorf("for float %g, wrote %d bytes; want %d", f, len(bts), want)
+		}
+		got, _, err := ReadFloat64Bytes(bts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != f && !(math.IsNaN(f) && math.IsNaN(got)) {
+			t.Errorf("read back %g; want %g", got, f)
+		}
+	}
+}
+
 func BenchmarkAppendFloat64(b *testing.B) {
 	f := float64(3.14159)
 	buf := make([]byte, 0, 9)
@@ -317,3 +345,123 @@ func BenchmarkAppendTime(b *testing.B) {
 		AppendTime(buf[0:0], t)
 	}
 }
+
+func TestAppendMapStrIntfSorted(t *testing.T) {
+	m := map[string]interface{}{"b": 2, "a": "1", "c": nil, "aa": true}
+	want := AppendMapHeader(nil, 4)
+	want = AppendString(want, "a")
+	want = AppendString(want, "1")
+	want = AppendString(want, "aa")
+	want = AppendBool(want, true)
+	want = AppendString(want, "b")
+	want = AppendInt(want, 2)
+	want = AppendString(want, "c")
+	want = AppendNil(want)
+	for i := 0; i < 10; i++ {
+		b, err := AppendMapStrIntfSorted(nil, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, want) {
+			t.Fatalf("got %x; want %x", b, want)
+		}
+	}
+}
+
+func TestAppendSlices(t *testing.T) {
+	ints := []int64{0, 1, -1, -32, -33, 127, 128, 255, 256, -128, -129,
+		math.MaxInt16, math.MinInt16, math.MaxInt16 + 1, math.MaxInt32,
+		math.MinInt32, math.MaxInt32 + 1, math.MaxInt64, math.MinInt64}
+	var want []byte
+	check := func(name string, got []byte) {
+		t.Helper()
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got %x, want %x", name, got, want)
+		}
+	}
+
+	want = AppendArrayHeader(nil, uint32(len(ints)))
+	is := make([]int, len(ints))
+	for i, v := range ints {
+		want = AppendInt64(want, v)
+		is[i] = int(v)
+	}
+	check("AppendInt64s", AppendInt64s(nil, ints))
+	check("AppendInts", AppendInts(make([]byte, 0, 3), is))
+
+	i32s := []int32{1, -100, math.MaxInt32, math.MinInt32, 300}
+	want = AppendArrayHeader(nil, uint32(len(i32s)))
+	for _, v := range i32s {
+		want = AppendInt32(want, v)
+	}
+	check("AppendInt32s", AppendInt32s(nil, i32s))
+
+	uints := []uint64{0, 127, 128, 255, 256, math.MaxUint16, math.MaxUint16 + 1,
+		math.MaxUint32, math.MaxUint32 + 1, math.MaxUint64}
+	want = AppendArrayHeader(nil, uint32(len(uints)))
+	for _, v := range uints {
+		want = AppendUint64(want, v)
+	}
+	check("AppendUint64s", AppendUint64s(nil, uints))
+
+	floats := make([]float64, 20)
+	f32s := make([]float32, 20)
+	for i := range floats {
+		floats[i] = float64(i) * 1.5
+		f32s[i] = float32(i) * -0.25
+	}
+	want = AppendArrayHeader(nil, uint32(len(floats)))
+	for _, f := range floats {
+		want = AppendFloat64(want, f)
+	}
+	check("AppendFloat64s", AppendFloat64s([]byte{}, floats))
+	want = AppendArrayHeader(nil, uint32(len(f32s)))
+	for _, f := range f32s {
+		want = AppendFloat32(want, f)
+	}
+	check("AppendFloat32s", AppendFloat32s(nil, f32s))
+
+	bools := []bool{true, false, false, true}
+	want = AppendArrayHeader(AppendNil(nil), uint32(len(bools)))
+	for _, v := range bools {
+		want = AppendBool(want, v)
+	}
+	check("AppendBools", AppendBools(AppendNil(nil), bools))
+
+	strs := []string{"", "a", string(make([]byte, 40)), string(make([]byte, 300))}
+	want = AppendArrayHeader(nil, uint32(len(strs)))
+	for _, s := range strs {
+		want = AppendString(want, s)
+	}
+	check("AppendStrings", AppendStrings(nil, strs))
+
+	want = AppendArrayHeader(nil, 0)
+	check("empty", AppendFloat64s(nil, nil))
+}
+
+func BenchmarkAppendInt64s(b *testing.B) {
+	s := make([]int64, 1000)
+	for i := range s {
+		s[i] = int64(i * i)
+	}
+	buf := make([]byte, 0, 8*len(s))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = AppendInt64s(buf[:0], s)
+	}
+}
+
+func BenchmarkAppendFloat64s(b *testing.B) {
+	s := make([]float64, 1000)
+	for i := range s {
+		s[i] = float64(i) / 3
+	}
+	buf := make([]byte, 0, ArrayHeaderSize+Float64Size*len(s))
+	b.SetBytes(int64(cap(buf)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = AppendFloat64s(buf[:0], s)
+	}
+}
@@ -2,8 +2,12 @@ package msgp
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"math"
 	"math/rand"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -171,6 +175,29 @@ func BenchmarkWriteArrayHeader(b *testing.B) {
 	}
 }
 
+func TestWriteBytesFrom(t *testing.T) {
+	data := RandBytes(3000)
+	var buf bytes.Buffer
+	wr := NewWriterSize(&buf, 100)
+	if err := wr.WriteBytesFrom(bytes.NewReader(data), uint32(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	// the rest of the reader is left unread
+	if err := wr.WriteStringFrom(strings.NewReader("hello, world"), 5); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	want := AppendString(AppendBytes(nil, data), "hello")
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Error("output doesn't match")
+	}
+
+	err := wr.WriteBytesFrom(bytes.NewReader(data[:10]), 11)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF; got %v", err)
+	}
+}
+
 func TestWriteNil(t *testing.T) {
 	var buf bytes.Buffer
 	wr := NewWriter(&buf)
@@ -403,3 +430,410 @@ func BenchmarkWriteTime(b *testing.B) {
 		wr.WriteTime(t)
 	}
 }
+
+func TestWriteMapSorted(t *testing.T) {
+	ss := map[string]string{"c": "3", "a": "1", "b": "2", "d": "4"}
+	si := map[string]interface{}{"y": ss, "x": []interface{}{ss}, "z": 1}
+
+	var want bytes.Buffer
+	w := NewWriter(&want)
+	w.WriteMapHeader(3)
+	w.WriteString("x")
+	w.WriteArrayHeader(1)
+	w.WriteMapHeader(4)
+	for _, kv := range []string{"a", "1", "b", "2", "c", "3", "d", "4"} {
+		w.WriteString(kv)
+	}
+	w.WriteString("y")
+	w.WriteMapHeader(4)
+	for _, kv := range []string{"a", "1", "b", "2", "c", "3", "d", "4"} {
+		w.WriteString(kv)
+	}
+	w.WriteString("z")
+	w.WriteInt(1)
+	w.Flush()
+
+	// map iteration order is random,
+	// so try it a few times
+	for i := 0; i < 10; i++ {
+		var buf bytes.Buffer
+		w := NewWriterWithOptions(&buf, WriterOptions{SortMaps: true})
+		if err := w.WriteIntf(si); err != nil {
+			t.Fatal(err)
+		}
+		w.Flush()
+		if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+			t.Fatalf("got %x; want %x", buf.Bytes(), want.Bytes())
+		}
+
+		buf.Reset()
+		w = NewWriter(&buf)
+		w.WriteMapStrStrSorted(ss)
+		w.Flush()
+		if !bytes.Equal(buf.Bytes(), AppendMapStrStrSorted(nil, ss)) {
+			t.Fatalf("WriteMapStrStrSorted and AppendMapStrStrSorted disagree")
+		}
+	}
+}
+
+func TestWriterPool(t *testing.T) {
+	p := NewWriterPool(64)
+	var a, b bytes.Buffer
+	w := p.Get(&a)
+	if w.BufferSize() != 64 {
+		t.Fatalf("buffer size is %d", w.BufferSize())
+	}
+	w.SortMaps(true)
+	w.WriteString("a")
+	w.Flush()
+	p.Put(w)
+
+	w = p.Get(&b)
+	if w.sortMaps {
+		t.Error("options survived Put")
+	}
+	w.WriteString("b")
+	w.Flush()
+	p.Put(w)
+
+	if a.String() != "\xa1a" || b.String() != "\xa1b" {
+		t.Errorf("got %q and %q", a.String(), b.String())
+	}
+
+	w = GetWriter(&a)
+	w.WriteNil()
+	w.Reset(&b)
+	w.WriteBool(true)
+	w.Flush()
+	PutWriter(w)
+	if b.String() != "\xa1b\xc3" {
+		t.Errorf("Reset didn't discard the buffered data: %q", b.String())
+	}
+}
+
+func TestWriterReadFrom(t *testing.T) {
+	data := RandBytes(1000)
+	var buf bytes.Buffer
+	wr := NewWriterSize(&buf, 100)
+	wr.WriteNil()
+	n, err := wr.ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("read %d bytes; want %d", n, len(data))
+	}
+	wr.Flush()
+	if !bytes.Equal(buf.Bytes(), append([]byte{mnil}, data...)) {
+		t.Error("output doesn't match")
+	}
+}
+
+func TestEncodeBuffer(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("prefix")
+	if err := Encode(&buf, &concreteStr{s: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	// streamInt has no MarshalMsg
+	if err := Encode(&buf, &streamInt{v: 3}); err != nil {
+		t.Fatal(err)
+	}
+	want := AppendInt(AppendString([]byte("prefix"), "hello"), 3)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got %x, want %x", buf.Bytes(), want)
+	}
+}
+
+func BenchmarkEncodeBuffer(b *testing.B) {
+	var buf bytes.Buffer
+	v := &concreteStr{s: strings.Repeat("x", 200)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		Encode(&buf, v)
+	}
+}
+
+// writeRecorder keeps copies of the slices passed
+// to Write, and the addresses of their first bytes
+type writeRecorder struct {
+	writes [][]byte
+	addrs  []*byte
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, append([]byte(nil), p...))
+	w.addrs = append(w.addrs, &p[0])
+	return len(p), nil
+}
+
+func TestWriteLarge(t *testing.T) {
+	data := RandBytes(100)
+	rec := &writeRecorder{}
+	wr := NewWriterSize(rec, 64)
+	if err := wr.WriteBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := wr.WriteString(string(data)); err != nil {
+		t.Fatal(err)
+	}
+	wr.WriteNil()
+	wr.Flush()
+	// the header and the payload of each object are
+	// written separately, and data isn't copied
+	if len(rec.writes) != 5 {
+		t.Fatalf("got %d writes; want 5", len(rec.writes))
+	}
+	if rec.addrs[1] != &data[0] {
+		t.Error("WriteBytes copied the payload")
+	}
+	var out []byte
+	for _, p := range rec.writes {
+		out = append(out, p...)
+	}
+	want := AppendNil(AppendString(AppendBytes(nil, data), string(data)))
+	if !bytes.Equal(out, want) {
+		t.Errorf("got %x, want %x", out, want)
+	}
+}
+
+// limitedWriter fails once
+// it has been given max bytes
+type limitedWriter struct {
+	buf bytes.Buffer
+	max int
+}
+
+var errLimited = errors.New("limitedWriter: limit reached")
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.buf.Len()+len(p) > l.max {
+		n, _ := l.buf.Write(p[:l.max-l.buf.Len()])
+		return n, errLimited
+	}
+	return l.buf.Write(p)
+}
+
+func TestWriterStickyError(t *testing.T) {
+	lw := &limitedWriter{max: 50}
+	wr := NewWriterSize(lw, 32)
+	for i := 0; i < 20; i++ {
+		wr.WriteString("0123456789")
+	}
+	if wr.Err() != errLimited {
+		t.Fatalf("got error %v, want %v", wr.Err(), errLimited)
+	}
+	n := lw.buf.Len()
+	checks := []struct {
+		name string
+		err  error
+	}{
+		{"WriteNil", wr.WriteNil()},
+		{"WriteString", wr.WriteString(string(make([]byte, 100)))},
+		{"BeginMap", wr.BeginMap()},
+		{"EndMap", wr.EndMap()},
+		{"Flush", wr.Flush()},
+	}
+	if _, err := wr.Write(make([]byte, 100)); err != errLimited {
+		t.Errorf("Write: got error %v", err)
+	}
+	for _, c := range checks {
+		if c.err != errLimited {
+			t.Errorf("%s: got error %v", c.name, c.err)
+		}
+	}
+	if lw.buf.Len() != n {
+		t.Errorf("%d bytes were written after the error", lw.buf.Len()-n)
+	}
+
+	wr.Reset(&bytes.Buffer{})
+	if wr.Err() != nil || wr.WriteNil() != nil || wr.Flush() != nil {
+		t.Error("expected Reset to clear the error")
+	}
+}
+
+// ptrCodec dereferences its receiver
+type ptrCodec struct{ v int }
+
+func (p *ptrCodec) MarshalMsg(b []byte) ([]byte, error) { return AppendInt(b, p.v), nil }
+func (p *ptrCodec) EncodeMsg(w *Writer) error          { return w.WriteInt(p.v) }
+
+func TestWriteIntfNilPointer(t *testing.T) {
+	var nilCodec *ptrCodec
+	var nilExt *RawExtension
+	var nilInt *int
+	vals := []interface{}{
+		nilCodec,
+		nilExt,
+		nilInt,
+		[]interface{}{nilCodec},
+		[]*ptrCodec{nil},
+	}
+	for _, v := range vals {
+		var buf bytes.Buffer
+		wr := NewWriter(&buf)
+		if err := wr.WriteIntf(v); err != nil {
+			t.Errorf("%T: %s", v, err)
+			continue
+		}
+		wr.Flush()
+		b, err := AppendIntf(nil, v)
+		if err != nil {
+			t.Errorf("AppendIntf(%T): %s", v, err)
+			continue
+		}
+		if !bytes.Equal(b, buf.Bytes()) {
+			t.Errorf("%T: WriteIntf wrote %x; AppendIntf wrote %x", v, buf.Bytes(), b)
+		}
+		if !bytes.Contains(b, []byte{mnil}) {
+			t.Errorf("%T: no nil in %x", v, b)
+		}
+
+		wr = NewWriterWithOptions(&buf, WriterOptions{NilPointerError: true})
+		err = wr.WriteIntf(v)
+		if _, ok := Cause(err).(NilPointerError); !ok {
+			t.Errorf("%T: got %v; want a NilPointerError", v, err)
+		}
+	}
+
+	// non-nil pointers still use their methods
+	b, err := AppendIntf(nil, &ptrCodec{v: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, _, err := ReadIntBytes(b); err != nil || i != 3 {
+		t.Errorf("got %d, %v", i, err)
+	}
+}
+
+// textKey is a map key that marshals as text
+type textKey struct{ a, b int }
+
+func (k textKey) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(k.a) + "-" + strconv.Itoa(k.b)), nil
+}
+
+func TestWriteIntfMapKeys(t *testing.T) {
+	type myInt int16
+	var buf bytes.Buffer
+	wr := NewWriterWithOptions(&buf, WriterOptions{SortMaps: true})
+	m := map[myInt]string{10: "ten", -1: "minus one", 2: "two"}
+	if err := wr.WriteIntf(m); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	want := AppendMapHeader(nil, 3)
+	want = AppendInt(want, -1)
+	want = AppendString(want, "minus one")
+	want = AppendInt(want, 2)
+	want = AppendString(want, "two")
+	want = AppendInt(want, 10)
+	want = AppendString(want, "ten")
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got %x; want %x", buf.Bytes(), want)
+	}
+	single := map[myInt]string{7: "seven"}
+	b, err := AppendIntf(nil, single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, _, err := ReadInt64Bytes(b[1:]); err != nil || k != 7 {
+		t.Errorf("AppendIntf key: got %d, %v", k, err)
+	}
+
+	// stringified keys, sorted as strings
+	buf.Reset()
+	wr = NewWriterWithOptions(&buf, WriterOptions{SortMaps: true, StringKeys: true})
+	in := map[interface{}]interface{}{
+		10:            map[float64]bool{1.5: true},
+		true:          "yes",
+		textKey{1, 2}: nil,
+		uint8(2):      "two",
+	}
+	if err := wr.WriteIntf(in); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	var js bytes.Buffer
+	if _, err := UnmarshalAsJSON(&js, buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if got := js.String(); got != `{"1-2":null,"10":{"1.5":true},"2":"two","true":"yes"}` {
+		t.Errorf("got %s", got)
+	}
+	b, err = AppendIntfStringKeys(nil, map[int]int{1: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, _, err := ReadStringBytes(b[1:]); err != nil || k != "1" {
+		t.Errorf("AppendIntfStringKeys key: got %q, %v", k, err)
+	}
+
+	// keys that can't be encoded
+	if err := wr.WriteIntf(map[[2]int]int{{1, 2}: 3}); err == nil {
+		t.Error("expected an error for an array key")
+	}
+	if _, err := AppendIntf(nil, map[textKey]int{{1, 2}: 3}); err == nil {
+		t.Error("expected an error for a struct key")
+	}
+}
+
+func TestWriteIntfStruct(t *testing.T) {
+	type inner struct {
+		X int `msg:"x"`
+	}
+	type embedded struct {
+		E string
+	}
+	type outer struct {
+		embedded `msg:",flatten"`
+		Name     string       `msg:"name"`
+		Skip     int          `msg:"-"`
+		Empty    []int        `msg:"empty,omitempty"`
+		In       inner        `msg:"in"`
+		Ptr      *inner       `msgpack:"ptr"`
+		Codec    ptrCodec     `msg:"codec"`
+		Keys     map[int]bool `msg:"keys"`
+		Any      interface{}  `msg:"any"`
+		private  int
+	}
+	v := outer{
+		embedded: embedded{E: "e"},
+		Name:     "n",
+		Skip:     1,
+		In:       inner{X: 2},
+		Codec:    ptrCodec{v: 3},
+		Keys:     map[int]bool{4: true},
+		Any:      inner{X: 5},
+		private:  6,
+	}
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	if err := wr.WriteIntf(v); err != nil {
+		t.Fatal(err)
+	}
+	if err := wr.WriteIntf(&v); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	b, err := AppendIntf(nil, []interface{}{v, &v})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b[1:], buf.Bytes()) {
+		t.Errorf("WriteIntf wrote %x; AppendIntf wrote %x", buf.Bytes(), b[1:])
+	}
+
+	if b, err = AppendIntfStringKeys(nil, v); err != nil {
+		t.Fatal(err)
+	}
+	var js bytes.Buffer
+	if _, err := UnmarshalAsJSON(&js, b); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"E":"e","name":"n","in":{"x":2},"ptr":null,"codec":3,"keys":{"4":true},"any":{"x":5}}`
+	if got := js.String(); got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+}
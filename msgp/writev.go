@@ -0,0 +1,41 @@
+// +build !purego,!appengine,!tinygo,!wasm
+
+package msgp
+
+import "net"
+
+// writeDirect writes the buffered data followed
+// by p, which is at least as large as the buffer,
+// to the underlying writer without copying p into
+// the buffer. The two are written as net.Buffers,
+// so a *net.TCPConn or *net.UnixConn gets them in
+// a single vectored write (writev), and any other
+// writer in separate calls to Write.
+func (mw *Writer) writeDirect(p []byte) (int, error) {
+	if mw.err != nil {
+		return 0, mw.err
+	}
+	buffered := int64(mw.wloc)
+	bufs := net.Buffers{p}
+	if buffered > 0 {
+		bufs = net.Buffers{mw.buf[:mw.wloc], p}
+	}
+	n, err := bufs.WriteTo(mw.w)
+	mw.wrote(int(n))
+	if err != nil {
+		mw.fail(err)
+		if n -= buffered; n < 0 {
+			n = 0
+		}
+		return int(n), err
+	}
+	mw.wloc = 0
+	return len(p), nil
+}
+
+// writeDirectString is writeDirect for a string,
+// whose bytes are only read
+func (mw *Writer) writeDirectString(s string) error {
+	_, err := mw.writeDirect(UnsafeBytes(s))
+	return err
+}
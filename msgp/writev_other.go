@@ -0,0 +1,34 @@
+// +build purego appengine tinygo wasm
+
+package msgp
+
+import "io"
+
+// writeDirect flushes the buffered data and then
+// writes p, which is at least as large as the
+// buffer, to the underlying writer without
+// copying it into the buffer.
+func (mw *Writer) writeDirect(p []byte) (int, error) {
+	if err := mw.flush(); err != nil {
+		return 0, err
+	}
+	n, err := mw.w.Write(p)
+	mw.wrote(n)
+	if err != nil {
+		return n, mw.fail(err)
+	}
+	return len(p), nil
+}
+
+// writeDirectString is writeDirect for a string
+func (mw *Writer) writeDirectString(s string) error {
+	if err := mw.flush(); err != nil {
+		return err
+	}
+	n, err := io.WriteString(mw.w, s)
+	mw.wrote(n)
+	if err != nil {
+		return mw.fail(err)
+	}
+	return nil
+}
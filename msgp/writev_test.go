@@ -0,0 +1,79 @@
+// +build !purego,!appengine,!tinygo,!wasm
+
+package msgp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+// countConn counts calls to Write; the
+// vectored writes of the embedded
+// *net.TCPConn bypass it
+type countConn struct {
+	*net.TCPConn
+	writes int
+}
+
+func (c *countConn) Write(p []byte) (int, error) {
+	c.writes++
+	return c.TCPConn.Write(p)
+}
+
+func TestWriteLargeVectored(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	got := make(chan []byte, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			got <- nil
+			return
+		}
+		b, _ := ioutil.ReadAll(c)
+		c.Close()
+		got <- b
+	}()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &countConn{TCPConn: c.(*net.TCPConn)}
+
+	data := RandBytes(1000)
+	wr := NewWriterSize(conn, 64)
+	wr.WriteNil()
+	if err := wr.WriteBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	// the buffered nil and header go out
+	// with the payload in one vectored write
+	if conn.writes != 0 {
+		t.Errorf("got %d calls to Write; want a vectored write", conn.writes)
+	}
+	wr.Flush()
+	conn.Close()
+
+	want := AppendBytes(AppendNil(nil), data)
+	if b := <-got; !bytes.Equal(b, want) {
+		t.Errorf("got %d bytes, want %d", len(b), len(want))
+	}
+}
+
+func TestWriteLargeVectoredError(t *testing.T) {
+	lw := &limitedWriter{max: 10}
+	wr := NewWriterSize(lw, 64)
+	wr.WriteNil()
+	n, err := wr.Write(make([]byte, 100))
+	if err != errLimited || n != 9 {
+		t.Errorf("got %d, %v; want 9, %v", n, err, errLimited)
+	}
+	if _, err = wr.Write([]byte{1}); err != errLimited {
+		t.Errorf("after the error: got %v", err)
+	}
+}
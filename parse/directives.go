@@ -3,6 +3,7 @@ package parse
 import (
 	"fmt"
 	"go/ast"
+	"go/parser"
 	"strings"
 
 	"github.com/tinylib/msgp/gen"
@@ -24,6 +25,7 @@ var directives = map[string]directive{
 	"shim":   applyShim,
 	"ignore": ignore,
 	"tuple":  astuple,
+	"enum":   enum,
 }
 
 var passDirectives = map[string]passDirective{
@@ -60,7 +62,22 @@ func applyShim(text []string, f *FileSet) error {
 	}
 
 	name := text[1]
-	be := gen.Ident(strings.TrimPrefix(strings.TrimSpace(text[2]), "as:")) // parse as::{base}
+	target := strings.TrimPrefix(strings.TrimSpace(text[2]), "as:") // parse as::{base}
+	be := gen.Ident(target)
+	if be.Value == gen.IDENT {
+		// non-primitive representations (named types,
+		// slices, maps, etc.) are parsed like any other
+		// type expression and generated through the shim
+		expr, err := parser.ParseExpr(target)
+		if err != nil {
+			return fmt.Errorf("invalid shim type %q: %s", target, err)
+		}
+		el := f.parseExpr(expr)
+		if el == nil {
+			return fmt.Errorf("unsupported shim type %q", target)
+		}
+		be = &gen.BaseElem{Value: gen.IDENT, ShimElem: el}
+	}
 	if name[0] == '*' {
 		name = name[1:]
 		be.Needsref(true)
@@ -89,12 +106,44 @@ func applyShim(text []string, f *FileSet) error {
 		}
 	}
 
-	infof("%s -> %s\n", name, be.Value.String())
+	infof("%s -> %s\n", name, target)
 	f.findShim(name, be)
 
 	return nil
 }
 
+//msgp:enum {Type} [{ValueA} {ValueB}...]
+func enum(text []string, f *FileSet) error {
+	if len(text) < 2 {
+		return fmt.Errorf("enum directive needs a type name")
+	}
+	name := strings.TrimSpace(text[1])
+	el, ok := f.Identities[name]
+	if !ok {
+		return fmt.Errorf("enum: unknown type %s", name)
+	}
+	be, ok := el.(*gen.BaseElem)
+	if !ok || be.Value == gen.IDENT {
+		return fmt.Errorf("enum: %s is not a primitive type", name)
+	}
+	var values []string
+	for _, item := range text[2:] {
+		if v := strings.TrimSpace(item); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		// use the constants declared with the type
+		values = f.Consts[name]
+		if len(values) == 0 {
+			return fmt.Errorf("enum: no values listed or declared for %s", name)
+		}
+	}
+	be.Enum = values
+	infof("%s: %s\n", name, strings.Join(values, ", "))
+	return nil
+}
+
 //msgp:ignore {TypeA} {TypeB}...
 func ignore(text []string, f *FileSet) error {
 	if len(text) < 2 {
@@ -22,6 +22,8 @@ type FileSet struct {
 	Identities map[string]gen.Elem // processed from specs
 	Directives []string            // raw preprocessor directives
 	Imports    []*ast.ImportSpec   // imports
+	Consts     map[string][]string // typed constants in file, by type name
+	IsEmpty    map[string]bool     // types with a MsgIsEmpty() bool method
 }
 
 // File parses a file at the relative path
@@ -36,6 +38,8 @@ func File(name string, unexported bool) (*FileSet, error) {
 	fs := &FileSet{
 		Specs:      make(map[string]ast.Expr),
 		Identities: make(map[string]gen.Elem),
+		Consts:     make(map[string][]string),
+		IsEmpty:    make(map[string]bool),
 	}
 
 	fset := token.NewFileSet()
@@ -86,6 +90,8 @@ func File(name string, unexported bool) (*FileSet, error) {
 	fs.process()
 	fs.applyDirectives()
 	fs.propInline()
+	fs.markIsEmpty()
+	fs.markLocal()
 
 	return fs, nil
 }
@@ -208,6 +214,8 @@ func strToMethod(s string) gen.Method {
 		return gen.Marshal
 	case "unmarshal":
 		return gen.Unmarshal
+	case "clone":
+		return gen.Clone
 	default:
 		return 0
 	}
@@ -276,9 +284,25 @@ func (fs *FileSet) getTypeSpecs(f *ast.File) {
 	// check all declarations...
 	for i := range f.Decls {
 
+		// note MsgIsEmpty methods for omitempty; only
+		// methods declared in the files being processed
+		// are seen, so a type whose method lives elsewhere
+		// in the package is compared to its zero value
+		if fd, ok := f.Decls[i].(*ast.FuncDecl); ok {
+			if name, ok := isEmptyMethod(fd); ok {
+				fs.IsEmpty[name] = true
+			}
+			continue
+		}
+
 		// for GenDecls...
 		if g, ok := f.Decls[i].(*ast.GenDecl); ok {
 
+			if g.Tok == token.CONST {
+				fs.getConsts(g)
+				continue
+			}
+
 			// and check the specs...
 			for _, s := range g.Specs {
 
@@ -302,6 +326,138 @@ func (fs *FileSet) getTypeSpecs(f *ast.File) {
 	}
 }
 
+// getConsts records the names of the typed
+// constants in a const declaration, e.g.
+//
+//	const (
+//		Red Color = iota
+//		Green
+//		Blue = Color(5)
+//	)
+//
+// records Red, Green, and Blue as values of Color.
+func (fs *FileSet) getConsts(g *ast.GenDecl) {
+	var typ string
+	for _, s := range g.Specs {
+		vs, ok := s.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		switch {
+		case vs.Type != nil:
+			typ = stringify(vs.Type)
+		case len(vs.Values) == 0:
+			// implicit repetition of the
+			// previous type and expression
+		case len(vs.Values) == 1:
+			typ = ""
+			if c, ok := vs.Values[0].(*ast.CallExpr); ok && len(c.Args) == 1 {
+				typ = stringify(c.Fun)
+			}
+		default:
+			typ = ""
+		}
+		if typ == "" || typ == "<BAD>" {
+			continue
+		}
+		for _, nm := range vs.Names {
+			if nm.Name != "_" {
+				fs.Consts[typ] = append(fs.Consts[typ], nm.Name)
+			}
+		}
+	}
+}
+
+// isEmptyMethod returns the receiver type name
+// if fd declares MsgIsEmpty() bool as a method
+func isEmptyMethod(fd *ast.FuncDecl) (string, bool) {
+	if fd.Recv == nil || len(fd.Recv.List) != 1 || fd.Name.Name != "MsgIsEmpty" {
+		return "", false
+	}
+	if fd.Type.Params.NumFields() != 0 || fd.Type.Results.NumFields() != 1 {
+		return "", false
+	}
+	if r, ok := fd.Type.Results.List[0].Type.(*ast.Ident); !ok || r.Name != "bool" {
+		return "", false
+	}
+	recv := fd.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	id, ok := recv.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	return id.Name, true
+}
+
+// markIsEmpty flags every struct field whose
+// type has a MsgIsEmpty method, so that it
+// decides for itself whether it is empty
+func (fs *FileSet) markIsEmpty() {
+	if len(fs.IsEmpty) == 0 {
+		return
+	}
+	for _, el := range fs.Identities {
+		fs.nextIsEmpty(el)
+	}
+}
+
+func (fs *FileSet) nextIsEmpty(el gen.Elem) {
+	switch el := el.(type) {
+	case *gen.Struct:
+		for i := range el.Fields {
+			sf := &el.Fields[i]
+			name := sf.FieldElem.TypeName()
+			if p, ok := sf.FieldElem.(*gen.Ptr); ok {
+				name = p.Value.TypeName()
+			}
+			if fs.IsEmpty[name] {
+				sf.HasIsEmpty = true
+			}
+			fs.nextIsEmpty(sf.FieldElem)
+		}
+	case *gen.Array:
+		fs.nextIsEmpty(el.Els)
+	case *gen.Slice:
+		fs.nextIsEmpty(el.Els)
+	case *gen.Map:
+		fs.nextIsEmpty(el.Value)
+	case *gen.Ptr:
+		fs.nextIsEmpty(el.Value)
+	}
+}
+
+// markLocal flags every reference to a type
+// whose methods are being generated, as opposed
+// to one from another file or package
+func (fs *FileSet) markLocal() {
+	for _, el := range fs.Identities {
+		fs.nextLocal(el)
+	}
+}
+
+func (fs *FileSet) nextLocal(el gen.Elem) {
+	switch el := el.(type) {
+	case *gen.BaseElem:
+		if el.Value == gen.IDENT {
+			_, el.Local = fs.Identities[el.TypeName()]
+		}
+	case *gen.Struct:
+		for i := range el.Fields {
+			fs.nextLocal(el.Fields[i].FieldElem)
+		}
+	case *gen.Array:
+		fs.nextLocal(el.Els)
+	case *gen.Slice:
+		fs.nextLocal(el.Els)
+	case *gen.Map:
+		fs.nextLocal(el.Value)
+	case *gen.Ptr:
+		fs.nextLocal(el.Value)
+	}
+}
+
 func fieldName(f *ast.Field) string {
 	switch len(f.Names) {
 	case 0:
@@ -410,9 +566,97 @@ func (fs *FileSet) getField(f *ast.Field) []gen.StructField {
 			return nil
 		}
 	}
+
+	// apply time format and nil element options
+	for _, opt := range sf[0].FieldTagParts[1:] {
+		if tf, ok := timeFormats[opt]; ok {
+			if !setTimeFormat(ex, tf) {
+				warnf("%s: tag option %q requires a time.Time field\n", sf[0].FieldName, opt)
+			}
+		}
+		if nm, ok := nilModes[opt]; ok {
+			if !setNilMode(ex, nm) {
+				warnf("%s: tag option %q requires a slice or map of pointers\n", sf[0].FieldName, opt)
+			}
+		}
+	}
 	return sf
 }
 
+// map of tag options for nil
+// slice and map elements
+var nilModes = map[string]gen.NilMode{
+	"omitnil": gen.NilOmit,
+	"zeronil": gen.NilAsZero,
+}
+
+// setNilMode sets the nil handling
+// of the pointer elements of the slice
+// or map el, and returns whether el
+// (or what it points to) is one
+func setNilMode(el gen.Elem, nm gen.NilMode) bool {
+	var inner gen.Elem
+	switch el := el.(type) {
+	case *gen.Ptr:
+		return setNilMode(el.Value, nm)
+	case *gen.Slice:
+		inner = el.Els
+	case *gen.Map:
+		inner = el.Value
+	default:
+		return false
+	}
+	pt, ok := inner.(*gen.Ptr)
+	if ok {
+		pt.NilMode = nm
+	}
+	return ok
+}
+
+// timeFormat is an alternative encoding for time.Time;
+// it is implemented as a shim using the runtime helpers
+type timeFormat struct {
+	base     gen.Primitive
+	to, from string
+	mode     gen.ShimMode
+}
+
+// map of time.Time tag options to their formats
+var timeFormats = map[string]timeFormat{
+	"unix":      {gen.Int64, "msgp.TimeToUnix", "msgp.UnixToTime", gen.Cast},
+	"unixmilli": {gen.Int64, "msgp.TimeToUnixMilli", "msgp.UnixMilliToTime", gen.Cast},
+	"unixmicro": {gen.Int64, "msgp.TimeToUnixMicro", "msgp.UnixMicroToTime", gen.Cast},
+	"unixnano":  {gen.Int64, "msgp.TimeToUnixNano", "msgp.UnixNanoToTime", gen.Cast},
+	"rfc3339":   {gen.String, "msgp.TimeToRFC3339", "msgp.RFC3339ToTime", gen.Convert},
+}
+
+// setTimeFormat applies tf to every time.Time
+// in el, and returns whether there were any
+func setTimeFormat(el gen.Elem, tf timeFormat) bool {
+	switch el := el.(type) {
+	case *gen.BaseElem:
+		if el.Value != gen.Time {
+			return false
+		}
+		el.Value = tf.base
+		el.ShimToBase = tf.to
+		el.ShimFromBase = tf.from
+		el.ShimMode = tf.mode
+		el.Alias("time.Time")
+		return true
+	case *gen.Ptr:
+		return setTimeFormat(el.Value, tf)
+	case *gen.Slice:
+		return setTimeFormat(el.Els, tf)
+	case *gen.Array:
+		return setTimeFormat(el.Els, tf)
+	case *gen.Map:
+		return setTimeFormat(el.Value, tf)
+	default:
+		return false
+	}
+}
+
 func (fs *FileSet) getFieldsFromEmbeddedStruct(f ast.Expr) []gen.StructField {
 	switch f := f.(type) {
 	case *ast.Ident: